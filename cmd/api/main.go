@@ -10,8 +10,12 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/ryanschneiderman/video-api/internal/app"
+	"github.com/ryanschneiderman/video-api/internal/auth"
 	"github.com/ryanschneiderman/video-api/internal/handlers"
+	"github.com/ryanschneiderman/video-api/internal/idempotency"
 	"github.com/ryanschneiderman/video-api/internal/metrics"
+	"github.com/ryanschneiderman/video-api/internal/ratelimit"
+	"github.com/ryanschneiderman/video-api/internal/worker"
 )
 
 func main() {
@@ -22,14 +26,13 @@ func main() {
 	apiMetrics := metrics.NewAPIMetrics()
 	apiMetrics.Register(reg)
 
-
 	ctx := context.TODO()
 	a, err := app.InitializeApp(ctx)
 	if err != nil {
 		log.Fatal("Failed to initialize application:", err)
 	}
 
-	router := setupRouter(a, reg, apiMetrics)
+	router := setupRouter(a, a.DB, reg, apiMetrics)
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -41,13 +44,65 @@ func main() {
 	}
 }
 
-func setupRouter(a *app.App, reg *prometheus.Registry, apiMetrics *metrics.APIMetrics) *gin.Engine {
+func setupRouter(a *app.App, keys auth.KeyStore, reg *prometheus.Registry, apiMetrics *metrics.APIMetrics) *gin.Engine {
 	router := gin.Default()
 	router.Use(metrics.PrometheusMiddleware(apiMetrics))
 
+	var tokens *auth.Verifier
+	if a.OIDC.Enabled() {
+		tokens = auth.NewVerifier(a.OIDC)
+	}
+	// Every route but /metrics needs credentials and only sees the caller's
+	// tenant. Routes are grouped by the scope they need, and each group has
+	// its own rate limit per caller.
 	videoHandler := handlers.NewVideoHandler(a)
-	router.POST("/videos", videoHandler.UploadVideo)
-	router.GET("/videos/:id", videoHandler.GetVideo)
+	videoHandler.Stages = worker.StageNames()
+	authed := router.Group("", auth.Middleware(keys, tokens))
+	reads := authed.Group("",
+		auth.RequireScope(auth.ScopeRead),
+		ratelimit.Middleware(ratelimit.NewLimiter(a.ReadRateLimit), auth.CallerID))
+	// POST requests that change something can be retried safely with an
+	// Idempotency-Key.
+	idempotent := idempotency.Middleware(a.DB, a.Idempotency)
+	writes := authed.Group("",
+		auth.RequireScope(auth.ScopeWrite),
+		ratelimit.Middleware(ratelimit.NewLimiter(a.WriteRateLimit), auth.CallerID),
+		idempotent)
+	admins := authed.Group("",
+		auth.RequireScope(auth.ScopeAdmin),
+		ratelimit.Middleware(ratelimit.NewLimiter(a.WriteRateLimit), auth.CallerID),
+		idempotent)
+
+	writes.POST("/videos", videoHandler.UploadVideo)
+	writes.POST("/videos/import", videoHandler.ImportVideo)
+	writes.POST("/videos/batch", videoHandler.CreateVideos)
+	writes.POST("/videos/batch/complete", videoHandler.CompleteVideos)
+	writes.POST("/videos/batch/tags", videoHandler.UpdateVideoTags)
+	writes.POST("/videos/batch/delete", videoHandler.DeleteVideos)
+	writes.POST("/videos/:id/reprocess", videoHandler.ReprocessVideo)
+	writes.POST("/videos/:id/versions/:version/promote", videoHandler.PromoteVideoVersion)
+	reads.POST("/videos/batch/get", videoHandler.GetVideos)
+	reads.GET("/videos/search", videoHandler.SearchVideos)
+	reads.POST("/videos/search/semantic", videoHandler.SemanticSearch)
+	reads.GET("/videos/:id", videoHandler.GetVideo)
+	reads.GET("/videos/:id/analysis", videoHandler.GetVideoAnalysis)
+	reads.GET("/videos/:id/similar", videoHandler.GetSimilarVideos)
+	reads.GET("/videos/:id/versions", videoHandler.GetVideoVersions)
+	reads.GET("/videos/:id/stream/*path", videoHandler.StreamVideo)
+	reads.GET("/usage", videoHandler.GetUsage)
+
+	admins.POST("/webhooks", videoHandler.CreateWebhook)
+	admins.GET("/webhooks", videoHandler.ListWebhooks)
+	admins.DELETE("/webhooks/:id", videoHandler.DeleteWebhook)
+	admins.GET("/webhooks/:id/deliveries", videoHandler.ListWebhookDeliveries)
+	admins.POST("/webhooks/:id/deliveries/:deliveryId/replay", videoHandler.ReplayWebhookDelivery)
+
+	// In token mode, video URLs point at the playback proxy, which takes the
+	// token in the path in place of credentials.
+	if videoHandler.PlaybackTokens != nil {
+		router.GET("/playback/:id/:token/*path", videoHandler.ServePlayback)
+	}
+
 	// Serve metrics from the provided custom registry.
 	router.GET("/metrics", gin.WrapH(promhttp.HandlerFor(reg, promhttp.HandlerOpts{})))
 	return router
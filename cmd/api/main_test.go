@@ -1,17 +1,56 @@
 package main
 
 import (
+	"context"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/ryanschneiderman/video-api/internal/app"
+	"github.com/ryanschneiderman/video-api/internal/auth"
+	"github.com/ryanschneiderman/video-api/internal/db"
 	"github.com/ryanschneiderman/video-api/internal/metrics"
+	"github.com/ryanschneiderman/video-api/internal/playback"
+	"github.com/ryanschneiderman/video-api/internal/ratelimit"
 )
 
+const (
+	testAPIKey     = "vapi_test"
+	readOnlyAPIKey = "vapi_readonly"
+	adminAPIKey    = "vapi_admin"
+)
+
+// stubKeyStore accepts testAPIKey, the read-only readOnlyAPIKey and the
+// admin adminAPIKey for the "acme" tenant.
+type stubKeyStore struct{}
+
+func (stubKeyStore) GetAPIKey(ctx context.Context, hash string) (*db.APIKey, error) {
+	switch hash {
+	case auth.HashKey(testAPIKey):
+		return &db.APIKey{Hash: hash, TenantID: "acme"}, nil
+	case auth.HashKey(readOnlyAPIKey):
+		return &db.APIKey{Hash: hash, TenantID: "acme", Scopes: []string{auth.ScopeRead}}, nil
+	case auth.HashKey(adminAPIKey):
+		return &db.APIKey{Hash: hash, TenantID: "acme", Scopes: []string{auth.ScopeAdmin}}, nil
+	}
+	return nil, db.ErrAPIKeyNotFound
+}
+
+// newAuthedRequest is http.NewRequest with testAPIKey attached.
+func newAuthedRequest(method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-API-Key", testAPIKey)
+	return req, nil
+}
+
 func TestMetricsEndpointWithTraffic(t *testing.T) {
 	// Set Gin to test mode.
 	gin.SetMode(gin.TestMode)
@@ -26,10 +65,10 @@ func TestMetricsEndpointWithTraffic(t *testing.T) {
 	fApp := &app.App{} // Use a minimal stub or fake as needed.
 
 	// Setup router using our helper with the custom registry and metrics instance.
-	router := setupRouter(fApp, reg, apiMetrics)
+	router := setupRouter(fApp, stubKeyStore{}, reg, apiMetrics)
 
 	// Simulate an HTTP GET request to trigger the middleware.
-	req1, err := http.NewRequest("GET", "/videos/test-id", nil)
+	req1, err := newAuthedRequest("GET", "/videos/test-id", nil)
 	if err != nil {
 		t.Fatalf("could not create request: %v", err)
 	}
@@ -70,10 +109,10 @@ func TestVideoEndpointsExist(t *testing.T) {
 	apiMetrics.Register(reg)
 
 	fApp := &app.App{}
-	router := setupRouter(fApp, reg, apiMetrics)
+	router := setupRouter(fApp, stubKeyStore{}, reg, apiMetrics)
 
 	// Test that POST /videos route is registered.
-	req, err := http.NewRequest("POST", "/videos", nil)
+	req, err := newAuthedRequest("POST", "/videos", nil)
 	if err != nil {
 		t.Fatalf("could not create POST /videos request: %v", err)
 	}
@@ -84,7 +123,7 @@ func TestVideoEndpointsExist(t *testing.T) {
 	}
 
 	// Test that GET /videos/:id route is registered.
-	req, err = http.NewRequest("GET", "/videos/test-id", nil)
+	req, err = newAuthedRequest("GET", "/videos/test-id", nil)
 	if err != nil {
 		t.Fatalf("could not create GET /videos/:id request: %v", err)
 	}
@@ -93,4 +132,268 @@ func TestVideoEndpointsExist(t *testing.T) {
 	if rr.Code == http.StatusNotFound {
 		t.Errorf("GET /videos/:id route not found, got %d", rr.Code)
 	}
+
+	// Test that GET /videos/:id/analysis route is registered.
+	req, err = newAuthedRequest("GET", "/videos/test-id/analysis", nil)
+	if err != nil {
+		t.Fatalf("could not create GET /videos/:id/analysis request: %v", err)
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code == http.StatusNotFound {
+		t.Errorf("GET /videos/:id/analysis route not found, got %d", rr.Code)
+	}
+
+	// Test that GET /videos/search reaches the search handler rather than
+	// GET /videos/:id, which would reject "search" as a video ID.
+	req, err = newAuthedRequest("GET", "/videos/search", nil)
+	if err != nil {
+		t.Fatalf("could not create GET /videos/search request: %v", err)
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "Missing search query") {
+		t.Errorf("GET /videos/search not routed to search, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	// Test that GET /videos/:id/similar route is registered.
+	req, err = newAuthedRequest("GET", "/videos/test-id/similar", nil)
+	if err != nil {
+		t.Fatalf("could not create GET /videos/:id/similar request: %v", err)
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code == http.StatusNotFound {
+		t.Errorf("GET /videos/:id/similar route not found, got %d", rr.Code)
+	}
+
+	// Test that GET /videos/:id/stream/*path reaches the stream handler,
+	// which rejects the stub ID.
+	req, err = newAuthedRequest("GET", "/videos/test-id/stream/hls/master.m3u8", nil)
+	if err != nil {
+		t.Fatalf("could not create GET /videos/:id/stream request: %v", err)
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("GET /videos/:id/stream not routed to stream, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	// Test that POST /videos/import reaches the import handler, which
+	// refuses a private source before touching the stub app.
+	req, err = newAuthedRequest("POST", "/videos/import", strings.NewReader(`{"sourceUrl": "http://127.0.0.1/a.mp4"}`))
+	if err != nil {
+		t.Fatalf("could not create POST /videos/import request: %v", err)
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "not public") {
+		t.Errorf("POST /videos/import not routed to import, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	// Test that POST /videos/batch/get reaches the batch handler, which
+	// refuses an empty batch before touching the stub app.
+	req, err = newAuthedRequest("POST", "/videos/batch/get", strings.NewReader(`{"videoIds": []}`))
+	if err != nil {
+		t.Fatalf("could not create POST /videos/batch/get request: %v", err)
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "between 1 and") {
+		t.Errorf("POST /videos/batch/get not routed to batch get, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	// Test that POST /videos/:id/reprocess reaches the reprocess handler,
+	// which refuses an unknown stage before touching the stub app.
+	req, err = newAuthedRequest("POST", "/videos/6f1c2a9e-3b7d-4e8a-9c5f-2d4b6a8e0c1f/reprocess", strings.NewReader(`{"stages": ["thumbnail"]}`))
+	if err != nil {
+		t.Fatalf("could not create POST /videos/:id/reprocess request: %v", err)
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "unknown stage") {
+		t.Errorf("POST /videos/:id/reprocess not routed to reprocess, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	// Test that POST /videos/:id/versions/:version/promote reaches the
+	// promote handler, which refuses a malformed version before touching the
+	// stub app.
+	req, err = newAuthedRequest("POST", "/videos/6f1c2a9e-3b7d-4e8a-9c5f-2d4b6a8e0c1f/versions/latest/promote", nil)
+	if err != nil {
+		t.Fatalf("could not create POST /videos/:id/versions/:version/promote request: %v", err)
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "Invalid version") {
+		t.Errorf("POST /videos/:id/versions/:version/promote not routed to promote, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	// Test that writes go through the idempotency middleware, which refuses
+	// a malformed key before the handler runs.
+	req, err = newAuthedRequest("POST", "/videos/batch", strings.NewReader(`{"videos": []}`))
+	if err != nil {
+		t.Fatalf("could not create POST /videos/batch request: %v", err)
+	}
+	req.Header.Set("Idempotency-Key", "not a valid key")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "Idempotency-Key") {
+		t.Errorf("POST /videos/batch not checked for an idempotency key, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	// Test that POST /videos/search/semantic route is registered. The stub
+	// app has no embedder configured, so the handler reports it unavailable.
+	req, err = newAuthedRequest("POST", "/videos/search/semantic", strings.NewReader(`{"query": "dog"}`))
+	if err != nil {
+		t.Fatalf("could not create POST /videos/search/semantic request: %v", err)
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("POST /videos/search/semantic not routed to semantic search, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestVideoEndpointsRequireAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	reg := prometheus.NewRegistry()
+	apiMetrics := metrics.NewAPIMetrics()
+	apiMetrics.Register(reg)
+
+	router := setupRouter(&app.App{}, stubKeyStore{}, reg, apiMetrics)
+
+	for _, path := range []string{"/videos/test-id", "/videos/search?q=dog", "/videos/test-id/similar", "/videos/test-id/stream/hls/master.m3u8"} {
+		req, err := http.NewRequest("GET", path, nil)
+		if err != nil {
+			t.Fatalf("could not create GET %s request: %v", path, err)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected 401 for unauthenticated GET %s, got %d", path, rr.Code)
+		}
+	}
+
+	// A key that isn't stored is rejected the same way.
+	req, err := http.NewRequest("GET", "/videos/test-id", nil)
+	if err != nil {
+		t.Fatalf("could not create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer vapi_unknown")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for an unknown API key, got %d", rr.Code)
+	}
+
+	// Uploading needs the write scope.
+	req, err = http.NewRequest("POST", "/videos", nil)
+	if err != nil {
+		t.Fatalf("could not create POST /videos request: %v", err)
+	}
+	req.Header.Set("X-API-Key", readOnlyAPIKey)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for an upload with a read-only key, got %d", rr.Code)
+	}
+
+	// Managing webhooks needs the admin scope.
+	for key, want := range map[string]int{testAPIKey: http.StatusForbidden, adminAPIKey: http.StatusBadRequest} {
+		req, err = http.NewRequest("POST", "/webhooks", strings.NewReader(`{"url":"ftp://example.com"}`))
+		if err != nil {
+			t.Fatalf("could not create POST /webhooks request: %v", err)
+		}
+		req.Header.Set("X-API-Key", key)
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Errorf("POST /webhooks with %s: expected %d, got %d", key, want, rr.Code)
+		}
+	}
+
+	// /metrics is scraped without credentials.
+	req, err = http.NewRequest("GET", "/metrics", nil)
+	if err != nil {
+		t.Fatalf("could not create /metrics request: %v", err)
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected /metrics to stay open, got %d", rr.Code)
+	}
+}
+
+func TestRateLimitPerRouteGroup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	reg := prometheus.NewRegistry()
+	apiMetrics := metrics.NewAPIMetrics()
+	apiMetrics.Register(reg)
+
+	fApp := &app.App{WriteRateLimit: ratelimit.Limit{Burst: 1, Per: time.Minute}}
+	router := setupRouter(fApp, stubKeyStore{}, reg, apiMetrics)
+
+	// The first upload uses up the write group's only token.
+	for i, want := range []int{http.StatusBadRequest, http.StatusTooManyRequests} {
+		req, err := newAuthedRequest("POST", "/videos", nil)
+		if err != nil {
+			t.Fatalf("could not create POST /videos request: %v", err)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Errorf("upload %d: expected %d, got %d", i, want, rr.Code)
+		}
+		if want == http.StatusTooManyRequests && rr.Header().Get("Retry-After") != "60" {
+			t.Errorf("expected Retry-After of 60 seconds, got %q", rr.Header().Get("Retry-After"))
+		}
+	}
+
+	// Reads have their own limit.
+	req, err := newAuthedRequest("GET", "/videos/search", nil)
+	if err != nil {
+		t.Fatalf("could not create GET /videos/search request: %v", err)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected reads to be unaffected by the write limit, got %d", rr.Code)
+	}
+
+	// GET /usage is registered.
+	req, err = newAuthedRequest("GET", "/usage?period=june", nil)
+	if err != nil {
+		t.Fatalf("could not create GET /usage request: %v", err)
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("GET /usage not routed to usage, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestPlaybackRouteOnlyInTokenMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	target := "/playback/0b7c2a4e-5d8f-4e1a-9c3b-2f6d8e0a1b2c/bad-token/source"
+
+	for mode, want := range map[string]int{
+		playback.ModePresign: http.StatusNotFound,
+		// The proxy takes the token instead of credentials.
+		playback.ModeToken: http.StatusForbidden,
+	} {
+		reg := prometheus.NewRegistry()
+		apiMetrics := metrics.NewAPIMetrics()
+		apiMetrics.Register(reg)
+
+		fApp := &app.App{Playback: playback.Config{Mode: mode, Secret: []byte(strings.Repeat("s", 32))}}
+		router := setupRouter(fApp, stubKeyStore{}, reg, apiMetrics)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		if rr.Code != want {
+			t.Errorf("%s mode: expected %d, got %d", mode, want, rr.Code)
+		}
+	}
 }
@@ -0,0 +1,105 @@
+// Command apikey creates and revokes API keys.
+//
+//	apikey create -tenant acme -name ci [-scopes videos:read,videos:write]
+//	apikey revoke -key vapi_...
+//
+// Keys are printed once on creation; only their hash is stored.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/ryanschneiderman/video-api/internal/auth"
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/tenant"
+)
+
+func main() {
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using system env variables")
+	}
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	ctx := context.Background()
+	store, err := db.NewDB(ctx, os.Getenv("DYNAMODB_TABLE"))
+	if err != nil {
+		log.Fatal("Failed to initialize DB: ", err)
+	}
+	if dataTable := os.Getenv("DYNAMODB_DATA_TABLE"); dataTable != "" {
+		store.DataTable = dataTable
+	}
+
+	switch os.Args[1] {
+	case "create":
+		err = create(ctx, store, os.Args[2:])
+	case "revoke":
+		err = revoke(ctx, store, os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: apikey create -tenant <id> [-name <name>] [-scopes <scopes>]")
+	fmt.Fprintln(os.Stderr, "       apikey revoke -key <key>")
+	os.Exit(2)
+}
+
+func create(ctx context.Context, store *db.DB, args []string) error {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	tenantID := fs.String("tenant", "", "tenant the key grants access to")
+	name := fs.String("name", "", "label to tell keys apart")
+	scopeList := fs.String("scopes", "", "comma separated scopes to grant (default videos:read,videos:write)")
+	fs.Parse(args)
+
+	if err := tenant.ValidateID(*tenantID); err != nil {
+		return err
+	}
+	scopes, err := auth.ParseScopes(*scopeList)
+	if err != nil {
+		return err
+	}
+	key, err := auth.GenerateKey()
+	if err != nil {
+		return err
+	}
+	err = store.PutAPIKey(ctx, db.APIKey{
+		Hash:      auth.HashKey(key),
+		TenantID:  *tenantID,
+		Name:      *name,
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store API key: %w", err)
+	}
+
+	fmt.Println(key)
+	return nil
+}
+
+func revoke(ctx context.Context, store *db.DB, args []string) error {
+	fs := flag.NewFlagSet("revoke", flag.ExitOnError)
+	key := fs.String("key", "", "API key to revoke")
+	fs.Parse(args)
+
+	if *key == "" {
+		return fmt.Errorf("-key is required")
+	}
+	if err := store.RevokeAPIKey(ctx, auth.HashKey(*key)); err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	log.Println("API key revoked")
+	return nil
+}
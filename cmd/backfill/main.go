@@ -0,0 +1,262 @@
+// Command backfill reprocesses every video matching a filter, for instance
+// to move old videos to a new transcoding profile or to rerun an analyzer.
+//
+//	backfill -status ready -exclude-profile h264-720p -profile h264-720p
+//	backfill -tenant acme -stages analyze -uploaded-before 2025-01-01
+//
+// Jobs are enqueued at -rate per second. The scan position is saved to the
+// -cursor file after every video, so an interrupted backfill picks up where
+// it stopped when run again with the same file. The file is removed once
+// the scan finishes.
+//
+// Like the API, it doesn't reprocess videos of tenants that have used up
+// this month's processing quota.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/ryanschneiderman/video-api/internal/app"
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/reprocess"
+	"github.com/ryanschneiderman/video-api/internal/worker"
+)
+
+func main() {
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using system env variables")
+	}
+
+	tenantID := flag.String("tenant", "", "only reprocess this tenant's videos")
+	status := flag.String("status", db.StatusReady, "only reprocess videos in this status (ready or failed)")
+	excludeProfile := flag.String("exclude-profile", "", "skip videos already processed with this profile")
+	uploadedAfter := flag.String("uploaded-after", "", "only reprocess videos uploaded at or after this date (YYYY-MM-DD or RFC 3339)")
+	uploadedBefore := flag.String("uploaded-before", "", "only reprocess videos uploaded before this date (YYYY-MM-DD or RFC 3339)")
+	stages := flag.String("stages", "", "comma separated stages to rerun (default all)")
+	profileName := flag.String("profile", "", "transcoding profile to use (default each video's current one)")
+	rate := flag.Float64("rate", 2, "jobs to enqueue per second")
+	cursorFile := flag.String("cursor", "backfill.cursor", "file the scan position is saved to")
+	dryRun := flag.Bool("dry-run", false, "list the matching videos without reprocessing them")
+	flag.Parse()
+
+	filter := db.VideoFilter{
+		TenantID:       *tenantID,
+		Status:         *status,
+		ExcludeProfile: *excludeProfile,
+		Limit:          100,
+	}
+	var err error
+	if filter.UploadedAfter, err = parseDate(*uploadedAfter); err != nil {
+		log.Fatal("Invalid -uploaded-after: ", err)
+	}
+	if filter.UploadedBefore, err = parseDate(*uploadedBefore); err != nil {
+		log.Fatal("Invalid -uploaded-before: ", err)
+	}
+	req := reprocess.Request{Profile: *profileName}
+	if *stages != "" {
+		req.Stages = strings.Split(*stages, ",")
+	}
+	if err := req.Validate(worker.StageNames()); err != nil {
+		log.Fatal(err)
+	}
+	if *rate <= 0 {
+		log.Fatal("-rate must be positive")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	myApp, err := app.InitializeApp(ctx)
+	if err != nil {
+		log.Fatal("Failed to initialize application:", err)
+	}
+
+	b := &backfill{
+		DB:       myApp.DB,
+		Enqueuer: &reprocess.Enqueuer{DB: myApp.DB, Queue: myApp.SQSClient, QueueURL: myApp.QueueURL},
+		Request:  req,
+		Interval: time.Duration(float64(time.Second) / *rate),
+		Cursor:   *cursorFile,
+		DryRun:   *dryRun,
+
+		ProcessingQuotaMinutes: myApp.ProcessingQuotaMinutes,
+	}
+	if err := b.run(ctx, filter); err != nil {
+		log.Fatal(err)
+	}
+}
+
+type backfill struct {
+	DB       *db.DB
+	Enqueuer *reprocess.Enqueuer
+	Request  reprocess.Request
+	Interval time.Duration
+	Cursor   string
+	DryRun   bool
+
+	ProcessingQuotaMinutes int64
+
+	enqueued, skipped int
+	// overQuota holds the tenants found to have used up this month's
+	// processing minutes, whose videos are skipped for the rest of the run.
+	overQuota map[string]bool
+}
+
+func (b *backfill) run(ctx context.Context, filter db.VideoFilter) error {
+	after, err := readCursor(b.Cursor)
+	if err != nil {
+		return err
+	}
+	if after != "" {
+		log.Printf("Resuming after videoID %s", after)
+	}
+	filter.After = after
+
+	ticker := time.NewTicker(b.Interval)
+	defer ticker.Stop()
+
+	for {
+		videos, next, err := b.DB.ScanVideos(ctx, filter)
+		if err != nil {
+			return err
+		}
+		for i := range videos {
+			if !b.DryRun {
+				select {
+				case <-ctx.Done():
+					b.report()
+					return ctx.Err()
+				case <-ticker.C:
+				}
+			}
+			if err := b.reprocess(ctx, &videos[i]); err != nil {
+				b.report()
+				return err
+			}
+			if err := writeCursor(b.Cursor, videos[i].VideoID); err != nil {
+				return err
+			}
+		}
+
+		if next == "" {
+			break
+		}
+		if err := writeCursor(b.Cursor, next); err != nil {
+			return err
+		}
+		filter.After = next
+	}
+
+	b.report()
+	if err := os.Remove(b.Cursor); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove cursor file: %w", err)
+	}
+	return nil
+}
+
+// reprocess enqueues one video. Videos that can't be reprocessed are
+// skipped; any other failure stops the backfill before the cursor passes
+// the video, so the next run retries it.
+func (b *backfill) reprocess(ctx context.Context, video *db.Video) error {
+	over, err := b.checkProcessingQuota(ctx, video.TenantID)
+	if err != nil {
+		return err
+	}
+	if over {
+		log.Printf("Skipping videoID %s: tenant %s is over its monthly processing quota", video.VideoID, video.TenantID)
+		b.skipped++
+		return nil
+	}
+
+	if b.DryRun {
+		log.Printf("Would reprocess videoID %s of tenant %s (%s, profile %q)", video.VideoID, video.TenantID, video.Status, video.Profile)
+		b.enqueued++
+		return nil
+	}
+
+	msg, err := b.Enqueuer.Enqueue(ctx, video, b.Request)
+	switch {
+	case errors.Is(err, reprocess.ErrAlias), errors.Is(err, reprocess.ErrNoSource), errors.Is(err, reprocess.ErrBusy):
+		log.Printf("Skipping videoID %s: %v", video.VideoID, err)
+		b.skipped++
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to reprocess videoID %s: %w", video.VideoID, err)
+	}
+	log.Printf("Enqueued reprocessing of videoID %s with profile %s", video.VideoID, msg.Profile)
+	b.enqueued++
+	return nil
+}
+
+// checkProcessingQuota reports whether the tenant has used up this month's
+// processing minutes, the same check the API makes before a reprocess.
+func (b *backfill) checkProcessingQuota(ctx context.Context, tenantID string) (bool, error) {
+	if b.ProcessingQuotaMinutes <= 0 {
+		return false, nil
+	}
+	if b.overQuota[tenantID] {
+		return true, nil
+	}
+	usage, err := b.DB.GetUsage(ctx, tenantID, db.UsagePeriod(time.Now()))
+	if err != nil {
+		return false, fmt.Errorf("failed to get usage of tenant %s: %w", tenantID, err)
+	}
+	if usage.ProcessingSeconds < float64(b.ProcessingQuotaMinutes*60) {
+		return false, nil
+	}
+	if b.overQuota == nil {
+		b.overQuota = map[string]bool{}
+	}
+	b.overQuota[tenantID] = true
+	return true, nil
+}
+
+func (b *backfill) report() {
+	log.Printf("Enqueued %d videos, skipped %d", b.enqueued, b.skipped)
+}
+
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// readCursor returns the video ID saved in the cursor file, or an empty
+// cursor if there is no file.
+func readCursor(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read cursor file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// writeCursor replaces the cursor file in one rename, so an interrupted
+// write leaves the previous cursor.
+func writeCursor(path, videoID string) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(videoID+"\n"), 0o644); err != nil {
+		return fmt.Errorf("failed to write cursor file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write cursor file: %w", err)
+	}
+	return nil
+}
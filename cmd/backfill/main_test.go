@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backfill.cursor")
+
+	cursor, err := readCursor(path)
+	assert.NoError(t, err)
+	assert.Empty(t, cursor)
+
+	assert.NoError(t, writeCursor(path, "video-1"))
+	assert.NoError(t, writeCursor(path, "video-2"))
+	cursor, err = readCursor(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "video-2", cursor)
+}
+
+func TestParseDate(t *testing.T) {
+	date, err := parseDate("2025-01-02")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), date)
+
+	date, err = parseDate("2025-01-02T03:04:05Z")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, date.Hour())
+
+	date, err = parseDate("")
+	assert.NoError(t, err)
+	assert.True(t, date.IsZero())
+
+	_, err = parseDate("yesterday")
+	assert.Error(t, err)
+}
+
+func TestCheckProcessingQuotaUnlimited(t *testing.T) {
+	// Without a quota the usage isn't even looked up.
+	over, err := (&backfill{}).checkProcessingQuota(context.Background(), "acme")
+	assert.NoError(t, err)
+	assert.False(t, over)
+}
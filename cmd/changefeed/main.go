@@ -0,0 +1,142 @@
+// Command changefeed tails the videos table's DynamoDB stream and reacts to
+// every change, whichever process wrote it: it publishes status and
+// metadata events, reindexes search when metadata changes, and purges the
+// files and index entries of deleted videos before announcing them to
+// webhooks and the event sink.
+//
+// The stream is DYNAMODB_STREAM_ARN, or the table's latest stream. It must
+// carry new and old images.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
+	"github.com/joho/godotenv"
+	"github.com/ryanschneiderman/video-api/internal/app"
+	"github.com/ryanschneiderman/video-api/internal/changefeed"
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/embedder"
+	"github.com/ryanschneiderman/video-api/internal/events"
+	"github.com/ryanschneiderman/video-api/internal/purge"
+	"github.com/ryanschneiderman/video-api/internal/semantic"
+	"github.com/ryanschneiderman/video-api/internal/webhook"
+	"github.com/ryanschneiderman/video-api/internal/worker"
+)
+
+func main() {
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using system env variables")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	myApp, err := app.InitializeApp(ctx)
+	if err != nil {
+		log.Fatal("Failed to initialize application:", err)
+	}
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		log.Fatal("Failed to load AWS config:", err)
+	}
+	streamArn, err := streamARN(ctx, dynamodb.NewFromConfig(cfg), myApp.TableName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	purger := &purge.Purger{DB: myApp.DB, S3: myApp.S3Client, Bucket: myApp.S3Bucket}
+	if e, err := embedder.New(myApp.Embedder); err != nil {
+		log.Printf("Semantic search disabled, not purging its index: %v", err)
+	} else {
+		purger.SemanticIndex = semantic.NewStore(myApp.S3Client, myApp.S3Bucket, e)
+	}
+
+	r := &reactor{
+		Events:   events.NewPublisher(myApp.Events, myApp.SNSClient, myApp.SQSClient),
+		Webhooks: webhook.NewDispatcher(myApp.DB, myApp.Webhooks),
+		Reindex: func(ctx context.Context, video *db.Video) error {
+			return worker.IndexSearch(ctx, myApp.DB, video)
+		},
+		Purge: purger.Purge,
+	}
+	consumer := changefeed.NewConsumer(dynamodbstreams.NewFromConfig(cfg), streamArn, myApp.DB, r)
+
+	log.Printf("Consuming stream %s", streamArn)
+	err = consumer.Run(ctx)
+	// Let webhook deliveries in flight finish, or record their attempts.
+	r.Webhooks.Wait()
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatal(err)
+	}
+}
+
+func streamARN(ctx context.Context, client *dynamodb.Client, table string) (string, error) {
+	if arn := os.Getenv("DYNAMODB_STREAM_ARN"); arn != "" {
+		return arn, nil
+	}
+	out, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe table %s: %w", table, err)
+	}
+	if out.Table.LatestStreamArn == nil {
+		return "", fmt.Errorf("table %s has no stream", table)
+	}
+	return *out.Table.LatestStreamArn, nil
+}
+
+// reactor is what the changefeed does with each change. Creations, and the
+// uploaded, ready and failed events, are already announced by the API and
+// worker, so they aren't repeated here.
+type reactor struct {
+	// Events is nil unless an event sink is configured.
+	Events   *events.Publisher
+	Webhooks *webhook.Dispatcher
+	Reindex  func(ctx context.Context, video *db.Video) error
+	// Purge deletes what a deleted video leaves behind. It runs before the
+	// video is announced, so a failed purge that is retried doesn't announce
+	// it twice.
+	Purge func(ctx context.Context, video *db.Video) error
+}
+
+func (r *reactor) HandleChange(ctx context.Context, change changefeed.Change) error {
+	video := change.Video
+	switch change.Kind {
+	case changefeed.KindStatusChanged:
+		if r.Events != nil {
+			return r.Events.Send(ctx, events.NewStatusChangedEvent(r.Events.Source, video, change.Previous.Status))
+		}
+
+	case changefeed.KindMetadataUpdated:
+		// Only processed videos are in the search index.
+		if video.Status == db.StatusReady && video.TenantID != "" {
+			if err := r.Reindex(ctx, video); err != nil {
+				return err
+			}
+		}
+		if r.Events != nil {
+			return r.Events.Send(ctx, events.NewMetadataUpdatedEvent(r.Events.Source, video, change.Fields))
+		}
+
+	case changefeed.KindDeleted:
+		if err := r.Purge(ctx, video); err != nil {
+			return err
+		}
+		if err := r.Webhooks.Publish(ctx, webhook.NewVideoEvent(webhook.EventVideoDeleted, video)); err != nil {
+			return err
+		}
+		if r.Events != nil {
+			return r.Events.Publish(ctx, events.TypeVideoDeleted, video)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ryanschneiderman/video-api/internal/changefeed"
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/events"
+	"github.com/ryanschneiderman/video-api/internal/webhook"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingSink struct{ events []events.Event }
+
+func (s *recordingSink) Send(ctx context.Context, event events.Event) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+// hookStore has no webhooks, and records which tenants were looked up.
+type hookStore struct{ tenants []string }
+
+func (s *hookStore) ListWebhooks(ctx context.Context, tenantId string) ([]db.Webhook, error) {
+	s.tenants = append(s.tenants, tenantId)
+	return nil, nil
+}
+
+func (s *hookStore) PutWebhookDelivery(ctx context.Context, delivery db.WebhookDelivery) error {
+	return nil
+}
+
+func TestReactor(t *testing.T) {
+	sink := &recordingSink{}
+	hooks := &hookStore{}
+	var reindexed, purged []string
+	r := &reactor{
+		Events:   &events.Publisher{Sink: sink, Source: "/test"},
+		Webhooks: webhook.NewDispatcher(hooks, webhook.Config{}),
+		Reindex: func(ctx context.Context, video *db.Video) error {
+			reindexed = append(reindexed, video.VideoID)
+			return nil
+		},
+		Purge: func(ctx context.Context, video *db.Video) error {
+			purged = append(purged, video.VideoID)
+			return nil
+		},
+	}
+	ready := &db.Video{VideoID: "v1", TenantID: "acme", Status: db.StatusReady, Title: "new"}
+	processing := &db.Video{VideoID: "v2", TenantID: "acme", Status: db.StatusProcessing}
+
+	ctx := context.Background()
+	assert.NoError(t, r.HandleChange(ctx, changefeed.Change{Kind: changefeed.KindCreated, Video: processing}))
+	assert.NoError(t, r.HandleChange(ctx, changefeed.Change{
+		Kind: changefeed.KindStatusChanged, Video: ready, Previous: &db.Video{Status: db.StatusProcessing},
+	}))
+	assert.NoError(t, r.HandleChange(ctx, changefeed.Change{Kind: changefeed.KindMetadataUpdated, Video: ready, Fields: []string{"title"}}))
+	assert.NoError(t, r.HandleChange(ctx, changefeed.Change{Kind: changefeed.KindMetadataUpdated, Video: processing, Fields: []string{"tags"}}))
+	assert.NoError(t, r.HandleChange(ctx, changefeed.Change{Kind: changefeed.KindDeleted, Video: ready}))
+
+	var types []string
+	for _, e := range sink.events {
+		types = append(types, e.Type)
+	}
+	assert.Equal(t, []string{
+		events.TypeVideoStatusChanged, events.TypeVideoMetadataUpdated, events.TypeVideoMetadataUpdated, events.TypeVideoDeleted,
+	}, types)
+	assert.Equal(t, events.VideoStatusChangedData{
+		VideoID: "v1", TenantID: "acme", Status: db.StatusReady, PreviousStatus: db.StatusProcessing,
+	}, sink.events[0].Data)
+	// Only the processed video is reindexed.
+	assert.Equal(t, []string{"v1"}, reindexed)
+	// The deleted video was purged, and went to the tenant's webhooks.
+	assert.Equal(t, []string{"v1"}, purged)
+	assert.Equal(t, []string{"acme"}, hooks.tenants)
+}
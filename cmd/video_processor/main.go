@@ -35,7 +35,10 @@ func main() {
 		log.Fatal("Failed to initialize application:", err)
 	}
 
-	processor := worker.NewProcessor(myApp)
+	processor, err := worker.NewProcessor(myApp)
+	if err != nil {
+		log.Fatal("Failed to initialize processor:", err)
+	}
 
 	log.Println("Starting SQS worker...")
 	for {
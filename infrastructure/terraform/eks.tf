@@ -46,13 +46,28 @@ data "aws_iam_policy_document" "s3_access" {
 
     actions = [
       "s3:PutObject",
-      "s3:GetObject"
+      "s3:GetObject",
+      "s3:DeleteObject"
     ]
 
     resources = [
       "arn:aws:s3:::498061775412-twelve-labs-video-storage/*"
     ]
   }
+
+  # Listing lets the worker delete the files of expired video versions, and
+  # the changefeed those of deleted videos.
+  statement {
+    effect = "Allow"
+
+    actions = [
+      "s3:ListBucket"
+    ]
+
+    resources = [
+      "arn:aws:s3:::498061775412-twelve-labs-video-storage"
+    ]
+  }
 }
 
 data "aws_iam_policy_document" "dynamodb_access" {
@@ -64,8 +79,11 @@ data "aws_iam_policy_document" "dynamodb_access" {
       "dynamodb:GetItem",
       "dynamodb:UpdateItem",
       "dynamodb:DeleteItem",
+      "dynamodb:BatchGetItem",
+      "dynamodb:BatchWriteItem",
       "dynamodb:Query",
-      "dynamodb:Scan"
+      "dynamodb:Scan",
+      "dynamodb:TransactWriteItems"
     ]
 
     resources = [aws_dynamodb_table.videos.arn]
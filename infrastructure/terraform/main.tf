@@ -35,18 +35,57 @@ resource "aws_ecr_repository" "twelve_labs_video_processor" {
   }
 }
 
+resource "aws_ecr_repository" "twelve_labs_changefeed" {
+  name                 = "twelve-labs/changefeed"
+  image_tag_mutability = "MUTABLE"
+
+  image_scanning_configuration {
+    scan_on_push = true
+  }
+}
+
 
 resource "aws_dynamodb_table" "videos" {
   name         = "twelve-labs-videos"
   billing_mode = "PAY_PER_REQUEST"
   hash_key     = "video_id"
 
+  # Read by the changefeed, which needs both images to tell what changed.
+  stream_enabled   = true
+  stream_view_type = "NEW_AND_OLD_IMAGES"
+
   attribute {
     name = "video_id"
     type = "S"
   }
 }
 
+# Item collections that hang off a video (analysis segments, indexes, ...)
+# live here under a generic pk/sk schema.
+resource "aws_dynamodb_table" "video_data" {
+  name         = "twelve-labs-videos-data"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "pk"
+  range_key    = "sk"
+
+  attribute {
+    name = "pk"
+    type = "S"
+  }
+
+  attribute {
+    name = "sk"
+    type = "S"
+  }
+
+  # Webhook deliveries expire out of the log, and idempotency keys once their
+  # responses are no longer replayed.
+  ttl {
+    attribute_name = "expires_at"
+    enabled        = true
+  }
+}
+
 resource "aws_sqs_queue" "dlq" {
   name = "video-processing-dlq"
 }
@@ -0,0 +1,94 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnknownAnalyzer = errors.New("unknown analyzer")
+	ErrInvalidConfig   = errors.New("invalid analyzer config")
+)
+
+type Label struct {
+	Name       string  `json:"name"`
+	Confidence float64 `json:"confidence"`
+}
+
+// Segment is a time-coded finding. Start and End are offsets into the video
+// in seconds.
+type Segment struct {
+	Start      float64 `json:"start"`
+	End        float64 `json:"end"`
+	Label      string  `json:"label,omitempty"`
+	Text       string  `json:"text,omitempty"`
+	Confidence float64 `json:"confidence,omitempty"`
+}
+
+type Result struct {
+	Analyzer     string    `json:"analyzer"`
+	ModelVersion string    `json:"modelVersion,omitempty"`
+	Labels       []Label   `json:"labels"`
+	Summary      string    `json:"summary"`
+	Segments     []Segment `json:"segments"`
+}
+
+// Analyzer inspects a local video file and describes its content.
+type Analyzer interface {
+	Name() string
+	Analyze(ctx context.Context, videoPath string) (*Result, error)
+}
+
+type Config struct {
+	Name     string
+	Endpoint string
+	APIKey   string
+	Timeout  time.Duration
+}
+
+type Factory func(cfg Config) (Analyzer, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register makes an analyzer implementation available to New under name.
+// It panics if name is already taken, mirroring how database/sql drivers
+// register themselves.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("analyzer %q registered twice", name))
+	}
+	registry[name] = factory
+}
+
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func New(cfg Config) (Analyzer, error) {
+	registryMu.RLock()
+	factory, ok := registry[cfg.Name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %q (registered: %v)", ErrUnknownAnalyzer, cfg.Name, Registered())
+	}
+	return factory(cfg)
+}
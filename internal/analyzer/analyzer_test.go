@@ -0,0 +1,92 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewUsesRegistry(t *testing.T) {
+	a, err := New(Config{Name: "fake"})
+	assert.NoError(t, err)
+	assert.Equal(t, "fake", a.Name())
+
+	_, err = New(Config{Name: "does-not-exist"})
+	assert.ErrorIs(t, err, ErrUnknownAnalyzer)
+
+	_, err = New(Config{Name: "http"})
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+}
+
+func TestRegisterTwicePanics(t *testing.T) {
+	assert.Panics(t, func() {
+		Register("fake", func(cfg Config) (Analyzer, error) { return &Fake{}, nil })
+	})
+}
+
+func TestFakeIsDeterministic(t *testing.T) {
+	fake := &Fake{}
+	first, err := fake.Analyze(context.Background(), "a.mp4")
+	assert.NoError(t, err)
+	second, err := fake.Analyze(context.Background(), "b.mp4")
+	assert.NoError(t, err)
+
+	assert.Equal(t, first, second)
+	assert.NotEmpty(t, first.Labels)
+	assert.NotEmpty(t, first.Segments)
+}
+
+func TestHTTPAnalyzer(t *testing.T) {
+	videoPath := filepath.Join(t.TempDir(), "video.mp4")
+	assert.NoError(t, os.WriteFile(videoPath, []byte("fake video bytes"), 0o600))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		assert.Equal(t, "video/mp4", r.Header.Get("Content-Type"))
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, "fake video bytes", string(body))
+
+		json.NewEncoder(w).Encode(Result{
+			ModelVersion: "v2",
+			Labels:       []Label{{Name: "dog", Confidence: 0.7}},
+			Summary:      "A dog runs.",
+			Segments:     []Segment{{Start: 1.5, End: 3, Label: "dog"}},
+		})
+	}))
+	defer server.Close()
+
+	a, err := New(Config{Name: "http", Endpoint: server.URL, APIKey: "secret"})
+	assert.NoError(t, err)
+
+	result, err := a.Analyze(context.Background(), videoPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "http", result.Analyzer)
+	assert.Equal(t, "v2", result.ModelVersion)
+	assert.Equal(t, "A dog runs.", result.Summary)
+	assert.Equal(t, []Segment{{Start: 1.5, End: 3, Label: "dog"}}, result.Segments)
+}
+
+func TestHTTPAnalyzerErrorStatus(t *testing.T) {
+	videoPath := filepath.Join(t.TempDir(), "video.mp4")
+	assert.NoError(t, os.WriteFile(videoPath, []byte("x"), 0o600))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "model overloaded", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	a, err := NewHTTPAnalyzer(Config{Endpoint: server.URL})
+	assert.NoError(t, err)
+
+	_, err = a.Analyze(context.Background(), videoPath)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "status 503")
+}
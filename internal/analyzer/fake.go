@@ -0,0 +1,42 @@
+package analyzer
+
+import "context"
+
+func init() {
+	Register("fake", func(cfg Config) (Analyzer, error) {
+		return &Fake{}, nil
+	})
+}
+
+// Fake returns the same canned result for every video. It lets the pipeline
+// run end to end without a model endpoint.
+type Fake struct {
+	// Result overrides the canned result when set.
+	Result *Result
+}
+
+func (f *Fake) Name() string { return "fake" }
+
+func (f *Fake) Analyze(ctx context.Context, videoPath string) (*Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if f.Result != nil {
+		result := *f.Result
+		return &result, nil
+	}
+
+	return &Result{
+		Analyzer:     f.Name(),
+		ModelVersion: "fake-1",
+		Labels: []Label{
+			{Name: "outdoor", Confidence: 0.94},
+			{Name: "sports", Confidence: 0.88},
+		},
+		Summary: "This video appears to contain outdoor sports action.",
+		Segments: []Segment{
+			{Start: 0, End: 4, Label: "outdoor", Confidence: 0.94},
+			{Start: 4, End: 10, Label: "sports", Confidence: 0.88},
+		},
+	}, nil
+}
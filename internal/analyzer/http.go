@@ -0,0 +1,84 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+const defaultHTTPTimeout = 5 * time.Minute
+
+func init() {
+	Register("http", func(cfg Config) (Analyzer, error) {
+		return NewHTTPAnalyzer(cfg)
+	})
+}
+
+// HTTPAnalyzer posts the video to a model endpoint and expects a JSON Result
+// in return.
+type HTTPAnalyzer struct {
+	Endpoint string
+	APIKey   string
+	Client   *http.Client
+}
+
+func NewHTTPAnalyzer(cfg Config) (*HTTPAnalyzer, error) {
+	if cfg.Endpoint == "" {
+		return nil, fmt.Errorf("%w: http analyzer requires an endpoint", ErrInvalidConfig)
+	}
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = defaultHTTPTimeout
+	}
+
+	return &HTTPAnalyzer{
+		Endpoint: cfg.Endpoint,
+		APIKey:   cfg.APIKey,
+		Client:   &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (a *HTTPAnalyzer) Name() string { return "http" }
+
+func (a *HTTPAnalyzer) Analyze(ctx context.Context, videoPath string) (*Result, error) {
+	file, err := os.Open(videoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open video: %w", err)
+	}
+	defer file.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.Endpoint, file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build analyzer request: %w", err)
+	}
+	req.Header.Set("Content-Type", "video/mp4")
+	req.Header.Set("Accept", "application/json")
+	if a.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+a.APIKey)
+	}
+
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("analyzer request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("analyzer returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result Result
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode analyzer response: %w", err)
+	}
+	if result.Analyzer == "" {
+		result.Analyzer = a.Name()
+	}
+
+	return &result, nil
+}
@@ -4,20 +4,62 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/ryanschneiderman/video-api/internal/analyzer"
+	"github.com/ryanschneiderman/video-api/internal/auth"
 	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/embedder"
+	"github.com/ryanschneiderman/video-api/internal/events"
+	"github.com/ryanschneiderman/video-api/internal/fetch"
+	"github.com/ryanschneiderman/video-api/internal/idempotency"
+	"github.com/ryanschneiderman/video-api/internal/playback"
+	"github.com/ryanschneiderman/video-api/internal/ratelimit"
+	"github.com/ryanschneiderman/video-api/internal/transcriber"
+	"github.com/ryanschneiderman/video-api/internal/webhook"
 )
 
 type App struct {
-	DB       *db.DB
-	S3Client *s3.Client
-	SQSClient *sqs.Client
-	TableName string
-	S3Bucket  string
-	QueueURL  string
+	DB          *db.DB
+	S3Client    *s3.Client
+	SQSClient   *sqs.Client
+	SNSClient   *sns.Client
+	TableName   string
+	S3Bucket    string
+	QueueURL    string
+	Analyzer    analyzer.Config
+	Transcriber transcriber.Config
+	Embedder    embedder.Config
+	OIDC        auth.OIDCConfig
+	Playback    playback.Config
+	Webhooks    webhook.Config
+	Events      events.Config
+	// Import fetches the sources of videos imported from a URL.
+	Import          fetch.Config
+	SceneThreshold  float64
+	LoudnessTarget  float64
+	AudioRenditions bool
+	MaxUploadBytes  int64
+	// Idempotency keeps the responses of POST requests sent with an
+	// Idempotency-Key.
+	Idempotency idempotency.Config
+	// UploadURLTTL is how long the presigned upload URLs of a batch work.
+	UploadURLTTL time.Duration
+	// Rate limits apply per API key or token subject to each group of routes.
+	ReadRateLimit  ratelimit.Limit
+	WriteRateLimit ratelimit.Limit
+	// Quotas apply to every tenant. Zero means unlimited.
+	StorageQuotaBytes      int64
+	ProcessingQuotaMinutes int64
+	// VersionRetainCount and VersionRetainFor bound how many past
+	// processing runs of a video keep their files, see worker.Retention.
+	VersionRetainCount int64
+	VersionRetainFor   time.Duration
 }
 
 func InitializeApp(ctx context.Context) (*App, error) {
@@ -28,6 +70,7 @@ func InitializeApp(ctx context.Context) (*App, error) {
 
 	s3Client := s3.NewFromConfig(cfg)
 	sqsClient := sqs.NewFromConfig(cfg)
+	snsClient := sns.NewFromConfig(cfg)
 
 	tableName := os.Getenv("DYNAMODB_TABLE")
 	if tableName == "" {
@@ -42,17 +85,412 @@ func InitializeApp(ctx context.Context) (*App, error) {
 		return nil, fmt.Errorf("SQS_QUEUE_URL env variable not set")
 	}
 
-    dbWrapper, err := db.NewDB(ctx, tableName)
-    if err != nil {
-        return nil, fmt.Errorf("failed to initialize DB wrapper: %w", err)
-    }
+	analyzerCfg, err := loadAnalyzerConfig()
+	if err != nil {
+		return nil, err
+	}
+	transcriberCfg, err := loadTranscriberConfig()
+	if err != nil {
+		return nil, err
+	}
+	embedderCfg, err := loadEmbedderConfig()
+	if err != nil {
+		return nil, err
+	}
+	oidcCfg, err := loadOIDCConfig()
+	if err != nil {
+		return nil, err
+	}
+	playbackCfg, err := loadPlaybackConfig()
+	if err != nil {
+		return nil, err
+	}
+	webhookCfg, err := loadWebhookConfig()
+	if err != nil {
+		return nil, err
+	}
+	eventsCfg, err := loadEventsConfig()
+	if err != nil {
+		return nil, err
+	}
+	sceneThreshold, err := floatEnv("SCENE_THRESHOLD", 0)
+	if err != nil {
+		return nil, err
+	}
+	loudnessTarget, err := floatEnv("LOUDNESS_TARGET_LUFS", 0)
+	if err != nil {
+		return nil, err
+	}
+	if loudnessTarget > 0 {
+		return nil, fmt.Errorf("invalid LOUDNESS_TARGET_LUFS: must be negative")
+	}
+	audioRenditions, err := boolEnv("AUDIO_RENDITIONS", false)
+	if err != nil {
+		return nil, err
+	}
+	maxUploadBytes, err := intEnv("MAX_UPLOAD_BYTES", 0)
+	if err != nil {
+		return nil, err
+	}
+	if maxUploadBytes < 0 {
+		return nil, fmt.Errorf("invalid MAX_UPLOAD_BYTES: must not be negative")
+	}
+	importCfg, err := loadImportConfig(maxUploadBytes)
+	if err != nil {
+		return nil, err
+	}
+	idempotencyCfg, err := loadIdempotencyConfig(maxUploadBytes)
+	if err != nil {
+		return nil, err
+	}
+	uploadURLTTL, err := durationEnv("UPLOAD_URL_TTL", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	if uploadURLTTL <= 0 || uploadURLTTL > 7*24*time.Hour {
+		return nil, fmt.Errorf("invalid UPLOAD_URL_TTL: must be positive and at most 7 days")
+	}
+	readRateLimit, err := limitEnv("RATE_LIMIT_READ", "600/1m")
+	if err != nil {
+		return nil, err
+	}
+	writeRateLimit, err := limitEnv("RATE_LIMIT_WRITE", "60/1m")
+	if err != nil {
+		return nil, err
+	}
+	storageQuota, err := intEnv("TENANT_STORAGE_QUOTA_BYTES", 0)
+	if err != nil {
+		return nil, err
+	}
+	processingQuota, err := intEnv("TENANT_PROCESSING_QUOTA_MINUTES", 0)
+	if err != nil {
+		return nil, err
+	}
+	if storageQuota < 0 || processingQuota < 0 {
+		return nil, fmt.Errorf("invalid tenant quota: must not be negative")
+	}
+	versionRetainCount, err := intEnv("VERSION_RETAIN_COUNT", 3)
+	if err != nil {
+		return nil, err
+	}
+	if versionRetainCount < 1 {
+		return nil, fmt.Errorf("invalid VERSION_RETAIN_COUNT: must be at least 1")
+	}
+	versionRetainFor, err := durationEnv("VERSION_RETAIN_FOR", 7*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	if versionRetainFor < 0 {
+		return nil, fmt.Errorf("invalid VERSION_RETAIN_FOR: must not be negative")
+	}
+
+	dbWrapper, err := db.NewDB(ctx, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize DB wrapper: %w", err)
+	}
+	if dataTable := os.Getenv("DYNAMODB_DATA_TABLE"); dataTable != "" {
+		dbWrapper.DataTable = dataTable
+	}
 
 	return &App{
-		DB:        dbWrapper,
-		S3Client:  s3Client,
-		SQSClient: sqsClient,
-		TableName: tableName,
-		S3Bucket:  bucket,
-		QueueURL:  queueURL,
+		DB:                     dbWrapper,
+		S3Client:               s3Client,
+		SQSClient:              sqsClient,
+		SNSClient:              snsClient,
+		TableName:              tableName,
+		S3Bucket:               bucket,
+		QueueURL:               queueURL,
+		Analyzer:               analyzerCfg,
+		Transcriber:            transcriberCfg,
+		Embedder:               embedderCfg,
+		OIDC:                   oidcCfg,
+		Playback:               playbackCfg,
+		Webhooks:               webhookCfg,
+		Events:                 eventsCfg,
+		Import:                 importCfg,
+		SceneThreshold:         sceneThreshold,
+		LoudnessTarget:         loudnessTarget,
+		AudioRenditions:        audioRenditions,
+		MaxUploadBytes:         maxUploadBytes,
+		UploadURLTTL:           uploadURLTTL,
+		Idempotency:            idempotencyCfg,
+		ReadRateLimit:          readRateLimit,
+		WriteRateLimit:         writeRateLimit,
+		StorageQuotaBytes:      storageQuota,
+		ProcessingQuotaMinutes: processingQuota,
+		VersionRetainCount:     versionRetainCount,
+		VersionRetainFor:       versionRetainFor,
 	}, nil
 }
+
+// modelName picks the client of a model service from <env>, or http when
+// only <env>_ENDPOINT is set. The canned fake has to be chosen explicitly,
+// so a deploy missing its endpoint fails to start instead of serving fake
+// results.
+func modelName(env string) (string, error) {
+	if name := os.Getenv(env); name != "" {
+		return name, nil
+	}
+	if os.Getenv(env+"_ENDPOINT") != "" {
+		return "http", nil
+	}
+	return "", fmt.Errorf("%s_ENDPOINT env variable not set: set it, or %s=fake for canned results", env, env)
+}
+
+// loadAnalyzerConfig picks the analyzer, see modelName.
+func loadAnalyzerConfig() (analyzer.Config, error) {
+	cfg := analyzer.Config{
+		Endpoint: os.Getenv("ANALYZER_ENDPOINT"),
+		APIKey:   os.Getenv("ANALYZER_API_KEY"),
+	}
+	name, err := modelName("ANALYZER")
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Name = name
+
+	timeout, err := durationEnv("ANALYZER_TIMEOUT", 0)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Timeout = timeout
+
+	return cfg, nil
+}
+
+// loadTranscriberConfig follows the same rules as loadAnalyzerConfig.
+func loadTranscriberConfig() (transcriber.Config, error) {
+	cfg := transcriber.Config{
+		Endpoint: os.Getenv("TRANSCRIBER_ENDPOINT"),
+		APIKey:   os.Getenv("TRANSCRIBER_API_KEY"),
+	}
+	name, err := modelName("TRANSCRIBER")
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Name = name
+
+	timeout, err := durationEnv("TRANSCRIBER_TIMEOUT", 0)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Timeout = timeout
+
+	return cfg, nil
+}
+
+// loadEmbedderConfig follows the same rules as loadAnalyzerConfig.
+func loadEmbedderConfig() (embedder.Config, error) {
+	cfg := embedder.Config{
+		Endpoint: os.Getenv("EMBEDDER_ENDPOINT"),
+		APIKey:   os.Getenv("EMBEDDER_API_KEY"),
+	}
+	name, err := modelName("EMBEDDER")
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Name = name
+
+	timeout, err := durationEnv("EMBEDDER_TIMEOUT", 0)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Timeout = timeout
+
+	return cfg, nil
+}
+
+// loadOIDCConfig enables bearer token authentication when OIDC_JWKS_URL is
+// set, in which case the issuer and audience to check are required too.
+func loadOIDCConfig() (auth.OIDCConfig, error) {
+	cfg := auth.OIDCConfig{
+		Issuer:      os.Getenv("OIDC_ISSUER"),
+		Audience:    os.Getenv("OIDC_AUDIENCE"),
+		JWKSURL:     os.Getenv("OIDC_JWKS_URL"),
+		TenantClaim: os.Getenv("OIDC_TENANT_CLAIM"),
+		ScopeClaim:  os.Getenv("OIDC_SCOPE_CLAIM"),
+	}
+	if !cfg.Enabled() {
+		return cfg, nil
+	}
+	if cfg.Issuer == "" || cfg.Audience == "" {
+		return cfg, fmt.Errorf("OIDC_ISSUER and OIDC_AUDIENCE must be set with OIDC_JWKS_URL")
+	}
+
+	refresh, err := durationEnv("OIDC_JWKS_REFRESH", 0)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.RefreshInterval = refresh
+
+	return cfg, nil
+}
+
+// loadPlaybackConfig presigns S3 URLs by default. PLAYBACK_URL_MODE=token
+// links to the playback proxy instead, signing with PLAYBACK_TOKEN_SECRET.
+func loadPlaybackConfig() (playback.Config, error) {
+	cfg := playback.Config{
+		Mode:    os.Getenv("PLAYBACK_URL_MODE"),
+		Secret:  []byte(os.Getenv("PLAYBACK_TOKEN_SECRET")),
+		BaseURL: os.Getenv("PLAYBACK_BASE_URL"),
+	}
+	if cfg.Mode == "" {
+		cfg.Mode = playback.ModePresign
+	}
+
+	ttl, err := durationEnv("PLAYBACK_URL_TTL", 15*time.Minute)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.TTL = ttl
+
+	if err := cfg.Validate(); err != nil {
+		return cfg, fmt.Errorf("invalid playback config: %w", err)
+	}
+	return cfg, nil
+}
+
+// loadWebhookConfig leaves unset values zero for the webhook package's
+// defaults.
+func loadWebhookConfig() (webhook.Config, error) {
+	var cfg webhook.Config
+
+	maxAttempts, err := intEnv("WEBHOOK_MAX_ATTEMPTS", 0)
+	if err != nil {
+		return cfg, err
+	}
+	if maxAttempts < 0 {
+		return cfg, fmt.Errorf("invalid WEBHOOK_MAX_ATTEMPTS: must not be negative")
+	}
+	cfg.MaxAttempts = int(maxAttempts)
+
+	if cfg.Backoff, err = durationEnv("WEBHOOK_RETRY_BACKOFF", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.Timeout, err = durationEnv("WEBHOOK_TIMEOUT", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.Retention, err = durationEnv("WEBHOOK_DELIVERY_RETENTION", 0); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// loadEventsConfig leaves event publishing off unless EVENTS_SINK is set.
+func loadEventsConfig() (events.Config, error) {
+	cfg := events.Config{
+		Sink:   os.Getenv("EVENTS_SINK"),
+		Target: os.Getenv("EVENTS_TARGET"),
+		Source: os.Getenv("EVENTS_SOURCE"),
+	}
+
+	timeout, err := durationEnv("EVENTS_TIMEOUT", 0)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Timeout = timeout
+
+	if err := cfg.Validate(); err != nil {
+		return cfg, fmt.Errorf("invalid events config: %w", err)
+	}
+	return cfg, nil
+}
+
+// loadImportConfig applies the upload size limit to imports too, and leaves
+// unset values zero for the fetch package's defaults.
+func loadImportConfig(maxBytes int64) (fetch.Config, error) {
+	cfg := fetch.Config{MaxBytes: maxBytes}
+
+	maxRedirects, err := intEnv("IMPORT_MAX_REDIRECTS", 0)
+	if err != nil {
+		return cfg, err
+	}
+	if maxRedirects < 0 {
+		return cfg, fmt.Errorf("invalid IMPORT_MAX_REDIRECTS: must not be negative")
+	}
+	cfg.MaxRedirects = int(maxRedirects)
+
+	if cfg.Timeout, err = durationEnv("IMPORT_TIMEOUT", 0); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// loadIdempotencyConfig lets request bodies be as large as the largest
+// upload, and leaves unset values zero for the idempotency package's
+// defaults.
+func loadIdempotencyConfig(maxUploadBytes int64) (idempotency.Config, error) {
+	var cfg idempotency.Config
+	if maxUploadBytes > 0 {
+		cfg.MaxBodyBytes = maxUploadBytes + 1<<20
+	}
+
+	var err error
+	if cfg.TTL, err = durationEnv("IDEMPOTENCY_TTL", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.LockTimeout, err = durationEnv("IDEMPOTENCY_LOCK_TIMEOUT", 0); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+func durationEnv(name string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return d, nil
+}
+
+func limitEnv(name string, fallback string) (ratelimit.Limit, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		value = fallback
+	}
+	limit, err := ratelimit.ParseLimit(value)
+	if err != nil {
+		return ratelimit.Limit{}, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return limit, nil
+}
+
+func floatEnv(name string, fallback float64) (float64, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return f, nil
+}
+
+func intEnv(name string, fallback int64) (int64, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return i, nil
+}
+
+func boolEnv(name string, fallback bool) (bool, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return b, nil
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ryanschneiderman/video-api/internal/app"
 )
@@ -15,6 +16,7 @@ func TestInitializeApp_Success(t *testing.T) {
 	os.Setenv("S3_BUCKET", "test-bucket")
 	os.Setenv("SQS_QUEUE_URL", "http://test-queue")
 	os.Setenv("AWS_REGION", "us-east-1")
+	fakeModels(t)
 	defer func() {
 		os.Unsetenv("DYNAMODB_TABLE")
 		os.Unsetenv("S3_BUCKET")
@@ -39,6 +41,14 @@ func TestInitializeApp_Success(t *testing.T) {
 	}
 }
 
+// fakeModels picks the canned model clients, which have to be chosen
+// explicitly.
+func fakeModels(t *testing.T) {
+	t.Setenv("ANALYZER", "fake")
+	t.Setenv("TRANSCRIBER", "fake")
+	t.Setenv("EMBEDDER", "fake")
+}
+
 func TestInitializeApp_MissingEnv(t *testing.T) {
 	os.Unsetenv("DYNAMODB_TABLE")
 	os.Unsetenv("S3_BUCKET")
@@ -55,3 +65,123 @@ func TestInitializeApp_MissingEnv(t *testing.T) {
 		t.Errorf("expected error to mention missing DYNAMODB_TABLE, got: %v", err)
 	}
 }
+
+func TestInitializeApp_OIDCRequiresIssuerAndAudience(t *testing.T) {
+	os.Setenv("DYNAMODB_TABLE", "test-table")
+	os.Setenv("S3_BUCKET", "test-bucket")
+	os.Setenv("SQS_QUEUE_URL", "http://test-queue")
+	os.Setenv("AWS_REGION", "us-east-1")
+	fakeModels(t)
+	os.Setenv("OIDC_JWKS_URL", "https://id.example.com/.well-known/jwks.json")
+	defer func() {
+		os.Unsetenv("DYNAMODB_TABLE")
+		os.Unsetenv("S3_BUCKET")
+		os.Unsetenv("SQS_QUEUE_URL")
+		os.Unsetenv("AWS_REGION")
+		os.Unsetenv("OIDC_JWKS_URL")
+		os.Unsetenv("OIDC_ISSUER")
+		os.Unsetenv("OIDC_AUDIENCE")
+	}()
+
+	_, err := app.InitializeApp(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "OIDC_ISSUER") {
+		t.Fatalf("expected an error about OIDC_ISSUER, got: %v", err)
+	}
+
+	os.Setenv("OIDC_ISSUER", "https://id.example.com/")
+	os.Setenv("OIDC_AUDIENCE", "video-api")
+	a, err := app.InitializeApp(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !a.OIDC.Enabled() || a.OIDC.Audience != "video-api" {
+		t.Errorf("expected OIDC to be enabled for audience video-api, got: %+v", a.OIDC)
+	}
+}
+
+func TestInitializeApp_PlaybackTokenMode(t *testing.T) {
+	os.Setenv("DYNAMODB_TABLE", "test-table")
+	os.Setenv("S3_BUCKET", "test-bucket")
+	os.Setenv("SQS_QUEUE_URL", "http://test-queue")
+	os.Setenv("AWS_REGION", "us-east-1")
+	fakeModels(t)
+	os.Setenv("PLAYBACK_URL_MODE", "token")
+	defer func() {
+		os.Unsetenv("DYNAMODB_TABLE")
+		os.Unsetenv("S3_BUCKET")
+		os.Unsetenv("SQS_QUEUE_URL")
+		os.Unsetenv("AWS_REGION")
+		os.Unsetenv("PLAYBACK_URL_MODE")
+		os.Unsetenv("PLAYBACK_TOKEN_SECRET")
+		os.Unsetenv("PLAYBACK_URL_TTL")
+	}()
+
+	_, err := app.InitializeApp(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "secret") {
+		t.Fatalf("expected an error about the token secret, got: %v", err)
+	}
+
+	os.Setenv("PLAYBACK_TOKEN_SECRET", strings.Repeat("s", 32))
+	os.Setenv("PLAYBACK_URL_TTL", "5m")
+	a, err := app.InitializeApp(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if a.Playback.Mode != "token" || a.Playback.TTL != 5*time.Minute {
+		t.Errorf("expected token mode with a 5m TTL, got: %+v", a.Playback)
+	}
+}
+
+func TestInitializeApp_EventsSink(t *testing.T) {
+	os.Setenv("DYNAMODB_TABLE", "test-table")
+	os.Setenv("S3_BUCKET", "test-bucket")
+	os.Setenv("SQS_QUEUE_URL", "http://test-queue")
+	os.Setenv("AWS_REGION", "us-east-1")
+	fakeModels(t)
+	os.Setenv("EVENTS_SINK", "sns")
+	defer func() {
+		os.Unsetenv("DYNAMODB_TABLE")
+		os.Unsetenv("S3_BUCKET")
+		os.Unsetenv("SQS_QUEUE_URL")
+		os.Unsetenv("AWS_REGION")
+		os.Unsetenv("EVENTS_SINK")
+		os.Unsetenv("EVENTS_TARGET")
+	}()
+
+	_, err := app.InitializeApp(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "target") {
+		t.Fatalf("expected an error about the sink target, got: %v", err)
+	}
+
+	os.Setenv("EVENTS_TARGET", "arn:aws:sns:us-east-1:123456789012:video-events")
+	a, err := app.InitializeApp(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !a.Events.Enabled() || a.Events.Target != "arn:aws:sns:us-east-1:123456789012:video-events" {
+		t.Errorf("expected the SNS sink to be configured, got: %+v", a.Events)
+	}
+}
+
+func TestInitializeApp_ModelsRequireEndpointOrFake(t *testing.T) {
+	t.Setenv("DYNAMODB_TABLE", "test-table")
+	t.Setenv("S3_BUCKET", "test-bucket")
+	t.Setenv("SQS_QUEUE_URL", "http://test-queue")
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("TRANSCRIBER", "fake")
+	t.Setenv("EMBEDDER", "fake")
+
+	_, err := app.InitializeApp(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "ANALYZER_ENDPOINT") {
+		t.Fatalf("expected an error about ANALYZER_ENDPOINT, got: %v", err)
+	}
+
+	t.Setenv("ANALYZER_ENDPOINT", "https://models.example.com/analyze")
+	a, err := app.InitializeApp(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if a.Analyzer.Name != "http" || a.Transcriber.Name != "fake" {
+		t.Errorf("expected the http analyzer and the fake transcriber, got: %s and %s", a.Analyzer.Name, a.Transcriber.Name)
+	}
+}
@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryKeyStore map[string]*db.APIKey
+
+func (m memoryKeyStore) GetAPIKey(ctx context.Context, hash string) (*db.APIKey, error) {
+	if hash == HashKey("vapi_broken") {
+		return nil, errors.New("dynamodb unavailable")
+	}
+	key, ok := m[hash]
+	if !ok {
+		return nil, db.ErrAPIKeyNotFound
+	}
+	return key, nil
+}
+
+func TestGenerateKey(t *testing.T) {
+	a, err := GenerateKey()
+	assert.NoError(t, err)
+	b, err := GenerateKey()
+	assert.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(a, keyPrefix))
+	assert.NotEqual(t, a, b)
+	assert.Len(t, HashKey(a), 64)
+	assert.Equal(t, HashKey(a), HashKey(a))
+}
+
+func TestMiddlewareAPIKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	revokedAt := time.Now()
+	keys := memoryKeyStore{
+		HashKey("vapi_good"):    {TenantID: "acme"},
+		HashKey("vapi_revoked"): {TenantID: "acme", RevokedAt: &revokedAt},
+		HashKey("vapi_reader"):  {TenantID: "globex", Scopes: []string{ScopeRead}},
+	}
+
+	router := gin.New()
+	router.Use(Middleware(keys, nil))
+	router.GET("/whoami", func(c *gin.Context) {
+		assert.Equal(t, "key:"+HashKey(c.GetHeader("X-API-Key")+strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")), CallerID(c))
+		c.String(http.StatusOK, TenantID(c)+" "+strings.Join(Scopes(c), ","))
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		status int
+		body   string
+	}{
+		{"bearer", "Authorization", "Bearer vapi_good", http.StatusOK, "acme videos:read,videos:write"},
+		{"api key header", "X-API-Key", "vapi_good", http.StatusOK, "acme videos:read,videos:write"},
+		{"scoped key", "X-API-Key", "vapi_reader", http.StatusOK, "globex videos:read"},
+		{"token without oidc", "Authorization", "Bearer a.b.c", http.StatusUnauthorized, "Invalid API key"},
+		{"missing", "", "", http.StatusUnauthorized, "Missing API key"},
+		{"basic auth", "Authorization", "Basic dXNlcjpwYXNz", http.StatusUnauthorized, "Missing API key"},
+		{"unknown", "X-API-Key", "vapi_nope", http.StatusUnauthorized, "Invalid API key"},
+		{"revoked", "X-API-Key", "vapi_revoked", http.StatusUnauthorized, "revoked"},
+		{"store error", "X-API-Key", "vapi_broken", http.StatusInternalServerError, "Failed to authenticate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Contains(t, w.Body.String(), tt.body)
+			if tt.status == http.StatusUnauthorized {
+				assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		granted []string
+		want    string
+		status  int
+	}{
+		{[]string{ScopeRead}, ScopeRead, http.StatusOK},
+		{[]string{ScopeRead}, ScopeWrite, http.StatusForbidden},
+		{[]string{ScopeAdmin}, ScopeWrite, http.StatusOK},
+		{nil, ScopeRead, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		router := gin.New()
+		router.GET("/", func(c *gin.Context) {
+			c.Set(scopesContextKey, tt.granted)
+		}, RequireScope(tt.want), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, tt.status, w.Code, "%v requiring %s", tt.granted, tt.want)
+		if tt.status == http.StatusForbidden {
+			assert.Contains(t, w.Header().Get("WWW-Authenticate"), `error="insufficient_scope"`)
+			assert.Contains(t, w.Body.String(), tt.want)
+		}
+	}
+}
+
+func TestParseScopes(t *testing.T) {
+	scopes, err := ParseScopes("videos:read, videos:write videos:read")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{ScopeRead, ScopeWrite}, scopes)
+
+	_, err = ParseScopes("videos:read,videos:delete")
+	assert.ErrorIs(t, err, ErrUnknownScope)
+}
@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	ErrUnknownKey      = errors.New("unknown signing key")
+	ErrKeysUnavailable = errors.New("signing keys unavailable")
+)
+
+const (
+	defaultJWKSRefresh = time.Hour
+	// minJWKSRefetch limits how often a token with an unknown key ID makes
+	// us refetch the key set, so made-up key IDs can't hammer the provider.
+	minJWKSRefetch = time.Minute
+	maxJWKSBytes   = 1 << 20
+)
+
+// signingKey is a public key from a key set and the algorithm it is
+// restricted to, if any.
+type signingKey struct {
+	key crypto.PublicKey
+	alg string
+}
+
+// JWKS is a cached JSON Web Key Set. Keys are refetched every
+// RefreshInterval, and early when a token names a key ID the cache doesn't
+// have, which is how providers roll out a new key. If a refresh fails the
+// cached keys stay in use.
+type JWKS struct {
+	URL             string
+	Client          *http.Client
+	RefreshInterval time.Duration
+
+	mu        sync.Mutex
+	keys      map[string]signingKey
+	fetched   time.Time
+	attempted time.Time
+	now       func() time.Time
+	refreshes singleflight.Group
+}
+
+func NewJWKS(url string, refreshInterval time.Duration) *JWKS {
+	if refreshInterval <= 0 {
+		refreshInterval = defaultJWKSRefresh
+	}
+	return &JWKS{
+		URL:             url,
+		Client:          &http.Client{Timeout: 10 * time.Second},
+		RefreshInterval: refreshInterval,
+		now:             time.Now,
+	}
+}
+
+// key returns the key with the given ID, refreshing the set if it is stale
+// or doesn't have the key. The cache is only locked to read and update it,
+// never during a fetch, and concurrent lookups share one refresh.
+func (s *JWKS) key(ctx context.Context, kid string) (signingKey, error) {
+	s.mu.Lock()
+	key, known := s.keys[kid]
+	due := !known || s.now().Sub(s.fetched) >= s.RefreshInterval
+	s.mu.Unlock()
+	if !due {
+		return key, nil
+	}
+
+	// The refresh is shared, so one caller giving up mustn't cancel it for
+	// the rest. The client's timeout still bounds it.
+	_, err, _ := s.refreshes.Do("", func() (any, error) {
+		return nil, s.refresh(context.WithoutCancel(ctx))
+	})
+
+	s.mu.Lock()
+	key, known = s.keys[kid]
+	s.mu.Unlock()
+	if err != nil {
+		if !known {
+			return signingKey{}, fmt.Errorf("%w: %v", ErrKeysUnavailable, err)
+		}
+		log.Printf("Failed to refresh JWKS, using cached keys: %v", err)
+	}
+	if !known {
+		return signingKey{}, fmt.Errorf("%w %q", ErrUnknownKey, kid)
+	}
+	return key, nil
+}
+
+// refresh refetches the key set, unless it was tried less than
+// minJWKSRefetch ago.
+func (s *JWKS) refresh(ctx context.Context) error {
+	s.mu.Lock()
+	now := s.now()
+	if !s.attempted.IsZero() && now.Sub(s.attempted) < minJWKSRefetch {
+		s.mu.Unlock()
+		return nil
+	}
+	s.attempted = now
+	s.mu.Unlock()
+
+	keys, err := s.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.keys = keys
+	s.fetched = now
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *JWKS) fetch(ctx context.Context) (map[string]signingKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxJWKSBytes)).Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]signingKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kid == "" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			log.Printf("Skipping JWKS key %q: %v", k.Kid, err)
+			continue
+		}
+		keys[k.Kid] = signingKey{key: pub, alg: k.Alg}
+	}
+	return keys, nil
+}
+
+// jwk is a JSON Web Key. Only RSA and P-256 EC keys are supported.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid exponent")
+		}
+		if n.BitLen() < 2048 {
+			return nil, fmt.Errorf("RSA key of %d bits is too small", n.BitLen())
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x coordinate: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y coordinate: %w", err)
+		}
+		if x.BitLen() > 256 || y.BitLen() > 256 {
+			return nil, fmt.Errorf("point is not on the curve")
+		}
+		point := make([]byte, 65)
+		point[0] = 4
+		x.FillBytes(point[1:33])
+		y.FillBytes(point[33:])
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return nil, fmt.Errorf("point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ryanschneiderman/video-api/internal/tenant"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// clockSkew is how far exp and nbf may be off before a token is rejected.
+const clockSkew = time.Minute
+
+// OIDCConfig configures bearer token authentication. It is disabled when
+// JWKSURL is empty.
+type OIDCConfig struct {
+	Issuer   string
+	Audience string
+	JWKSURL  string
+	// TenantClaim names the claim holding the caller's tenant ID.
+	TenantClaim string
+	// ScopeClaim names the claim holding the caller's scopes, either a space
+	// separated string or a list.
+	ScopeClaim      string
+	RefreshInterval time.Duration
+}
+
+func (cfg OIDCConfig) Enabled() bool {
+	return cfg.JWKSURL != ""
+}
+
+// Identity is an authenticated token's subject, tenant and scopes.
+type Identity struct {
+	Subject  string
+	TenantID string
+	Scopes   []string
+}
+
+// Verifier checks the signature, issuer, audience and lifetime of JWTs
+// issued by an OIDC provider. Tokens must be signed with RS256 or ES256 by
+// a key in the provider's key set and name it in their kid header.
+type Verifier struct {
+	Config OIDCConfig
+	Keys   *JWKS
+	now    func() time.Time
+}
+
+func NewVerifier(cfg OIDCConfig) *Verifier {
+	if cfg.TenantClaim == "" {
+		cfg.TenantClaim = "tenant_id"
+	}
+	if cfg.ScopeClaim == "" {
+		cfg.ScopeClaim = "scope"
+	}
+	return &Verifier{
+		Config: cfg,
+		Keys:   NewJWKS(cfg.JWKSURL, cfg.RefreshInterval),
+		now:    time.Now,
+	}
+}
+
+// Verify returns the identity of a valid token. Invalid tokens fail with
+// ErrInvalidToken; ErrKeysUnavailable means the key set couldn't be fetched
+// to check one.
+func (v *Verifier) Verify(ctx context.Context, token string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidToken)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: bad header: %v", ErrInvalidToken, err)
+	}
+	key, err := v.Keys.key(ctx, header.Kid)
+	if errors.Is(err, ErrUnknownKey) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad signature encoding", ErrInvalidToken)
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: bad claims: %v", ErrInvalidToken, err)
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	tenantID, _ := claims[v.Config.TenantClaim].(string)
+	if err := tenant.ValidateID(tenantID); err != nil {
+		return nil, fmt.Errorf("%w: %s claim: %v", ErrInvalidToken, v.Config.TenantClaim, err)
+	}
+	subject, _ := claims["sub"].(string)
+	return &Identity{
+		Subject:  subject,
+		TenantID: tenantID,
+		Scopes:   knownScopesOf(stringList(claims[v.Config.ScopeClaim])),
+	}, nil
+}
+
+func (v *Verifier) checkClaims(claims map[string]any) error {
+	if iss, _ := claims["iss"].(string); iss != v.Config.Issuer {
+		return fmt.Errorf("unexpected issuer %q", iss)
+	}
+	if !slices.Contains(stringList(claims["aud"]), v.Config.Audience) {
+		return fmt.Errorf("audience does not include %q", v.Config.Audience)
+	}
+
+	now := v.now()
+	exp, ok := numericDate(claims["exp"])
+	if !ok {
+		return errors.New("missing exp")
+	}
+	if now.After(exp.Add(clockSkew)) {
+		return errors.New("token has expired")
+	}
+	if nbf, ok := numericDate(claims["nbf"]); ok && now.Add(clockSkew).Before(nbf) {
+		return errors.New("token is not valid yet")
+	}
+	return nil
+}
+
+// verifySignature checks the signature with the algorithm the header names,
+// which must match the key's type and the algorithm the key set allows.
+// Anything but RS256 and ES256, "none" included, is rejected.
+func verifySignature(alg string, key signingKey, signed string, signature []byte) error {
+	if key.alg != "" && key.alg != alg {
+		return fmt.Errorf("key does not allow algorithm %q", alg)
+	}
+	digest := sha256.Sum256([]byte(signed))
+	switch alg {
+	case "RS256":
+		pub, ok := key.key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("RS256 token signed with a non-RSA key")
+		}
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature); err != nil {
+			return errors.New("signature mismatch")
+		}
+		return nil
+	case "ES256":
+		pub, ok := key.key.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("ES256 token signed with a non-EC key")
+		}
+		if len(signature) != 64 {
+			return errors.New("signature mismatch")
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(pub, digest[:], r, s) {
+			return errors.New("signature mismatch")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported algorithm %q", alg)
+}
+
+func decodeSegment(segment string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// stringList reads a claim that may be a single string, split on spaces as
+// the scope claim is, or a list of strings.
+func stringList(claim any) []string {
+	switch v := claim.(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func numericDate(claim any) (time.Time, bool) {
+	seconds, ok := claim.(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(seconds), 0), true
+}
@@ -0,0 +1,393 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testIssuer   = "https://id.example.com/"
+	testAudience = "video-api"
+)
+
+// jwksStub serves a key set that tests can rotate, counting fetches.
+type jwksStub struct {
+	mu      sync.Mutex
+	keys    []jwk
+	fetches int
+	fail    bool
+}
+
+func (s *jwksStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetches++
+	if s.fail {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"keys": s.keys})
+}
+
+func (s *jwksStub) serve(keys ...jwk) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+func (s *jwksStub) fetchCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetches
+}
+
+type testSigner struct {
+	kid string
+	alg string
+	key crypto.Signer
+}
+
+func newRSASigner(t *testing.T, kid string) testSigner {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	return testSigner{kid: kid, alg: "RS256", key: key}
+}
+
+func newECSigner(t *testing.T, kid string) testSigner {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate EC key: %v", err)
+	}
+	return testSigner{kid: kid, alg: "ES256", key: key}
+}
+
+func (s testSigner) jwk() jwk {
+	enc := base64.RawURLEncoding.EncodeToString
+	switch key := s.key.(type) {
+	case *rsa.PrivateKey:
+		return jwk{Kty: "RSA", Kid: s.kid, Use: "sig", Alg: s.alg, N: enc(key.N.Bytes()), E: enc(big.NewInt(int64(key.E)).Bytes())}
+	case *ecdsa.PrivateKey:
+		ecdhKey, _ := key.PublicKey.ECDH()
+		point := ecdhKey.Bytes()
+		return jwk{Kty: "EC", Kid: s.kid, Crv: "P-256", X: enc(point[1:33]), Y: enc(point[33:])}
+	}
+	return jwk{}
+}
+
+func (s testSigner) sign(t *testing.T, header map[string]any, claims map[string]any) string {
+	enc := func(v any) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("failed to marshal token segment: %v", err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	if header == nil {
+		header = map[string]any{"alg": s.alg, "kid": s.kid, "typ": "JWT"}
+	}
+	signed := enc(header) + "." + enc(claims)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch key := s.key.(type) {
+	case *rsa.PrivateKey:
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatalf("failed to sign token: %v", err)
+		}
+		signature = sig
+	case *ecdsa.PrivateKey:
+		r, sv, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatalf("failed to sign token: %v", err)
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		sv.FillBytes(signature[32:])
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+var testNow = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func validClaims() map[string]any {
+	return map[string]any{
+		"iss":       testIssuer,
+		"aud":       testAudience,
+		"sub":       "user-1",
+		"exp":       testNow.Add(time.Hour).Unix(),
+		"iat":       testNow.Unix(),
+		"tenant_id": "acme",
+		"scope":     "openid profile videos:read videos:write",
+	}
+}
+
+// newTestVerifier returns a verifier backed by a stub key set and a clock the
+// test can move.
+func newTestVerifier(t *testing.T, stub *jwksStub) (*Verifier, *time.Time) {
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+
+	now := testNow
+	v := NewVerifier(OIDCConfig{Issuer: testIssuer, Audience: testAudience, JWKSURL: server.URL})
+	v.now = func() time.Time { return now }
+	v.Keys.now = func() time.Time { return now }
+	return v, &now
+}
+
+func TestVerifierAcceptsValidTokens(t *testing.T) {
+	rsaSigner := newRSASigner(t, "rsa-1")
+	ecSigner := newECSigner(t, "ec-1")
+	stub := &jwksStub{}
+	stub.serve(rsaSigner.jwk(), ecSigner.jwk())
+	v, _ := newTestVerifier(t, stub)
+
+	for _, signer := range []testSigner{rsaSigner, ecSigner} {
+		identity, err := v.Verify(context.Background(), signer.sign(t, nil, validClaims()))
+		if !assert.NoError(t, err, signer.alg) {
+			continue
+		}
+		assert.Equal(t, "user-1", identity.Subject)
+		assert.Equal(t, "acme", identity.TenantID)
+		assert.Equal(t, []string{ScopeRead, ScopeWrite}, identity.Scopes)
+	}
+	assert.Equal(t, 1, stub.fetchCount(), "key set should be cached")
+
+	// A list audience and a list scope claim work as well.
+	claims := validClaims()
+	claims["aud"] = []string{"other-api", testAudience}
+	claims["scope"] = []string{ScopeAdmin}
+	identity, err := v.Verify(context.Background(), rsaSigner.sign(t, nil, claims))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{ScopeAdmin}, identity.Scopes)
+	}
+}
+
+func TestVerifierRejectsInvalidTokens(t *testing.T) {
+	signer := newRSASigner(t, "rsa-1")
+	other := newRSASigner(t, "rsa-1")
+	stub := &jwksStub{}
+	stub.serve(signer.jwk())
+	v, _ := newTestVerifier(t, stub)
+
+	with := func(name string, value any) map[string]any {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+	tampered := strings.Split(signer.sign(t, nil, validClaims()), ".")
+	tampered[1] = strings.Split(signer.sign(t, nil, with("tenant_id", "globex")), ".")[1]
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"malformed", "not-a-token"},
+		{"expired", signer.sign(t, nil, with("exp", testNow.Add(-2*time.Minute).Unix()))},
+		{"missing exp", signer.sign(t, nil, with("exp", nil))},
+		{"not yet valid", signer.sign(t, nil, with("nbf", testNow.Add(5*time.Minute).Unix()))},
+		{"wrong issuer", signer.sign(t, nil, with("iss", "https://evil.example.com/"))},
+		{"wrong audience", signer.sign(t, nil, with("aud", "other-api"))},
+		{"missing tenant", signer.sign(t, nil, with("tenant_id", nil))},
+		{"invalid tenant", signer.sign(t, nil, with("tenant_id", "../globex"))},
+		{"unknown kid", signer.sign(t, map[string]any{"alg": "RS256", "kid": "rsa-2"}, validClaims())},
+		{"alg none", signer.sign(t, map[string]any{"alg": "none", "kid": "rsa-1"}, validClaims())},
+		{"alg mismatch", signer.sign(t, map[string]any{"alg": "ES256", "kid": "rsa-1"}, validClaims())},
+		{"wrong key", other.sign(t, nil, validClaims())},
+		{"tampered claims", strings.Join(tampered, ".")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.Verify(context.Background(), tt.token)
+			assert.ErrorIs(t, err, ErrInvalidToken)
+		})
+	}
+
+	// Small clock skew is tolerated.
+	_, err := v.Verify(context.Background(), signer.sign(t, nil, with("exp", testNow.Add(-30*time.Second).Unix())))
+	assert.NoError(t, err)
+}
+
+func TestJWKSKeyRotation(t *testing.T) {
+	oldSigner := newRSASigner(t, "old")
+	newSigner := newECSigner(t, "new")
+	stub := &jwksStub{}
+	stub.serve(oldSigner.jwk())
+	v, now := newTestVerifier(t, stub)
+	ctx := context.Background()
+
+	_, err := v.Verify(ctx, oldSigner.sign(t, nil, validClaims()))
+	assert.NoError(t, err)
+
+	// The provider rotates to a new key. Tokens with its kid trigger a
+	// refetch, but not more than once a minute.
+	stub.serve(newSigner.jwk())
+	*now = now.Add(10 * time.Second)
+	_, err = v.Verify(ctx, newSigner.sign(t, nil, validClaims()))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	assert.Equal(t, 1, stub.fetchCount())
+
+	*now = now.Add(2 * time.Minute)
+	_, err = v.Verify(ctx, newSigner.sign(t, nil, validClaims()))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stub.fetchCount())
+
+	// The retired key is gone from the cache.
+	_, err = v.Verify(ctx, oldSigner.sign(t, nil, validClaims()))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	// Once the refresh interval passes the set is refetched even for a
+	// known kid, and a failed refresh keeps the cached keys.
+	stub.mu.Lock()
+	stub.fail = true
+	stub.mu.Unlock()
+	*now = now.Add(2 * time.Hour)
+	claims := validClaims()
+	claims["exp"] = now.Add(time.Hour).Unix()
+	_, err = v.Verify(ctx, newSigner.sign(t, nil, claims))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, stub.fetchCount())
+}
+
+func TestJWKSUnavailable(t *testing.T) {
+	signer := newRSASigner(t, "rsa-1")
+	stub := &jwksStub{fail: true}
+	v, _ := newTestVerifier(t, stub)
+
+	_, err := v.Verify(context.Background(), signer.sign(t, nil, validClaims()))
+	assert.ErrorIs(t, err, ErrKeysUnavailable)
+	assert.NotErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestJWKSRefreshDoesNotBlockCachedKeys(t *testing.T) {
+	oldSigner := newRSASigner(t, "old")
+	newSigner := newECSigner(t, "new")
+	stub := &jwksStub{}
+	stub.serve(oldSigner.jwk())
+	var slow atomic.Bool
+	fetching := make(chan struct{}, 1)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slow.Load() {
+			fetching <- struct{}{}
+			<-release
+		}
+		stub.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	keys := NewJWKS(server.URL, time.Hour)
+	now := testNow
+	keys.now = func() time.Time { return now }
+	ctx := context.Background()
+	_, err := keys.key(ctx, "old")
+	assert.NoError(t, err)
+
+	// The provider rotates in a new key and is slow to serve it. Lookups of
+	// the new kid share one refetch.
+	stub.serve(oldSigner.jwk(), newSigner.jwk())
+	slow.Store(true)
+	now = now.Add(2 * time.Minute)
+	errs := make([]error, 5)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = keys.key(ctx, "new")
+		}()
+	}
+	<-fetching
+
+	// A cached key is served while the refetch is in flight.
+	cached := make(chan error, 1)
+	go func() {
+		_, err := keys.key(ctx, "old")
+		cached <- err
+	}()
+	select {
+	case err := <-cached:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("lookup of a cached key waited for the refetch")
+	}
+
+	close(release)
+	wg.Wait()
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 2, stub.fetchCount())
+}
+
+func TestMiddlewareBearerTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	signer := newRSASigner(t, "rsa-1")
+	stub := &jwksStub{}
+	stub.serve(signer.jwk())
+	v, _ := newTestVerifier(t, stub)
+	keys := memoryKeyStore{HashKey("vapi_good"): {TenantID: "globex"}}
+
+	router := gin.New()
+	router.Use(Middleware(keys, v))
+	router.GET("/videos", RequireScope(ScopeRead), func(c *gin.Context) {
+		c.String(http.StatusOK, TenantID(c)+" "+CallerID(c))
+	})
+	router.POST("/videos", RequireScope(ScopeWrite), func(c *gin.Context) {
+		c.String(http.StatusCreated, TenantID(c))
+	})
+
+	readOnly := validClaims()
+	readOnly["scope"] = "openid videos:read"
+	tests := []struct {
+		name       string
+		method     string
+		credential string
+		status     int
+		body       string
+	}{
+		{"read", http.MethodGet, signer.sign(t, nil, validClaims()), http.StatusOK, "acme token:acme:user-1"},
+		{"write", http.MethodPost, signer.sign(t, nil, validClaims()), http.StatusCreated, "acme"},
+		{"read-only token writes", http.MethodPost, signer.sign(t, nil, readOnly), http.StatusForbidden, "requires videos:write"},
+		{"invalid token", http.MethodGet, "a.b.c", http.StatusUnauthorized, "Invalid bearer token"},
+		{"api key as bearer", http.MethodGet, "vapi_good", http.StatusOK, "globex"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/videos", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.credential)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Contains(t, w.Body.String(), tt.body)
+			if tt.status == http.StatusUnauthorized {
+				assert.Contains(t, w.Header().Get("WWW-Authenticate"), `error="invalid_token"`)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ryanschneiderman/video-api/internal/db"
+)
+
+// Gin context keys holding the caller's tenant ID and a stable identifier
+// for the caller's credential.
+const (
+	tenantContextKey = "auth.tenantID"
+	callerContextKey = "auth.caller"
+)
+
+// KeyStore looks up stored API keys by hash. db.DB implements it.
+type KeyStore interface {
+	GetAPIKey(ctx context.Context, hash string) (*db.APIKey, error)
+}
+
+// Middleware rejects requests without valid credentials and records the
+// caller's tenant and scopes for TenantID and Scopes. Callers pass an API
+// key as "X-API-Key: <key>" or "Authorization: Bearer <key>". When tokens is
+// not nil, bearer credentials that aren't API keys are verified as OIDC
+// tokens.
+func Middleware(keys KeyStore, tokens *Verifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		credential, bearer := requestCredential(c.Request)
+		if credential == "" {
+			unauthorized(c, "Missing API key or bearer token")
+			return
+		}
+
+		if bearer && tokens != nil && !strings.HasPrefix(credential, keyPrefix) {
+			authenticateToken(c, tokens, credential)
+		} else {
+			authenticateKey(c, keys, credential)
+		}
+		if !c.IsAborted() {
+			c.Next()
+		}
+	}
+}
+
+func authenticateKey(c *gin.Context, keys KeyStore, key string) {
+	hash := HashKey(key)
+	stored, err := keys.GetAPIKey(c.Request.Context(), hash)
+	if errors.Is(err, db.ErrAPIKeyNotFound) {
+		unauthorized(c, "Invalid API key")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to look up API key: %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to authenticate request"})
+		return
+	}
+	if stored.Revoked() {
+		unauthorized(c, "API key has been revoked")
+		return
+	}
+
+	scopes := stored.Scopes
+	if len(scopes) == 0 {
+		scopes = defaultKeyScopes
+	}
+	c.Set(tenantContextKey, stored.TenantID)
+	c.Set(callerContextKey, "key:"+hash)
+	c.Set(scopesContextKey, scopes)
+}
+
+func authenticateToken(c *gin.Context, tokens *Verifier, token string) {
+	identity, err := tokens.Verify(c.Request.Context(), token)
+	if errors.Is(err, ErrInvalidToken) {
+		log.Printf("Rejected bearer token: %v", err)
+		c.Header("WWW-Authenticate", `Bearer realm="video-api", error="invalid_token"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid bearer token"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to verify bearer token: %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to authenticate request"})
+		return
+	}
+
+	c.Set(tenantContextKey, identity.TenantID)
+	c.Set(callerContextKey, "token:"+identity.TenantID+":"+identity.Subject)
+	c.Set(scopesContextKey, identity.Scopes)
+}
+
+// TenantID returns the tenant of the authenticated caller, or "" on routes
+// the middleware doesn't guard.
+func TenantID(c *gin.Context) string {
+	return c.GetString(tenantContextKey)
+}
+
+// requestCredential returns the request's credential and whether it came
+// from the Authorization header.
+// CallerID identifies the credential the caller authenticated with: the API
+// key, or the token's tenant and subject. It is stable across requests, for
+// per-caller rate limits.
+func CallerID(c *gin.Context) string {
+	return c.GetString(callerContextKey)
+}
+
+func requestCredential(r *http.Request) (string, bool) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key, false
+	}
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token), true
+	}
+	return "", false
+}
+
+func unauthorized(c *gin.Context, message string) {
+	c.Header("WWW-Authenticate", `Bearer realm="video-api"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": message})
+}
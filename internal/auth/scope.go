@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Scopes a caller can be granted. ScopeAdmin implies the others.
+const (
+	ScopeRead  = "videos:read"
+	ScopeWrite = "videos:write"
+	ScopeAdmin = "videos:admin"
+)
+
+// scopesContextKey is the gin context key holding the caller's scopes.
+const scopesContextKey = "auth.scopes"
+
+var ErrUnknownScope = errors.New("unknown scope")
+
+var knownScopes = []string{ScopeRead, ScopeWrite, ScopeAdmin}
+
+// defaultKeyScopes are granted to API keys stored without scopes, which
+// includes every key created before scopes existed.
+var defaultKeyScopes = []string{ScopeRead, ScopeWrite}
+
+// ParseScopes parses a comma or space separated list of scopes.
+func ParseScopes(value string) ([]string, error) {
+	fields := strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' })
+	scopes := make([]string, 0, len(fields))
+	for _, scope := range fields {
+		if !slices.Contains(knownScopes, scope) {
+			return nil, fmt.Errorf("%w %q: must be one of %s", ErrUnknownScope, scope, strings.Join(knownScopes, ", "))
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
+
+// RequireScope rejects callers that weren't granted scope with 403. It must
+// run after Middleware.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !hasScope(Scopes(c), scope) {
+			c.Header("WWW-Authenticate", fmt.Sprintf(`Bearer realm="video-api", error="insufficient_scope", scope="%s"`, scope))
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient scope: requires " + scope})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Scopes returns the scopes granted to the authenticated caller.
+func Scopes(c *gin.Context) []string {
+	return c.GetStringSlice(scopesContextKey)
+}
+
+func hasScope(granted []string, scope string) bool {
+	return slices.Contains(granted, scope) || slices.Contains(granted, ScopeAdmin)
+}
+
+// knownScopesOf drops the scopes this API doesn't define, such as the
+// "openid" and "profile" scopes of an OIDC token.
+func knownScopesOf(scopes []string) []string {
+	var known []string
+	for _, scope := range scopes {
+		if slices.Contains(knownScopes, scope) && !slices.Contains(known, scope) {
+			known = append(known, scope)
+		}
+	}
+	return known
+}
@@ -0,0 +1,185 @@
+// Package changefeed tails the videos table's DynamoDB stream and turns its
+// records into domain changes, so reactions to a change don't depend on
+// which process made it.
+package changefeed
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	dbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams/types"
+	"github.com/ryanschneiderman/video-api/internal/db"
+)
+
+// Kinds of change. A single write can both change the status and update
+// the metadata.
+const (
+	KindCreated         = "created"
+	KindStatusChanged   = "status_changed"
+	KindMetadataUpdated = "metadata_updated"
+	KindDeleted         = "deleted"
+)
+
+// Metadata fields whose changes are reported as KindMetadataUpdated.
+const (
+	FieldTitle       = "title"
+	FieldDescription = "description"
+	FieldTags        = "tags"
+)
+
+// Change is one domain change of a video.
+type Change struct {
+	Kind    string
+	VideoID string
+	// Video is the video after the change, or before it for KindDeleted.
+	Video *db.Video
+	// Previous is the video before the change, and nil for KindCreated and
+	// KindDeleted.
+	Previous *db.Video
+	// Fields are the metadata fields that changed, for KindMetadataUpdated.
+	Fields []string
+	// SequenceNumber is the stream record's, and At is about when the write
+	// happened.
+	SequenceNumber string
+	At             time.Time
+}
+
+// Handler reacts to changes. An error stops the shard at that record, to be
+// retried from the last checkpoint.
+type Handler interface {
+	HandleChange(ctx context.Context, change Change) error
+}
+
+type HandlerFunc func(ctx context.Context, change Change) error
+
+func (f HandlerFunc) HandleChange(ctx context.Context, change Change) error {
+	return f(ctx, change)
+}
+
+// Changes turns a stream record into the changes it holds. The stream must
+// carry new and old images. A modification of anything else, such as a
+// processing checkpoint, holds no changes.
+func Changes(record types.Record) ([]Change, error) {
+	if record.Dynamodb == nil {
+		return nil, nil
+	}
+	base := Change{
+		SequenceNumber: aws.ToString(record.Dynamodb.SequenceNumber),
+		At:             aws.ToTime(record.Dynamodb.ApproximateCreationDateTime),
+	}
+	newImage, err := videoImage(record.Dynamodb.NewImage)
+	if err != nil {
+		return nil, err
+	}
+	oldImage, err := videoImage(record.Dynamodb.OldImage)
+	if err != nil {
+		return nil, err
+	}
+
+	switch record.EventName {
+	case types.OperationTypeInsert:
+		if newImage == nil {
+			return nil, fmt.Errorf("insert record %s has no new image", base.SequenceNumber)
+		}
+		base.Kind, base.VideoID, base.Video = KindCreated, newImage.VideoID, newImage
+		return []Change{base}, nil
+
+	case types.OperationTypeRemove:
+		if oldImage == nil {
+			return nil, fmt.Errorf("remove record %s has no old image", base.SequenceNumber)
+		}
+		base.Kind, base.VideoID, base.Video = KindDeleted, oldImage.VideoID, oldImage
+		return []Change{base}, nil
+
+	case types.OperationTypeModify:
+		if newImage == nil || oldImage == nil {
+			return nil, fmt.Errorf("modify record %s needs new and old images", base.SequenceNumber)
+		}
+		base.VideoID, base.Video, base.Previous = newImage.VideoID, newImage, oldImage
+
+		var changes []Change
+		if newImage.Status != oldImage.Status {
+			change := base
+			change.Kind = KindStatusChanged
+			changes = append(changes, change)
+		}
+		if fields := changedMetadata(oldImage, newImage); len(fields) > 0 {
+			change := base
+			change.Kind = KindMetadataUpdated
+			change.Fields = fields
+			changes = append(changes, change)
+		}
+		return changes, nil
+	}
+	return nil, nil
+}
+
+func changedMetadata(before, after *db.Video) []string {
+	var fields []string
+	if before.Title != after.Title {
+		fields = append(fields, FieldTitle)
+	}
+	if before.Description != after.Description {
+		fields = append(fields, FieldDescription)
+	}
+	if !slices.Equal(before.Tags, after.Tags) {
+		fields = append(fields, FieldTags)
+	}
+	return fields
+}
+
+func videoImage(image map[string]types.AttributeValue) (*db.Video, error) {
+	if len(image) == 0 {
+		return nil, nil
+	}
+	var video db.Video
+	if err := attributevalue.UnmarshalMap(convertMap(image), &video); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal video image: %w", err)
+	}
+	return &video, nil
+}
+
+// The streams API has its own copy of the attribute value types, which the
+// attributevalue package doesn't decode, so images are converted first.
+func convertMap(m map[string]types.AttributeValue) map[string]dbtypes.AttributeValue {
+	out := make(map[string]dbtypes.AttributeValue, len(m))
+	for k, v := range m {
+		out[k] = convert(v)
+	}
+	return out
+}
+
+func convert(v types.AttributeValue) dbtypes.AttributeValue {
+	switch v := v.(type) {
+	case *types.AttributeValueMemberS:
+		return &dbtypes.AttributeValueMemberS{Value: v.Value}
+	case *types.AttributeValueMemberN:
+		return &dbtypes.AttributeValueMemberN{Value: v.Value}
+	case *types.AttributeValueMemberB:
+		return &dbtypes.AttributeValueMemberB{Value: v.Value}
+	case *types.AttributeValueMemberBOOL:
+		return &dbtypes.AttributeValueMemberBOOL{Value: v.Value}
+	case *types.AttributeValueMemberNULL:
+		return &dbtypes.AttributeValueMemberNULL{Value: v.Value}
+	case *types.AttributeValueMemberSS:
+		return &dbtypes.AttributeValueMemberSS{Value: v.Value}
+	case *types.AttributeValueMemberNS:
+		return &dbtypes.AttributeValueMemberNS{Value: v.Value}
+	case *types.AttributeValueMemberBS:
+		return &dbtypes.AttributeValueMemberBS{Value: v.Value}
+	case *types.AttributeValueMemberL:
+		list := make([]dbtypes.AttributeValue, len(v.Value))
+		for i, item := range v.Value {
+			list[i] = convert(item)
+		}
+		return &dbtypes.AttributeValueMemberL{Value: list}
+	case *types.AttributeValueMemberM:
+		return &dbtypes.AttributeValueMemberM{Value: convertMap(v.Value)}
+	}
+	return &dbtypes.AttributeValueMemberNULL{Value: true}
+}
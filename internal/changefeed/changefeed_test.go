@@ -0,0 +1,282 @@
+package changefeed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams/types"
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/stretchr/testify/assert"
+)
+
+const testStream = "arn:aws:dynamodb:us-east-1:123456789012:table/videos/stream/2025-06-01T00:00:00.000"
+
+func s(v string) types.AttributeValue { return &types.AttributeValueMemberS{Value: v} }
+
+func videoItem(id, status, title string, tags ...string) map[string]types.AttributeValue {
+	item := map[string]types.AttributeValue{
+		"video_id":  s(id),
+		"tenant_id": s("acme"),
+		"title":     s(title),
+		"status":    s(status),
+		"checkpoints": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+			"probe": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{"done": &types.AttributeValueMemberBOOL{Value: true}}},
+		}},
+	}
+	list := make([]types.AttributeValue, len(tags))
+	for i, tag := range tags {
+		list[i] = s(tag)
+	}
+	item["tags"] = &types.AttributeValueMemberL{Value: list}
+	return item
+}
+
+func record(op types.OperationType, seq string, oldImage, newImage map[string]types.AttributeValue) types.Record {
+	return types.Record{
+		EventName: op,
+		Dynamodb: &types.StreamRecord{
+			SequenceNumber:              aws.String(seq),
+			ApproximateCreationDateTime: aws.Time(time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)),
+			OldImage:                    oldImage,
+			NewImage:                    newImage,
+		},
+	}
+}
+
+func TestChanges(t *testing.T) {
+	changes, err := Changes(record(types.OperationTypeInsert, "1", nil, videoItem("v1", db.StatusUploaded, "a.mp4")))
+	assert.NoError(t, err)
+	assert.Len(t, changes, 1)
+	assert.Equal(t, KindCreated, changes[0].Kind)
+	assert.Equal(t, "acme", changes[0].Video.TenantID)
+
+	// A write of both the status and the tags.
+	changes, err = Changes(record(types.OperationTypeModify, "2",
+		videoItem("v1", db.StatusProcessing, "a.mp4", "dog"),
+		videoItem("v1", db.StatusReady, "a.mp4", "dog", "cat")))
+	assert.NoError(t, err)
+	assert.Len(t, changes, 2)
+	assert.Equal(t, KindStatusChanged, changes[0].Kind)
+	assert.Equal(t, db.StatusProcessing, changes[0].Previous.Status)
+	assert.Equal(t, db.StatusReady, changes[0].Video.Status)
+	assert.Equal(t, KindMetadataUpdated, changes[1].Kind)
+	assert.Equal(t, []string{FieldTags}, changes[1].Fields)
+	assert.Equal(t, "2", changes[1].SequenceNumber)
+
+	// A processing checkpoint isn't a domain change.
+	changes, err = Changes(record(types.OperationTypeModify, "3",
+		videoItem("v1", db.StatusProcessing, "a.mp4"), videoItem("v1", db.StatusProcessing, "a.mp4")))
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+
+	changes, err = Changes(record(types.OperationTypeRemove, "4", videoItem("v1", db.StatusReady, "a.mp4"), nil))
+	assert.NoError(t, err)
+	assert.Equal(t, KindDeleted, changes[0].Kind)
+	assert.Equal(t, "v1", changes[0].VideoID)
+
+	_, err = Changes(record(types.OperationTypeModify, "5", nil, videoItem("v1", db.StatusReady, "a.mp4")))
+	assert.Error(t, err)
+}
+
+// fakeStream serves shards of records. Iterators are "<shard>/<index>", and
+// closed shards end with a nil next iterator.
+type fakeStream struct {
+	shards  []types.Shard
+	records map[string][]types.Record
+	open    map[string]bool
+	// expireNext makes the next GetRecords fail with an expired iterator.
+	expireNext bool
+	starts     []string
+}
+
+func (f *fakeStream) DescribeStream(ctx context.Context, in *dynamodbstreams.DescribeStreamInput, _ ...func(*dynamodbstreams.Options)) (*dynamodbstreams.DescribeStreamOutput, error) {
+	// One shard per page, to exercise paging.
+	start := 0
+	if in.ExclusiveStartShardId != nil {
+		for i, sh := range f.shards {
+			if *sh.ShardId == *in.ExclusiveStartShardId {
+				start = i + 1
+			}
+		}
+	}
+	desc := &types.StreamDescription{Shards: f.shards[start : start+1]}
+	if start+1 < len(f.shards) {
+		desc.LastEvaluatedShardId = f.shards[start].ShardId
+	}
+	return &dynamodbstreams.DescribeStreamOutput{StreamDescription: desc}, nil
+}
+
+func (f *fakeStream) GetShardIterator(ctx context.Context, in *dynamodbstreams.GetShardIteratorInput, _ ...func(*dynamodbstreams.Options)) (*dynamodbstreams.GetShardIteratorOutput, error) {
+	shardId := *in.ShardId
+	index := 0
+	if in.ShardIteratorType == types.ShardIteratorTypeAfterSequenceNumber {
+		for i, r := range f.records[shardId] {
+			if *r.Dynamodb.SequenceNumber == *in.SequenceNumber {
+				index = i + 1
+			}
+		}
+	}
+	f.starts = append(f.starts, fmt.Sprintf("%s/%s", shardId, in.ShardIteratorType))
+	return &dynamodbstreams.GetShardIteratorOutput{ShardIterator: aws.String(fmt.Sprintf("%s/%d", shardId, index))}, nil
+}
+
+func (f *fakeStream) GetRecords(ctx context.Context, in *dynamodbstreams.GetRecordsInput, _ ...func(*dynamodbstreams.Options)) (*dynamodbstreams.GetRecordsOutput, error) {
+	if f.expireNext {
+		f.expireNext = false
+		return nil, &types.ExpiredIteratorException{Message: aws.String("expired")}
+	}
+	shardId, indexText, _ := strings.Cut(*in.ShardIterator, "/")
+	index, _ := strconv.Atoi(indexText)
+	// Two records per read.
+	records := f.records[shardId][index:]
+	if len(records) > 2 {
+		records = records[:2]
+	}
+	out := &dynamodbstreams.GetRecordsOutput{Records: records}
+	next := index + len(records)
+	if next < len(f.records[shardId]) || f.open[shardId] {
+		out.NextShardIterator = aws.String(fmt.Sprintf("%s/%d", shardId, next))
+	}
+	return out, nil
+}
+
+type memoryCheckpoints map[string]db.StreamCheckpoint
+
+func (m memoryCheckpoints) GetStreamCheckpoints(ctx context.Context, streamArn string) (map[string]db.StreamCheckpoint, error) {
+	out := map[string]db.StreamCheckpoint{}
+	for k, v := range m {
+		out[k] = v
+	}
+	return out, nil
+}
+
+func (m memoryCheckpoints) PutStreamCheckpoint(ctx context.Context, cp db.StreamCheckpoint) error {
+	m[cp.ShardID] = cp
+	return nil
+}
+
+// statusRecords returns a record per status change of video, with
+// sequence numbers from first.
+func statusRecords(video string, first int, statuses ...string) []types.Record {
+	var records []types.Record
+	for i := 1; i < len(statuses); i++ {
+		records = append(records, record(types.OperationTypeModify, strconv.Itoa(first+i-1),
+			videoItem(video, statuses[i-1], "a.mp4"), videoItem(video, statuses[i], "a.mp4")))
+	}
+	return records
+}
+
+// splitStream has a closed parent shard whose records continue in its open
+// child. The child is listed first, so only the lineage orders them.
+func splitStream() *fakeStream {
+	return &fakeStream{
+		shards: []types.Shard{
+			{ShardId: aws.String("child"), ParentShardId: aws.String("parent")},
+			{ShardId: aws.String("parent")},
+		},
+		records: map[string][]types.Record{
+			"parent": statusRecords("v1", 100, db.StatusUploaded, db.StatusProcessing, db.StatusReady),
+			"child":  statusRecords("v1", 200, db.StatusReady, db.StatusFailed, db.StatusReady),
+		},
+		open: map[string]bool{"child": true},
+	}
+}
+
+func TestConsumerReadsParentBeforeChild(t *testing.T) {
+	stream := splitStream()
+	checkpoints := memoryCheckpoints{}
+	var seen []string
+	c := NewConsumer(stream, testStream, checkpoints, HandlerFunc(func(ctx context.Context, ch Change) error {
+		seen = append(seen, ch.SequenceNumber+":"+ch.Video.Status)
+		return nil
+	}))
+
+	for i := 0; i < 3; i++ {
+		_, err := c.Step(context.Background())
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, []string{"100:processing", "101:ready", "200:failed", "201:ready"}, seen)
+	assert.True(t, checkpoints["parent"].Finished)
+	assert.Equal(t, "101", checkpoints["parent"].SequenceNumber)
+	assert.False(t, checkpoints["child"].Finished)
+	assert.Equal(t, "201", checkpoints["child"].SequenceNumber)
+	assert.NotZero(t, checkpoints["child"].ExpiresAt)
+}
+
+func TestConsumerResumesFromCheckpoint(t *testing.T) {
+	stream := splitStream()
+	checkpoints := memoryCheckpoints{
+		"parent": {StreamARN: testStream, ShardID: "parent", SequenceNumber: "101", Finished: true},
+		"child":  {StreamARN: testStream, ShardID: "child", SequenceNumber: "200"},
+	}
+	var seen []string
+	c := NewConsumer(stream, testStream, checkpoints, HandlerFunc(func(ctx context.Context, ch Change) error {
+		seen = append(seen, ch.SequenceNumber)
+		return nil
+	}))
+
+	_, err := c.Step(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"201"}, seen)
+	assert.Equal(t, []string{"child/AFTER_SEQUENCE_NUMBER"}, stream.starts)
+}
+
+func TestConsumerRetriesAfterHandlerError(t *testing.T) {
+	stream := &fakeStream{
+		shards:  []types.Shard{{ShardId: aws.String("s1")}},
+		records: map[string][]types.Record{"s1": statusRecords("v1", 1, db.StatusUploaded, db.StatusProcessing, db.StatusReady)},
+		open:    map[string]bool{"s1": true},
+	}
+	checkpoints := memoryCheckpoints{}
+	failures := 1
+	var seen []string
+	c := NewConsumer(stream, testStream, checkpoints, HandlerFunc(func(ctx context.Context, ch Change) error {
+		if ch.SequenceNumber == "2" && failures > 0 {
+			failures--
+			return errors.New("sink down")
+		}
+		seen = append(seen, ch.SequenceNumber)
+		return nil
+	}))
+
+	_, err := c.Step(context.Background())
+	assert.ErrorContains(t, err, "sink down")
+	assert.Equal(t, "1", checkpoints["s1"].SequenceNumber)
+
+	_, err = c.Step(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, seen)
+	assert.Equal(t, "2", checkpoints["s1"].SequenceNumber)
+}
+
+func TestConsumerRenewsExpiredIterator(t *testing.T) {
+	stream := &fakeStream{
+		shards:  []types.Shard{{ShardId: aws.String("s1")}},
+		records: map[string][]types.Record{"s1": statusRecords("v1", 1, db.StatusUploaded, db.StatusProcessing)},
+		open:    map[string]bool{"s1": true},
+	}
+	var seen int
+	c := NewConsumer(stream, testStream, memoryCheckpoints{}, HandlerFunc(func(ctx context.Context, ch Change) error {
+		seen++
+		return nil
+	}))
+
+	_, err := c.Step(context.Background())
+	assert.NoError(t, err)
+	stream.expireNext = true
+	_, err = c.Step(context.Background())
+	assert.NoError(t, err)
+	_, err = c.Step(context.Background())
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, seen)
+	assert.Equal(t, []string{"s1/TRIM_HORIZON", "s1/AFTER_SEQUENCE_NUMBER"}, stream.starts)
+}
@@ -0,0 +1,282 @@
+package changefeed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams/types"
+	"github.com/ryanschneiderman/video-api/internal/db"
+)
+
+const (
+	defaultPollInterval    = time.Second
+	defaultRefreshInterval = time.Minute
+	// Stream records are kept for a day, so a checkpoint untouched for a
+	// week belongs to a shard that's long gone.
+	checkpointRetention = 7 * 24 * time.Hour
+	maxRecordsPerRead   = 1000
+)
+
+// StreamsAPI is the part of the DynamoDB Streams client the consumer uses.
+type StreamsAPI interface {
+	DescribeStream(ctx context.Context, params *dynamodbstreams.DescribeStreamInput, optFns ...func(*dynamodbstreams.Options)) (*dynamodbstreams.DescribeStreamOutput, error)
+	GetShardIterator(ctx context.Context, params *dynamodbstreams.GetShardIteratorInput, optFns ...func(*dynamodbstreams.Options)) (*dynamodbstreams.GetShardIteratorOutput, error)
+	GetRecords(ctx context.Context, params *dynamodbstreams.GetRecordsInput, optFns ...func(*dynamodbstreams.Options)) (*dynamodbstreams.GetRecordsOutput, error)
+}
+
+// CheckpointStore keeps shard positions across restarts.
+type CheckpointStore interface {
+	GetStreamCheckpoints(ctx context.Context, streamArn string) (map[string]db.StreamCheckpoint, error)
+	PutStreamCheckpoint(ctx context.Context, checkpoint db.StreamCheckpoint) error
+}
+
+// Consumer reads every shard of a stream, checkpointing after each batch of
+// records. When a shard splits, its children are only read once the parent
+// is finished, so each video's changes are handled in order. Delivery to
+// the handler is at least once: records after the last checkpoint are read
+// again after a restart or a handler error.
+type Consumer struct {
+	Client      StreamsAPI
+	StreamARN   string
+	Checkpoints CheckpointStore
+	Handler     Handler
+	// PollInterval is the wait between reads when no shard had records.
+	PollInterval time.Duration
+	// RefreshInterval is how often the shard list is described again, to
+	// find the children of splits.
+	RefreshInterval time.Duration
+
+	shards    map[string]*shard
+	order     []string
+	refreshed time.Time
+	now       func() time.Time
+}
+
+type shard struct {
+	id         string
+	parentID   string
+	iterator   *string
+	checkpoint db.StreamCheckpoint
+}
+
+func NewConsumer(client StreamsAPI, streamArn string, checkpoints CheckpointStore, handler Handler) *Consumer {
+	return &Consumer{
+		Client:          client,
+		StreamARN:       streamArn,
+		Checkpoints:     checkpoints,
+		Handler:         handler,
+		PollInterval:    defaultPollInterval,
+		RefreshInterval: defaultRefreshInterval,
+		shards:          map[string]*shard{},
+		now:             time.Now,
+	}
+}
+
+// Run consumes the stream until ctx is done.
+func (c *Consumer) Run(ctx context.Context) error {
+	for {
+		read, err := c.Step(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			var notFound *types.ResourceNotFoundException
+			if errors.As(err, &notFound) {
+				return fmt.Errorf("stream %s not found: %w", c.StreamARN, err)
+			}
+			log.Printf("Changefeed error: %v", err)
+		}
+		if read == 0 || err != nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(c.PollInterval):
+			}
+		}
+	}
+}
+
+// Step refreshes the shard list when it's due, then reads one batch from
+// each shard that's ready. It returns the number of records read.
+func (c *Consumer) Step(ctx context.Context) (int, error) {
+	if c.refreshed.IsZero() || c.now().Sub(c.refreshed) >= c.RefreshInterval {
+		if err := c.refresh(ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	total := 0
+	var errs []error
+	for _, id := range c.order {
+		s := c.shards[id]
+		if !c.ready(s) {
+			continue
+		}
+		read, err := c.read(ctx, s)
+		total += read
+		if err != nil {
+			errs = append(errs, fmt.Errorf("shard %s: %w", s.id, err))
+		}
+		if s.checkpoint.Finished {
+			// Its children may not be listed yet.
+			c.refreshed = time.Time{}
+		}
+	}
+	return total, errors.Join(errs...)
+}
+
+// refresh lists the stream's shards, oldest first, and loads their
+// checkpoints. Shards trimmed from the stream are forgotten.
+func (c *Consumer) refresh(ctx context.Context) error {
+	var listed []types.Shard
+	input := &dynamodbstreams.DescribeStreamInput{StreamArn: aws.String(c.StreamARN)}
+	for {
+		out, err := c.Client.DescribeStream(ctx, input)
+		if err != nil {
+			return fmt.Errorf("failed to describe stream: %w", err)
+		}
+		listed = append(listed, out.StreamDescription.Shards...)
+		if out.StreamDescription.LastEvaluatedShardId == nil {
+			break
+		}
+		input.ExclusiveStartShardId = out.StreamDescription.LastEvaluatedShardId
+	}
+
+	checkpoints, err := c.Checkpoints.GetStreamCheckpoints(ctx, c.StreamARN)
+	if err != nil {
+		return err
+	}
+
+	shards := make(map[string]*shard, len(listed))
+	order := make([]string, 0, len(listed))
+	for _, l := range listed {
+		id := aws.ToString(l.ShardId)
+		s, ok := c.shards[id]
+		if !ok {
+			s = &shard{id: id, parentID: aws.ToString(l.ParentShardId)}
+			if cp, ok := checkpoints[id]; ok {
+				s.checkpoint = cp
+			} else {
+				s.checkpoint = db.StreamCheckpoint{StreamARN: c.StreamARN, ShardID: id}
+			}
+		}
+		shards[id] = s
+		order = append(order, id)
+	}
+	c.shards, c.order = shards, order
+	c.refreshed = c.now()
+	return nil
+}
+
+// ready reports whether a shard can be read: it isn't finished, and its
+// parent is finished or already trimmed from the stream.
+func (c *Consumer) ready(s *shard) bool {
+	if s.checkpoint.Finished {
+		return false
+	}
+	parent, ok := c.shards[s.parentID]
+	return s.parentID == "" || !ok || parent.checkpoint.Finished
+}
+
+// read handles one batch of the shard's records and checkpoints after the
+// last one handled.
+func (c *Consumer) read(ctx context.Context, s *shard) (int, error) {
+	if s.iterator == nil {
+		iterator, err := c.iterator(ctx, s)
+		if err != nil {
+			return 0, err
+		}
+		s.iterator = iterator
+	}
+
+	out, err := c.Client.GetRecords(ctx, &dynamodbstreams.GetRecordsInput{
+		ShardIterator: s.iterator,
+		Limit:         aws.Int32(maxRecordsPerRead),
+	})
+	if err != nil {
+		var expired *types.ExpiredIteratorException
+		var trimmed *types.TrimmedDataAccessException
+		if errors.As(err, &expired) || errors.As(err, &trimmed) {
+			// Start over from the checkpoint.
+			s.iterator = nil
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to get records: %w", err)
+	}
+
+	handled := 0
+	for _, record := range out.Records {
+		if err := c.handle(ctx, record); err != nil {
+			// Read the rest again from the checkpoint.
+			s.iterator = nil
+			return handled, c.save(ctx, s, err)
+		}
+		s.checkpoint.SequenceNumber = aws.ToString(record.Dynamodb.SequenceNumber)
+		handled++
+	}
+
+	s.iterator = out.NextShardIterator
+	if s.iterator == nil {
+		s.checkpoint.Finished = true
+		log.Printf("Finished reading shard %s", s.id)
+	}
+	if handled > 0 || s.checkpoint.Finished {
+		return handled, c.save(ctx, s, nil)
+	}
+	return handled, nil
+}
+
+func (c *Consumer) handle(ctx context.Context, record types.Record) error {
+	changes, err := Changes(record)
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		if err := c.Handler.HandleChange(ctx, change); err != nil {
+			return fmt.Errorf("failed to handle %s change of video %s: %w", change.Kind, change.VideoID, err)
+		}
+	}
+	return nil
+}
+
+// save stores the shard's checkpoint, returning cause along with any error
+// saving it.
+func (c *Consumer) save(ctx context.Context, s *shard, cause error) error {
+	now := c.now().UTC()
+	s.checkpoint.UpdatedAt = now
+	s.checkpoint.ExpiresAt = now.Add(checkpointRetention).Unix()
+	return errors.Join(cause, c.Checkpoints.PutStreamCheckpoint(ctx, s.checkpoint))
+}
+
+// iterator starts after the shard's checkpoint, or at the oldest record
+// still in the shard. A checkpoint that has been trimmed away means records
+// were missed, which is logged.
+func (c *Consumer) iterator(ctx context.Context, s *shard) (*string, error) {
+	input := &dynamodbstreams.GetShardIteratorInput{
+		StreamArn:         aws.String(c.StreamARN),
+		ShardId:           aws.String(s.id),
+		ShardIteratorType: types.ShardIteratorTypeTrimHorizon,
+	}
+	if s.checkpoint.SequenceNumber != "" {
+		input.ShardIteratorType = types.ShardIteratorTypeAfterSequenceNumber
+		input.SequenceNumber = aws.String(s.checkpoint.SequenceNumber)
+	}
+
+	out, err := c.Client.GetShardIterator(ctx, input)
+	var trimmed *types.TrimmedDataAccessException
+	if errors.As(err, &trimmed) && s.checkpoint.SequenceNumber != "" {
+		log.Printf("Records of shard %s after %s were trimmed before they were read", s.id, s.checkpoint.SequenceNumber)
+		input.ShardIteratorType = types.ShardIteratorTypeTrimHorizon
+		input.SequenceNumber = nil
+		out, err = c.Client.GetShardIterator(ctx, input)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shard iterator: %w", err)
+	}
+	return out.ShardIterator, nil
+}
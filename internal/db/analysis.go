@@ -0,0 +1,207 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	SegmentTypeLabel      = "label"
+	SegmentTypeScene      = "scene"
+	SegmentTypeTranscript = "transcript"
+)
+
+var SegmentTypes = []string{SegmentTypeLabel, SegmentTypeScene, SegmentTypeTranscript}
+
+// AnalysisSegment is a time-coded analysis result. Segments live in the data
+// table under the video's partition rather than on the video item, which
+// keeps long transcripts from pushing the video past the 400KB item limit.
+type AnalysisSegment struct {
+	Type       string  `dynamodbav:"type"`
+	Start      float64 `dynamodbav:"start"`
+	End        float64 `dynamodbav:"end"`
+	Label      string  `dynamodbav:"label,omitempty"`
+	Text       string  `dynamodbav:"text,omitempty"`
+	Confidence float64 `dynamodbav:"confidence,omitempty"`
+	// KeyframeKey is the S3 key of a representative frame for scenes.
+	KeyframeKey string `dynamodbav:"keyframe_key,omitempty"`
+}
+
+type analysisSegmentItem struct {
+	PK string `dynamodbav:"pk"`
+	SK string `dynamodbav:"sk"`
+	AnalysisSegment
+}
+
+// SegmentFilter narrows a segment query. An empty Types matches every type and
+// a nil From or To leaves that end of the time range open. A segment matches
+// the time range when it overlaps it.
+type SegmentFilter struct {
+	Types []string
+	From  *float64
+	To    *float64
+}
+
+func videoPartition(videoId string) string {
+	return "video#" + videoId
+}
+
+func segmentTypePrefix(segmentType string) string {
+	return "analysis#" + segmentType + "#"
+}
+
+// segmentSortKey orders segments of a type by start time, in milliseconds.
+func segmentSortKey(segmentType string, start float64, seq int) string {
+	return fmt.Sprintf("%s%012d#%05d", segmentTypePrefix(segmentType), int64(start*1000), seq)
+}
+
+// PutAnalysisSegments replaces every stored segment of the given type for a
+// video.
+func (db *DB) PutAnalysisSegments(ctx context.Context, videoId string, segmentType string, segments []AnalysisSegment) error {
+	if videoId == "" || segmentType == "" {
+		return fmt.Errorf("%w: video ID and segment type cannot be empty", ErrInvalidInput)
+	}
+
+	existing, err := db.querySegmentKeys(ctx, videoId, segmentType)
+	if err != nil {
+		return err
+	}
+
+	requests := make([]types.WriteRequest, 0, len(segments)+len(existing))
+	written := make(map[string]bool, len(segments))
+	for i, seg := range segments {
+		seg.Type = segmentType
+		item := analysisSegmentItem{
+			PK:              videoPartition(videoId),
+			SK:              segmentSortKey(segmentType, seg.Start, i),
+			AnalysisSegment: seg,
+		}
+		av, err := attributevalue.MarshalMap(item)
+		if err != nil {
+			return fmt.Errorf("failed to marshal segment: %w", err)
+		}
+		written[item.SK] = true
+		requests = append(requests, types.WriteRequest{PutRequest: &types.PutRequest{Item: av}})
+	}
+	for _, sk := range existing {
+		if written[sk] {
+			continue
+		}
+		requests = append(requests, types.WriteRequest{DeleteRequest: &types.DeleteRequest{
+			Key: map[string]types.AttributeValue{
+				"pk": &types.AttributeValueMemberS{Value: videoPartition(videoId)},
+				"sk": &types.AttributeValueMemberS{Value: sk},
+			},
+		}})
+	}
+
+	return db.batchWrite(ctx, db.DataTable, requests)
+}
+
+func (db *DB) querySegmentKeys(ctx context.Context, videoId string, segmentType string) ([]string, error) {
+	var keys []string
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(db.DataTable),
+		KeyConditionExpression: aws.String("pk = :pk AND begins_with(sk, :prefix)"),
+		ProjectionExpression:   aws.String("sk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":     &types.AttributeValueMemberS{Value: videoPartition(videoId)},
+			":prefix": &types.AttributeValueMemberS{Value: segmentTypePrefix(segmentType)},
+		},
+	}
+	for {
+		output, err := db.Client.Query(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query segments from DynamoDB: %w", err)
+		}
+		for _, item := range output.Items {
+			if sk, ok := item["sk"].(*types.AttributeValueMemberS); ok {
+				keys = append(keys, sk.Value)
+			}
+		}
+		if len(output.LastEvaluatedKey) == 0 {
+			return keys, nil
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
+
+// GetAnalysisSegments returns the segments of a video matching filter, ordered
+// by start time.
+func (db *DB) GetAnalysisSegments(ctx context.Context, videoId string, filter SegmentFilter) ([]AnalysisSegment, error) {
+	if videoId == "" {
+		return nil, fmt.Errorf("%w: video ID cannot be empty", ErrInvalidInput)
+	}
+
+	segmentTypes := filter.Types
+	if len(segmentTypes) == 0 {
+		segmentTypes = SegmentTypes
+	}
+
+	segments := []AnalysisSegment{}
+	for _, segmentType := range segmentTypes {
+		found, err := db.querySegments(ctx, videoId, segmentType, filter)
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, found...)
+	}
+
+	sort.SliceStable(segments, func(i, j int) bool {
+		return segments[i].Start < segments[j].Start
+	})
+	return segments, nil
+}
+
+func (db *DB) querySegments(ctx context.Context, videoId string, segmentType string, filter SegmentFilter) ([]AnalysisSegment, error) {
+	prefix := segmentTypePrefix(segmentType)
+	values := map[string]types.AttributeValue{
+		":pk": &types.AttributeValueMemberS{Value: videoPartition(videoId)},
+	}
+
+	// Segments are sorted by start, so the upper bound of the range can be
+	// applied to the key. Overlap with the lower bound depends on the end
+	// time and is left to a filter.
+	keyCondition := "pk = :pk AND begins_with(sk, :prefix)"
+	values[":prefix"] = &types.AttributeValueMemberS{Value: prefix}
+	if filter.To != nil {
+		keyCondition = "pk = :pk AND sk BETWEEN :lo AND :hi"
+		delete(values, ":prefix")
+		values[":lo"] = &types.AttributeValueMemberS{Value: prefix}
+		values[":hi"] = &types.AttributeValueMemberS{Value: segmentSortKey(segmentType, *filter.To, 99999)}
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(db.DataTable),
+		KeyConditionExpression:    aws.String(keyCondition),
+		ExpressionAttributeValues: values,
+	}
+	if filter.From != nil {
+		input.FilterExpression = aws.String("#end >= :from")
+		input.ExpressionAttributeNames = map[string]string{"#end": "end"}
+		values[":from"] = &types.AttributeValueMemberN{Value: fmt.Sprintf("%g", *filter.From)}
+	}
+
+	var segments []AnalysisSegment
+	for {
+		output, err := db.Client.Query(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query segments from DynamoDB: %w", err)
+		}
+		var page []AnalysisSegment
+		if err := attributevalue.UnmarshalListOfMaps(output.Items, &page); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal segments: %w", err)
+		}
+		segments = append(segments, page...)
+		if len(output.LastEvaluatedKey) == 0 {
+			return segments, nil
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPutAnalysisSegmentsReplacesExisting(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{
+		Client:    mockClient,
+		TableName: "test-table",
+		DataTable: "test-data",
+	}
+
+	ctx := context.Background()
+	stale := segmentSortKey(SegmentTypeLabel, 42, 3)
+
+	mockClient.On("Query", mock.Anything, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+		return *input.TableName == "test-data" && *input.ProjectionExpression == "sk"
+	})).Return(&dynamodb.QueryOutput{
+		Items: []map[string]types.AttributeValue{
+			{"sk": &types.AttributeValueMemberS{Value: stale}},
+		},
+	}, nil)
+	mockClient.On("BatchWriteItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.BatchWriteItemInput) bool {
+		requests := input.RequestItems["test-data"]
+		return len(requests) == 3 &&
+			requests[0].PutRequest != nil &&
+			requests[1].PutRequest != nil &&
+			requests[2].DeleteRequest != nil
+	})).Return(&dynamodb.BatchWriteItemOutput{}, nil)
+
+	err := db.PutAnalysisSegments(ctx, "test-id", SegmentTypeLabel, []AnalysisSegment{
+		{Start: 0, End: 4, Label: "outdoor"},
+		{Start: 4, End: 10, Label: "sports"},
+	})
+
+	assert.NoError(t, err)
+	mockClient.AssertExpectations(t)
+}
+
+func TestPutAnalysisSegmentsRetriesUnprocessed(t *testing.T) {
+	batchRetryDelay = 0
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{
+		Client:    mockClient,
+		DataTable: "test-data",
+	}
+
+	ctx := context.Background()
+
+	mockClient.On("Query", mock.Anything, mock.Anything).Return(&dynamodb.QueryOutput{}, nil)
+	unprocessed := map[string][]types.WriteRequest{
+		"test-data": {{PutRequest: &types.PutRequest{}}},
+	}
+	mockClient.On("BatchWriteItem", mock.Anything, mock.Anything).
+		Return(&dynamodb.BatchWriteItemOutput{UnprocessedItems: unprocessed}, nil).Once()
+	mockClient.On("BatchWriteItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.BatchWriteItemInput) bool {
+		return len(input.RequestItems["test-data"]) == 1
+	})).Return(&dynamodb.BatchWriteItemOutput{}, nil).Once()
+
+	err := db.PutAnalysisSegments(ctx, "test-id", SegmentTypeLabel, []AnalysisSegment{
+		{Start: 0, End: 4, Label: "outdoor"},
+		{Start: 4, End: 10, Label: "sports"},
+	})
+
+	assert.NoError(t, err)
+	mockClient.AssertExpectations(t)
+}
+
+func TestGetAnalysisSegments(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{
+		Client:    mockClient,
+		DataTable: "test-data",
+	}
+
+	ctx := context.Background()
+	from, to := 5.0, 20.0
+
+	mockClient.On("Query", mock.Anything, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+		return *input.KeyConditionExpression == "pk = :pk AND sk BETWEEN :lo AND :hi" &&
+			*input.FilterExpression == "#end >= :from"
+	})).Return(&dynamodb.QueryOutput{
+		Items: []map[string]types.AttributeValue{
+			{
+				"type":  &types.AttributeValueMemberS{Value: SegmentTypeScene},
+				"start": &types.AttributeValueMemberN{Value: "12"},
+				"end":   &types.AttributeValueMemberN{Value: "18"},
+			},
+			{
+				"type":  &types.AttributeValueMemberS{Value: SegmentTypeScene},
+				"start": &types.AttributeValueMemberN{Value: "3"},
+				"end":   &types.AttributeValueMemberN{Value: "12"},
+			},
+		},
+	}, nil).Once()
+
+	segments, err := db.GetAnalysisSegments(ctx, "test-id", SegmentFilter{
+		Types: []string{SegmentTypeScene},
+		From:  &from,
+		To:    &to,
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, segments, 2)
+	assert.Equal(t, 3.0, segments[0].Start)
+	assert.Equal(t, 12.0, segments[1].Start)
+	mockClient.AssertExpectations(t)
+}
+
+func TestSegmentSortKeyOrdersByStart(t *testing.T) {
+	assert.Less(t, segmentSortKey(SegmentTypeLabel, 9.5, 0), segmentSortKey(SegmentTypeLabel, 10, 0))
+	assert.Less(t, segmentSortKey(SegmentTypeLabel, 10, 0), segmentSortKey(SegmentTypeLabel, 100, 0))
+}
@@ -0,0 +1,122 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	ErrAPIKeyExists   = errors.New("API key already exists")
+)
+
+// APIKey is a stored API key. Only the hex SHA-256 of the key is kept, so a
+// leaked table doesn't leak usable keys. Keys without Scopes get the auth
+// package's defaults.
+type APIKey struct {
+	Hash      string     `dynamodbav:"key_hash"`
+	TenantID  string     `dynamodbav:"tenant_id"`
+	Name      string     `dynamodbav:"name"`
+	Scopes    []string   `dynamodbav:"scopes,omitempty,stringset"`
+	CreatedAt time.Time  `dynamodbav:"created_at"`
+	RevokedAt *time.Time `dynamodbav:"revoked_at,omitempty"`
+}
+
+func (k *APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+func apiKeyKey(hash string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: "apikey#" + hash},
+		"sk": &types.AttributeValueMemberS{Value: "apikey"},
+	}
+}
+
+// PutAPIKey stores a new API key. It fails with ErrAPIKeyExists rather than
+// overwrite a key with the same hash.
+func (db *DB) PutAPIKey(ctx context.Context, key APIKey) error {
+	if key.Hash == "" || key.TenantID == "" {
+		return fmt.Errorf("%w: key hash and tenant ID cannot be empty", ErrInvalidInput)
+	}
+
+	item, err := attributevalue.MarshalMap(key)
+	if err != nil {
+		return fmt.Errorf("failed to marshal API key: %w", err)
+	}
+	for name, value := range apiKeyKey(key.Hash) {
+		item[name] = value
+	}
+
+	_, err = db.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(db.DataTable),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(pk)"),
+	})
+	if err != nil {
+		if isConditionalCheckFailed(err) {
+			return ErrAPIKeyExists
+		}
+		return fmt.Errorf("failed to put API key in DynamoDB: %w", err)
+	}
+	return nil
+}
+
+// GetAPIKey looks up a key by its hash. Revoked keys are returned too; the
+// caller decides what to do with them.
+func (db *DB) GetAPIKey(ctx context.Context, hash string) (*APIKey, error) {
+	if hash == "" {
+		return nil, fmt.Errorf("%w: key hash cannot be empty", ErrInvalidInput)
+	}
+
+	result, err := db.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(db.DataTable),
+		Key:       apiKeyKey(hash),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key from DynamoDB: %w", err)
+	}
+	if len(result.Item) == 0 {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	var key APIKey
+	if err := attributevalue.UnmarshalMap(result.Item, &key); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal API key: %w", err)
+	}
+	return &key, nil
+}
+
+// RevokeAPIKey marks a key as revoked. Revoking a key twice keeps the first
+// revocation time.
+func (db *DB) RevokeAPIKey(ctx context.Context, hash string) error {
+	if hash == "" {
+		return fmt.Errorf("%w: key hash cannot be empty", ErrInvalidInput)
+	}
+
+	now, err := attributevalue.Marshal(time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to marshal revocation time: %w", err)
+	}
+	_, err = db.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(db.DataTable),
+		Key:                       apiKeyKey(hash),
+		UpdateExpression:          aws.String("SET revoked_at = if_not_exists(revoked_at, :now)"),
+		ConditionExpression:       aws.String("attribute_exists(pk)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":now": now},
+	})
+	if err != nil {
+		if isConditionalCheckFailed(err) {
+			return ErrAPIKeyNotFound
+		}
+		return fmt.Errorf("failed to revoke API key in DynamoDB: %w", err)
+	}
+	return nil
+}
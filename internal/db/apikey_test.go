@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPutAPIKey(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{Client: mockClient, TableName: "test-table", DataTable: "test-table-data"}
+
+	mockClient.On("PutItem", mock.Anything, mock.MatchedBy(func(in *dynamodb.PutItemInput) bool {
+		return *in.TableName == "test-table-data" &&
+			*in.ConditionExpression == "attribute_not_exists(pk)" &&
+			in.Item["pk"].(*types.AttributeValueMemberS).Value == "apikey#abc" &&
+			in.Item["tenant_id"].(*types.AttributeValueMemberS).Value == "acme" &&
+			assert.ObjectsAreEqual([]string{"videos:read"}, in.Item["scopes"].(*types.AttributeValueMemberSS).Value)
+	})).Return(&dynamodb.PutItemOutput{}, nil).Once()
+	mockClient.On("PutItem", mock.Anything, mock.Anything).
+		Return(&dynamodb.PutItemOutput{}, &types.ConditionalCheckFailedException{})
+
+	key := APIKey{Hash: "abc", TenantID: "acme", Name: "ci", Scopes: []string{"videos:read"}, CreatedAt: time.Now()}
+	assert.NoError(t, db.PutAPIKey(context.Background(), key))
+	assert.ErrorIs(t, db.PutAPIKey(context.Background(), key), ErrAPIKeyExists)
+	assert.ErrorIs(t, db.PutAPIKey(context.Background(), APIKey{Hash: "abc"}), ErrInvalidInput)
+}
+
+func TestGetAPIKey(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{Client: mockClient, TableName: "test-table", DataTable: "test-table-data"}
+
+	mockClient.On("GetItem", mock.Anything, mock.MatchedBy(func(in *dynamodb.GetItemInput) bool {
+		return in.Key["pk"].(*types.AttributeValueMemberS).Value == "apikey#abc"
+	})).Return(&dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+		"key_hash":   &types.AttributeValueMemberS{Value: "abc"},
+		"tenant_id":  &types.AttributeValueMemberS{Value: "acme"},
+		"scopes":     &types.AttributeValueMemberSS{Value: []string{"videos:read", "videos:write"}},
+		"revoked_at": &types.AttributeValueMemberS{Value: "2025-01-02T03:04:05Z"},
+	}}, nil)
+	mockClient.On("GetItem", mock.Anything, mock.Anything).Return(&dynamodb.GetItemOutput{}, nil)
+
+	key, err := db.GetAPIKey(context.Background(), "abc")
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", key.TenantID)
+	assert.ElementsMatch(t, []string{"videos:read", "videos:write"}, key.Scopes)
+	assert.True(t, key.Revoked())
+
+	_, err = db.GetAPIKey(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+}
+
+func TestRevokeAPIKeyNotFound(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{Client: mockClient, TableName: "test-table", DataTable: "test-table-data"}
+
+	mockClient.On("UpdateItem", mock.Anything, mock.Anything).
+		Return(&dynamodb.UpdateItemOutput{}, &types.ConditionalCheckFailedException{})
+
+	assert.ErrorIs(t, db.RevokeAPIKey(context.Background(), "abc"), ErrAPIKeyNotFound)
+}
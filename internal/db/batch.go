@@ -0,0 +1,226 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	maxBatchWriteItems = 25
+	maxBatchGetItems   = 100
+	maxBatchRetries    = 5
+)
+
+var batchRetryDelay = 100 * time.Millisecond
+
+// batchWrite sends write requests to a table in chunks of 25, resending any
+// unprocessed items with exponential backoff.
+func (db *DB) batchWrite(ctx context.Context, tableName string, requests []types.WriteRequest) error {
+	for start := 0; start < len(requests); start += maxBatchWriteItems {
+		end := start + maxBatchWriteItems
+		if end > len(requests) {
+			end = len(requests)
+		}
+
+		pending := map[string][]types.WriteRequest{tableName: requests[start:end]}
+		delay := batchRetryDelay
+		for attempt := 0; len(pending) > 0; attempt++ {
+			if attempt > maxBatchRetries {
+				return fmt.Errorf("failed to write batch to DynamoDB: %d items left unprocessed", len(pending[tableName]))
+			}
+			if attempt > 0 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(delay):
+				}
+				delay *= 2
+			}
+
+			output, err := db.Client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+				RequestItems: pending,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to write batch to DynamoDB: %w", err)
+			}
+			pending = output.UnprocessedItems
+		}
+	}
+
+	return nil
+}
+
+// batchGet reads items from a table in chunks of 100, rereading any
+// unprocessed keys with exponential backoff. Keys with no item are left out
+// of the result, which is in no particular order.
+func (db *DB) batchGet(ctx context.Context, tableName string, keys []map[string]types.AttributeValue) ([]map[string]types.AttributeValue, error) {
+	var items []map[string]types.AttributeValue
+	for start := 0; start < len(keys); start += maxBatchGetItems {
+		end := start + maxBatchGetItems
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		pending := map[string]types.KeysAndAttributes{tableName: {Keys: keys[start:end]}}
+		delay := batchRetryDelay
+		for attempt := 0; len(pending) > 0; attempt++ {
+			if attempt > maxBatchRetries {
+				return nil, fmt.Errorf("failed to read batch from DynamoDB: %d keys left unprocessed", len(pending[tableName].Keys))
+			}
+			if attempt > 0 {
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-time.After(delay):
+				}
+				delay *= 2
+			}
+
+			output, err := db.Client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+				RequestItems: pending,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to read batch from DynamoDB: %w", err)
+			}
+			items = append(items, output.Responses[tableName]...)
+			pending = output.UnprocessedKeys
+		}
+	}
+
+	return items, nil
+}
+
+func videoKey(videoId string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"video_id": &types.AttributeValueMemberS{Value: videoId},
+	}
+}
+
+// BatchGetVideos returns the videos with the given IDs, keyed by ID. IDs
+// with no video are left out, and repeated IDs are read once.
+func (db *DB) BatchGetVideos(ctx context.Context, videoIds []string) (map[string]*Video, error) {
+	seen := make(map[string]bool, len(videoIds))
+	keys := make([]map[string]types.AttributeValue, 0, len(videoIds))
+	for _, id := range videoIds {
+		if id == "" {
+			return nil, fmt.Errorf("%w: video ID cannot be empty", ErrInvalidInput)
+		}
+		if !seen[id] {
+			seen[id] = true
+			keys = append(keys, videoKey(id))
+		}
+	}
+
+	items, err := db.batchGet(ctx, db.TableName, keys)
+	if err != nil {
+		return nil, err
+	}
+	videos := make(map[string]*Video, len(items))
+	for _, item := range items {
+		var video Video
+		if err := attributevalue.UnmarshalMap(item, &video); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal item: %w", err)
+		}
+		videos[video.VideoID] = &video
+	}
+	return videos, nil
+}
+
+// ResolveAliases fills in the renditions, analysis and loudness of the
+// aliases among videos from the videos they are aliases of. Aliases share
+// their original's files, which reprocessing and retention move from
+// version to version, so they don't keep their own copy. An alias whose
+// original is gone is left with none.
+func (db *DB) ResolveAliases(ctx context.Context, videos ...*Video) error {
+	var ids []string
+	for _, video := range videos {
+		if video != nil && video.AliasOf != "" {
+			ids = append(ids, video.AliasOf)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	originals, err := db.BatchGetVideos(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, video := range videos {
+		if video == nil || video.AliasOf == "" {
+			continue
+		}
+		video.Renditions, video.Analysis, video.Loudness = nil, nil, nil
+		if original, ok := originals[video.AliasOf]; ok && original.TenantID == video.TenantID {
+			video.Renditions = original.Renditions
+			video.Analysis = original.Analysis
+			video.Loudness = original.Loudness
+		}
+	}
+	return nil
+}
+
+// PutVideos writes new videos in batches.
+func (db *DB) PutVideos(ctx context.Context, videos []Video) error {
+	requests := make([]types.WriteRequest, 0, len(videos))
+	for _, video := range videos {
+		if video.VideoID == "" {
+			return fmt.Errorf("%w: video ID cannot be empty", ErrInvalidInput)
+		}
+		av, err := attributevalue.MarshalMap(video)
+		if err != nil {
+			return fmt.Errorf("failed to marshal video: %w", err)
+		}
+		requests = append(requests, types.WriteRequest{PutRequest: &types.PutRequest{Item: av}})
+	}
+	return db.batchWrite(ctx, db.TableName, requests)
+}
+
+// SetVideoTags replaces a video's tags, provided they are still previous.
+// Otherwise it fails with ErrConflict, and the caller rereads the video and
+// tries again.
+func (db *DB) SetVideoTags(ctx context.Context, videoId string, previous []string, tags []string) error {
+	if videoId == "" {
+		return fmt.Errorf("%w: video ID cannot be empty", ErrInvalidInput)
+	}
+	newTags, err := attributevalue.Marshal(tags)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tags: %w", err)
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                aws.String(db.TableName),
+		Key:                      videoKey(videoId),
+		UpdateExpression:         aws.String("SET #tags = :tags"),
+		ExpressionAttributeNames: map[string]string{"#tags": "tags", "#id": "video_id"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":tags": newTags,
+		},
+	}
+	// Videos written without tags have a null or missing attribute.
+	if previous == nil {
+		input.ConditionExpression = aws.String("attribute_exists(#id) AND (attribute_not_exists(#tags) OR attribute_type(#tags, :null))")
+		input.ExpressionAttributeValues[":null"] = &types.AttributeValueMemberS{Value: "NULL"}
+	} else {
+		old, err := attributevalue.Marshal(previous)
+		if err != nil {
+			return fmt.Errorf("failed to marshal tags: %w", err)
+		}
+		input.ConditionExpression = aws.String("attribute_exists(#id) AND #tags = :previous")
+		input.ExpressionAttributeValues[":previous"] = old
+	}
+
+	if _, err := db.Client.UpdateItem(ctx, input); err != nil {
+		if isConditionalCheckFailed(err) {
+			return ErrConflict
+		}
+		return fmt.Errorf("failed to update tags in DynamoDB: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestBatchGetVideosRetriesUnprocessed(t *testing.T) {
+	batchRetryDelay = 0
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{
+		Client:    mockClient,
+		TableName: "test-table",
+	}
+
+	ctx := context.Background()
+	item := func(id string) map[string]types.AttributeValue {
+		return map[string]types.AttributeValue{
+			"video_id":  &types.AttributeValueMemberS{Value: id},
+			"tenant_id": &types.AttributeValueMemberS{Value: "acme"},
+		}
+	}
+
+	mockClient.On("BatchGetItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.BatchGetItemInput) bool {
+		return len(input.RequestItems["test-table"].Keys) == 2
+	})).Return(&dynamodb.BatchGetItemOutput{
+		Responses: map[string][]map[string]types.AttributeValue{"test-table": {item("v1")}},
+		UnprocessedKeys: map[string]types.KeysAndAttributes{
+			"test-table": {Keys: []map[string]types.AttributeValue{videoKey("v2")}},
+		},
+	}, nil).Once()
+	mockClient.On("BatchGetItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.BatchGetItemInput) bool {
+		return len(input.RequestItems["test-table"].Keys) == 1
+	})).Return(&dynamodb.BatchGetItemOutput{
+		Responses: map[string][]map[string]types.AttributeValue{"test-table": {item("v2")}},
+	}, nil).Once()
+
+	videos, err := db.BatchGetVideos(ctx, []string{"v1", "v2", "v1"})
+
+	assert.NoError(t, err)
+	assert.Len(t, videos, 2)
+	assert.Equal(t, "acme", videos["v2"].TenantID)
+	mockClient.AssertExpectations(t)
+}
+
+func TestResolveAliasesUsesOriginalsFiles(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{Client: mockClient, TableName: "test-table"}
+
+	// The original has moved on to version 3 and expired version 1.
+	original, err := attributevalue.MarshalMap(Video{
+		VideoID:    "v1",
+		TenantID:   "acme",
+		Renditions: map[string]string{"mp4": "tenants/acme/processed/v1/v3/transcoded.mp4"},
+		Analysis:   &Analysis{Analyzer: "labels", Summary: "a dog"},
+	})
+	assert.NoError(t, err)
+	mockClient.On("BatchGetItem", mock.Anything, mock.Anything).Return(&dynamodb.BatchGetItemOutput{
+		Responses: map[string][]map[string]types.AttributeValue{"test-table": {original}},
+	}, nil)
+
+	// An alias saved with a copy of version 1, and one whose original is gone.
+	alias := &Video{
+		VideoID:    "v2",
+		TenantID:   "acme",
+		AliasOf:    "v1",
+		Renditions: map[string]string{"mp4": "tenants/acme/processed/v1/v1/transcoded.mp4"},
+	}
+	orphan := &Video{
+		VideoID:    "v3",
+		TenantID:   "acme",
+		AliasOf:    "v4",
+		Renditions: map[string]string{"mp4": "tenants/acme/processed/v4/v1/transcoded.mp4"},
+	}
+	plain := &Video{VideoID: "v5", TenantID: "acme", Renditions: map[string]string{"mp4": "tenants/acme/processed/v5/v1/transcoded.mp4"}}
+
+	assert.NoError(t, db.ResolveAliases(context.Background(), alias, nil, orphan, plain))
+	assert.Equal(t, map[string]string{"mp4": "tenants/acme/processed/v1/v3/transcoded.mp4"}, alias.Renditions)
+	assert.Equal(t, "a dog", alias.Analysis.Summary)
+	assert.Nil(t, orphan.Renditions)
+	assert.Equal(t, "tenants/acme/processed/v5/v1/transcoded.mp4", plain.Renditions["mp4"])
+}
+
+func TestSetVideoTagsConflict(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{
+		Client:    mockClient,
+		TableName: "test-table",
+	}
+
+	ctx := context.Background()
+
+	mockClient.On("UpdateItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+		_, ok := input.ExpressionAttributeValues[":previous"]
+		return ok
+	})).Return(&dynamodb.UpdateItemOutput{}, &types.ConditionalCheckFailedException{})
+
+	err := db.SetVideoTags(ctx, "test-id", []string{"a"}, []string{"a", "b"})
+
+	assert.ErrorIs(t, err, ErrConflict)
+	mockClient.AssertExpectations(t)
+}
+
+func TestUpdateVideoIfStatus(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{
+		Client:    mockClient,
+		TableName: "test-table",
+	}
+
+	ctx := context.Background()
+
+	mockClient.On("UpdateItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+		status, ok := input.ExpressionAttributeValues[":status"].(*types.AttributeValueMemberS)
+		return ok && status.Value == StatusAwaitingUpload &&
+			*input.ConditionExpression == "attribute_exists(#id) AND #status = :status"
+	})).Return(&dynamodb.UpdateItemOutput{}, &types.ConditionalCheckFailedException{})
+
+	err := db.UpdateVideoIfStatus(ctx, "test-id", StatusAwaitingUpload, map[string]interface{}{"status": StatusUploaded})
+
+	assert.ErrorIs(t, err, ErrConflict)
+	mockClient.AssertExpectations(t)
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -16,27 +18,121 @@ import (
 var (
 	ErrVideoNotFound = errors.New("video not found")
 	ErrInvalidInput  = errors.New("invalid input")
+	// ErrConflict means a conditional update lost to a concurrent change.
+	ErrConflict = errors.New("conflicting update")
+)
+
+// Processing states of a video. A failed video records why in StatusReason.
+// An imported video is importing until its source has been fetched into S3,
+// and a video created in a batch is awaiting_upload until its file is.
+const (
+	StatusAwaitingUpload = "awaiting_upload"
+	StatusImporting      = "importing"
+	StatusUploaded       = "uploaded"
+	StatusProcessing     = "processing"
+	StatusReady          = "ready"
+	StatusFailed         = "failed"
 )
 
 type Video struct {
-	VideoID     string    `dynamodbav:"video_id"`
-	Title       string    `dynamodbav:"title"`
-	Description string    `dynamodbav:"description"`
-	URL         string    `dynamodbav:"url"`
-	Tags        []string  `dynamodbav:"tags"`
-	Metadata    map[string]interface{} `json:"metadata,omitempty"`
-	UploadDate  time.Time `dynamodbav:"upload_date"`
+	VideoID string `dynamodbav:"video_id"`
+	// TenantID is the tenant that uploaded the video. Videos created before
+	// tenants existed have none and are not visible through the API.
+	TenantID    string `dynamodbav:"tenant_id,omitempty"`
+	Title       string `dynamodbav:"title"`
+	Description string `dynamodbav:"description"`
+	URL         string `dynamodbav:"url"`
+	// SourceURL is where an imported video was fetched from.
+	SourceURL    string                 `dynamodbav:"source_url,omitempty"`
+	Tags         []string               `dynamodbav:"tags"`
+	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+	UploadDate   time.Time              `dynamodbav:"upload_date"`
+	Status       string                 `dynamodbav:"status,omitempty"`
+	StatusReason string                 `dynamodbav:"status_reason,omitempty"`
+	// ContentHash is the hex SHA-256 of the uploaded file.
+	ContentHash string `dynamodbav:"content_hash,omitempty"`
+	// AliasOf is set on a record created for a duplicate upload and names the
+	// video whose outputs it shares. Aliases counts the aliases of a video,
+	// which can't be deleted while it has any.
+	AliasOf string `dynamodbav:"alias_of,omitempty"`
+	Aliases int    `dynamodbav:"aliases,omitempty"`
+	// Size is the number of bytes of the upload counted against the tenant's
+	// storage, given back when the video is deleted. It is zero until the
+	// upload is stored, and for videos stored before sizes were recorded.
+	Size        int64                      `dynamodbav:"size,omitempty"`
+	Checkpoints map[string]StageCheckpoint `dynamodbav:"checkpoints,omitempty"`
+	Analysis    *Analysis                  `dynamodbav:"analysis,omitempty"`
+	Loudness    *Loudness                  `dynamodbav:"loudness,omitempty"`
+	// Renditions maps a rendition name such as "mp4" or "audio" to its S3 key.
+	Renditions map[string]string `dynamodbav:"renditions,omitempty"`
+	// Profile names the transcoding profile of the renditions. Videos
+	// processed before profiles existed have none and used the default.
+	Profile string `dynamodbav:"profile,omitempty"`
+	// Version is the processing run being served, see VideoVersion, and
+	// LatestVersion the last run started. Both are zero for videos processed
+	// before versions existed.
+	Version       int `dynamodbav:"version,omitempty"`
+	LatestVersion int `dynamodbav:"latest_version,omitempty"`
+	// ProcessingSeconds is the duration counted against the tenant's
+	// processing usage when a run finishes, and ProcessingVersion the
+	// version of the last run counted.
+	ProcessingSeconds float64 `dynamodbav:"processing_seconds,omitempty"`
+	ProcessingVersion int     `dynamodbav:"processing_version,omitempty"`
+}
+
+// Loudness is the integrated loudness measured before normalization, in LUFS,
+// and the target it was normalized to.
+type Loudness struct {
+	TargetLUFS     float64   `dynamodbav:"target_lufs"`
+	IntegratedLUFS float64   `dynamodbav:"integrated_lufs"`
+	TruePeak       float64   `dynamodbav:"true_peak"`
+	LoudnessRange  float64   `dynamodbav:"loudness_range"`
+	Threshold      float64   `dynamodbav:"threshold"`
+	TargetOffset   float64   `dynamodbav:"target_offset"`
+	MeasuredAt     time.Time `dynamodbav:"measured_at"`
+}
+
+// Analysis holds the video-level output of the analyzer that processed a
+// video. Its time-coded segments are stored separately, see AnalysisSegment.
+type Analysis struct {
+	Analyzer     string    `dynamodbav:"analyzer"`
+	ModelVersion string    `dynamodbav:"model_version,omitempty"`
+	Summary      string    `dynamodbav:"summary"`
+	Labels       []Label   `dynamodbav:"labels"`
+	SegmentCount int       `dynamodbav:"segment_count"`
+	AnalyzedAt   time.Time `dynamodbav:"analyzed_at"`
+}
+
+type Label struct {
+	Name       string  `dynamodbav:"name"`
+	Confidence float64 `dynamodbav:"confidence"`
+}
+
+// StageCheckpoint records the artifacts of a completed processing stage so a
+// redelivered job can skip it.
+type StageCheckpoint struct {
+	Artifacts   map[string]string `dynamodbav:"artifacts"`
+	CompletedAt time.Time         `dynamodbav:"completed_at"`
 }
 
 type DynamoDBClient interface {
 	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
 	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
-
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+	BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
 }
 
+// DB wraps the videos table and the data table. The data table has a generic
+// pk/sk key schema and holds the item collections that hang off a video.
 type DB struct {
 	Client    DynamoDBClient
 	TableName string
+	DataTable string
 }
 
 func NewDB(ctx context.Context, tableName string) (*DB, error) {
@@ -53,6 +149,7 @@ func NewDB(ctx context.Context, tableName string) (*DB, error) {
 	return &DB{
 		Client:    client,
 		TableName: tableName,
+		DataTable: tableName + "-data",
 	}, nil
 }
 
@@ -110,4 +207,175 @@ func (db *DB) GetVideoById(ctx context.Context, videoId string) (*Video, error)
 	}
 
 	return &video, nil
-}
\ No newline at end of file
+}
+
+// GetVideoForTenant returns a video only if it belongs to the tenant. Other
+// tenants' videos are reported as not found so their IDs can't be probed.
+func (db *DB) GetVideoForTenant(ctx context.Context, tenantId string, videoId string) (*Video, error) {
+	video, err := db.GetVideoById(ctx, videoId)
+	if err != nil {
+		return nil, err
+	}
+	if video.TenantID != tenantId {
+		return nil, ErrVideoNotFound
+	}
+	return video, nil
+}
+
+// UpdateVideo sets the given top-level attributes on an existing video,
+// leaving every other attribute untouched.
+func (db *DB) UpdateVideo(ctx context.Context, videoId string, fields map[string]interface{}) error {
+	return db.updateVideo(ctx, videoId, fields, "", ErrVideoNotFound)
+}
+
+// UpdateVideoIfStatus is UpdateVideo for a video that is still in the given
+// status. It fails with ErrConflict if the video is missing or has moved on.
+func (db *DB) UpdateVideoIfStatus(ctx context.Context, videoId string, status string, fields map[string]interface{}) error {
+	return db.updateVideo(ctx, videoId, fields, status, ErrConflict)
+}
+
+func (db *DB) updateVideo(ctx context.Context, videoId string, fields map[string]interface{}, status string, conditionErr error) error {
+	if videoId == "" {
+		return fmt.Errorf("%w: video ID cannot be empty", ErrInvalidInput)
+	}
+	if len(fields) == 0 {
+		return fmt.Errorf("%w: no fields to update", ErrInvalidInput)
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	expressionNames := map[string]string{"#id": "video_id"}
+	expressionValues := map[string]types.AttributeValue{}
+	assignments := make([]string, 0, len(names))
+	for i, name := range names {
+		av, err := attributevalue.Marshal(fields[name])
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %w", name, err)
+		}
+		expressionNames[fmt.Sprintf("#f%d", i)] = name
+		expressionValues[fmt.Sprintf(":v%d", i)] = av
+		assignments = append(assignments, fmt.Sprintf("#f%d = :v%d", i, i))
+	}
+	condition := "attribute_exists(#id)"
+	if status != "" {
+		condition += " AND #status = :status"
+		expressionNames["#status"] = "status"
+		expressionValues[":status"] = &types.AttributeValueMemberS{Value: status}
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(db.TableName),
+		Key: map[string]types.AttributeValue{
+			"video_id": &types.AttributeValueMemberS{Value: videoId},
+		},
+		UpdateExpression:          aws.String("SET " + strings.Join(assignments, ", ")),
+		ConditionExpression:       aws.String(condition),
+		ExpressionAttributeNames:  expressionNames,
+		ExpressionAttributeValues: expressionValues,
+	}
+
+	_, err := db.Client.UpdateItem(ctx, input)
+	if err != nil {
+		if isConditionalCheckFailed(err) {
+			return conditionErr
+		}
+		return fmt.Errorf("failed to update item in DynamoDB: %w", err)
+	}
+
+	return nil
+}
+
+// SaveCheckpoint records a completed stage on the video's checkpoints map,
+// creating the map on the first checkpoint.
+func (db *DB) SaveCheckpoint(ctx context.Context, videoId string, stage string, checkpoint StageCheckpoint) error {
+	if videoId == "" || stage == "" {
+		return fmt.Errorf("%w: video ID and stage cannot be empty", ErrInvalidInput)
+	}
+
+	cp, err := attributevalue.Marshal(checkpoint)
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+	key := map[string]types.AttributeValue{
+		"video_id": &types.AttributeValueMemberS{Value: videoId},
+	}
+
+	// Two parallel stages can race to create the map, so retry the pair once
+	// if the create loses.
+	for attempt := 0; attempt < 2; attempt++ {
+		_, err = db.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(db.TableName),
+			Key:                       key,
+			UpdateExpression:          aws.String("SET #cp.#stage = :cp"),
+			ConditionExpression:       aws.String("attribute_exists(#cp)"),
+			ExpressionAttributeNames:  map[string]string{"#cp": "checkpoints", "#stage": stage},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":cp": cp},
+		})
+		if !isConditionalCheckFailed(err) {
+			break
+		}
+
+		_, err = db.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                aws.String(db.TableName),
+			Key:                      key,
+			UpdateExpression:         aws.String("SET #cp = :cps"),
+			ConditionExpression:      aws.String("attribute_exists(#id) AND attribute_not_exists(#cp)"),
+			ExpressionAttributeNames: map[string]string{"#cp": "checkpoints", "#id": "video_id"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":cps": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{stage: cp}},
+			},
+		})
+		if !isConditionalCheckFailed(err) {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint in DynamoDB: %w", err)
+	}
+
+	return nil
+}
+
+// ClearCheckpoints forgets the given stages' checkpoints, so the next run of
+// the video's pipeline runs them again.
+func (db *DB) ClearCheckpoints(ctx context.Context, videoId string, stages []string) error {
+	if videoId == "" {
+		return fmt.Errorf("%w: video ID cannot be empty", ErrInvalidInput)
+	}
+	if len(stages) == 0 {
+		return nil
+	}
+
+	names := map[string]string{"#cp": "checkpoints", "#id": "video_id"}
+	paths := make([]string, len(stages))
+	for i, stage := range stages {
+		placeholder := fmt.Sprintf("#stage%d", i)
+		names[placeholder] = stage
+		paths[i] = "#cp." + placeholder
+	}
+
+	// Removing a path under a missing map is an error, so the map must exist.
+	_, err := db.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                aws.String(db.TableName),
+		Key:                      videoKey(videoId),
+		UpdateExpression:         aws.String("REMOVE " + strings.Join(paths, ", ")),
+		ConditionExpression:      aws.String("attribute_exists(#id) AND attribute_exists(#cp)"),
+		ExpressionAttributeNames: names,
+	})
+	if err != nil {
+		if isConditionalCheckFailed(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to clear checkpoints in DynamoDB: %w", err)
+	}
+	return nil
+}
+
+func isConditionalCheckFailed(err error) bool {
+	var ccf *types.ConditionalCheckFailedException
+	return errors.As(err, &ccf)
+}
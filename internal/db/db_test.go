@@ -26,16 +26,50 @@ func (m *mockDynamoDBClient) GetItem(ctx context.Context, params *dynamodb.GetIt
 	return args.Get(0).(*dynamodb.GetItemOutput), args.Error(1)
 }
 
+func (m *mockDynamoDBClient) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*dynamodb.UpdateItemOutput), args.Error(1)
+}
+
+func (m *mockDynamoDBClient) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*dynamodb.DeleteItemOutput), args.Error(1)
+}
+
+func (m *mockDynamoDBClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*dynamodb.QueryOutput), args.Error(1)
+}
+
+func (m *mockDynamoDBClient) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*dynamodb.BatchWriteItemOutput), args.Error(1)
+}
+
+func (m *mockDynamoDBClient) BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*dynamodb.BatchGetItemOutput), args.Error(1)
+}
+
+func (m *mockDynamoDBClient) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*dynamodb.TransactWriteItemsOutput), args.Error(1)
+}
+
+func (m *mockDynamoDBClient) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*dynamodb.ScanOutput), args.Error(1)
+}
+
 func TestNewDB(t *testing.T) {
 
 	t.Skip("Skipping test as it requires AWS credentials")
-	
 
 	ctx := context.Background()
 	tableName := "test-table"
-	
+
 	db, err := NewDB(ctx, tableName)
-	
+
 	assert.NoError(t, err)
 	assert.NotNil(t, db)
 	assert.Equal(t, tableName, db.TableName)
@@ -48,7 +82,7 @@ func TestPutVideo(t *testing.T) {
 		Client:    mockClient,
 		TableName: "test-table",
 	}
-	
+
 	ctx := context.Background()
 	video := Video{
 		VideoID:     "test-id",
@@ -58,14 +92,12 @@ func TestPutVideo(t *testing.T) {
 		Tags:        []string{"test", "video"},
 		UploadDate:  time.Now(),
 	}
-	
 
 	mockClient.On("PutItem", mock.Anything, mock.AnythingOfType("*dynamodb.PutItemInput")).
 		Return(&dynamodb.PutItemOutput{}, nil)
-	
 
 	err := db.PutVideo(ctx, video)
-	
+
 	assert.NoError(t, err)
 	mockClient.AssertExpectations(t)
 }
@@ -76,7 +108,7 @@ func TestPutVideoError(t *testing.T) {
 		Client:    mockClient,
 		TableName: "test-table",
 	}
-	
+
 	ctx := context.Background()
 	video := Video{
 		VideoID:     "test-id",
@@ -86,14 +118,12 @@ func TestPutVideoError(t *testing.T) {
 		Tags:        []string{"test", "video"},
 		UploadDate:  time.Now(),
 	}
-	
 
 	mockClient.On("PutItem", mock.Anything, mock.AnythingOfType("*dynamodb.PutItemInput")).
 		Return(&dynamodb.PutItemOutput{}, errors.New("DynamoDB error"))
-	
 
 	err := db.PutVideo(ctx, video)
-	
+
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to put item in DynamoDB")
 	mockClient.AssertExpectations(t)
@@ -105,11 +135,10 @@ func TestGetVideoById(t *testing.T) {
 		Client:    mockClient,
 		TableName: "test-table",
 	}
-	
+
 	ctx := context.Background()
 	videoID := "test-id"
 	uploadTime := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
-	
 
 	getItemOutput := &dynamodb.GetItemOutput{
 		Item: map[string]types.AttributeValue{
@@ -117,21 +146,19 @@ func TestGetVideoById(t *testing.T) {
 			"title":       &types.AttributeValueMemberS{Value: "Test Video"},
 			"description": &types.AttributeValueMemberS{Value: "Test Description"},
 			"url":         &types.AttributeValueMemberS{Value: "https://example.com/video"},
-			"tags":        &types.AttributeValueMemberL{Value: []types.AttributeValue{
+			"tags": &types.AttributeValueMemberL{Value: []types.AttributeValue{
 				&types.AttributeValueMemberS{Value: "test"},
 				&types.AttributeValueMemberS{Value: "video"},
 			}},
 			"upload_date": &types.AttributeValueMemberS{Value: uploadTime.Format(time.RFC3339)},
 		},
 	}
-	
 
 	mockClient.On("GetItem", mock.Anything, mock.AnythingOfType("*dynamodb.GetItemInput")).
 		Return(getItemOutput, nil)
-	
 
 	video, err := db.GetVideoById(ctx, videoID)
-	
+
 	assert.NoError(t, err)
 	assert.NotNil(t, video)
 	assert.Equal(t, videoID, video.VideoID)
@@ -148,17 +175,15 @@ func TestGetVideoByIdNotFound(t *testing.T) {
 		Client:    mockClient,
 		TableName: "test-table",
 	}
-	
+
 	ctx := context.Background()
 	videoID := "nonexistent-id"
-	
 
 	mockClient.On("GetItem", mock.Anything, mock.AnythingOfType("*dynamodb.GetItemInput")).
 		Return(&dynamodb.GetItemOutput{}, nil)
-	
 
 	video, err := db.GetVideoById(ctx, videoID)
-	
+
 	assert.Error(t, err)
 	assert.True(t, errors.Is(err, ErrVideoNotFound))
 	assert.Nil(t, video)
@@ -171,20 +196,106 @@ func TestGetVideoByIdError(t *testing.T) {
 		Client:    mockClient,
 		TableName: "test-table",
 	}
-	
+
 	ctx := context.Background()
 	videoID := "test-id"
-	
 
 	mockClient.On("GetItem", mock.Anything, mock.AnythingOfType("*dynamodb.GetItemInput")).
 		Return(&dynamodb.GetItemOutput{}, errors.New("DynamoDB error"))
-	
 
 	video, err := db.GetVideoById(ctx, videoID)
-	
+
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to get item from DynamoDB")
 
 	assert.Nil(t, video)
 	mockClient.AssertExpectations(t)
-}
\ No newline at end of file
+}
+
+func TestUpdateVideo(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{
+		Client:    mockClient,
+		TableName: "test-table",
+	}
+
+	ctx := context.Background()
+
+	mockClient.On("UpdateItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+		return *input.UpdateExpression == "SET #f0 = :v0, #f1 = :v1" &&
+			input.ExpressionAttributeNames["#f0"] == "description" &&
+			input.ExpressionAttributeNames["#f1"] == "tags"
+	})).Return(&dynamodb.UpdateItemOutput{}, nil)
+
+	err := db.UpdateVideo(ctx, "test-id", map[string]interface{}{
+		"tags":        []string{"transcoded"},
+		"description": "Processed",
+	})
+
+	assert.NoError(t, err)
+	mockClient.AssertExpectations(t)
+}
+
+func TestUpdateVideoNotFound(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{
+		Client:    mockClient,
+		TableName: "test-table",
+	}
+
+	ctx := context.Background()
+
+	mockClient.On("UpdateItem", mock.Anything, mock.AnythingOfType("*dynamodb.UpdateItemInput")).
+		Return(&dynamodb.UpdateItemOutput{}, &types.ConditionalCheckFailedException{})
+
+	err := db.UpdateVideo(ctx, "missing-id", map[string]interface{}{"description": "Processed"})
+
+	assert.True(t, errors.Is(err, ErrVideoNotFound))
+	mockClient.AssertExpectations(t)
+}
+
+func TestSaveCheckpointCreatesMap(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{
+		Client:    mockClient,
+		TableName: "test-table",
+	}
+
+	ctx := context.Background()
+	checkpoint := StageCheckpoint{
+		Artifacts:   map[string]string{"transcoded_key": "processed/test-id/transcoded.mp4"},
+		CompletedAt: time.Now(),
+	}
+
+	mockClient.On("UpdateItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+		return *input.UpdateExpression == "SET #cp.#stage = :cp"
+	})).Return(&dynamodb.UpdateItemOutput{}, &types.ConditionalCheckFailedException{}).Once()
+	mockClient.On("UpdateItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+		cps, ok := input.ExpressionAttributeValues[":cps"].(*types.AttributeValueMemberM)
+		return *input.UpdateExpression == "SET #cp = :cps" && ok && cps.Value["transcode"] != nil
+	})).Return(&dynamodb.UpdateItemOutput{}, nil).Once()
+
+	err := db.SaveCheckpoint(ctx, "test-id", "transcode", checkpoint)
+
+	assert.NoError(t, err)
+	mockClient.AssertExpectations(t)
+}
+
+func TestSaveCheckpointError(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{
+		Client:    mockClient,
+		TableName: "test-table",
+	}
+
+	ctx := context.Background()
+
+	mockClient.On("UpdateItem", mock.Anything, mock.AnythingOfType("*dynamodb.UpdateItemInput")).
+		Return(&dynamodb.UpdateItemOutput{}, errors.New("DynamoDB error")).Once()
+
+	err := db.SaveCheckpoint(ctx, "test-id", "transcode", StageCheckpoint{})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to save checkpoint in DynamoDB")
+	mockClient.AssertExpectations(t)
+}
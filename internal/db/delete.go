@@ -0,0 +1,228 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ErrHasAliases means duplicate uploads share the video's files, so it can't
+// be deleted before them.
+var ErrHasAliases = errors.New("video has aliases")
+
+// PutAlias saves the record of a duplicate upload and counts it on the video
+// it is an alias of, in one transaction. It fails with ErrVideoNotFound if
+// that video is gone.
+func (db *DB) PutAlias(ctx context.Context, alias Video) error {
+	if alias.VideoID == "" || alias.AliasOf == "" {
+		return fmt.Errorf("%w: video ID and original cannot be empty", ErrInvalidInput)
+	}
+	item, err := attributevalue.MarshalMap(alias)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alias: %w", err)
+	}
+
+	_, err = db.Client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Put: &types.Put{
+				TableName:           aws.String(db.TableName),
+				Item:                item,
+				ConditionExpression: aws.String("attribute_not_exists(video_id)"),
+			}},
+			{Update: &types.Update{
+				TableName:                aws.String(db.TableName),
+				Key:                      videoKey(alias.AliasOf),
+				UpdateExpression:         aws.String("ADD #aliases :one"),
+				ConditionExpression:      aws.String("attribute_exists(video_id)"),
+				ExpressionAttributeNames: map[string]string{"#aliases": "aliases"},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":one": &types.AttributeValueMemberN{Value: "1"},
+				},
+			}},
+		},
+	})
+	if err != nil {
+		if _, ok := conditionFailed(err, 1); ok {
+			return ErrVideoNotFound
+		}
+		return fmt.Errorf("failed to put alias in DynamoDB: %w", err)
+	}
+	return nil
+}
+
+// DeleteVideo removes the record of a video, as it was read into video. The
+// storage its upload was counted for is given back in the same transaction,
+// so a delete that is retried only releases it once, and an alias is taken
+// off its original's count. It fails with ErrHasAliases while the video has
+// aliases, and with ErrConflict if its size changed since it was read. The
+// files and index entries it leaves are purged from the changefeed.
+func (db *DB) DeleteVideo(ctx context.Context, video *Video) error {
+	if video.VideoID == "" {
+		return fmt.Errorf("%w: video ID cannot be empty", ErrInvalidInput)
+	}
+
+	remove := &types.Delete{
+		TableName:           aws.String(db.TableName),
+		Key:                 videoKey(video.VideoID),
+		ConditionExpression: aws.String("attribute_exists(video_id) AND (attribute_not_exists(#aliases) OR #aliases <= :zero)"),
+		ExpressionAttributeNames: map[string]string{
+			"#aliases": "aliases",
+			"#size":    "size",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":zero": &types.AttributeValueMemberN{Value: "0"},
+		},
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}
+	items := []types.TransactWriteItem{{Delete: remove}}
+	switch {
+	case video.AliasOf != "":
+		*remove.ConditionExpression += " AND attribute_not_exists(#size)"
+		items = append(items, types.TransactWriteItem{Update: &types.Update{
+			TableName:                aws.String(db.TableName),
+			Key:                      videoKey(video.AliasOf),
+			UpdateExpression:         aws.String("ADD #aliases :minus"),
+			ConditionExpression:      aws.String("#aliases > :zero"),
+			ExpressionAttributeNames: map[string]string{"#aliases": "aliases"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":minus": &types.AttributeValueMemberN{Value: "-1"},
+				":zero":  &types.AttributeValueMemberN{Value: "0"},
+			},
+		}})
+	case video.Size > 0 && video.TenantID != "":
+		*remove.ConditionExpression += " AND #size = :size"
+		remove.ExpressionAttributeValues[":size"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(video.Size, 10)}
+		items = append(items, types.TransactWriteItem{Update: &types.Update{
+			TableName:                aws.String(db.DataTable),
+			Key:                      usageKey(video.TenantID, storageUsageKey),
+			UpdateExpression:         aws.String("ADD #bytes :bytes, #videos :minus"),
+			ExpressionAttributeNames: map[string]string{"#bytes": "bytes", "#videos": "videos"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":bytes": &types.AttributeValueMemberN{Value: strconv.FormatInt(-video.Size, 10)},
+				":minus": &types.AttributeValueMemberN{Value: "-1"},
+			},
+		}})
+	default:
+		*remove.ConditionExpression += " AND attribute_not_exists(#size)"
+	}
+
+	_, err := db.Client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+	if _, ok := conditionFailed(err, 1); ok && video.AliasOf != "" {
+		// Aliases saved before they were counted aren't on their original's
+		// count, and the original may be gone.
+		_, err = db.Client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items[:1]})
+	}
+	if err == nil {
+		return nil
+	}
+	reason, ok := conditionFailed(err, 0)
+	if !ok {
+		return fmt.Errorf("failed to delete video from DynamoDB: %w", err)
+	}
+	if len(reason.Item) == 0 {
+		return ErrVideoNotFound
+	}
+	var current Video
+	if err := attributevalue.UnmarshalMap(reason.Item, &current); err != nil {
+		return fmt.Errorf("failed to unmarshal video: %w", err)
+	}
+	if current.Aliases > 0 {
+		return ErrHasAliases
+	}
+	return ErrConflict
+}
+
+// DeleteVideoItems removes what a deleted video left in the data table: its
+// versions, analysis segments and frame hashes, and the frame hashes'
+// entries in the tenant's band index. The search document is left to
+// DeleteSearchDocument, which needs it to find the postings. Repeating a
+// delete that was interrupted finishes it.
+func (db *DB) DeleteVideoItems(ctx context.Context, tenantId string, videoId string) error {
+	if videoId == "" {
+		return fmt.Errorf("%w: video ID cannot be empty", ErrInvalidInput)
+	}
+
+	hashes, err := db.GetFrameHashes(ctx, videoId)
+	if err != nil {
+		return err
+	}
+	var requests []types.WriteRequest
+	for _, hash := range hashes {
+		for _, item := range hash.items(tenantId) {
+			if item.PK == videoPartition(videoId) {
+				continue
+			}
+			requests = append(requests, types.WriteRequest{DeleteRequest: &types.DeleteRequest{
+				Key: map[string]types.AttributeValue{
+					"pk": &types.AttributeValueMemberS{Value: item.PK},
+					"sk": &types.AttributeValueMemberS{Value: item.SK},
+				},
+			}})
+		}
+	}
+
+	// The band entries go first, while the frame hashes that find them are
+	// still there.
+	keys, err := db.queryPartitionKeys(ctx, videoPartition(videoId))
+	if err != nil {
+		return err
+	}
+	for _, sk := range keys {
+		if sk == searchDocumentSortKey {
+			continue
+		}
+		requests = append(requests, types.WriteRequest{DeleteRequest: &types.DeleteRequest{
+			Key: map[string]types.AttributeValue{
+				"pk": &types.AttributeValueMemberS{Value: videoPartition(videoId)},
+				"sk": &types.AttributeValueMemberS{Value: sk},
+			},
+		}})
+	}
+	return db.batchWrite(ctx, db.DataTable, requests)
+}
+
+// queryPartitionKeys returns the sort keys of every item in a data table
+// partition.
+func (db *DB) queryPartitionKeys(ctx context.Context, pk string) ([]string, error) {
+	var keys []string
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(db.DataTable),
+		KeyConditionExpression: aws.String("pk = :pk"),
+		ProjectionExpression:   aws.String("sk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: pk},
+		},
+	}
+	for {
+		output, err := db.Client.Query(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query %s from DynamoDB: %w", pk, err)
+		}
+		for _, item := range output.Items {
+			if sk, ok := item["sk"].(*types.AttributeValueMemberS); ok {
+				keys = append(keys, sk.Value)
+			}
+		}
+		if len(output.LastEvaluatedKey) == 0 {
+			return keys, nil
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
+
+// conditionFailed reports whether err cancelled a transaction because the
+// condition of its i-th item failed, and why.
+func conditionFailed(err error, i int) (types.CancellationReason, bool) {
+	var cancelled *types.TransactionCanceledException
+	if !errors.As(err, &cancelled) || i >= len(cancelled.CancellationReasons) {
+		return types.CancellationReason{}, false
+	}
+	reason := cancelled.CancellationReasons[i]
+	return reason, aws.ToString(reason.Code) == "ConditionalCheckFailed"
+}
@@ -0,0 +1,180 @@
+package db
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// addN adds the number attribute value to *total.
+func addN(total *int64, v types.AttributeValue) {
+	n, _ := strconv.ParseInt(v.(*types.AttributeValueMemberN).Value, 10, 64)
+	*total += n
+}
+
+func TestDeleteVideoReleasesStorage(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{Client: mockClient, TableName: "test-table", DataTable: "test-table-data"}
+	ctx := context.Background()
+
+	// The storage counter, as reservations and deletes change it.
+	var bytes, videos int64
+	mockClient.On("UpdateItem", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		in := args.Get(1).(*dynamodb.UpdateItemInput)
+		addN(&bytes, in.ExpressionAttributeValues[":bytes"])
+		addN(&videos, in.ExpressionAttributeValues[":one"])
+	}).Return(&dynamodb.UpdateItemOutput{}, nil)
+	mockClient.On("TransactWriteItems", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		in := args.Get(1).(*dynamodb.TransactWriteItemsInput)
+		for _, item := range in.TransactItems {
+			if item.Update != nil && aws.ToString(item.Update.TableName) == "test-table-data" {
+				addN(&bytes, item.Update.ExpressionAttributeValues[":bytes"])
+				addN(&videos, item.Update.ExpressionAttributeValues[":minus"])
+			}
+		}
+	}).Return(&dynamodb.TransactWriteItemsOutput{}, nil)
+	counter := &dynamodb.GetItemOutput{}
+	mockClient.On("GetItem", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		counter.Item = nil
+		if args.Get(1).(*dynamodb.GetItemInput).Key["sk"].(*types.AttributeValueMemberS).Value == "storage" {
+			counter.Item = map[string]types.AttributeValue{
+				"bytes":  &types.AttributeValueMemberN{Value: strconv.FormatInt(bytes, 10)},
+				"videos": &types.AttributeValueMemberN{Value: strconv.FormatInt(videos, 10)},
+			}
+		}
+	}).Return(counter, nil)
+
+	assert.NoError(t, db.ReserveStorage(ctx, "acme", 300, 0))
+	assert.NoError(t, db.ReserveStorage(ctx, "acme", 200, 0))
+	usage, err := db.GetUsage(ctx, "acme", "2025-06")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(500), usage.StorageBytes)
+	assert.Equal(t, int64(2), usage.Videos)
+
+	assert.NoError(t, db.DeleteVideo(ctx, &Video{VideoID: "video-1", TenantID: "acme", Size: 300}))
+	usage, err = db.GetUsage(ctx, "acme", "2025-06")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(200), usage.StorageBytes)
+	assert.Equal(t, int64(1), usage.Videos)
+
+	// Aliases and videos without a stored upload count no storage.
+	assert.NoError(t, db.DeleteVideo(ctx, &Video{VideoID: "video-2", TenantID: "acme", AliasOf: "video-3"}))
+	assert.NoError(t, db.DeleteVideo(ctx, &Video{VideoID: "video-4", TenantID: "acme"}))
+	usage, err = db.GetUsage(ctx, "acme", "2025-06")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(200), usage.StorageBytes)
+}
+
+func cancelled(reasons ...types.CancellationReason) error {
+	return &types.TransactionCanceledException{CancellationReasons: reasons}
+}
+
+var (
+	none          = types.CancellationReason{Code: aws.String("None")}
+	checkFailed   = types.CancellationReason{Code: aws.String("ConditionalCheckFailed")}
+	checkFailedOn = func(item map[string]types.AttributeValue) types.CancellationReason {
+		return types.CancellationReason{Code: aws.String("ConditionalCheckFailed"), Item: item}
+	}
+)
+
+func TestDeleteVideoConditions(t *testing.T) {
+	ctx := context.Background()
+	video := &Video{VideoID: "video-1", TenantID: "acme", Size: 300}
+
+	tests := []struct {
+		name   string
+		reason types.CancellationReason
+		want   error
+	}{
+		{"gone", checkFailed, ErrVideoNotFound},
+		{"aliases", checkFailedOn(map[string]types.AttributeValue{
+			"video_id": &types.AttributeValueMemberS{Value: "video-1"},
+			"aliases":  &types.AttributeValueMemberN{Value: "2"},
+		}), ErrHasAliases},
+		{"resized", checkFailedOn(map[string]types.AttributeValue{
+			"video_id": &types.AttributeValueMemberS{Value: "video-1"},
+			"size":     &types.AttributeValueMemberN{Value: "400"},
+		}), ErrConflict},
+	}
+	for _, tt := range tests {
+		mockClient := new(mockDynamoDBClient)
+		db := &DB{Client: mockClient, TableName: "test-table", DataTable: "test-table-data"}
+		mockClient.On("TransactWriteItems", mock.Anything, mock.Anything).
+			Return(&dynamodb.TransactWriteItemsOutput{}, cancelled(tt.reason, none))
+
+		assert.ErrorIs(t, db.DeleteVideo(ctx, video), tt.want, tt.name)
+	}
+}
+
+func TestDeleteAliasOfUncountedOriginal(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{Client: mockClient, TableName: "test-table", DataTable: "test-table-data"}
+
+	// The original doesn't count the alias, so it is deleted on its own.
+	mockClient.On("TransactWriteItems", mock.Anything, mock.MatchedBy(func(in *dynamodb.TransactWriteItemsInput) bool {
+		return len(in.TransactItems) == 2
+	})).Return(&dynamodb.TransactWriteItemsOutput{}, cancelled(none, checkFailed)).Once()
+	mockClient.On("TransactWriteItems", mock.Anything, mock.MatchedBy(func(in *dynamodb.TransactWriteItemsInput) bool {
+		return len(in.TransactItems) == 1 && in.TransactItems[0].Delete != nil
+	})).Return(&dynamodb.TransactWriteItemsOutput{}, nil).Once()
+
+	assert.NoError(t, db.DeleteVideo(context.Background(), &Video{VideoID: "video-2", TenantID: "acme", AliasOf: "video-1"}))
+	mockClient.AssertExpectations(t)
+}
+
+func TestPutAliasOfDeletedVideo(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{Client: mockClient, TableName: "test-table", DataTable: "test-table-data"}
+	mockClient.On("TransactWriteItems", mock.Anything, mock.Anything).
+		Return(&dynamodb.TransactWriteItemsOutput{}, cancelled(none, checkFailed))
+
+	err := db.PutAlias(context.Background(), Video{VideoID: "video-2", TenantID: "acme", AliasOf: "video-1"})
+	assert.ErrorIs(t, err, ErrVideoNotFound)
+}
+
+func TestDeleteVideoItems(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{Client: mockClient, TableName: "test-table", DataTable: "test-table-data"}
+
+	mockClient.On("Query", mock.Anything, mock.MatchedBy(func(in *dynamodb.QueryInput) bool {
+		return in.ExpressionAttributeValues[":prefix"] != nil
+	})).Return(&dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{{
+		"pk":       &types.AttributeValueMemberS{Value: "video#video-1"},
+		"sk":       &types.AttributeValueMemberS{Value: "phash#000"},
+		"video_id": &types.AttributeValueMemberS{Value: "video-1"},
+		"frame":    &types.AttributeValueMemberN{Value: "0"},
+		"hash":     &types.AttributeValueMemberS{Value: "1"},
+	}}}, nil)
+	mockClient.On("Query", mock.Anything, mock.Anything).Return(&dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{
+		{"sk": &types.AttributeValueMemberS{Value: "phash#000"}},
+		{"sk": &types.AttributeValueMemberS{Value: "version#0000000001"}},
+		{"sk": &types.AttributeValueMemberS{Value: "analysis#label#0000000000"}},
+		{"sk": &types.AttributeValueMemberS{Value: "search"}},
+	}}, nil)
+	var deleted []string
+	mockClient.On("BatchWriteItem", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		for _, req := range args.Get(1).(*dynamodb.BatchWriteItemInput).RequestItems["test-table-data"] {
+			key := req.DeleteRequest.Key
+			deleted = append(deleted, key["pk"].(*types.AttributeValueMemberS).Value+" "+key["sk"].(*types.AttributeValueMemberS).Value)
+		}
+	}).Return(&dynamodb.BatchWriteItemOutput{}, nil)
+
+	assert.NoError(t, db.DeleteVideoItems(context.Background(), "acme", "video-1"))
+	// The band entries go before the frame hashes, and the search document
+	// is left for DeleteSearchDocument.
+	assert.Equal(t, []string{
+		"tenant#acme#phash#0#0001 video-1#000",
+		"tenant#acme#phash#1#0000 video-1#000",
+		"tenant#acme#phash#2#0000 video-1#000",
+		"tenant#acme#phash#3#0000 video-1#000",
+		"video#video-1 phash#000",
+		"video#video-1 version#0000000001",
+		"video#video-1 analysis#label#0000000000",
+	}, deleted)
+}
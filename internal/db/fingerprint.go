@@ -0,0 +1,263 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"math/bits"
+	"sort"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Frame hashes are indexed by multi-index hashing: each 64-bit hash is split
+// into four 16-bit bands and stored once under every band's value. Two hashes
+// within a Hamming distance of 3 must agree exactly on at least one band, so
+// looking up the four bands of a hash finds them without scanning every
+// video. Hashes further apart are usually found too, and callers filter
+// candidates by their full distance.
+const (
+	hashBands    = 4
+	hashBandBits = 64 / hashBands
+)
+
+// FrameHash is the perceptual hash of a frame sampled from a video.
+type FrameHash struct {
+	VideoID string
+	Frame   int
+	Hash    uint64
+}
+
+// SimilarVideo is a video with frames close to those of another video.
+// MatchedFrames counts the frames of the queried video, out of Frames, that
+// have a match within the distance limit.
+type SimilarVideo struct {
+	VideoID       string
+	MatchedFrames int
+	Frames        int
+	MinDistance   int
+}
+
+type frameHashItem struct {
+	PK      string `dynamodbav:"pk"`
+	SK      string `dynamodbav:"sk"`
+	VideoID string `dynamodbav:"video_id"`
+	Frame   int    `dynamodbav:"frame"`
+	Hash    string `dynamodbav:"hash"`
+}
+
+const frameHashPrefix = "phash#"
+
+func frameHashSortKey(frame int) string {
+	return fmt.Sprintf("%s%03d", frameHashPrefix, frame)
+}
+
+func hashBandPartition(tenantId string, band int, hash uint64) string {
+	value := (hash >> (uint(band) * hashBandBits)) & (1<<hashBandBits - 1)
+	return tenantPartition(tenantId, fmt.Sprintf("phash#%d#%04x", band, value))
+}
+
+func hashBandSortKey(videoId string, frame int) string {
+	return fmt.Sprintf("%s#%03d", videoId, frame)
+}
+
+// items are the per-video item of a frame hash and its entries in the
+// tenant's band index.
+func (f FrameHash) items(tenantId string) []frameHashItem {
+	hash := strconv.FormatUint(f.Hash, 16)
+	items := []frameHashItem{{
+		PK:      videoPartition(f.VideoID),
+		SK:      frameHashSortKey(f.Frame),
+		VideoID: f.VideoID,
+		Frame:   f.Frame,
+		Hash:    hash,
+	}}
+	for band := 0; band < hashBands; band++ {
+		items = append(items, frameHashItem{
+			PK:      hashBandPartition(tenantId, band, f.Hash),
+			SK:      hashBandSortKey(f.VideoID, f.Frame),
+			VideoID: f.VideoID,
+			Frame:   f.Frame,
+			Hash:    hash,
+		})
+	}
+	return items
+}
+
+func (item frameHashItem) frameHash() (FrameHash, error) {
+	hash, err := strconv.ParseUint(item.Hash, 16, 64)
+	if err != nil {
+		return FrameHash{}, fmt.Errorf("invalid frame hash %q: %w", item.Hash, err)
+	}
+	return FrameHash{VideoID: item.VideoID, Frame: item.Frame, Hash: hash}, nil
+}
+
+// PutFrameHashes replaces the frame hashes of a video, removing its entries
+// for frames that are no longer hashed the same way from the tenant's band
+// index.
+func (db *DB) PutFrameHashes(ctx context.Context, tenantId string, videoId string, hashes []uint64) error {
+	if videoId == "" {
+		return fmt.Errorf("%w: video ID cannot be empty", ErrInvalidInput)
+	}
+
+	existing, err := db.GetFrameHashes(ctx, videoId)
+	if err != nil {
+		return err
+	}
+
+	written := make(map[string]bool)
+	var requests []types.WriteRequest
+	for frame, hash := range hashes {
+		for _, item := range (FrameHash{VideoID: videoId, Frame: frame, Hash: hash}).items(tenantId) {
+			av, err := attributevalue.MarshalMap(item)
+			if err != nil {
+				return fmt.Errorf("failed to marshal frame hash: %w", err)
+			}
+			written[item.PK+"|"+item.SK] = true
+			requests = append(requests, types.WriteRequest{PutRequest: &types.PutRequest{Item: av}})
+		}
+	}
+	for _, old := range existing {
+		for _, item := range old.items(tenantId) {
+			if written[item.PK+"|"+item.SK] {
+				continue
+			}
+			requests = append(requests, types.WriteRequest{DeleteRequest: &types.DeleteRequest{
+				Key: map[string]types.AttributeValue{
+					"pk": &types.AttributeValueMemberS{Value: item.PK},
+					"sk": &types.AttributeValueMemberS{Value: item.SK},
+				},
+			}})
+		}
+	}
+
+	return db.batchWrite(ctx, db.DataTable, requests)
+}
+
+// GetFrameHashes returns the frame hashes of a video in frame order.
+func (db *DB) GetFrameHashes(ctx context.Context, videoId string) ([]FrameHash, error) {
+	if videoId == "" {
+		return nil, fmt.Errorf("%w: video ID cannot be empty", ErrInvalidInput)
+	}
+
+	return db.queryFrameHashes(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(db.DataTable),
+		KeyConditionExpression: aws.String("pk = :pk AND begins_with(sk, :prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":     &types.AttributeValueMemberS{Value: videoPartition(videoId)},
+			":prefix": &types.AttributeValueMemberS{Value: frameHashPrefix},
+		},
+	})
+}
+
+// FindFrameCandidates returns the frames of the tenant's videos that share at
+// least one band with hash. The result may include frames of any distance and
+// the frames of the video hash came from.
+func (db *DB) FindFrameCandidates(ctx context.Context, tenantId string, hash uint64) ([]FrameHash, error) {
+	seen := make(map[string]bool)
+	var candidates []FrameHash
+	for band := 0; band < hashBands; band++ {
+		found, err := db.queryFrameHashes(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(db.DataTable),
+			KeyConditionExpression: aws.String("pk = :pk"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":pk": &types.AttributeValueMemberS{Value: hashBandPartition(tenantId, band, hash)},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range found {
+			key := hashBandSortKey(f.VideoID, f.Frame)
+			if !seen[key] {
+				seen[key] = true
+				candidates = append(candidates, f)
+			}
+		}
+	}
+	return candidates, nil
+}
+
+// FindSimilarVideos returns the tenant's other videos with frames within
+// maxDistance bits of the frames of videoId, best matches first.
+func (db *DB) FindSimilarVideos(ctx context.Context, tenantId string, videoId string, maxDistance int) ([]SimilarVideo, error) {
+	frames, err := db.GetFrameHashes(ctx, videoId)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make(map[string]*SimilarVideo)
+	for _, frame := range frames {
+		candidates, err := db.FindFrameCandidates(ctx, tenantId, frame.Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		matched := make(map[string]bool)
+		for _, c := range candidates {
+			if c.VideoID == videoId {
+				continue
+			}
+			distance := bits.OnesCount64(frame.Hash ^ c.Hash)
+			if distance > maxDistance {
+				continue
+			}
+			match, ok := matches[c.VideoID]
+			if !ok {
+				match = &SimilarVideo{VideoID: c.VideoID, Frames: len(frames), MinDistance: distance}
+				matches[c.VideoID] = match
+			}
+			if !matched[c.VideoID] {
+				matched[c.VideoID] = true
+				match.MatchedFrames++
+			}
+			if distance < match.MinDistance {
+				match.MinDistance = distance
+			}
+		}
+	}
+
+	similar := make([]SimilarVideo, 0, len(matches))
+	for _, match := range matches {
+		similar = append(similar, *match)
+	}
+	sort.Slice(similar, func(i, j int) bool {
+		a, b := similar[i], similar[j]
+		if a.MatchedFrames != b.MatchedFrames {
+			return a.MatchedFrames > b.MatchedFrames
+		}
+		if a.MinDistance != b.MinDistance {
+			return a.MinDistance < b.MinDistance
+		}
+		return a.VideoID < b.VideoID
+	})
+	return similar, nil
+}
+
+func (db *DB) queryFrameHashes(ctx context.Context, input *dynamodb.QueryInput) ([]FrameHash, error) {
+	var hashes []FrameHash
+	for {
+		output, err := db.Client.Query(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query frame hashes from DynamoDB: %w", err)
+		}
+		var page []frameHashItem
+		if err := attributevalue.UnmarshalListOfMaps(output.Items, &page); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal frame hashes: %w", err)
+		}
+		for _, item := range page {
+			f, err := item.frameHash()
+			if err != nil {
+				return nil, err
+			}
+			hashes = append(hashes, f)
+		}
+		if len(output.LastEvaluatedKey) == 0 {
+			return hashes, nil
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
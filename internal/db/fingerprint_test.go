@@ -0,0 +1,128 @@
+package db
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestHashBandPartition(t *testing.T) {
+	hash := uint64(0x1111222233334444)
+
+	assert.Equal(t, "tenant#acme#phash#0#4444", hashBandPartition("acme", 0, hash))
+	assert.Equal(t, "tenant#acme#phash#3#1111", hashBandPartition("acme", 3, hash))
+	assert.Equal(t, "phash#0#4444", hashBandPartition("", 0, hash))
+}
+
+func TestPutFrameHashesReplacesIndex(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{Client: mockClient, TableName: "test-table", DataTable: "test-table-data"}
+
+	// Frame 0 is unchanged and frame 1 goes away.
+	mockClient.On("Query", mock.Anything, mock.Anything).Return(&dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{
+		{
+			"pk": &types.AttributeValueMemberS{Value: "video#vid-1"}, "sk": &types.AttributeValueMemberS{Value: "phash#000"},
+			"video_id": &types.AttributeValueMemberS{Value: "vid-1"}, "frame": &types.AttributeValueMemberN{Value: "0"},
+			"hash": &types.AttributeValueMemberS{Value: "1111222233334444"},
+		},
+		{
+			"pk": &types.AttributeValueMemberS{Value: "video#vid-1"}, "sk": &types.AttributeValueMemberS{Value: "phash#001"},
+			"video_id": &types.AttributeValueMemberS{Value: "vid-1"}, "frame": &types.AttributeValueMemberN{Value: "1"},
+			"hash": &types.AttributeValueMemberS{Value: "ffff"},
+		},
+	}}, nil)
+
+	var puts, deletes int
+	mockClient.On("BatchWriteItem", mock.Anything, mock.MatchedBy(func(in *dynamodb.BatchWriteItemInput) bool {
+		for _, r := range in.RequestItems["test-table-data"] {
+			if r.PutRequest != nil {
+				puts++
+			}
+			if r.DeleteRequest != nil {
+				deletes++
+			}
+		}
+		return true
+	})).Return(&dynamodb.BatchWriteItemOutput{}, nil)
+
+	err := db.PutFrameHashes(context.Background(), "acme", "vid-1", []uint64{0x1111222233334444})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1+hashBands, puts)
+	assert.Equal(t, 1+hashBands, deletes)
+}
+
+func TestFindFrameCandidatesDeduplicates(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{Client: mockClient, TableName: "test-table", DataTable: "test-table-data"}
+
+	// The same frame matches on every band.
+	mockClient.On("Query", mock.Anything, mock.Anything).Return(&dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{{
+		"pk": &types.AttributeValueMemberS{Value: "tenant#acme#phash#0#4444"}, "sk": &types.AttributeValueMemberS{Value: "vid-2#003"},
+		"video_id": &types.AttributeValueMemberS{Value: "vid-2"}, "frame": &types.AttributeValueMemberN{Value: "3"},
+		"hash": &types.AttributeValueMemberS{Value: "1111222233334444"},
+	}}}, nil)
+
+	candidates, err := db.FindFrameCandidates(context.Background(), "acme", 0x1111222233334444)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []FrameHash{{VideoID: "vid-2", Frame: 3, Hash: 0x1111222233334444}}, candidates)
+	mockClient.AssertNumberOfCalls(t, "Query", hashBands)
+}
+
+func frameHashItems(pk string, frames ...FrameHash) []map[string]types.AttributeValue {
+	var items []map[string]types.AttributeValue
+	for _, f := range frames {
+		items = append(items, map[string]types.AttributeValue{
+			"pk":       &types.AttributeValueMemberS{Value: pk},
+			"sk":       &types.AttributeValueMemberS{Value: hashBandSortKey(f.VideoID, f.Frame)},
+			"video_id": &types.AttributeValueMemberS{Value: f.VideoID},
+			"frame":    &types.AttributeValueMemberN{Value: strconv.Itoa(f.Frame)},
+			"hash":     &types.AttributeValueMemberS{Value: strconv.FormatUint(f.Hash, 16)},
+		})
+	}
+	return items
+}
+
+func TestFindSimilarVideos(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{Client: mockClient, TableName: "test-table", DataTable: "test-table-data"}
+
+	query := []FrameHash{
+		{VideoID: "vid-1", Frame: 0, Hash: 0x00000000000000ff},
+		{VideoID: "vid-1", Frame: 1, Hash: 0x000000000000ff00},
+	}
+	isPartition := func(prefix string) interface{} {
+		return mock.MatchedBy(func(in *dynamodb.QueryInput) bool {
+			pk := in.ExpressionAttributeValues[":pk"].(*types.AttributeValueMemberS).Value
+			return strings.HasPrefix(pk, prefix)
+		})
+	}
+	mockClient.On("Query", mock.Anything, isPartition("video#")).
+		Return(&dynamodb.QueryOutput{Items: frameHashItems("video#vid-1", query...)}, nil)
+	mockClient.On("Query", mock.Anything, isPartition("tenant#acme#phash#")).
+		Return(&dynamodb.QueryOutput{Items: frameHashItems("tenant#acme#phash#0#00ff",
+			query[0],
+			// A re-encode matching both frames closely.
+			FrameHash{VideoID: "vid-2", Frame: 0, Hash: 0x00000000000000fe},
+			FrameHash{VideoID: "vid-2", Frame: 1, Hash: 0x000000000000fe00},
+			// Shares a band but is too far from either frame.
+			FrameHash{VideoID: "vid-3", Frame: 0, Hash: 0xffffffff000000ff},
+			// Matches one frame at the limit.
+			FrameHash{VideoID: "vid-4", Frame: 5, Hash: 0x000000000000000f},
+		)}, nil)
+
+	similar, err := db.FindSimilarVideos(context.Background(), "acme", "vid-1", 4)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []SimilarVideo{
+		{VideoID: "vid-2", MatchedFrames: 2, Frames: 2, MinDistance: 1},
+		{VideoID: "vid-4", MatchedFrames: 1, Frames: 2, MinDistance: 4},
+	}, similar)
+}
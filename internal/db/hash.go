@@ -0,0 +1,112 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// contentHashItem maps the SHA-256 of an uploaded file to the first video
+// with that content to finish processing. It lives in the data table so the
+// lookup is a single GetItem rather than a scan of the videos table.
+type contentHashItem struct {
+	PK        string    `dynamodbav:"pk"`
+	SK        string    `dynamodbav:"sk"`
+	VideoID   string    `dynamodbav:"video_id"`
+	IndexedAt time.Time `dynamodbav:"indexed_at"`
+}
+
+func contentHashKey(tenantId, hash string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: tenantPartition(tenantId, "sha256#"+hash)},
+		"sk": &types.AttributeValueMemberS{Value: "video"},
+	}
+}
+
+// PutContentHash indexes a processed video by the hash of its source file.
+// The first video of a tenant indexed for a hash keeps it; later ones are
+// ignored.
+func (db *DB) PutContentHash(ctx context.Context, tenantId string, hash string, videoId string) error {
+	if hash == "" || videoId == "" {
+		return fmt.Errorf("%w: hash and video ID cannot be empty", ErrInvalidInput)
+	}
+
+	key := contentHashKey(tenantId, hash)
+	item, err := attributevalue.MarshalMap(contentHashItem{
+		VideoID:   videoId,
+		IndexedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal content hash: %w", err)
+	}
+	for name, value := range key {
+		item[name] = value
+	}
+
+	_, err = db.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(db.DataTable),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(pk)"),
+	})
+	if err != nil {
+		if isConditionalCheckFailed(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to index content hash: %w", err)
+	}
+	return nil
+}
+
+// GetVideoByHash returns the tenant's video indexed under hash, or
+// ErrVideoNotFound when none of its processed videos has that content.
+func (db *DB) GetVideoByHash(ctx context.Context, tenantId string, hash string) (*Video, error) {
+	if hash == "" {
+		return nil, fmt.Errorf("%w: hash cannot be empty", ErrInvalidInput)
+	}
+
+	result, err := db.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(db.DataTable),
+		Key:       contentHashKey(tenantId, hash),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get content hash from DynamoDB: %w", err)
+	}
+	if len(result.Item) == 0 {
+		return nil, ErrVideoNotFound
+	}
+
+	var item contentHashItem
+	if err := attributevalue.UnmarshalMap(result.Item, &item); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal content hash: %w", err)
+	}
+	return db.GetVideoForTenant(ctx, tenantId, item.VideoID)
+}
+
+// DeleteContentHash removes the hash of a deleted video's source from the
+// tenant's index, unless another video is indexed under it.
+func (db *DB) DeleteContentHash(ctx context.Context, tenantId string, hash string, videoId string) error {
+	if hash == "" || videoId == "" {
+		return fmt.Errorf("%w: hash and video ID cannot be empty", ErrInvalidInput)
+	}
+
+	_, err := db.Client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(db.DataTable),
+		Key:                 contentHashKey(tenantId, hash),
+		ConditionExpression: aws.String("video_id = :id"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id": &types.AttributeValueMemberS{Value: videoId},
+		},
+	})
+	if err != nil {
+		if isConditionalCheckFailed(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to delete content hash: %w", err)
+	}
+	return nil
+}
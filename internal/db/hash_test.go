@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetVideoByHash(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{Client: mockClient, TableName: "test-table", DataTable: "test-table-data"}
+
+	mockClient.On("GetItem", mock.Anything, mock.MatchedBy(func(in *dynamodb.GetItemInput) bool {
+		pk := in.Key["pk"].(*types.AttributeValueMemberS).Value
+		return *in.TableName == "test-table-data" && pk == "tenant#acme#sha256#abc123"
+	})).Return(&dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+		"pk":       &types.AttributeValueMemberS{Value: "tenant#acme#sha256#abc123"},
+		"sk":       &types.AttributeValueMemberS{Value: "video"},
+		"video_id": &types.AttributeValueMemberS{Value: "original-id"},
+	}}, nil)
+	mockClient.On("GetItem", mock.Anything, mock.MatchedBy(func(in *dynamodb.GetItemInput) bool {
+		return *in.TableName == "test-table"
+	})).Return(&dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+		"video_id":  &types.AttributeValueMemberS{Value: "original-id"},
+		"tenant_id": &types.AttributeValueMemberS{Value: "acme"},
+		"status":    &types.AttributeValueMemberS{Value: StatusReady},
+	}}, nil)
+
+	video, err := db.GetVideoByHash(context.Background(), "acme", "abc123")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "original-id", video.VideoID)
+	assert.Equal(t, StatusReady, video.Status)
+}
+
+func TestGetVideoByHashNotFound(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{Client: mockClient, TableName: "test-table", DataTable: "test-table-data"}
+
+	mockClient.On("GetItem", mock.Anything, mock.Anything).Return(&dynamodb.GetItemOutput{}, nil)
+
+	_, err := db.GetVideoByHash(context.Background(), "acme", "abc123")
+
+	assert.ErrorIs(t, err, ErrVideoNotFound)
+	mockClient.AssertNumberOfCalls(t, "GetItem", 1)
+}
+
+func TestPutContentHashKeepsFirstVideo(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{Client: mockClient, TableName: "test-table", DataTable: "test-table-data"}
+
+	mockClient.On("PutItem", mock.Anything, mock.MatchedBy(func(in *dynamodb.PutItemInput) bool {
+		return *in.ConditionExpression == "attribute_not_exists(pk)" &&
+			in.Item["video_id"].(*types.AttributeValueMemberS).Value == "second-id"
+	})).Return(&dynamodb.PutItemOutput{}, &types.ConditionalCheckFailedException{})
+
+	err := db.PutContentHash(context.Background(), "acme", "abc123", "second-id")
+
+	assert.NoError(t, err)
+}
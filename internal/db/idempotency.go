@@ -0,0 +1,162 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var ErrIdempotencyKeyLost = errors.New("idempotency key claimed by another request")
+
+// States of an idempotency key. An in-progress key is held by the request
+// that claimed it until LockedUntil.
+const (
+	IdempotencyInProgress = "in_progress"
+	IdempotencyCompleted  = "completed"
+)
+
+// IdempotencyRecord is a request made with an idempotency key, and once it
+// has completed, the response to replay. Token identifies the request that
+// holds the key. LockedUntil and ExpiresAt are in Unix seconds; ExpiresAt is
+// the data table's TTL attribute, and a record past it counts as gone even
+// before DynamoDB removes it.
+type IdempotencyRecord struct {
+	TenantID    string    `dynamodbav:"tenant_id"`
+	Key         string    `dynamodbav:"idempotency_key"`
+	Fingerprint string    `dynamodbav:"fingerprint"`
+	State       string    `dynamodbav:"state"`
+	Token       string    `dynamodbav:"token"`
+	LockedUntil int64     `dynamodbav:"locked_until,omitempty"`
+	StatusCode  int       `dynamodbav:"status_code,omitempty"`
+	ContentType string    `dynamodbav:"content_type,omitempty"`
+	Body        []byte    `dynamodbav:"body,omitempty"`
+	CreatedAt   time.Time `dynamodbav:"created_at"`
+	ExpiresAt   int64     `dynamodbav:"expires_at"`
+}
+
+func idempotencyKey(tenantId, key string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: tenantPartition(tenantId, "idempotency#"+key)},
+		"sk": &types.AttributeValueMemberS{Value: "request"},
+	}
+}
+
+// ClaimIdempotencyKey stores record as the in-progress request for its key,
+// unless the key is already held. A key is free when it has no record, its
+// record has expired, or its in-progress request has outlived its lock. If
+// the key is held, the holder's record is returned instead and nothing is
+// written.
+func (db *DB) ClaimIdempotencyKey(ctx context.Context, record IdempotencyRecord) (*IdempotencyRecord, error) {
+	if record.TenantID == "" || record.Key == "" || record.Token == "" {
+		return nil, fmt.Errorf("%w: tenant ID, key and token cannot be empty", ErrInvalidInput)
+	}
+	record.State = IdempotencyInProgress
+
+	item, err := attributevalue.MarshalMap(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal idempotency record: %w", err)
+	}
+	for name, value := range idempotencyKey(record.TenantID, record.Key) {
+		item[name] = value
+	}
+
+	// The record can expire or be released between a failed claim and the
+	// read of the holder, so the claim is tried again once.
+	for attempt := 0; attempt < 2; attempt++ {
+		now := strconv.FormatInt(time.Now().Unix(), 10)
+		_, err = db.Client.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName: aws.String(db.DataTable),
+			Item:      item,
+			ConditionExpression: aws.String("attribute_not_exists(pk) OR expires_at < :now OR " +
+				"(#state = :in_progress AND locked_until < :now)"),
+			ExpressionAttributeNames: map[string]string{"#state": "state"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":now":         &types.AttributeValueMemberN{Value: now},
+				":in_progress": &types.AttributeValueMemberS{Value: IdempotencyInProgress},
+			},
+		})
+		if err == nil {
+			return nil, nil
+		}
+		if !isConditionalCheckFailed(err) {
+			return nil, fmt.Errorf("failed to put idempotency record in DynamoDB: %w", err)
+		}
+
+		result, err := db.Client.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName:      aws.String(db.DataTable),
+			Key:            idempotencyKey(record.TenantID, record.Key),
+			ConsistentRead: aws.Bool(true),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get idempotency record from DynamoDB: %w", err)
+		}
+		if len(result.Item) == 0 {
+			continue
+		}
+		var holder IdempotencyRecord
+		if err := attributevalue.UnmarshalMap(result.Item, &holder); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal idempotency record: %w", err)
+		}
+		return &holder, nil
+	}
+	return nil, ErrIdempotencyKeyLost
+}
+
+// CompleteIdempotencyKey records the response of the request holding a key,
+// to be replayed until expiresAt. It fails with ErrIdempotencyKeyLost if
+// another request has taken the key over.
+func (db *DB) CompleteIdempotencyKey(ctx context.Context, tenantId, key, token string, statusCode int, contentType string, body []byte, expiresAt time.Time) error {
+	values := map[string]types.AttributeValue{
+		":completed":    &types.AttributeValueMemberS{Value: IdempotencyCompleted},
+		":status":       &types.AttributeValueMemberN{Value: strconv.Itoa(statusCode)},
+		":content_type": &types.AttributeValueMemberS{Value: contentType},
+		":expires":      &types.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt.Unix(), 10)},
+		":token":        &types.AttributeValueMemberS{Value: token},
+	}
+	update := "SET #state = :completed, status_code = :status, content_type = :content_type, expires_at = :expires REMOVE locked_until"
+	if len(body) > 0 {
+		values[":body"] = &types.AttributeValueMemberB{Value: body}
+		update = "SET #state = :completed, status_code = :status, content_type = :content_type, body = :body, expires_at = :expires REMOVE locked_until"
+	}
+
+	_, err := db.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(db.DataTable),
+		Key:                       idempotencyKey(tenantId, key),
+		UpdateExpression:          aws.String(update),
+		ConditionExpression:       aws.String("#token = :token"),
+		ExpressionAttributeNames:  map[string]string{"#state": "state", "#token": "token"},
+		ExpressionAttributeValues: values,
+	})
+	if err != nil {
+		if isConditionalCheckFailed(err) {
+			return ErrIdempotencyKeyLost
+		}
+		return fmt.Errorf("failed to complete idempotency record in DynamoDB: %w", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey frees a key held by the request with token, so the
+// request can be retried. A key taken over by another request is left to it.
+func (db *DB) ReleaseIdempotencyKey(ctx context.Context, tenantId, key, token string) error {
+	_, err := db.Client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:                aws.String(db.DataTable),
+		Key:                      idempotencyKey(tenantId, key),
+		ConditionExpression:      aws.String("#token = :token"),
+		ExpressionAttributeNames: map[string]string{"#token": "token"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":token": &types.AttributeValueMemberS{Value: token},
+		},
+	})
+	if err != nil && !isConditionalCheckFailed(err) {
+		return fmt.Errorf("failed to delete idempotency record from DynamoDB: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestClaimIdempotencyKeyReturnsHolder(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{Client: mockClient, TableName: "test-table", DataTable: "test-data"}
+
+	mockClient.On("PutItem", mock.Anything, mock.MatchedBy(func(in *dynamodb.PutItemInput) bool {
+		return in.Item["pk"].(*types.AttributeValueMemberS).Value == "tenant#acme#idempotency#key-1" &&
+			in.Item["state"].(*types.AttributeValueMemberS).Value == IdempotencyInProgress
+	})).Return(&dynamodb.PutItemOutput{}, &types.ConditionalCheckFailedException{})
+	mockClient.On("GetItem", mock.Anything, mock.MatchedBy(func(in *dynamodb.GetItemInput) bool {
+		return *in.ConsistentRead
+	})).Return(&dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+		"fingerprint": &types.AttributeValueMemberS{Value: "abc"},
+		"state":       &types.AttributeValueMemberS{Value: IdempotencyCompleted},
+		"status_code": &types.AttributeValueMemberN{Value: "201"},
+		"body":        &types.AttributeValueMemberB{Value: []byte(`{"videoId":"v1"}`)},
+	}}, nil)
+
+	holder, err := db.ClaimIdempotencyKey(context.Background(), IdempotencyRecord{
+		TenantID: "acme", Key: "key-1", Fingerprint: "abc", Token: "t1",
+	})
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, holder) {
+		assert.Equal(t, 201, holder.StatusCode)
+		assert.Equal(t, `{"videoId":"v1"}`, string(holder.Body))
+	}
+	mockClient.AssertExpectations(t)
+}
+
+func TestCompleteIdempotencyKeyLost(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{Client: mockClient, TableName: "test-table", DataTable: "test-data"}
+
+	mockClient.On("UpdateItem", mock.Anything, mock.Anything).
+		Return(&dynamodb.UpdateItemOutput{}, &types.ConditionalCheckFailedException{})
+
+	err := db.CompleteIdempotencyKey(context.Background(), "acme", "key-1", "t1", 201, "application/json", []byte("{}"), time.Now())
+
+	assert.ErrorIs(t, err, ErrIdempotencyKeyLost)
+}
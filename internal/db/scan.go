@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// VideoFilter narrows a scan of the videos table. Every field is optional.
+// Like DeliveryFilter, it applies after a page is read, so a page can hold
+// fewer than Limit videos, or none, and still have a next page.
+type VideoFilter struct {
+	TenantID string
+	Status   string
+	// ExcludeProfile skips videos last processed with this profile. Videos
+	// with no profile recorded are never skipped.
+	ExcludeProfile string
+	// UploadedAfter and UploadedBefore bound the upload date, compared as
+	// the stored RFC 3339 text.
+	UploadedAfter  time.Time
+	UploadedBefore time.Time
+	Limit          int32
+	// After continues from the video ID returned as the previous page's
+	// cursor.
+	After string
+}
+
+// ScanVideos returns a page of the videos matching filter, in no particular
+// order, and the cursor of the next page, which is empty on the last one.
+// Scans read the whole table, so they are meant for maintenance jobs rather
+// than requests.
+func (db *DB) ScanVideos(ctx context.Context, filter VideoFilter) ([]Video, string, error) {
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(db.TableName),
+	}
+	if filter.Limit > 0 {
+		input.Limit = aws.Int32(filter.Limit)
+	}
+	if filter.After != "" {
+		input.ExclusiveStartKey = videoKey(filter.After)
+	}
+
+	var conditions []string
+	names := map[string]string{}
+	values := map[string]types.AttributeValue{}
+	if filter.TenantID != "" {
+		conditions = append(conditions, "#tenant = :tenant")
+		names["#tenant"] = "tenant_id"
+		values[":tenant"] = &types.AttributeValueMemberS{Value: filter.TenantID}
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "#status = :status")
+		names["#status"] = "status"
+		values[":status"] = &types.AttributeValueMemberS{Value: filter.Status}
+	}
+	if filter.ExcludeProfile != "" {
+		conditions = append(conditions, "(attribute_not_exists(#profile) OR #profile <> :profile)")
+		names["#profile"] = "profile"
+		values[":profile"] = &types.AttributeValueMemberS{Value: filter.ExcludeProfile}
+	}
+	if !filter.UploadedAfter.IsZero() {
+		conditions = append(conditions, "#uploaded >= :after")
+		names["#uploaded"] = "upload_date"
+		values[":after"] = &types.AttributeValueMemberS{Value: filter.UploadedAfter.UTC().Format(time.RFC3339Nano)}
+	}
+	if !filter.UploadedBefore.IsZero() {
+		conditions = append(conditions, "#uploaded < :before")
+		names["#uploaded"] = "upload_date"
+		values[":before"] = &types.AttributeValueMemberS{Value: filter.UploadedBefore.UTC().Format(time.RFC3339Nano)}
+	}
+	if len(conditions) > 0 {
+		input.FilterExpression = aws.String(strings.Join(conditions, " AND "))
+		input.ExpressionAttributeNames = names
+		input.ExpressionAttributeValues = values
+	}
+
+	result, err := db.Client.Scan(ctx, input)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to scan videos: %w", err)
+	}
+	videos := []Video{}
+	if err := attributevalue.UnmarshalListOfMaps(result.Items, &videos); err != nil {
+		return nil, "", fmt.Errorf("failed to unmarshal videos: %w", err)
+	}
+
+	var next string
+	if id, ok := result.LastEvaluatedKey["video_id"].(*types.AttributeValueMemberS); ok {
+		next = id.Value
+	}
+	return videos, next, nil
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestScanVideos(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{
+		Client:    mockClient,
+		TableName: "test-table",
+	}
+
+	ctx := context.Background()
+
+	mockClient.On("Scan", mock.Anything, mock.MatchedBy(func(input *dynamodb.ScanInput) bool {
+		start, _ := input.ExclusiveStartKey["video_id"].(*types.AttributeValueMemberS)
+		return start != nil && start.Value == "v0" &&
+			aws.ToInt32(input.Limit) == 10 &&
+			aws.ToString(input.FilterExpression) == "#tenant = :tenant AND #status = :status AND "+
+				"(attribute_not_exists(#profile) OR #profile <> :profile) AND #uploaded < :before"
+	})).Return(&dynamodb.ScanOutput{
+		Items: []map[string]types.AttributeValue{{
+			"video_id":  &types.AttributeValueMemberS{Value: "v1"},
+			"tenant_id": &types.AttributeValueMemberS{Value: "acme"},
+		}},
+		LastEvaluatedKey: videoKey("v7"),
+	}, nil)
+
+	videos, next, err := db.ScanVideos(ctx, VideoFilter{
+		TenantID:       "acme",
+		Status:         StatusReady,
+		ExcludeProfile: "h264-720p",
+		UploadedBefore: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		Limit:          10,
+		After:          "v0",
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, videos, 1)
+	assert.Equal(t, "v1", videos[0].VideoID)
+	assert.Equal(t, "v7", next)
+	mockClient.AssertExpectations(t)
+}
+
+func TestClearCheckpoints(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{
+		Client:    mockClient,
+		TableName: "test-table",
+	}
+
+	ctx := context.Background()
+
+	mockClient.On("UpdateItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+		return aws.ToString(input.UpdateExpression) == "REMOVE #cp.#stage0, #cp.#stage1" &&
+			input.ExpressionAttributeNames["#stage1"] == "scenes"
+	})).Return(&dynamodb.UpdateItemOutput{}, &types.ConditionalCheckFailedException{})
+
+	err := db.ClearCheckpoints(ctx, "test-id", []string{"transcode", "scenes"})
+
+	assert.NoError(t, err)
+	assert.NoError(t, db.ClearCheckpoints(ctx, "test-id", nil))
+	mockClient.AssertExpectations(t)
+}
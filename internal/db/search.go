@@ -0,0 +1,280 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ryanschneiderman/video-api/internal/search"
+)
+
+// The search index lives in the data table, one index per tenant. Each term
+// has a partition of postings keyed by video, each video keeps its indexed
+// text and term list for snippets and reindexing, and a stats item per
+// tenant counts the indexed videos for scoring.
+type searchDocumentItem struct {
+	PK          string   `dynamodbav:"pk"`
+	SK          string   `dynamodbav:"sk"`
+	VideoID     string   `dynamodbav:"video_id"`
+	Title       string   `dynamodbav:"title"`
+	Description string   `dynamodbav:"description"`
+	Tags        []string `dynamodbav:"tags"`
+	Labels      []string `dynamodbav:"labels"`
+	Transcript  string   `dynamodbav:"transcript"`
+	Terms       []string `dynamodbav:"terms"`
+}
+
+type postingItem struct {
+	PK        string           `dynamodbav:"pk"`
+	SK        string           `dynamodbav:"sk"`
+	VideoID   string           `dynamodbav:"video_id"`
+	Positions map[string][]int `dynamodbav:"positions"`
+	Tags      []string         `dynamodbav:"tags"`
+}
+
+const searchDocumentSortKey = "search"
+
+func termPartition(tenantId, term string) string {
+	return tenantPartition(tenantId, "term#"+term)
+}
+
+func searchDocumentKey(videoId string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: videoPartition(videoId)},
+		"sk": &types.AttributeValueMemberS{Value: searchDocumentSortKey},
+	}
+}
+
+func searchStatsKey(tenantId string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: tenantPartition(tenantId, "search")},
+		"sk": &types.AttributeValueMemberS{Value: "stats"},
+	}
+}
+
+// PutSearchDocument indexes a video in its tenant's index, replacing its
+// previous postings.
+func (db *DB) PutSearchDocument(ctx context.Context, tenantId string, doc *search.Document) error {
+	if doc.VideoID == "" {
+		return fmt.Errorf("%w: video ID cannot be empty", ErrInvalidInput)
+	}
+
+	previous, err := db.getSearchDocumentItem(ctx, doc.VideoID)
+	if err != nil {
+		return err
+	}
+
+	postings := search.Postings(doc)
+	item := searchDocumentItem{
+		PK:          videoPartition(doc.VideoID),
+		SK:          searchDocumentSortKey,
+		VideoID:     doc.VideoID,
+		Title:       doc.Title,
+		Description: doc.Description,
+		Tags:        doc.Tags,
+		Labels:      doc.Labels,
+		Transcript:  doc.Transcript,
+		Terms:       make([]string, 0, len(postings)),
+	}
+
+	requests := make([]types.WriteRequest, 0, len(postings)+1)
+	current := make(map[string]bool, len(postings))
+	for _, p := range postings {
+		av, err := attributevalue.MarshalMap(postingItem{
+			PK:        termPartition(tenantId, p.Term),
+			SK:        doc.VideoID,
+			VideoID:   doc.VideoID,
+			Positions: p.Positions,
+			Tags:      p.Tags,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to marshal posting: %w", err)
+		}
+		requests = append(requests, types.WriteRequest{PutRequest: &types.PutRequest{Item: av}})
+		item.Terms = append(item.Terms, p.Term)
+		current[p.Term] = true
+	}
+	if previous != nil {
+		for _, term := range previous.Terms {
+			if current[term] {
+				continue
+			}
+			requests = append(requests, types.WriteRequest{DeleteRequest: &types.DeleteRequest{
+				Key: map[string]types.AttributeValue{
+					"pk": &types.AttributeValueMemberS{Value: termPartition(tenantId, term)},
+					"sk": &types.AttributeValueMemberS{Value: doc.VideoID},
+				},
+			}})
+		}
+	}
+
+	av, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return fmt.Errorf("failed to marshal search document: %w", err)
+	}
+	requests = append(requests, types.WriteRequest{PutRequest: &types.PutRequest{Item: av}})
+
+	if err := db.batchWrite(ctx, db.DataTable, requests); err != nil {
+		return err
+	}
+	if previous != nil {
+		return nil
+	}
+
+	_, err = db.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(db.DataTable),
+		Key:              searchStatsKey(tenantId),
+		UpdateExpression: aws.String("ADD doc_count :one"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":one": &types.AttributeValueMemberN{Value: "1"},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update search stats: %w", err)
+	}
+	return nil
+}
+
+// DeleteSearchDocument removes a video from its tenant's index. A video that
+// was never indexed is left alone.
+func (db *DB) DeleteSearchDocument(ctx context.Context, tenantId string, videoId string) error {
+	if videoId == "" {
+		return fmt.Errorf("%w: video ID cannot be empty", ErrInvalidInput)
+	}
+
+	previous, err := db.getSearchDocumentItem(ctx, videoId)
+	if err != nil || previous == nil {
+		return err
+	}
+
+	requests := make([]types.WriteRequest, 0, len(previous.Terms)+1)
+	for _, term := range previous.Terms {
+		requests = append(requests, types.WriteRequest{DeleteRequest: &types.DeleteRequest{
+			Key: map[string]types.AttributeValue{
+				"pk": &types.AttributeValueMemberS{Value: termPartition(tenantId, term)},
+				"sk": &types.AttributeValueMemberS{Value: videoId},
+			},
+		}})
+	}
+	// The document goes last, so an interrupted delete can be repeated.
+	requests = append(requests, types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: searchDocumentKey(videoId)}})
+	if err := db.batchWrite(ctx, db.DataTable, requests); err != nil {
+		return err
+	}
+
+	_, err = db.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(db.DataTable),
+		Key:              searchStatsKey(tenantId),
+		UpdateExpression: aws.String("ADD doc_count :minus"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":minus": &types.AttributeValueMemberN{Value: "-1"},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update search stats: %w", err)
+	}
+	return nil
+}
+
+// tenantSearchIndex reads one tenant's search index.
+type tenantSearchIndex struct {
+	db       *DB
+	tenantId string
+}
+
+// SearchIndex returns the search.Store of a tenant's index.
+func (db *DB) SearchIndex(tenantId string) search.Store {
+	return &tenantSearchIndex{db: db, tenantId: tenantId}
+}
+
+// SearchPostings returns the postings of a term across the tenant's videos.
+func (idx *tenantSearchIndex) SearchPostings(ctx context.Context, term string) ([]search.Posting, error) {
+	db := idx.db
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(db.DataTable),
+		KeyConditionExpression: aws.String("pk = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: termPartition(idx.tenantId, term)},
+		},
+	}
+
+	var postings []search.Posting
+	for {
+		output, err := db.Client.Query(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query postings from DynamoDB: %w", err)
+		}
+		var page []postingItem
+		if err := attributevalue.UnmarshalListOfMaps(output.Items, &page); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal postings: %w", err)
+		}
+		for _, item := range page {
+			postings = append(postings, search.Posting{
+				Term:      term,
+				VideoID:   item.VideoID,
+				Positions: item.Positions,
+				Tags:      item.Tags,
+			})
+		}
+		if len(output.LastEvaluatedKey) == 0 {
+			return postings, nil
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
+
+// SearchDocument returns the indexed text of a video, or nil if it hasn't
+// been indexed. Only videos found through the tenant's postings are looked
+// up, so the document itself isn't scoped.
+func (idx *tenantSearchIndex) SearchDocument(ctx context.Context, videoId string) (*search.Document, error) {
+	item, err := idx.db.getSearchDocumentItem(ctx, videoId)
+	if err != nil || item == nil {
+		return nil, err
+	}
+	return &search.Document{
+		VideoID:     item.VideoID,
+		Title:       item.Title,
+		Description: item.Description,
+		Tags:        item.Tags,
+		Labels:      item.Labels,
+		Transcript:  item.Transcript,
+	}, nil
+}
+
+func (idx *tenantSearchIndex) SearchDocumentCount(ctx context.Context) (int, error) {
+	result, err := idx.db.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(idx.db.DataTable),
+		Key:       searchStatsKey(idx.tenantId),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get search stats from DynamoDB: %w", err)
+	}
+	var stats struct {
+		DocCount int `dynamodbav:"doc_count"`
+	}
+	if err := attributevalue.UnmarshalMap(result.Item, &stats); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal search stats: %w", err)
+	}
+	return stats.DocCount, nil
+}
+
+func (db *DB) getSearchDocumentItem(ctx context.Context, videoId string) (*searchDocumentItem, error) {
+	result, err := db.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(db.DataTable),
+		Key:       searchDocumentKey(videoId),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get search document from DynamoDB: %w", err)
+	}
+	if len(result.Item) == 0 {
+		return nil, nil
+	}
+	var item searchDocumentItem
+	if err := attributevalue.UnmarshalMap(result.Item, &item); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal search document: %w", err)
+	}
+	return &item, nil
+}
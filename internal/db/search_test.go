@@ -0,0 +1,95 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ryanschneiderman/video-api/internal/search"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func captureWrites(mockClient *mockDynamoDBClient) (puts, deletes *[]string) {
+	puts, deletes = &[]string{}, &[]string{}
+	mockClient.On("BatchWriteItem", mock.Anything, mock.MatchedBy(func(in *dynamodb.BatchWriteItemInput) bool {
+		for _, r := range in.RequestItems["test-table-data"] {
+			if r.PutRequest != nil {
+				*puts = append(*puts, r.PutRequest.Item["pk"].(*types.AttributeValueMemberS).Value)
+			}
+			if r.DeleteRequest != nil {
+				*deletes = append(*deletes, r.DeleteRequest.Key["pk"].(*types.AttributeValueMemberS).Value)
+			}
+		}
+		return true
+	})).Return(&dynamodb.BatchWriteItemOutput{}, nil)
+	return puts, deletes
+}
+
+func TestPutSearchDocumentFirstIndex(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{Client: mockClient, TableName: "test-table", DataTable: "test-table-data"}
+
+	mockClient.On("GetItem", mock.Anything, mock.Anything).Return(&dynamodb.GetItemOutput{}, nil)
+	puts, deletes := captureWrites(mockClient)
+	mockClient.On("UpdateItem", mock.Anything, mock.MatchedBy(func(in *dynamodb.UpdateItemInput) bool {
+		return *in.UpdateExpression == "ADD doc_count :one" &&
+			in.Key["pk"].(*types.AttributeValueMemberS).Value == "tenant#acme#search"
+	})).Return(&dynamodb.UpdateItemOutput{}, nil)
+
+	err := db.PutSearchDocument(context.Background(), "acme", &search.Document{VideoID: "vid-1", Title: "Beach sunset"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tenant#acme#term#beach", "tenant#acme#term#sunset", "video#vid-1"}, *puts)
+	assert.Empty(t, *deletes)
+	mockClient.AssertCalled(t, "UpdateItem", mock.Anything, mock.Anything)
+}
+
+func TestPutSearchDocumentReindex(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{Client: mockClient, TableName: "test-table", DataTable: "test-table-data"}
+
+	mockClient.On("GetItem", mock.Anything, mock.Anything).Return(&dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+		"pk":       &types.AttributeValueMemberS{Value: "video#vid-1"},
+		"sk":       &types.AttributeValueMemberS{Value: "search"},
+		"video_id": &types.AttributeValueMemberS{Value: "vid-1"},
+		"terms": &types.AttributeValueMemberL{Value: []types.AttributeValue{
+			&types.AttributeValueMemberS{Value: "beach"},
+			&types.AttributeValueMemberS{Value: "dawn"},
+		}},
+	}}, nil)
+	puts, deletes := captureWrites(mockClient)
+
+	err := db.PutSearchDocument(context.Background(), "acme", &search.Document{VideoID: "vid-1", Title: "Beach sunset"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tenant#acme#term#beach", "tenant#acme#term#sunset", "video#vid-1"}, *puts)
+	assert.Equal(t, []string{"tenant#acme#term#dawn"}, *deletes)
+	mockClient.AssertNotCalled(t, "UpdateItem", mock.Anything, mock.Anything)
+}
+
+func TestDeleteSearchDocument(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{Client: mockClient, TableName: "test-table", DataTable: "test-table-data"}
+
+	mockClient.On("GetItem", mock.Anything, mock.Anything).Return(&dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+		"pk":       &types.AttributeValueMemberS{Value: "video#vid-1"},
+		"sk":       &types.AttributeValueMemberS{Value: "search"},
+		"video_id": &types.AttributeValueMemberS{Value: "vid-1"},
+		"terms": &types.AttributeValueMemberL{Value: []types.AttributeValue{
+			&types.AttributeValueMemberS{Value: "beach"},
+		}},
+	}}, nil)
+	puts, deletes := captureWrites(mockClient)
+	mockClient.On("UpdateItem", mock.Anything, mock.MatchedBy(func(in *dynamodb.UpdateItemInput) bool {
+		return *in.UpdateExpression == "ADD doc_count :minus"
+	})).Return(&dynamodb.UpdateItemOutput{}, nil)
+
+	err := db.DeleteSearchDocument(context.Background(), "acme", "vid-1")
+
+	assert.NoError(t, err)
+	assert.Empty(t, *puts)
+	assert.Equal(t, []string{"tenant#acme#term#beach", "video#vid-1"}, *deletes)
+	mockClient.AssertExpectations(t)
+}
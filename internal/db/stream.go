@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// StreamCheckpoint is how far a consumer got through one shard of a
+// DynamoDB stream. SequenceNumber is the last record handled; Finished is
+// set once the shard is closed and fully read. ExpiresAt, in Unix seconds,
+// is the data table's TTL attribute: shards are trimmed after a day, so old
+// checkpoints are only clutter.
+type StreamCheckpoint struct {
+	StreamARN      string    `dynamodbav:"stream_arn"`
+	ShardID        string    `dynamodbav:"shard_id"`
+	SequenceNumber string    `dynamodbav:"sequence_number,omitempty"`
+	Finished       bool      `dynamodbav:"finished"`
+	UpdatedAt      time.Time `dynamodbav:"updated_at"`
+	ExpiresAt      int64     `dynamodbav:"expires_at,omitempty"`
+}
+
+// A stream's checkpoints share one partition, keyed by shard.
+func streamPartition(streamArn string) string {
+	return "stream#" + streamArn
+}
+
+func (db *DB) PutStreamCheckpoint(ctx context.Context, checkpoint StreamCheckpoint) error {
+	if checkpoint.StreamARN == "" || checkpoint.ShardID == "" {
+		return fmt.Errorf("%w: stream ARN and shard ID cannot be empty", ErrInvalidInput)
+	}
+
+	item, err := attributevalue.MarshalMap(checkpoint)
+	if err != nil {
+		return fmt.Errorf("failed to marshal stream checkpoint: %w", err)
+	}
+	item["pk"] = &types.AttributeValueMemberS{Value: streamPartition(checkpoint.StreamARN)}
+	item["sk"] = &types.AttributeValueMemberS{Value: "shard#" + checkpoint.ShardID}
+
+	_, err = db.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(db.DataTable),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put stream checkpoint in DynamoDB: %w", err)
+	}
+	return nil
+}
+
+// GetStreamCheckpoints returns the stream's checkpoints by shard ID.
+func (db *DB) GetStreamCheckpoints(ctx context.Context, streamArn string) (map[string]StreamCheckpoint, error) {
+	if streamArn == "" {
+		return nil, fmt.Errorf("%w: stream ARN cannot be empty", ErrInvalidInput)
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(db.DataTable),
+		KeyConditionExpression: aws.String("pk = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: streamPartition(streamArn)},
+		},
+		ConsistentRead: aws.Bool(true),
+	}
+
+	checkpoints := map[string]StreamCheckpoint{}
+	for {
+		result, err := db.Client.Query(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query stream checkpoints: %w", err)
+		}
+		var page []StreamCheckpoint
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal stream checkpoints: %w", err)
+		}
+		for _, cp := range page {
+			checkpoints[cp.ShardID] = cp
+		}
+		if len(result.LastEvaluatedKey) == 0 {
+			return checkpoints, nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
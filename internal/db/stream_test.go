@@ -0,0 +1,35 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestStreamCheckpoints(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{Client: mockClient, TableName: "test-table", DataTable: "test-table-data"}
+	arn := "arn:aws:dynamodb:us-east-1:123456789012:table/videos/stream/2025-06-01T00:00:00.000"
+
+	mockClient.On("PutItem", mock.Anything, mock.MatchedBy(func(in *dynamodb.PutItemInput) bool {
+		return in.Item["pk"].(*types.AttributeValueMemberS).Value == "stream#"+arn &&
+			in.Item["sk"].(*types.AttributeValueMemberS).Value == "shard#s1"
+	})).Return(&dynamodb.PutItemOutput{}, nil)
+	assert.NoError(t, db.PutStreamCheckpoint(context.Background(), StreamCheckpoint{StreamARN: arn, ShardID: "s1", SequenceNumber: "100"}))
+
+	item, _ := attributevalue.MarshalMap(StreamCheckpoint{StreamARN: arn, ShardID: "s1", SequenceNumber: "100", Finished: true})
+	mockClient.On("Query", mock.Anything, mock.Anything).Return(&dynamodb.QueryOutput{
+		Items: []map[string]types.AttributeValue{item},
+	}, nil)
+	checkpoints, err := db.GetStreamCheckpoints(context.Background(), arn)
+	assert.NoError(t, err)
+	assert.True(t, checkpoints["s1"].Finished)
+	assert.Equal(t, "100", checkpoints["s1"].SequenceNumber)
+
+	assert.ErrorIs(t, db.PutStreamCheckpoint(context.Background(), StreamCheckpoint{StreamARN: arn}), ErrInvalidInput)
+}
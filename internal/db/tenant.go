@@ -0,0 +1,14 @@
+package db
+
+import "fmt"
+
+// tenantPartition scopes a data table partition key to a tenant, so indexes
+// shared by many videos (search terms, frame hash bands, content hashes) only
+// ever return the tenant's own videos. Videos without a tenant keep the
+// unscoped keys they were indexed under.
+func tenantPartition(tenantId, key string) string {
+	if tenantId == "" {
+		return key
+	}
+	return fmt.Sprintf("tenant#%s#%s", tenantId, key)
+}
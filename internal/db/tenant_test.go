@@ -0,0 +1,36 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestTenantPartition(t *testing.T) {
+	assert.Equal(t, "tenant#acme#term#beach", tenantPartition("acme", "term#beach"))
+	assert.Equal(t, "term#beach", tenantPartition("", "term#beach"))
+}
+
+func TestGetVideoForTenant(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{Client: mockClient, TableName: "test-table", DataTable: "test-table-data"}
+
+	mockClient.On("GetItem", mock.Anything, mock.Anything).Return(&dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+		"video_id":  &types.AttributeValueMemberS{Value: "vid-1"},
+		"tenant_id": &types.AttributeValueMemberS{Value: "acme"},
+	}}, nil)
+
+	video, err := db.GetVideoForTenant(context.Background(), "acme", "vid-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", video.TenantID)
+
+	_, err = db.GetVideoForTenant(context.Background(), "globex", "vid-1")
+	assert.ErrorIs(t, err, ErrVideoNotFound)
+
+	_, err = db.GetVideoForTenant(context.Background(), "", "vid-1")
+	assert.ErrorIs(t, err, ErrVideoNotFound)
+}
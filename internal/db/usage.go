@@ -0,0 +1,205 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Usage is what a tenant has used: the bytes of video uploaded and kept, and
+// the duration of video processed in one calendar month.
+type Usage struct {
+	TenantID          string
+	Period            string
+	StorageBytes      int64
+	Videos            int64
+	ProcessingSeconds float64
+	ProcessedVideos   int64
+}
+
+// UsagePeriod is the calendar month, in UTC, that usage at t counts towards.
+func UsagePeriod(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// Usage counters live in one partition per tenant: a storage item, and a
+// processing item per month.
+const storageUsageKey = "storage"
+
+func processingUsageKey(period string) string {
+	return "processing#" + period
+}
+
+func usageKey(tenantId, sk string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: tenantPartition(tenantId, "usage")},
+		"sk": &types.AttributeValueMemberS{Value: sk},
+	}
+}
+
+type storageUsageItem struct {
+	Bytes  int64 `dynamodbav:"bytes"`
+	Videos int64 `dynamodbav:"videos"`
+}
+
+type processingUsageItem struct {
+	Seconds float64 `dynamodbav:"seconds"`
+	Videos  int64   `dynamodbav:"videos"`
+}
+
+// ReserveStorage counts an upload's bytes towards the tenant's storage
+// before it is written. With a positive limit it fails with
+// ErrQuotaExceeded, counting nothing, when usage would go over the limit.
+// The check and the increment are one conditional update, so concurrent
+// uploads can't overshoot the quota together.
+func (db *DB) ReserveStorage(ctx context.Context, tenantId string, bytes int64, limit int64) error {
+	if tenantId == "" {
+		return fmt.Errorf("%w: tenant ID cannot be empty", ErrInvalidInput)
+	}
+	if limit > 0 && bytes > limit {
+		return ErrQuotaExceeded
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                aws.String(db.DataTable),
+		Key:                      usageKey(tenantId, storageUsageKey),
+		UpdateExpression:         aws.String("ADD #bytes :bytes, #videos :one"),
+		ExpressionAttributeNames: map[string]string{"#bytes": "bytes", "#videos": "videos"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":bytes": &types.AttributeValueMemberN{Value: strconv.FormatInt(bytes, 10)},
+			":one":   &types.AttributeValueMemberN{Value: "1"},
+		},
+	}
+	if limit > 0 {
+		input.ConditionExpression = aws.String("attribute_not_exists(#bytes) OR #bytes <= :max")
+		input.ExpressionAttributeValues[":max"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(limit-bytes, 10)}
+	}
+
+	if _, err := db.Client.UpdateItem(ctx, input); err != nil {
+		if isConditionalCheckFailed(err) {
+			return ErrQuotaExceeded
+		}
+		return fmt.Errorf("failed to reserve storage in DynamoDB: %w", err)
+	}
+	return nil
+}
+
+// ReleaseStorage gives back storage reserved for an upload that didn't
+// complete.
+func (db *DB) ReleaseStorage(ctx context.Context, tenantId string, bytes int64) error {
+	if tenantId == "" {
+		return fmt.Errorf("%w: tenant ID cannot be empty", ErrInvalidInput)
+	}
+
+	_, err := db.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                aws.String(db.DataTable),
+		Key:                      usageKey(tenantId, storageUsageKey),
+		UpdateExpression:         aws.String("ADD #bytes :bytes, #videos :one"),
+		ExpressionAttributeNames: map[string]string{"#bytes": "bytes", "#videos": "videos"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":bytes": &types.AttributeValueMemberN{Value: strconv.FormatInt(-bytes, 10)},
+			":one":   &types.AttributeValueMemberN{Value: "-1"},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release storage in DynamoDB: %w", err)
+	}
+	return nil
+}
+
+// RecordProcessing counts the duration of a run that processed the video
+// towards its tenant's usage for the month of at. Every version is counted
+// once: the video is marked with the version first and a version already
+// marked is skipped, so a redelivered job isn't counted twice.
+func (db *DB) RecordProcessing(ctx context.Context, video *Video, version int, seconds float64, at time.Time) error {
+	if video.TenantID == "" {
+		return fmt.Errorf("%w: tenant ID cannot be empty", ErrInvalidInput)
+	}
+
+	_, err := db.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(db.TableName),
+		Key: map[string]types.AttributeValue{
+			"video_id": &types.AttributeValueMemberS{Value: video.VideoID},
+		},
+		UpdateExpression:    aws.String("SET processing_seconds = :seconds, processing_version = :version"),
+		ConditionExpression: aws.String("attribute_exists(video_id) AND (attribute_not_exists(processing_version) OR processing_version < :version)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":seconds": &types.AttributeValueMemberN{Value: strconv.FormatFloat(seconds, 'f', -1, 64)},
+			":version": &types.AttributeValueMemberN{Value: strconv.Itoa(version)},
+		},
+	})
+	if err != nil {
+		if isConditionalCheckFailed(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to mark video processing usage: %w", err)
+	}
+
+	_, err = db.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                aws.String(db.DataTable),
+		Key:                      usageKey(video.TenantID, processingUsageKey(UsagePeriod(at))),
+		UpdateExpression:         aws.String("ADD #seconds :seconds, #videos :one"),
+		ExpressionAttributeNames: map[string]string{"#seconds": "seconds", "#videos": "videos"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":seconds": &types.AttributeValueMemberN{Value: strconv.FormatFloat(seconds, 'f', -1, 64)},
+			":one":     &types.AttributeValueMemberN{Value: "1"},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record processing usage in DynamoDB: %w", err)
+	}
+	return nil
+}
+
+// GetUsage returns the tenant's storage and its processing in period, a
+// month as formatted by UsagePeriod.
+func (db *DB) GetUsage(ctx context.Context, tenantId string, period string) (*Usage, error) {
+	if tenantId == "" {
+		return nil, fmt.Errorf("%w: tenant ID cannot be empty", ErrInvalidInput)
+	}
+
+	var storage storageUsageItem
+	if err := db.getUsageItem(ctx, tenantId, storageUsageKey, &storage); err != nil {
+		return nil, err
+	}
+	var processing processingUsageItem
+	if err := db.getUsageItem(ctx, tenantId, processingUsageKey(period), &processing); err != nil {
+		return nil, err
+	}
+
+	return &Usage{
+		TenantID:          tenantId,
+		Period:            period,
+		StorageBytes:      storage.Bytes,
+		Videos:            storage.Videos,
+		ProcessingSeconds: processing.Seconds,
+		ProcessedVideos:   processing.Videos,
+	}, nil
+}
+
+// getUsageItem leaves out untouched when the counter doesn't exist yet.
+func (db *DB) getUsageItem(ctx context.Context, tenantId, sk string, out interface{}) error {
+	result, err := db.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(db.DataTable),
+		Key:       usageKey(tenantId, sk),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get usage from DynamoDB: %w", err)
+	}
+	if len(result.Item) == 0 {
+		return nil
+	}
+	if err := attributevalue.UnmarshalMap(result.Item, out); err != nil {
+		return fmt.Errorf("failed to unmarshal usage: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestReserveStorage(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{Client: mockClient, TableName: "test-table", DataTable: "test-table-data"}
+
+	mockClient.On("UpdateItem", mock.Anything, mock.MatchedBy(func(in *dynamodb.UpdateItemInput) bool {
+		return *in.TableName == "test-table-data" &&
+			in.Key["pk"].(*types.AttributeValueMemberS).Value == "tenant#acme#usage" &&
+			in.Key["sk"].(*types.AttributeValueMemberS).Value == "storage" &&
+			in.ExpressionAttributeValues[":bytes"].(*types.AttributeValueMemberN).Value == "300" &&
+			in.ExpressionAttributeValues[":max"].(*types.AttributeValueMemberN).Value == "700"
+	})).Return(&dynamodb.UpdateItemOutput{}, nil).Once()
+	mockClient.On("UpdateItem", mock.Anything, mock.Anything).
+		Return(&dynamodb.UpdateItemOutput{}, &types.ConditionalCheckFailedException{})
+
+	assert.NoError(t, db.ReserveStorage(context.Background(), "acme", 300, 1000))
+	assert.ErrorIs(t, db.ReserveStorage(context.Background(), "acme", 300, 1000), ErrQuotaExceeded)
+
+	// Uploads larger than the whole quota are rejected without a write.
+	assert.ErrorIs(t, db.ReserveStorage(context.Background(), "acme", 2000, 1000), ErrQuotaExceeded)
+	mockClient.AssertNumberOfCalls(t, "UpdateItem", 2)
+}
+
+func TestReserveStorageUnlimited(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{Client: mockClient, TableName: "test-table", DataTable: "test-table-data"}
+
+	mockClient.On("UpdateItem", mock.Anything, mock.MatchedBy(func(in *dynamodb.UpdateItemInput) bool {
+		return in.ConditionExpression == nil
+	})).Return(&dynamodb.UpdateItemOutput{}, nil)
+
+	assert.NoError(t, db.ReserveStorage(context.Background(), "acme", 300, 0))
+	assert.ErrorIs(t, db.ReserveStorage(context.Background(), "", 300, 0), ErrInvalidInput)
+}
+
+func TestRecordProcessingCountsEachVersionOnce(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{Client: mockClient, TableName: "test-table", DataTable: "test-table-data"}
+	at := time.Date(2025, 6, 30, 23, 0, 0, 0, time.UTC)
+
+	mockClient.On("UpdateItem", mock.Anything, mock.MatchedBy(func(in *dynamodb.UpdateItemInput) bool {
+		return *in.TableName == "test-table" &&
+			in.ExpressionAttributeValues[":version"].(*types.AttributeValueMemberN).Value == "2"
+	})).Return(&dynamodb.UpdateItemOutput{}, nil).Once()
+	mockClient.On("UpdateItem", mock.Anything, mock.MatchedBy(func(in *dynamodb.UpdateItemInput) bool {
+		return *in.TableName == "test-table-data" &&
+			in.Key["pk"].(*types.AttributeValueMemberS).Value == "tenant#acme#usage" &&
+			in.Key["sk"].(*types.AttributeValueMemberS).Value == "processing#2025-06" &&
+			in.ExpressionAttributeValues[":seconds"].(*types.AttributeValueMemberN).Value == "90.5"
+	})).Return(&dynamodb.UpdateItemOutput{}, nil).Once()
+	mockClient.On("UpdateItem", mock.Anything, mock.MatchedBy(func(in *dynamodb.UpdateItemInput) bool {
+		return *in.TableName == "test-table"
+	})).Return(&dynamodb.UpdateItemOutput{}, &types.ConditionalCheckFailedException{})
+
+	video := &Video{VideoID: "video-1", TenantID: "acme"}
+	assert.NoError(t, db.RecordProcessing(context.Background(), video, 2, 90.5, at))
+	// The version is already marked, so the monthly counter isn't touched.
+	assert.NoError(t, db.RecordProcessing(context.Background(), video, 2, 90.5, at))
+	mockClient.AssertNumberOfCalls(t, "UpdateItem", 3)
+}
+
+func TestGetUsage(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{Client: mockClient, TableName: "test-table", DataTable: "test-table-data"}
+
+	mockClient.On("GetItem", mock.Anything, mock.MatchedBy(func(in *dynamodb.GetItemInput) bool {
+		return in.Key["sk"].(*types.AttributeValueMemberS).Value == "storage"
+	})).Return(&dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+		"bytes":  &types.AttributeValueMemberN{Value: "4096"},
+		"videos": &types.AttributeValueMemberN{Value: "2"},
+	}}, nil)
+	mockClient.On("GetItem", mock.Anything, mock.Anything).Return(&dynamodb.GetItemOutput{}, nil)
+
+	usage, err := db.GetUsage(context.Background(), "acme", "2025-06")
+	assert.NoError(t, err)
+	assert.Equal(t, &Usage{TenantID: "acme", Period: "2025-06", StorageBytes: 4096, Videos: 2}, usage)
+}
@@ -0,0 +1,199 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var ErrVersionNotFound = errors.New("version not found")
+
+// States of a video version. A processing version is a run still going, and
+// an expired one has had its files removed by the retention policy.
+const (
+	VersionProcessing = "processing"
+	VersionReady      = "ready"
+	VersionFailed     = "failed"
+	VersionExpired    = "expired"
+)
+
+// VideoVersion is one processing run of a video. Each run writes its files
+// under its own Prefix, but reuses the files of stages it didn't rerun, so
+// Prefixes lists every version prefix its files are under. Checkpoints are
+// the video's checkpoints when the run finished, which is what promoting
+// the version restores. Analyzers names the analyzer, transcriber and
+// embedder that produced the results, keyed by stage. Times that haven't
+// happened are zero.
+type VideoVersion struct {
+	VideoID     string                     `dynamodbav:"video_id"`
+	Version     int                        `dynamodbav:"version"`
+	State       string                     `dynamodbav:"state"`
+	Profile     string                     `dynamodbav:"profile"`
+	Analyzers   map[string]string          `dynamodbav:"analyzers,omitempty"`
+	Prefix      string                     `dynamodbav:"prefix"`
+	Prefixes    []string                   `dynamodbav:"prefixes,omitempty"`
+	Renditions  map[string]string          `dynamodbav:"renditions,omitempty"`
+	Checkpoints map[string]StageCheckpoint `dynamodbav:"checkpoints,omitempty"`
+	StartedAt   time.Time                  `dynamodbav:"started_at"`
+	CompletedAt time.Time                  `dynamodbav:"completed_at"`
+	PromotedAt  time.Time                  `dynamodbav:"promoted_at"`
+	ExpiredAt   time.Time                  `dynamodbav:"expired_at"`
+}
+
+type videoVersionItem struct {
+	PK string `dynamodbav:"pk"`
+	SK string `dynamodbav:"sk"`
+	VideoVersion
+}
+
+const versionPrefix = "version#"
+
+// versionSortKey pads the number so a video's versions sort in order.
+func versionSortKey(version int) string {
+	return fmt.Sprintf("%s%010d", versionPrefix, version)
+}
+
+func versionKey(videoId string, version int) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: videoPartition(videoId)},
+		"sk": &types.AttributeValueMemberS{Value: versionSortKey(version)},
+	}
+}
+
+// AllocateVersion returns the next version number of a video, counting from
+// 1. Numbers are never reused, even if a run fails.
+func (db *DB) AllocateVersion(ctx context.Context, videoId string) (int, error) {
+	if videoId == "" {
+		return 0, fmt.Errorf("%w: video ID cannot be empty", ErrInvalidInput)
+	}
+
+	output, err := db.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                aws.String(db.TableName),
+		Key:                      videoKey(videoId),
+		UpdateExpression:         aws.String("ADD #latest :one"),
+		ConditionExpression:      aws.String("attribute_exists(#id)"),
+		ExpressionAttributeNames: map[string]string{"#latest": "latest_version", "#id": "video_id"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":one": &types.AttributeValueMemberN{Value: "1"},
+		},
+		ReturnValues: types.ReturnValueUpdatedNew,
+	})
+	if err != nil {
+		if isConditionalCheckFailed(err) {
+			return 0, ErrVideoNotFound
+		}
+		return 0, fmt.Errorf("failed to allocate version in DynamoDB: %w", err)
+	}
+
+	latest, ok := output.Attributes["latest_version"].(*types.AttributeValueMemberN)
+	if !ok {
+		return 0, fmt.Errorf("failed to allocate version: no version returned")
+	}
+	version, err := strconv.Atoi(latest.Value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse allocated version: %w", err)
+	}
+	return version, nil
+}
+
+// PutVideoVersion creates or replaces a version record.
+func (db *DB) PutVideoVersion(ctx context.Context, version VideoVersion) error {
+	if version.VideoID == "" || version.Version < 1 {
+		return fmt.Errorf("%w: video ID and version are required", ErrInvalidInput)
+	}
+
+	item, err := attributevalue.MarshalMap(videoVersionItem{
+		PK:           videoPartition(version.VideoID),
+		SK:           versionSortKey(version.Version),
+		VideoVersion: version,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal video version: %w", err)
+	}
+
+	_, err = db.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(db.DataTable),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put video version in DynamoDB: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) GetVideoVersion(ctx context.Context, videoId string, version int) (*VideoVersion, error) {
+	if videoId == "" {
+		return nil, fmt.Errorf("%w: video ID cannot be empty", ErrInvalidInput)
+	}
+
+	result, err := db.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(db.DataTable),
+		Key:       versionKey(videoId, version),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get video version from DynamoDB: %w", err)
+	}
+	if len(result.Item) == 0 {
+		return nil, ErrVersionNotFound
+	}
+
+	var item videoVersionItem
+	if err := attributevalue.UnmarshalMap(result.Item, &item); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal video version: %w", err)
+	}
+	return &item.VideoVersion, nil
+}
+
+// ListVideoVersions returns every version of a video, newest first.
+func (db *DB) ListVideoVersions(ctx context.Context, videoId string) ([]VideoVersion, error) {
+	if videoId == "" {
+		return nil, fmt.Errorf("%w: video ID cannot be empty", ErrInvalidInput)
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(db.DataTable),
+		KeyConditionExpression: aws.String("pk = :pk AND begins_with(sk, :prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":     &types.AttributeValueMemberS{Value: videoPartition(videoId)},
+			":prefix": &types.AttributeValueMemberS{Value: versionPrefix},
+		},
+		ScanIndexForward: aws.Bool(false),
+	}
+
+	versions := []VideoVersion{}
+	for {
+		output, err := db.Client.Query(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query video versions from DynamoDB: %w", err)
+		}
+		var items []videoVersionItem
+		if err := attributevalue.UnmarshalListOfMaps(output.Items, &items); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal video versions: %w", err)
+		}
+		for _, item := range items {
+			versions = append(versions, item.VideoVersion)
+		}
+		if len(output.LastEvaluatedKey) == 0 {
+			return versions, nil
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
+
+// SetCheckpoints replaces all of a video's checkpoints, such as with those of
+// a version being promoted.
+func (db *DB) SetCheckpoints(ctx context.Context, videoId string, checkpoints map[string]StageCheckpoint) error {
+	if checkpoints == nil {
+		checkpoints = map[string]StageCheckpoint{}
+	}
+	return db.UpdateVideo(ctx, videoId, map[string]interface{}{
+		"checkpoints": checkpoints,
+	})
+}
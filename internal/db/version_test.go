@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAllocateVersion(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{
+		Client:    mockClient,
+		TableName: "test-table",
+	}
+
+	ctx := context.Background()
+
+	mockClient.On("UpdateItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+		id, _ := input.Key["video_id"].(*types.AttributeValueMemberS)
+		return id != nil && id.Value == "test-id" && aws.ToString(input.UpdateExpression) == "ADD #latest :one"
+	})).Return(&dynamodb.UpdateItemOutput{
+		Attributes: map[string]types.AttributeValue{
+			"latest_version": &types.AttributeValueMemberN{Value: "4"},
+		},
+	}, nil).Once()
+	mockClient.On("UpdateItem", mock.Anything, mock.Anything).
+		Return(&dynamodb.UpdateItemOutput{}, &types.ConditionalCheckFailedException{}).Once()
+
+	version, err := db.AllocateVersion(ctx, "test-id")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, version)
+
+	_, err = db.AllocateVersion(ctx, "missing-id")
+	assert.ErrorIs(t, err, ErrVideoNotFound)
+	mockClient.AssertExpectations(t)
+}
+
+func TestListVideoVersions(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{
+		Client:    mockClient,
+		DataTable: "test-data",
+	}
+
+	ctx := context.Background()
+
+	item := func(version int, state string) map[string]types.AttributeValue {
+		av, err := attributevalue.MarshalMap(videoVersionItem{
+			PK: videoPartition("test-id"),
+			SK: versionSortKey(version),
+			VideoVersion: VideoVersion{
+				VideoID:   "test-id",
+				Version:   version,
+				State:     state,
+				StartedAt: time.Date(2025, 1, version, 0, 0, 0, 0, time.UTC),
+			},
+		})
+		assert.NoError(t, err)
+		return av
+	}
+
+	mockClient.On("Query", mock.Anything, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+		return len(input.ExclusiveStartKey) == 0 && !aws.ToBool(input.ScanIndexForward)
+	})).Return(&dynamodb.QueryOutput{
+		Items:            []map[string]types.AttributeValue{item(3, VersionProcessing)},
+		LastEvaluatedKey: versionKey("test-id", 3),
+	}, nil).Once()
+	mockClient.On("Query", mock.Anything, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+		return len(input.ExclusiveStartKey) > 0
+	})).Return(&dynamodb.QueryOutput{
+		Items: []map[string]types.AttributeValue{item(2, VersionReady), item(1, VersionExpired)},
+	}, nil).Once()
+
+	versions, err := db.ListVideoVersions(ctx, "test-id")
+
+	assert.NoError(t, err)
+	assert.Len(t, versions, 3)
+	assert.Equal(t, 3, versions[0].Version)
+	assert.Equal(t, VersionExpired, versions[2].State)
+	assert.True(t, versions[1].CompletedAt.IsZero())
+	mockClient.AssertExpectations(t)
+}
+
+func TestVersionSortKey(t *testing.T) {
+	assert.Equal(t, "version#0000000012", versionSortKey(12))
+	assert.Less(t, versionSortKey(9), versionSortKey(10))
+}
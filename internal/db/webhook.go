@@ -0,0 +1,301 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var (
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+)
+
+// States of a webhook delivery. A pending delivery is still being retried.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook is an endpoint a tenant registered for video events. Secret signs
+// the deliveries, so unlike API keys it is stored as is. Deleted webhooks
+// are kept, like revoked API keys, but no longer listed or delivered to.
+type Webhook struct {
+	TenantID  string     `dynamodbav:"tenant_id"`
+	WebhookID string     `dynamodbav:"webhook_id"`
+	URL       string     `dynamodbav:"url"`
+	Events    []string   `dynamodbav:"events,stringset"`
+	Secret    string     `dynamodbav:"secret"`
+	CreatedAt time.Time  `dynamodbav:"created_at"`
+	DeletedAt *time.Time `dynamodbav:"deleted_at,omitempty"`
+}
+
+func (w *Webhook) Subscribed(eventType string) bool {
+	for _, e := range w.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one event sent, or being sent, to one webhook. Payload
+// is the exact body, so a replay sends the same bytes. ExpiresAt, in Unix
+// seconds, is the data table's TTL attribute.
+type WebhookDelivery struct {
+	TenantID       string    `dynamodbav:"tenant_id"`
+	WebhookID      string    `dynamodbav:"webhook_id"`
+	DeliveryID     string    `dynamodbav:"delivery_id"`
+	EventID        string    `dynamodbav:"event_id"`
+	EventType      string    `dynamodbav:"event_type"`
+	Payload        string    `dynamodbav:"payload"`
+	Status         string    `dynamodbav:"status"`
+	Attempts       int       `dynamodbav:"attempts"`
+	ResponseStatus int       `dynamodbav:"response_status,omitempty"`
+	LastError      string    `dynamodbav:"last_error,omitempty"`
+	ReplayOf       string    `dynamodbav:"replay_of,omitempty"`
+	CreatedAt      time.Time `dynamodbav:"created_at"`
+	UpdatedAt      time.Time `dynamodbav:"updated_at"`
+	ExpiresAt      int64     `dynamodbav:"expires_at,omitempty"`
+}
+
+// DeliveryFilter narrows a page of a webhook's delivery log. Status filters
+// after the page is read, so a filtered page can hold fewer than Limit
+// deliveries and still have a next page.
+type DeliveryFilter struct {
+	Status string
+	Limit  int32
+	// After continues from the delivery ID returned as the previous page's
+	// cursor.
+	After string
+}
+
+// A tenant's webhooks share one partition. Each webhook's deliveries have
+// their own, sorted by their time-ordered IDs.
+func webhookKey(tenantId, webhookId string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: tenantPartition(tenantId, "webhooks")},
+		"sk": &types.AttributeValueMemberS{Value: webhookId},
+	}
+}
+
+func deliveryPartition(tenantId, webhookId string) string {
+	return tenantPartition(tenantId, "webhook#"+webhookId)
+}
+
+func deliveryKey(tenantId, webhookId, deliveryId string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: deliveryPartition(tenantId, webhookId)},
+		"sk": &types.AttributeValueMemberS{Value: "delivery#" + deliveryId},
+	}
+}
+
+func (db *DB) PutWebhook(ctx context.Context, hook Webhook) error {
+	if hook.TenantID == "" || hook.WebhookID == "" {
+		return fmt.Errorf("%w: tenant ID and webhook ID cannot be empty", ErrInvalidInput)
+	}
+
+	item, err := attributevalue.MarshalMap(hook)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook: %w", err)
+	}
+	for name, value := range webhookKey(hook.TenantID, hook.WebhookID) {
+		item[name] = value
+	}
+
+	_, err = db.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(db.DataTable),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put webhook in DynamoDB: %w", err)
+	}
+	return nil
+}
+
+// GetWebhook fails with ErrWebhookNotFound for deleted webhooks too.
+func (db *DB) GetWebhook(ctx context.Context, tenantId, webhookId string) (*Webhook, error) {
+	if tenantId == "" || webhookId == "" {
+		return nil, fmt.Errorf("%w: tenant ID and webhook ID cannot be empty", ErrInvalidInput)
+	}
+
+	result, err := db.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(db.DataTable),
+		Key:       webhookKey(tenantId, webhookId),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook from DynamoDB: %w", err)
+	}
+	if len(result.Item) == 0 {
+		return nil, ErrWebhookNotFound
+	}
+
+	var hook Webhook
+	if err := attributevalue.UnmarshalMap(result.Item, &hook); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal webhook: %w", err)
+	}
+	if hook.DeletedAt != nil {
+		return nil, ErrWebhookNotFound
+	}
+	return &hook, nil
+}
+
+// ListWebhooks returns the tenant's webhooks that haven't been deleted.
+func (db *DB) ListWebhooks(ctx context.Context, tenantId string) ([]Webhook, error) {
+	if tenantId == "" {
+		return nil, fmt.Errorf("%w: tenant ID cannot be empty", ErrInvalidInput)
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(db.DataTable),
+		KeyConditionExpression: aws.String("pk = :pk"),
+		FilterExpression:       aws.String("attribute_not_exists(deleted_at)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: tenantPartition(tenantId, "webhooks")},
+		},
+	}
+
+	var hooks []Webhook
+	for {
+		result, err := db.Client.Query(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query webhooks: %w", err)
+		}
+		var page []Webhook
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal webhooks: %w", err)
+		}
+		hooks = append(hooks, page...)
+		if len(result.LastEvaluatedKey) == 0 {
+			return hooks, nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// DeleteWebhook stops deliveries to a webhook. Its delivery log is kept
+// until it expires.
+func (db *DB) DeleteWebhook(ctx context.Context, tenantId, webhookId string) error {
+	if tenantId == "" || webhookId == "" {
+		return fmt.Errorf("%w: tenant ID and webhook ID cannot be empty", ErrInvalidInput)
+	}
+
+	now, err := attributevalue.Marshal(time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to marshal deletion time: %w", err)
+	}
+	_, err = db.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(db.DataTable),
+		Key:                       webhookKey(tenantId, webhookId),
+		UpdateExpression:          aws.String("SET deleted_at = :now"),
+		ConditionExpression:       aws.String("attribute_exists(pk) AND attribute_not_exists(deleted_at)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":now": now},
+	})
+	if err != nil {
+		if isConditionalCheckFailed(err) {
+			return ErrWebhookNotFound
+		}
+		return fmt.Errorf("failed to delete webhook in DynamoDB: %w", err)
+	}
+	return nil
+}
+
+// PutWebhookDelivery saves a delivery, replacing the stored copy as its
+// attempts progress.
+func (db *DB) PutWebhookDelivery(ctx context.Context, delivery WebhookDelivery) error {
+	if delivery.TenantID == "" || delivery.WebhookID == "" || delivery.DeliveryID == "" {
+		return fmt.Errorf("%w: tenant, webhook and delivery IDs cannot be empty", ErrInvalidInput)
+	}
+
+	item, err := attributevalue.MarshalMap(delivery)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook delivery: %w", err)
+	}
+	for name, value := range deliveryKey(delivery.TenantID, delivery.WebhookID, delivery.DeliveryID) {
+		item[name] = value
+	}
+
+	_, err = db.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(db.DataTable),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put webhook delivery in DynamoDB: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) GetWebhookDelivery(ctx context.Context, tenantId, webhookId, deliveryId string) (*WebhookDelivery, error) {
+	if tenantId == "" || webhookId == "" || deliveryId == "" {
+		return nil, fmt.Errorf("%w: tenant, webhook and delivery IDs cannot be empty", ErrInvalidInput)
+	}
+
+	result, err := db.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(db.DataTable),
+		Key:       deliveryKey(tenantId, webhookId, deliveryId),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook delivery from DynamoDB: %w", err)
+	}
+	if len(result.Item) == 0 {
+		return nil, ErrDeliveryNotFound
+	}
+
+	var delivery WebhookDelivery
+	if err := attributevalue.UnmarshalMap(result.Item, &delivery); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal webhook delivery: %w", err)
+	}
+	return &delivery, nil
+}
+
+// ListWebhookDeliveries returns a page of a webhook's deliveries, newest
+// first, and the cursor of the next page, which is empty on the last one.
+func (db *DB) ListWebhookDeliveries(ctx context.Context, tenantId, webhookId string, filter DeliveryFilter) ([]WebhookDelivery, string, error) {
+	if tenantId == "" || webhookId == "" {
+		return nil, "", fmt.Errorf("%w: tenant ID and webhook ID cannot be empty", ErrInvalidInput)
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(db.DataTable),
+		KeyConditionExpression: aws.String("pk = :pk AND begins_with(sk, :prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":     &types.AttributeValueMemberS{Value: deliveryPartition(tenantId, webhookId)},
+			":prefix": &types.AttributeValueMemberS{Value: "delivery#"},
+		},
+		ScanIndexForward: aws.Bool(false),
+	}
+	if filter.Limit > 0 {
+		input.Limit = aws.Int32(filter.Limit)
+	}
+	if filter.Status != "" {
+		input.FilterExpression = aws.String("#status = :status")
+		input.ExpressionAttributeNames = map[string]string{"#status": "status"}
+		input.ExpressionAttributeValues[":status"] = &types.AttributeValueMemberS{Value: filter.Status}
+	}
+	if filter.After != "" {
+		input.ExclusiveStartKey = deliveryKey(tenantId, webhookId, filter.After)
+	}
+
+	result, err := db.Client.Query(ctx, input)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query webhook deliveries: %w", err)
+	}
+	deliveries := []WebhookDelivery{}
+	if err := attributevalue.UnmarshalListOfMaps(result.Items, &deliveries); err != nil {
+		return nil, "", fmt.Errorf("failed to unmarshal webhook deliveries: %w", err)
+	}
+
+	var next string
+	if sk, ok := result.LastEvaluatedKey["sk"].(*types.AttributeValueMemberS); ok {
+		next = strings.TrimPrefix(sk.Value, "delivery#")
+	}
+	return deliveries, next, nil
+}
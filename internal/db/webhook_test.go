@@ -0,0 +1,63 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetWebhookHidesDeleted(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{Client: mockClient, TableName: "test-table", DataTable: "test-table-data"}
+
+	deletedAt := time.Now()
+	item, _ := attributevalue.MarshalMap(Webhook{TenantID: "acme", WebhookID: "h1", DeletedAt: &deletedAt})
+	mockClient.On("GetItem", mock.Anything, mock.MatchedBy(func(in *dynamodb.GetItemInput) bool {
+		return in.Key["pk"].(*types.AttributeValueMemberS).Value == "tenant#acme#webhooks" &&
+			in.Key["sk"].(*types.AttributeValueMemberS).Value == "h1"
+	})).Return(&dynamodb.GetItemOutput{Item: item}, nil)
+
+	_, err := db.GetWebhook(context.Background(), "acme", "h1")
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+}
+
+func TestDeleteWebhookNotFound(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{Client: mockClient, TableName: "test-table", DataTable: "test-table-data"}
+
+	mockClient.On("UpdateItem", mock.Anything, mock.Anything).
+		Return(&dynamodb.UpdateItemOutput{}, &types.ConditionalCheckFailedException{})
+
+	assert.ErrorIs(t, db.DeleteWebhook(context.Background(), "acme", "h1"), ErrWebhookNotFound)
+}
+
+func TestListWebhookDeliveries(t *testing.T) {
+	mockClient := new(mockDynamoDBClient)
+	db := &DB{Client: mockClient, TableName: "test-table", DataTable: "test-table-data"}
+
+	item, _ := attributevalue.MarshalMap(WebhookDelivery{TenantID: "acme", WebhookID: "h1", DeliveryID: "d2", Status: DeliveryFailed})
+	mockClient.On("Query", mock.Anything, mock.MatchedBy(func(in *dynamodb.QueryInput) bool {
+		return in.ExpressionAttributeValues[":pk"].(*types.AttributeValueMemberS).Value == "tenant#acme#webhook#h1" &&
+			in.ExpressionAttributeValues[":status"].(*types.AttributeValueMemberS).Value == DeliveryFailed &&
+			in.ExclusiveStartKey["sk"].(*types.AttributeValueMemberS).Value == "delivery#d3" &&
+			!*in.ScanIndexForward && *in.Limit == 10
+	})).Return(&dynamodb.QueryOutput{
+		Items: []map[string]types.AttributeValue{item},
+		LastEvaluatedKey: map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: "tenant#acme#webhook#h1"},
+			"sk": &types.AttributeValueMemberS{Value: "delivery#d2"},
+		},
+	}, nil)
+
+	deliveries, next, err := db.ListWebhookDeliveries(context.Background(), "acme", "h1", DeliveryFilter{Status: DeliveryFailed, Limit: 10, After: "d3"})
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 1)
+	assert.Equal(t, "d2", deliveries[0].DeliveryID)
+	assert.Equal(t, "d2", next)
+}
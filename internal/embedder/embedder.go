@@ -0,0 +1,72 @@
+package embedder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnknownEmbedder = errors.New("unknown embedder")
+	ErrInvalidConfig   = errors.New("invalid embedder config")
+)
+
+// Embedder maps keyframes and search text into a shared vector space, so a
+// text query lands near the frames it describes.
+type Embedder interface {
+	Name() string
+	EmbedImage(ctx context.Context, imagePath string) ([]float32, error)
+	EmbedText(ctx context.Context, text string) ([]float32, error)
+}
+
+type Config struct {
+	Name     string
+	Endpoint string
+	APIKey   string
+	Timeout  time.Duration
+}
+
+type Factory func(cfg Config) (Embedder, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register makes an embedder implementation available to New under name.
+// It panics if name is already taken.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("embedder %q registered twice", name))
+	}
+	registry[name] = factory
+}
+
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func New(cfg Config) (Embedder, error) {
+	registryMu.RLock()
+	factory, ok := registry[cfg.Name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %q (registered: %v)", ErrUnknownEmbedder, cfg.Name, Registered())
+	}
+	return factory(cfg)
+}
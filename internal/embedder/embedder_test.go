@@ -0,0 +1,137 @@
+package embedder
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func dot(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+func TestNewUsesRegistry(t *testing.T) {
+	e, err := New(Config{Name: "fake"})
+	assert.NoError(t, err)
+	assert.Equal(t, "fake", e.Name())
+
+	_, err = New(Config{Name: "does-not-exist"})
+	assert.ErrorIs(t, err, ErrUnknownEmbedder)
+
+	_, err = New(Config{Name: "http"})
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+}
+
+func TestRegisterTwicePanics(t *testing.T) {
+	assert.Panics(t, func() {
+		Register("fake", func(cfg Config) (Embedder, error) { return &Fake{}, nil })
+	})
+}
+
+func TestFakeTextEmbeddings(t *testing.T) {
+	fake := &Fake{}
+	ctx := context.Background()
+
+	dog, err := fake.EmbedText(ctx, "a dog runs on the beach")
+	assert.NoError(t, err)
+	assert.Len(t, dog, FakeDimensions)
+	assert.InDelta(t, 1, dot(dog, dog), 1e-5)
+
+	again, err := fake.EmbedText(ctx, "A dog runs on the beach!")
+	assert.NoError(t, err)
+	assert.Equal(t, dog, again)
+
+	related, err := fake.EmbedText(ctx, "dog on the beach")
+	assert.NoError(t, err)
+	unrelated, err := fake.EmbedText(ctx, "spreadsheet quarterly taxes")
+	assert.NoError(t, err)
+	assert.Greater(t, dot(dog, related), dot(dog, unrelated))
+}
+
+func TestFakeImageEmbeddings(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.jpg")
+	second := filepath.Join(dir, "second.jpg")
+	assert.NoError(t, os.WriteFile(first, []byte("frame one bytes"), 0o600))
+	assert.NoError(t, os.WriteFile(second, []byte("frame two bytes"), 0o600))
+
+	fake := &Fake{}
+	a, err := fake.EmbedImage(context.Background(), first)
+	assert.NoError(t, err)
+	again, err := fake.EmbedImage(context.Background(), first)
+	assert.NoError(t, err)
+	b, err := fake.EmbedImage(context.Background(), second)
+	assert.NoError(t, err)
+
+	assert.Equal(t, a, again)
+	assert.NotEqual(t, a, b)
+
+	_, err = fake.EmbedImage(context.Background(), filepath.Join(dir, "missing.jpg"))
+	assert.Error(t, err)
+}
+
+func TestHTTPEmbedder(t *testing.T) {
+	imagePath := filepath.Join(t.TempDir(), "frame.jpg")
+	assert.NoError(t, os.WriteFile(imagePath, []byte("jpeg bytes"), 0o600))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		body, _ := io.ReadAll(r.Body)
+
+		switch r.Header.Get("Content-Type") {
+		case "image/jpeg":
+			assert.Equal(t, "jpeg bytes", string(body))
+			json.NewEncoder(w).Encode(map[string]any{"embedding": []float32{1, 0}})
+		case "application/json":
+			var req map[string]string
+			assert.NoError(t, json.Unmarshal(body, &req))
+			assert.Equal(t, "a dog", req["text"])
+			json.NewEncoder(w).Encode(map[string]any{"embedding": []float32{0, 1}})
+		default:
+			t.Errorf("unexpected content type %q", r.Header.Get("Content-Type"))
+		}
+	}))
+	defer server.Close()
+
+	e, err := New(Config{Name: "http", Endpoint: server.URL, APIKey: "secret"})
+	assert.NoError(t, err)
+
+	image, err := e.EmbedImage(context.Background(), imagePath)
+	assert.NoError(t, err)
+	assert.Equal(t, []float32{1, 0}, image)
+
+	text, err := e.EmbedText(context.Background(), "a dog")
+	assert.NoError(t, err)
+	assert.Equal(t, []float32{0, 1}, text)
+}
+
+func TestHTTPEmbedderErrors(t *testing.T) {
+	status := http.StatusBadGateway
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(`{"embedding": []}`))
+	}))
+	defer server.Close()
+
+	e, err := New(Config{Name: "http", Endpoint: server.URL})
+	assert.NoError(t, err)
+
+	_, err = e.EmbedText(context.Background(), "x")
+	assert.ErrorContains(t, err, "status 502")
+
+	status = http.StatusOK
+	_, err = e.EmbedText(context.Background(), "x")
+	assert.ErrorContains(t, err, "empty embedding")
+}
@@ -0,0 +1,86 @@
+package embedder
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// FakeDimensions is the size of the vectors Fake returns.
+const FakeDimensions = 64
+
+func init() {
+	Register("fake", func(cfg Config) (Embedder, error) {
+		return &Fake{}, nil
+	})
+}
+
+// Fake hashes its input into a unit vector. Identical inputs embed
+// identically and texts sharing words land close together, which is enough
+// to exercise indexing and search without a model endpoint. Images and text
+// don't share meaning.
+type Fake struct{}
+
+func (f *Fake) Name() string { return "fake" }
+
+func (f *Fake) EmbedImage(ctx context.Context, imagePath string) ([]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+
+	vec := make([]float32, FakeDimensions)
+	for i := 0; i < len(data); i += 64 {
+		end := min(i+64, len(data))
+		addFeature(vec, data[i:end])
+	}
+	return normalize(vec), nil
+}
+
+func (f *Fake) EmbedText(ctx context.Context, text string) ([]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	vec := make([]float32, FakeDimensions)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		addFeature(vec, []byte(word))
+	}
+	return normalize(vec), nil
+}
+
+// addFeature adds a signed one to the dimension a feature hashes to.
+func addFeature(vec []float32, feature []byte) {
+	h := fnv.New64a()
+	h.Write(feature)
+	sum := h.Sum64()
+	sign := float32(1)
+	if sum>>63 == 1 {
+		sign = -1
+	}
+	vec[sum%uint64(len(vec))] += sign
+}
+
+func normalize(vec []float32) []float32 {
+	var norm float64
+	for _, v := range vec {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return vec
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i := range vec {
+		vec[i] *= scale
+	}
+	return vec
+}
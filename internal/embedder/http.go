@@ -0,0 +1,96 @@
+package embedder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+const defaultHTTPTimeout = 30 * time.Second
+
+func init() {
+	Register("http", func(cfg Config) (Embedder, error) {
+		return NewHTTPEmbedder(cfg)
+	})
+}
+
+// HTTPEmbedder posts a JPEG, or a JSON {"text": ...} body, to a model
+// endpoint and expects {"embedding": [...]} in return.
+type HTTPEmbedder struct {
+	Endpoint string
+	APIKey   string
+	Client   *http.Client
+}
+
+func NewHTTPEmbedder(cfg Config) (*HTTPEmbedder, error) {
+	if cfg.Endpoint == "" {
+		return nil, fmt.Errorf("%w: http embedder requires an endpoint", ErrInvalidConfig)
+	}
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = defaultHTTPTimeout
+	}
+
+	return &HTTPEmbedder{
+		Endpoint: cfg.Endpoint,
+		APIKey:   cfg.APIKey,
+		Client:   &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (e *HTTPEmbedder) Name() string { return "http" }
+
+func (e *HTTPEmbedder) EmbedImage(ctx context.Context, imagePath string) ([]float32, error) {
+	data, err := os.ReadFile(imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	return e.embed(ctx, "image/jpeg", data)
+}
+
+func (e *HTTPEmbedder) EmbedText(ctx context.Context, text string) ([]float32, error) {
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode embedder request: %w", err)
+	}
+	return e.embed(ctx, "application/json", body)
+}
+
+func (e *HTTPEmbedder) embed(ctx context.Context, contentType string, body []byte) ([]float32, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build embedder request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/json")
+	if e.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.APIKey)
+	}
+
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embedder request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("embedder returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var result struct {
+		Embedding []float32 `json:"embedding"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode embedder response: %w", err)
+	}
+	if len(result.Embedding) == 0 {
+		return nil, fmt.Errorf("embedder returned an empty embedding")
+	}
+	return result.Embedding, nil
+}
@@ -0,0 +1,311 @@
+// Package events publishes video lifecycle changes as CloudEvents 1.0, in
+// structured JSON mode, to a configurable sink. Each event type has its own
+// data payload, documented with a JSON Schema under api_specs/events, so
+// subscribers don't depend on how videos are stored.
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ryanschneiderman/video-api/internal/db"
+)
+
+const (
+	SpecVersion = "1.0"
+
+	TypeVideoUploaded = "video.uploaded"
+	TypeVideoReady    = "video.ready"
+	TypeVideoFailed   = "video.failed"
+	TypeVideoDeleted  = "video.deleted"
+
+	// Published by the changefeed consumer for any write to the videos table.
+	TypeVideoStatusChanged   = "video.status_changed"
+	TypeVideoMetadataUpdated = "video.metadata_updated"
+
+	SinkSNS  = "sns"
+	SinkSQS  = "sqs"
+	SinkHTTP = "http"
+
+	defaultSource  = "/video-api"
+	defaultTimeout = 10 * time.Second
+)
+
+// Types lists every event type published. The first four are named like the
+// webhook events, and published at the same points.
+var Types = []string{
+	TypeVideoUploaded, TypeVideoReady, TypeVideoFailed, TypeVideoDeleted,
+	TypeVideoStatusChanged, TypeVideoMetadataUpdated,
+}
+
+var ErrUnknownType = errors.New("unknown event type")
+
+// Event is a CloudEvent in its JSON format. Subject is the video ID, and the
+// tenantid extension names the video's tenant.
+type Event struct {
+	SpecVersion     string    `json:"specversion"`
+	ID              string    `json:"id"`
+	Source          string    `json:"source"`
+	Type            string    `json:"type"`
+	Subject         string    `json:"subject"`
+	Time            time.Time `json:"time"`
+	DataContentType string    `json:"datacontenttype"`
+	TenantID        string    `json:"tenantid,omitempty"`
+	Data            any       `json:"data"`
+}
+
+// VideoUploadedData is the data of video.uploaded: an upload was accepted and
+// queued for processing, or recorded as an alias of an identical video.
+type VideoUploadedData struct {
+	VideoID     string    `json:"videoId"`
+	TenantID    string    `json:"tenantId"`
+	Title       string    `json:"title"`
+	Tags        []string  `json:"tags"`
+	ContentHash string    `json:"contentHash,omitempty"`
+	AliasOf     string    `json:"aliasOf,omitempty"`
+	UploadedAt  time.Time `json:"uploadedAt"`
+}
+
+// VideoReadyData is the data of video.ready: the video's outputs exist.
+type VideoReadyData struct {
+	VideoID         string   `json:"videoId"`
+	TenantID        string   `json:"tenantId"`
+	Title           string   `json:"title"`
+	DurationSeconds float64  `json:"durationSeconds"`
+	Renditions      []string `json:"renditions"`
+	AliasOf         string   `json:"aliasOf,omitempty"`
+}
+
+// VideoFailedData is the data of video.failed: processing failed for good.
+type VideoFailedData struct {
+	VideoID  string `json:"videoId"`
+	TenantID string `json:"tenantId"`
+	Title    string `json:"title"`
+	Reason   string `json:"reason"`
+}
+
+// VideoDeletedData is the data of video.deleted.
+type VideoDeletedData struct {
+	VideoID  string `json:"videoId"`
+	TenantID string `json:"tenantId"`
+}
+
+// VideoStatusChangedData is the data of video.status_changed.
+type VideoStatusChangedData struct {
+	VideoID        string `json:"videoId"`
+	TenantID       string `json:"tenantId"`
+	Status         string `json:"status"`
+	PreviousStatus string `json:"previousStatus"`
+	Reason         string `json:"reason,omitempty"`
+}
+
+// VideoMetadataUpdatedData is the data of video.metadata_updated. Fields
+// names the ones that changed; all of them have their new values.
+type VideoMetadataUpdatedData struct {
+	VideoID     string   `json:"videoId"`
+	TenantID    string   `json:"tenantId"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	Fields      []string `json:"fields"`
+}
+
+// NewVideoEvent returns the event of eventType about video, with the data
+// payload that type documents. The changefeed's types have their own
+// constructors.
+func NewVideoEvent(source, eventType string, video *db.Video) (Event, error) {
+	var data any
+	switch eventType {
+	case TypeVideoUploaded:
+		data = VideoUploadedData{
+			VideoID:     video.VideoID,
+			TenantID:    video.TenantID,
+			Title:       video.Title,
+			Tags:        nonNil(video.Tags),
+			ContentHash: video.ContentHash,
+			AliasOf:     video.AliasOf,
+			UploadedAt:  video.UploadDate.UTC(),
+		}
+	case TypeVideoReady:
+		renditions := make([]string, 0, len(video.Renditions))
+		for name := range video.Renditions {
+			renditions = append(renditions, name)
+		}
+		slices.Sort(renditions)
+		data = VideoReadyData{
+			VideoID:         video.VideoID,
+			TenantID:        video.TenantID,
+			Title:           video.Title,
+			DurationSeconds: video.ProcessingSeconds,
+			Renditions:      renditions,
+			AliasOf:         video.AliasOf,
+		}
+	case TypeVideoFailed:
+		data = VideoFailedData{
+			VideoID:  video.VideoID,
+			TenantID: video.TenantID,
+			Title:    video.Title,
+			Reason:   video.StatusReason,
+		}
+	case TypeVideoDeleted:
+		data = VideoDeletedData{VideoID: video.VideoID, TenantID: video.TenantID}
+	default:
+		return Event{}, fmt.Errorf("%w %q", ErrUnknownType, eventType)
+	}
+	return newEvent(source, eventType, video, data), nil
+}
+
+func NewStatusChangedEvent(source string, video *db.Video, previousStatus string) Event {
+	data := VideoStatusChangedData{
+		VideoID:        video.VideoID,
+		TenantID:       video.TenantID,
+		Status:         video.Status,
+		PreviousStatus: previousStatus,
+	}
+	if video.Status == db.StatusFailed {
+		data.Reason = video.StatusReason
+	}
+	return newEvent(source, TypeVideoStatusChanged, video, data)
+}
+
+func NewMetadataUpdatedEvent(source string, video *db.Video, fields []string) Event {
+	return newEvent(source, TypeVideoMetadataUpdated, video, VideoMetadataUpdatedData{
+		VideoID:     video.VideoID,
+		TenantID:    video.TenantID,
+		Title:       video.Title,
+		Description: video.Description,
+		Tags:        nonNil(video.Tags),
+		Fields:      fields,
+	})
+}
+
+func newEvent(source, eventType string, video *db.Video, data any) Event {
+	return Event{
+		SpecVersion:     SpecVersion,
+		ID:              uuid.NewString(),
+		Source:          source,
+		Type:            eventType,
+		Subject:         video.VideoID,
+		Time:            time.Now().UTC(),
+		DataContentType: "application/json",
+		TenantID:        video.TenantID,
+		Data:            data,
+	}
+}
+
+// nonNil keeps empty lists as [] rather than null in the JSON.
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// Config picks the sink events are published to. Target is the SNS topic
+// ARN, SQS queue URL or HTTP endpoint, depending on Sink. Publishing is
+// off when Sink is empty.
+type Config struct {
+	Sink    string
+	Target  string
+	Source  string
+	Timeout time.Duration
+}
+
+func (c Config) Enabled() bool {
+	return c.Sink != ""
+}
+
+func (c Config) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	if c.Target == "" {
+		return fmt.Errorf("an event sink target is required")
+	}
+	switch c.Sink {
+	case SinkSNS, SinkSQS:
+	case SinkHTTP:
+		u, err := url.Parse(c.Target)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("event sink target must be an absolute http or https URL")
+		}
+	default:
+		return fmt.Errorf("unknown event sink %q: use %s, %s or %s", c.Sink, SinkSNS, SinkSQS, SinkHTTP)
+	}
+	if c.Source != "" {
+		if _, err := url.Parse(c.Source); err != nil {
+			return fmt.Errorf("event source must be a URI reference: %w", err)
+		}
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("event sink timeout must not be negative")
+	}
+	return nil
+}
+
+// Sink delivers events somewhere.
+type Sink interface {
+	Send(ctx context.Context, event Event) error
+}
+
+// Publisher turns video changes into events and sends them to its sink.
+type Publisher struct {
+	Sink   Sink
+	Source string
+}
+
+// NewPublisher returns a publisher for cfg, which must be valid, or nil when
+// publishing is off.
+func NewPublisher(cfg Config, snsClient SNSAPI, sqsClient SQSAPI) *Publisher {
+	if !cfg.Enabled() {
+		return nil
+	}
+	source := cfg.Source
+	if source == "" {
+		source = defaultSource
+	}
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	var sink Sink
+	switch cfg.Sink {
+	case SinkSNS:
+		sink = &SNSSink{Client: snsClient, TopicARN: cfg.Target}
+	case SinkSQS:
+		sink = &SQSSink{Client: sqsClient, QueueURL: cfg.Target}
+	default:
+		sink = NewHTTPSink(cfg.Target, timeout)
+	}
+	return &Publisher{Sink: sink, Source: source}
+}
+
+// Publish sends the eventType event about video. Videos without a tenant
+// don't produce events.
+func (p *Publisher) Publish(ctx context.Context, eventType string, video *db.Video) error {
+	if video.TenantID == "" {
+		return nil
+	}
+	event, err := NewVideoEvent(p.Source, eventType, video)
+	if err != nil {
+		return err
+	}
+	return p.Send(ctx, event)
+}
+
+// Send publishes an event built by the caller, such as the changefeed's.
+func (p *Publisher) Send(ctx context.Context, event Event) error {
+	if event.TenantID == "" {
+		return nil
+	}
+	if err := p.Sink.Send(ctx, event); err != nil {
+		return fmt.Errorf("failed to publish %s event: %w", event.Type, err)
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/stretchr/testify/assert"
+)
+
+const schemaDir = "../../api_specs/events"
+
+// schema is the subset of JSON Schema the documented schemas use.
+type schema struct {
+	Type                 string             `json:"type"`
+	Const                any                `json:"const"`
+	Enum                 []any              `json:"enum"`
+	Format               string             `json:"format"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Properties           map[string]*schema `json:"properties"`
+	Items                *schema            `json:"items"`
+}
+
+func loadSchema(t *testing.T, name string) *schema {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(schemaDir, name))
+	if err != nil {
+		t.Fatalf("could not read schema %s: %v", name, err)
+	}
+	var s schema
+	if err := json.Unmarshal(b, &s); err != nil {
+		t.Fatalf("could not parse schema %s: %v", name, err)
+	}
+	return &s
+}
+
+// validate reports every way value, decoded from JSON, breaks s.
+func validate(s *schema, value any, at string) []string {
+	var errs []string
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return []string{at + ": not an object"}
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				errs = append(errs, fmt.Sprintf("%s: missing %s", at, name))
+			}
+		}
+		for name, v := range obj {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					errs = append(errs, fmt.Sprintf("%s: undocumented property %s", at, name))
+				}
+				continue
+			}
+			errs = append(errs, validate(prop, v, at+"."+name)...)
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return []string{at + ": not an array"}
+		}
+		for i, item := range items {
+			errs = append(errs, validate(s.Items, item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return []string{at + ": not a string"}
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				errs = append(errs, at+": not a date-time")
+			}
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return []string{at + ": not a number"}
+		}
+	}
+	if s.Const != nil && s.Const != value {
+		errs = append(errs, fmt.Sprintf("%s: %v is not %v", at, value, s.Const))
+	}
+	if s.Enum != nil && !slices.Contains(s.Enum, value) {
+		errs = append(errs, fmt.Sprintf("%s: %v is not one of %v", at, value, s.Enum))
+	}
+	return errs
+}
+
+// sampleVideo has every field an event can report set.
+func sampleVideo() *db.Video {
+	return &db.Video{
+		VideoID:           "v1",
+		TenantID:          "acme",
+		Title:             "clip.mp4",
+		Tags:              []string{"dog"},
+		UploadDate:        time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC),
+		Status:            db.StatusFailed,
+		StatusReason:      "no video stream",
+		ContentHash:       "abc123",
+		AliasOf:           "v0",
+		Renditions:        map[string]string{"mp4": "a.mp4", "audio": "a.m4a"},
+		ProcessingSeconds: 12.5,
+	}
+}
+
+// sampleEvents builds an event of every type about video.
+func sampleEvents(t *testing.T, video *db.Video) map[string]Event {
+	events := map[string]Event{
+		TypeVideoStatusChanged:   NewStatusChangedEvent(defaultSource, video, db.StatusProcessing),
+		TypeVideoMetadataUpdated: NewMetadataUpdatedEvent(defaultSource, video, []string{"title", "tags"}),
+	}
+	for _, eventType := range []string{TypeVideoUploaded, TypeVideoReady, TypeVideoFailed, TypeVideoDeleted} {
+		event, err := NewVideoEvent(defaultSource, eventType, video)
+		assert.NoError(t, err)
+		events[eventType] = event
+	}
+	return events
+}
+
+func TestEventsMatchDocumentedSchemas(t *testing.T) {
+	envelope := loadSchema(t, "cloudevent.schema.json")
+
+	// Both with every field set and with only the required ones.
+	for _, video := range []*db.Video{sampleVideo(), {VideoID: "v2", TenantID: "acme", Status: db.StatusReady}} {
+		events := sampleEvents(t, video)
+		assert.Len(t, events, len(Types))
+
+		for _, eventType := range Types {
+			dataSchema := loadSchema(t, eventType+".schema.json")
+			event := events[eventType]
+			body, err := json.Marshal(event)
+			assert.NoError(t, err)
+
+			var decoded map[string]any
+			assert.NoError(t, json.Unmarshal(body, &decoded))
+			assert.Empty(t, validate(envelope, decoded, "event"), eventType)
+			assert.Empty(t, validate(dataSchema, decoded["data"], "data"), eventType)
+		}
+	}
+}
+
+func TestEverySchemaIsAnEventType(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(schemaDir, "*.schema.json"))
+	assert.NoError(t, err)
+
+	var documented []string
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".schema.json")
+		if name != "cloudevent" {
+			documented = append(documented, name)
+		}
+	}
+	assert.ElementsMatch(t, Types, documented)
+
+	var enum []string
+	for _, v := range loadSchema(t, "cloudevent.schema.json").Properties["type"].Enum {
+		enum = append(enum, v.(string))
+	}
+	assert.ElementsMatch(t, Types, enum)
+}
+
+func TestNewVideoEvent(t *testing.T) {
+	event, err := NewVideoEvent("/test", TypeVideoReady, sampleVideo())
+	assert.NoError(t, err)
+	assert.Equal(t, SpecVersion, event.SpecVersion)
+	assert.Equal(t, "v1", event.Subject)
+	assert.Equal(t, "acme", event.TenantID)
+	assert.Equal(t, VideoReadyData{
+		VideoID: "v1", TenantID: "acme", Title: "clip.mp4",
+		DurationSeconds: 12.5, Renditions: []string{"audio", "mp4"}, AliasOf: "v0",
+	}, event.Data)
+
+	_, err = NewVideoEvent("/test", "video.renamed", sampleVideo())
+	assert.ErrorIs(t, err, ErrUnknownType)
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{}.Validate())
+	assert.NoError(t, Config{Sink: SinkSNS, Target: "arn:aws:sns:us-east-1:123456789012:videos"}.Validate())
+	assert.NoError(t, Config{Sink: SinkHTTP, Target: "https://events.example.com/in"}.Validate())
+	assert.Error(t, Config{Sink: SinkSQS}.Validate())
+	assert.Error(t, Config{Sink: SinkHTTP, Target: "events.example.com"}.Validate())
+	assert.Error(t, Config{Sink: "kafka", Target: "x"}.Validate())
+}
+
+type recordingSNS struct{ input *sns.PublishInput }
+
+func (r *recordingSNS) Publish(ctx context.Context, in *sns.PublishInput, _ ...func(*sns.Options)) (*sns.PublishOutput, error) {
+	r.input = in
+	return &sns.PublishOutput{}, nil
+}
+
+type recordingSQS struct{ input *sqs.SendMessageInput }
+
+func (r *recordingSQS) SendMessage(ctx context.Context, in *sqs.SendMessageInput, _ ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	r.input = in
+	return &sqs.SendMessageOutput{}, nil
+}
+
+func TestSNSSink(t *testing.T) {
+	client := &recordingSNS{}
+	p := NewPublisher(Config{Sink: SinkSNS, Target: "arn:aws:sns:us-east-1:123456789012:videos.fifo"}, client, nil)
+	assert.NoError(t, p.Publish(context.Background(), TypeVideoFailed, sampleVideo()))
+
+	var event map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(*client.input.Message), &event))
+	assert.Equal(t, TypeVideoFailed, event["type"])
+	assert.Equal(t, defaultSource, event["source"])
+	assert.Equal(t, TypeVideoFailed, *client.input.MessageAttributes["ce_type"].StringValue)
+	assert.Equal(t, "v1", *client.input.MessageGroupId)
+	assert.Equal(t, event["id"], *client.input.MessageDeduplicationId)
+}
+
+func TestSQSSink(t *testing.T) {
+	client := &recordingSQS{}
+	p := NewPublisher(Config{Sink: SinkSQS, Target: "https://sqs.us-east-1.amazonaws.com/123456789012/videos"}, nil, client)
+	assert.NoError(t, p.Publish(context.Background(), TypeVideoUploaded, sampleVideo()))
+
+	assert.Contains(t, *client.input.MessageBody, `"type":"video.uploaded"`)
+	assert.Equal(t, "acme", *client.input.MessageAttributes["ce_tenantid"].StringValue)
+	assert.Nil(t, client.input.MessageGroupId)
+}
+
+func TestHTTPSink(t *testing.T) {
+	var contentType string
+	var body []byte
+	status := http.StatusAccepted
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	p := NewPublisher(Config{Sink: SinkHTTP, Target: server.URL}, nil, nil)
+	assert.NoError(t, p.Publish(context.Background(), TypeVideoReady, sampleVideo()))
+	assert.Equal(t, ContentType, contentType)
+	assert.Contains(t, string(body), `"specversion":"1.0"`)
+
+	status = http.StatusInternalServerError
+	assert.Error(t, p.Publish(context.Background(), TypeVideoReady, sampleVideo()))
+}
+
+func TestPublishWithoutTenant(t *testing.T) {
+	client := &recordingSQS{}
+	p := NewPublisher(Config{Sink: SinkSQS, Target: "https://sqs.us-east-1.amazonaws.com/123456789012/videos"}, nil, client)
+	assert.NoError(t, p.Publish(context.Background(), TypeVideoReady, &db.Video{VideoID: "v1"}))
+	assert.Nil(t, client.input)
+	assert.Nil(t, NewPublisher(Config{}, nil, nil))
+}
@@ -0,0 +1,131 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// ContentType is the media type of a CloudEvent in structured JSON mode.
+const ContentType = "application/cloudevents+json"
+
+// SNSAPI is the part of the SNS client SNSSink uses.
+type SNSAPI interface {
+	Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+}
+
+// SQSAPI is the part of the SQS client SQSSink uses.
+type SQSAPI interface {
+	SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+}
+
+// SNSSink publishes each event as the message of an SNS topic. The type and
+// tenant are also message attributes, for subscription filter policies.
+type SNSSink struct {
+	Client   SNSAPI
+	TopicARN string
+}
+
+func (s *SNSSink) Send(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	input := &sns.PublishInput{
+		TopicArn: aws.String(s.TopicARN),
+		Message:  aws.String(string(body)),
+		MessageAttributes: map[string]snstypes.MessageAttributeValue{
+			"ce_type":     {DataType: aws.String("String"), StringValue: aws.String(event.Type)},
+			"ce_tenantid": {DataType: aws.String("String"), StringValue: aws.String(event.TenantID)},
+		},
+	}
+	// FIFO topics keep each video's events in order.
+	if strings.HasSuffix(s.TopicARN, ".fifo") {
+		input.MessageGroupId = aws.String(event.Subject)
+		input.MessageDeduplicationId = aws.String(event.ID)
+	}
+	_, err = s.Client.Publish(ctx, input)
+	return err
+}
+
+// SQSSink sends each event as the body of a message on an SQS queue, with
+// the same attributes as SNSSink.
+type SQSSink struct {
+	Client   SQSAPI
+	QueueURL string
+}
+
+func (s *SQSSink) Send(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	input := &sqs.SendMessageInput{
+		QueueUrl:    aws.String(s.QueueURL),
+		MessageBody: aws.String(string(body)),
+		MessageAttributes: map[string]sqstypes.MessageAttributeValue{
+			"ce_type":     {DataType: aws.String("String"), StringValue: aws.String(event.Type)},
+			"ce_tenantid": {DataType: aws.String("String"), StringValue: aws.String(event.TenantID)},
+		},
+	}
+	if strings.HasSuffix(s.QueueURL, ".fifo") {
+		input.MessageGroupId = aws.String(event.Subject)
+		input.MessageDeduplicationId = aws.String(event.ID)
+	}
+	_, err = s.Client.SendMessage(ctx, input)
+	return err
+}
+
+// HTTPSink POSTs each event to an endpoint, in the structured mode of the
+// CloudEvents HTTP binding. Any 2xx response is a success.
+type HTTPSink struct {
+	Client *http.Client
+	URL    string
+}
+
+func NewHTTPSink(url string, timeout time.Duration) *HTTPSink {
+	return &HTTPSink{
+		Client: &http.Client{
+			Timeout: timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		URL: url,
+	}
+}
+
+func (s *HTTPSink) Send(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", ContentType)
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint responded with %s", resp.Status)
+	}
+	return nil
+}
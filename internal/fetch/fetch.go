@@ -0,0 +1,294 @@
+// Package fetch downloads videos from URLs that API callers supply. The
+// caller picks the URL, so every connection, including those made to follow
+// redirects, is refused unless it goes to a public address.
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/ryanschneiderman/video-api/internal/media"
+)
+
+const (
+	defaultMaxBytes     = 5 << 30
+	defaultMaxRedirects = 5
+	defaultTimeout      = 30 * time.Minute
+	// sniffLen is how much of the body is read to detect the container,
+	// mimetype's default read limit.
+	sniffLen = 3072
+)
+
+var (
+	ErrInvalidURL       = errors.New("invalid source URL")
+	ErrBlockedAddress   = errors.New("address is not public")
+	ErrTooManyRedirects = errors.New("too many redirects")
+	ErrTooLarge         = errors.New("source exceeds the size limit")
+)
+
+// StatusError is a response other than 2xx from the source.
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("source responded with status %d", e.Code)
+}
+
+// Permanent reports whether fetching the same URL again can't succeed:
+// the URL or its content was refused, or the source answered with a client
+// error other than a timeout or rate limit.
+func Permanent(err error) bool {
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.Code >= 400 && status.Code < 500 &&
+			status.Code != http.StatusRequestTimeout && status.Code != http.StatusTooManyRequests
+	}
+	return errors.Is(err, ErrInvalidURL) || errors.Is(err, ErrBlockedAddress) ||
+		errors.Is(err, ErrTooManyRedirects) || errors.Is(err, ErrTooLarge) ||
+		errors.Is(err, media.ErrUnsupportedType)
+}
+
+type Config struct {
+	// MaxBytes caps the size of a fetched file. Defaults to 5 GiB.
+	MaxBytes int64
+	// MaxRedirects is how many redirects are followed. Defaults to 5.
+	MaxRedirects int
+	// Timeout bounds the whole download. Defaults to 30m.
+	Timeout time.Duration
+}
+
+// Fetcher downloads videos over HTTP or HTTPS.
+type Fetcher struct {
+	client       *http.Client
+	allow        func(netip.Addr) bool
+	maxBytes     int64
+	maxRedirects int
+}
+
+// Result describes a fetched file.
+type Result struct {
+	// ContentType is the container detected from the file's magic bytes.
+	ContentType string
+	Size        int64
+	// SHA256 is the hex SHA-256 of the file.
+	SHA256 string
+}
+
+func New(cfg Config) *Fetcher {
+	return newFetcher(cfg, IsPublic)
+}
+
+// newFetcher only connects to addresses allow accepts.
+func newFetcher(cfg Config, allow func(netip.Addr) bool) *Fetcher {
+	f := &Fetcher{
+		allow:        allow,
+		maxBytes:     cfg.MaxBytes,
+		maxRedirects: cfg.MaxRedirects,
+	}
+	if f.maxBytes <= 0 {
+		f.maxBytes = defaultMaxBytes
+	}
+	if f.maxRedirects <= 0 {
+		f.maxRedirects = defaultMaxRedirects
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	f.client = &http.Client{
+		Timeout:   timeout,
+		Transport: NewTransport(allow),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > f.maxRedirects {
+				return fmt.Errorf("%w: more than %d", ErrTooManyRedirects, f.maxRedirects)
+			}
+			_, err := validateURL(req.URL.String(), f.allow)
+			return err
+		},
+	}
+	return f
+}
+
+// NewTransport is an HTTP transport that only connects to addresses allow
+// accepts, such as IsPublic. The address is checked as each connection is
+// made, after DNS resolution, so a name can't resolve to a public address
+// when its URL is checked and a private one when it's requested.
+func NewTransport(allow func(netip.Addr) bool) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil || !allow(addr.Unmap()) {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+			}
+			return nil
+		},
+	}
+	return &http.Transport{
+		// An environment proxy would make the connections instead, so none
+		// is used.
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		ForceAttemptHTTP2:     true,
+	}
+}
+
+// ValidateURL checks that raw is an absolute http or https URL without
+// credentials whose host, if it's an IP address or localhost, is public.
+// Other hosts are checked when they're connected to.
+func ValidateURL(raw string) (*url.URL, error) {
+	return validateURL(raw, IsPublic)
+}
+
+func validateURL(raw string, allow func(netip.Addr) bool) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return nil, fmt.Errorf("%w: must be an absolute http or https URL", ErrInvalidURL)
+	}
+	if u.User != nil {
+		return nil, fmt.Errorf("%w: must not contain credentials", ErrInvalidURL)
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return nil, fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !allow(addr.Unmap()) {
+		return nil, fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+	}
+	return u, nil
+}
+
+// nonPublic are the ranges netip's predicates don't cover that mustn't be
+// reachable: shared and reserved IPv4 space, and IPv6 prefixes that embed
+// an IPv4 address.
+var nonPublic = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2002::/16"),
+}
+
+// IsPublic reports whether addr is a globally routable unicast address.
+// Loopback, private, link-local (which includes the cloud metadata
+// endpoint), multicast and reserved addresses aren't.
+func IsPublic(addr netip.Addr) bool {
+	if !addr.IsValid() || addr.IsUnspecified() || addr.IsLoopback() || addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range nonPublic {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// Fetch streams the video at rawURL into w. The response must be declared
+// as a video or as binary data, and its magic bytes must be an accepted
+// container. Nothing past the size limit is written.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string, w io.Writer) (*Result, error) {
+	u, err := validateURL(rawURL, f.allow)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	req.Header.Set("Accept", "video/*, application/octet-stream;q=0.9")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch source: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &StatusError{Code: resp.StatusCode}
+	}
+	if resp.ContentLength > f.maxBytes {
+		return nil, fmt.Errorf("%w: %d bytes declared, limit is %d", ErrTooLarge, resp.ContentLength, f.maxBytes)
+	}
+	if err := checkContentType(resp.Header.Get("Content-Type")); err != nil {
+		return nil, err
+	}
+
+	body := io.LimitReader(resp.Body, f.maxBytes)
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(body, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("failed to read source: %w", err)
+	}
+	head = head[:n]
+	contentType, err := media.VideoType(mimetype.Detect(head))
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(w, h), io.MultiReader(bytes.NewReader(head), body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source: %w", err)
+	}
+	// Any byte past the limit means the file was cut short.
+	if n, _ := io.ReadFull(resp.Body, make([]byte, 1)); n > 0 {
+		return nil, fmt.Errorf("%w: limit is %d bytes", ErrTooLarge, f.maxBytes)
+	}
+	return &Result{
+		ContentType: contentType,
+		Size:        size,
+		SHA256:      hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
+
+// checkContentType refuses responses declared as anything but a video or
+// generic binary data, such as the HTML of an error or login page. A
+// missing type is left to the magic bytes.
+func checkContentType(header string) error {
+	if header == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return fmt.Errorf("%w: %q", media.ErrUnsupportedType, header)
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "video/"),
+		mediaType == "application/octet-stream",
+		mediaType == "binary/octet-stream",
+		mediaType == "application/mp4":
+		return nil
+	}
+	return fmt.Errorf("%w: declared as %s", media.ErrUnsupportedType, mediaType)
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ryanschneiderman/video-api/internal/auth"
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/mapper"
+)
+
+// GetVideoAnalysis returns the time-coded analysis segments of a video,
+// optionally filtered by ?type= and a ?start=&end= range in seconds.
+func (vh *VideoHandler) GetVideoAnalysis(c *gin.Context) {
+	videoId := c.Param("id")
+
+	if _, err := uuid.Parse(videoId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid video ID format"})
+		return
+	}
+
+	filter, err := parseSegmentFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	video, err := vh.DB.GetVideoForTenant(ctx, auth.TenantID(c), videoId)
+	if err != nil {
+		if errors.Is(err, db.ErrVideoNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+			return
+		}
+		log.Printf("Failed to get video with ID: %s, error: %v", videoId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get video"})
+		return
+	}
+
+	// Aliases of duplicate uploads share the original's segments.
+	sourceId := videoId
+	if video.AliasOf != "" {
+		sourceId = video.AliasOf
+	}
+	segments, err := vh.DB.GetAnalysisSegments(ctx, sourceId, filter)
+	if err != nil {
+		log.Printf("Failed to get analysis for video ID: %s, error: %v", videoId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get video analysis"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapper.ToSegmentsResponse(ctx, video, segments, vh.Playback))
+}
+
+func parseSegmentFilter(c *gin.Context) (db.SegmentFilter, error) {
+	var filter db.SegmentFilter
+
+	for _, param := range c.QueryArray("type") {
+		for _, segmentType := range strings.Split(param, ",") {
+			segmentType = strings.TrimSpace(segmentType)
+			if segmentType == "" {
+				continue
+			}
+			if !slices.Contains(db.SegmentTypes, segmentType) {
+				return filter, fmt.Errorf("Invalid segment type %q, expected one of %s", segmentType, strings.Join(db.SegmentTypes, ", "))
+			}
+			if !slices.Contains(filter.Types, segmentType) {
+				filter.Types = append(filter.Types, segmentType)
+			}
+		}
+	}
+
+	var err error
+	if filter.From, err = parseSeconds(c, "start"); err != nil {
+		return filter, err
+	}
+	if filter.To, err = parseSeconds(c, "end"); err != nil {
+		return filter, err
+	}
+	if filter.From != nil && filter.To != nil && *filter.From > *filter.To {
+		return filter, fmt.Errorf("start must not be after end")
+	}
+
+	return filter, nil
+}
+
+func parseSeconds(c *gin.Context, name string) (*float64, error) {
+	value, ok := c.GetQuery(name)
+	if !ok || value == "" {
+		return nil, nil
+	}
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || seconds < 0 {
+		return nil, fmt.Errorf("Invalid %s, expected a non-negative number of seconds", name)
+	}
+	return &seconds, nil
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func testContext(target string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", target, nil)
+	return c
+}
+
+func TestParseSegmentFilter(t *testing.T) {
+	filter, err := parseSegmentFilter(testContext("/videos/x/analysis?type=scene,transcript&type=scene&start=1.5&end=30"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"scene", "transcript"}, filter.Types)
+	assert.Equal(t, 1.5, *filter.From)
+	assert.Equal(t, 30.0, *filter.To)
+}
+
+func TestParseSegmentFilterDefaults(t *testing.T) {
+	filter, err := parseSegmentFilter(testContext("/videos/x/analysis"))
+
+	assert.NoError(t, err)
+	assert.Empty(t, filter.Types)
+	assert.Nil(t, filter.From)
+	assert.Nil(t, filter.To)
+}
+
+func TestParseSegmentFilterInvalid(t *testing.T) {
+	for _, target := range []string{
+		"/videos/x/analysis?type=faces",
+		"/videos/x/analysis?start=abc",
+		"/videos/x/analysis?end=-1",
+		"/videos/x/analysis?start=10&end=5",
+	} {
+		_, err := parseSegmentFilter(testContext(target))
+		assert.Error(t, err, target)
+	}
+}
@@ -0,0 +1,611 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ryanschneiderman/video-api/internal/auth"
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/mapper"
+	"github.com/ryanschneiderman/video-api/internal/media"
+	"github.com/ryanschneiderman/video-api/internal/playback"
+	"github.com/ryanschneiderman/video-api/internal/tenant"
+	"github.com/ryanschneiderman/video-api/internal/webhook"
+)
+
+const (
+	maxBatchItems       = 100
+	defaultUploadURLTTL = time.Hour
+	// maxTagRetries bounds the rereads of a video whose tags keep changing
+	// under a batch tag update.
+	maxTagRetries = 3
+	// sniffBytes is how much of an uploaded file is read to detect its
+	// container, mimetype's default read limit.
+	sniffBytes = 3072
+)
+
+type batchCreateRequest struct {
+	Videos []batchVideoRequest `json:"videos" binding:"required"`
+}
+
+type batchVideoRequest struct {
+	Filename    string   `json:"filename"`
+	Size        int64    `json:"size"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+}
+
+type batchIDsRequest struct {
+	VideoIDs []string `json:"videoIds" binding:"required"`
+}
+
+type batchTagsRequest struct {
+	VideoIDs []string `json:"videoIds" binding:"required"`
+	Add      []string `json:"add"`
+	Remove   []string `json:"remove"`
+}
+
+// CreateVideos creates videos awaiting upload and returns a presigned URL
+// to PUT each file to. The files are checked and processed once the client
+// reports them uploaded to CompleteVideos. Each video is validated on its
+// own, so some can be created while others are refused.
+func (vh *VideoHandler) CreateVideos(c *gin.Context) {
+	req, err := parseBatchCreateRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	tenantId := auth.TenantID(c)
+	if err := vh.checkProcessingQuota(ctx, tenantId); err != nil {
+		if errors.Is(err, errProcessingQuota) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Monthly processing quota exceeded"})
+			return
+		}
+		log.Println("Error checking processing quota:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create videos"})
+		return
+	}
+
+	ttl := vh.UploadURLTTL
+	if ttl <= 0 {
+		ttl = defaultUploadURLTTL
+	}
+	presigner := s3.NewPresignClient(vh.S3Client)
+	maxBytes := vh.maxUploadBytes()
+
+	items := make([]mapper.BatchItem, len(req.Videos))
+	var videos []db.Video
+	var created []int
+	for i, v := range req.Videos {
+		items[i] = mapper.BatchItem{Index: i}
+		if err := validateBatchVideo(&v, maxBytes); err != nil {
+			items[i].Status = http.StatusBadRequest
+			items[i].Err = err.Error()
+			continue
+		}
+
+		videoID := uuid.New().String()
+		key := tenant.ObjectKey(tenantId, fmt.Sprintf("%s-%s", videoID, safeFilename(v.Filename, "upload")))
+		upload, err := presigner.PresignPutObject(ctx, &s3.PutObjectInput{
+			Bucket:        aws.String(vh.S3Bucket),
+			Key:           aws.String(key),
+			ContentLength: aws.Int64(v.Size),
+		}, s3.WithPresignExpires(ttl))
+		if err != nil {
+			log.Printf("Failed to presign upload of %s: %v", key, err)
+			items[i].Status = http.StatusInternalServerError
+			items[i].Err = "Failed to create upload URL"
+			continue
+		}
+
+		videos = append(videos, db.Video{
+			VideoID:     videoID,
+			TenantID:    tenantId,
+			Title:       v.Title,
+			Description: v.Description,
+			URL:         fmt.Sprintf("https://%s.s3.amazonaws.com/%s", vh.S3Bucket, key),
+			Tags:        v.Tags,
+			UploadDate:  time.Now(),
+			Status:      db.StatusAwaitingUpload,
+		})
+		created = append(created, i)
+		items[i].VideoID = videoID
+		items[i].UploadURL = upload.URL
+		items[i].UploadURLExpires = time.Now().Add(ttl)
+	}
+
+	if len(videos) > 0 {
+		status, message := http.StatusCreated, ""
+		if err := vh.DB.PutVideos(ctx, videos); err != nil {
+			log.Println("Error saving video records:", err)
+			status, message = http.StatusInternalServerError, "Failed to save video metadata"
+		}
+		for n, i := range created {
+			items[i].Status = status
+			items[i].Err = message
+			if message != "" {
+				items[i].UploadURL = ""
+				items[i].UploadURLExpires = time.Time{}
+				continue
+			}
+			items[i].VideoStatus = videos[n].Status
+		}
+	}
+	c.JSON(http.StatusOK, mapper.ToBatchResponse(ctx, items, vh.Playback))
+}
+
+// CompleteVideos checks the files uploaded for videos created by
+// CreateVideos and enqueues their processing. A file that is too large or
+// isn't a video is deleted and its video failed. Completing a video that is
+// already uploaded enqueues it again, in case an earlier completion couldn't,
+// and completing one that is processing or ready does nothing.
+func (vh *VideoHandler) CompleteVideos(c *gin.Context) {
+	req, err := parseBatchIDsRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	items, videos, ok := vh.batchLookup(c, req.VideoIDs)
+	if !ok {
+		return
+	}
+	for i := range items {
+		video := videos[i]
+		if video == nil {
+			continue
+		}
+		items[i].VideoStatus = video.Status
+		switch video.Status {
+		case db.StatusAwaitingUpload:
+			vh.completeUpload(ctx, &items[i], video)
+		case db.StatusUploaded:
+			vh.enqueueUpload(&items[i], video)
+		case db.StatusProcessing, db.StatusReady:
+			items[i].Status = http.StatusOK
+		default:
+			items[i].Status = http.StatusConflict
+			items[i].Err = fmt.Sprintf("Video is %s", video.Status)
+		}
+	}
+	c.JSON(http.StatusOK, mapper.ToBatchResponse(ctx, items, vh.Playback))
+}
+
+// completeUpload moves one video from awaiting_upload to uploaded.
+func (vh *VideoHandler) completeUpload(ctx context.Context, item *mapper.BatchItem, video *db.Video) {
+	fail := func(status int, message string) {
+		item.Status = status
+		item.Err = message
+	}
+	key, ok := playback.SourceKey(video)
+	if !ok {
+		fail(http.StatusInternalServerError, "Video has no upload location")
+		return
+	}
+
+	head, err := vh.S3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(vh.S3Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			fail(http.StatusConflict, "File has not been uploaded")
+			return
+		}
+		log.Printf("Failed to check upload of videoID %s: %v", video.VideoID, err)
+		fail(http.StatusInternalServerError, "Failed to check upload")
+		return
+	}
+	size := aws.ToInt64(head.ContentLength)
+	if maxBytes := vh.maxUploadBytes(); size > maxBytes {
+		vh.rejectUpload(ctx, video, key, fmt.Sprintf("file exceeds the %d byte upload limit", maxBytes))
+		fail(http.StatusRequestEntityTooLarge, fmt.Sprintf("File exceeds the %d byte upload limit", maxBytes))
+		item.VideoStatus = db.StatusFailed
+		return
+	}
+	if _, err := vh.sniffObject(ctx, key); err != nil {
+		if errors.Is(err, errUnsupportedType) {
+			vh.rejectUpload(ctx, video, key, "file is not a supported video format")
+			fail(http.StatusUnsupportedMediaType, "File is not a supported video format")
+			item.VideoStatus = db.StatusFailed
+			return
+		}
+		log.Printf("Failed to read upload of videoID %s: %v", video.VideoID, err)
+		fail(http.StatusInternalServerError, "Failed to check upload")
+		return
+	}
+
+	if err := vh.DB.ReserveStorage(ctx, video.TenantID, size, vh.StorageQuotaBytes); err != nil {
+		if errors.Is(err, db.ErrQuotaExceeded) {
+			fail(http.StatusForbidden, "Storage quota exceeded")
+			return
+		}
+		log.Println("Error reserving storage:", err)
+		fail(http.StatusInternalServerError, "Failed to complete upload")
+		return
+	}
+	err = vh.DB.UpdateVideoIfStatus(ctx, video.VideoID, db.StatusAwaitingUpload, map[string]interface{}{
+		"status": db.StatusUploaded,
+		"size":   size,
+	})
+	if err != nil {
+		vh.releaseStorage(ctx, video.TenantID, size)
+		if errors.Is(err, db.ErrConflict) {
+			fail(http.StatusConflict, "Video changed while completing its upload")
+			return
+		}
+		log.Println("Error updating video record:", err)
+		fail(http.StatusInternalServerError, "Failed to complete upload")
+		return
+	}
+	video.Status = db.StatusUploaded
+	video.Size = size
+	item.VideoStatus = video.Status
+
+	// The video stays uploaded if the job can't be sent, and completing it
+	// again sends it.
+	vh.enqueueUpload(item, video)
+	if item.Status == http.StatusOK {
+		vh.notify(ctx, webhook.EventVideoUploaded, video)
+	}
+}
+
+// enqueueUpload sends the processing job of an uploaded video.
+func (vh *VideoHandler) enqueueUpload(item *mapper.BatchItem, video *db.Video) {
+	key, ok := playback.SourceKey(video)
+	if !ok {
+		item.Status = http.StatusInternalServerError
+		item.Err = "Video has no upload location"
+		return
+	}
+	if err := vh.sendSQSMessage(video.VideoID, key); err != nil {
+		log.Println("Error sending SQS message:", err)
+		item.Status = http.StatusInternalServerError
+		item.Err = "Failed to enqueue processing job, complete the video again"
+		return
+	}
+	item.Status = http.StatusOK
+}
+
+// sniffObject detects the container of an uploaded file from its first
+// bytes.
+func (vh *VideoHandler) sniffObject(ctx context.Context, key string) (string, error) {
+	out, err := vh.S3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(vh.S3Bucket),
+		Key:    aws.String(key),
+		Range:  aws.String(fmt.Sprintf("bytes=0-%d", sniffBytes-1)),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get upload: %w", err)
+	}
+	defer out.Body.Close()
+	head, err := io.ReadAll(io.LimitReader(out.Body, sniffBytes))
+	if err != nil {
+		return "", fmt.Errorf("failed to read upload: %w", err)
+	}
+	return media.VideoType(mimetype.Detect(head))
+}
+
+// rejectUpload deletes a refused file, which was never counted against the
+// tenant's storage, and fails its video.
+func (vh *VideoHandler) rejectUpload(ctx context.Context, video *db.Video, key string, reason string) {
+	log.Printf("Rejected upload of videoID %s: %s", video.VideoID, reason)
+	_, err := vh.S3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(vh.S3Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		log.Printf("Failed to delete rejected upload %s: %v", key, err)
+	}
+	err = vh.DB.UpdateVideoIfStatus(ctx, video.VideoID, db.StatusAwaitingUpload, map[string]interface{}{
+		"status":        db.StatusFailed,
+		"status_reason": reason,
+	})
+	if err != nil {
+		log.Printf("Failed to fail videoID %s: %v", video.VideoID, err)
+	}
+}
+
+// GetVideos returns the caller's videos with the given IDs.
+func (vh *VideoHandler) GetVideos(c *gin.Context) {
+	req, err := parseBatchIDsRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	items, videos, ok := vh.batchLookup(c, req.VideoIDs)
+	if !ok {
+		return
+	}
+	if err := vh.DB.ResolveAliases(c.Request.Context(), videos...); err != nil {
+		log.Println("Error getting originals of videos:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get videos"})
+		return
+	}
+	for i := range items {
+		if videos[i] != nil {
+			items[i].Status = http.StatusOK
+			items[i].Video = videos[i]
+		}
+	}
+	c.JSON(http.StatusOK, mapper.ToBatchResponse(c.Request.Context(), items, vh.Playback))
+}
+
+// UpdateVideoTags adds and removes tags on the caller's videos. Each video
+// is updated on its own, and only if its tags haven't changed since they
+// were read, so concurrent updates are merged rather than lost.
+func (vh *VideoHandler) UpdateVideoTags(c *gin.Context) {
+	req, err := parseBatchTagsRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	items, videos, ok := vh.batchLookup(c, req.VideoIDs)
+	if !ok {
+		return
+	}
+	for i := range items {
+		video := videos[i]
+		if video == nil {
+			continue
+		}
+		for attempt := 0; ; attempt++ {
+			tags := editTags(video.Tags, req.Add, req.Remove)
+			if len(tags) > maxImportTags {
+				items[i].Status = http.StatusBadRequest
+				items[i].Err = fmt.Sprintf("At most %d tags are allowed", maxImportTags)
+				break
+			}
+			if slices.Equal(tags, video.Tags) {
+				items[i].Status = http.StatusOK
+				items[i].Tags = tags
+				break
+			}
+			err := vh.DB.SetVideoTags(ctx, video.VideoID, video.Tags, tags)
+			if err == nil {
+				items[i].Status = http.StatusOK
+				items[i].Tags = tags
+				break
+			}
+			if !errors.Is(err, db.ErrConflict) || attempt == maxTagRetries {
+				log.Printf("Failed to update tags of videoID %s: %v", video.VideoID, err)
+				items[i].Status = http.StatusInternalServerError
+				items[i].Err = "Failed to update tags"
+				break
+			}
+			video, err = vh.DB.GetVideoForTenant(ctx, video.TenantID, video.VideoID)
+			if errors.Is(err, db.ErrVideoNotFound) {
+				items[i].Status = http.StatusNotFound
+				items[i].Err = "Video not found"
+				break
+			}
+			if err != nil {
+				log.Printf("Failed to reread videoID %s: %v", items[i].VideoID, err)
+				items[i].Status = http.StatusInternalServerError
+				items[i].Err = "Failed to update tags"
+				break
+			}
+		}
+	}
+	c.JSON(http.StatusOK, mapper.ToBatchResponse(ctx, items, vh.Playback))
+}
+
+// editTags returns tags with remove taken out and add appended, keeping
+// their order and dropping duplicates.
+func editTags(tags []string, add []string, remove []string) []string {
+	edited := make([]string, 0, len(tags)+len(add))
+	for _, tag := range append(slices.Clone(tags), add...) {
+		if !slices.Contains(remove, tag) && !slices.Contains(edited, tag) {
+			edited = append(edited, tag)
+		}
+	}
+	return edited
+}
+
+// DeleteVideos deletes the caller's videos, gives back the storage they
+// count against and removes them from search. A video can't be deleted
+// while duplicate uploads share its files. The changefeed sends the
+// video.deleted events as the records go, and purges the files and index
+// entries they leave.
+func (vh *VideoHandler) DeleteVideos(c *gin.Context) {
+	req, err := parseBatchIDsRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	items, videos, ok := vh.batchLookup(c, req.VideoIDs)
+	if !ok {
+		return
+	}
+	deleted := map[string]bool{}
+	for i := range items {
+		video := videos[i]
+		if video == nil {
+			continue
+		}
+		if deleted[video.VideoID] {
+			items[i].Status = http.StatusOK
+			continue
+		}
+		if err := vh.DB.DeleteVideo(ctx, video); err != nil {
+			switch {
+			case errors.Is(err, db.ErrVideoNotFound):
+				items[i].Status = http.StatusNotFound
+				items[i].Err = "Video not found"
+			case errors.Is(err, db.ErrHasAliases):
+				items[i].Status = http.StatusConflict
+				items[i].Err = "Video has duplicates sharing its files, delete them first"
+			case errors.Is(err, db.ErrConflict):
+				items[i].Status = http.StatusConflict
+				items[i].Err = "Video changed while deleting it"
+			default:
+				log.Printf("Failed to delete videoID %s: %v", video.VideoID, err)
+				items[i].Status = http.StatusInternalServerError
+				items[i].Err = "Failed to delete video"
+			}
+			continue
+		}
+		deleted[video.VideoID] = true
+		// The video is gone either way, so a stale index entry is only
+		// logged, and the changefeed's purge tries again. Full-text search
+		// doesn't look its hits up.
+		if err := vh.DB.DeleteSearchDocument(ctx, video.TenantID, video.VideoID); err != nil {
+			log.Printf("Failed to remove videoID %s from search: %v", video.VideoID, err)
+		}
+		items[i].Status = http.StatusOK
+	}
+	c.JSON(http.StatusOK, mapper.ToBatchResponse(ctx, items, vh.Playback))
+}
+
+// batchLookup reads the caller's videos with the given IDs, in order. Items
+// for invalid IDs and videos the caller can't see are filled in with their
+// error and have no video. If the read fails the response has been written
+// and ok is false.
+func (vh *VideoHandler) batchLookup(c *gin.Context, ids []string) (items []mapper.BatchItem, videos []*db.Video, ok bool) {
+	items = make([]mapper.BatchItem, len(ids))
+	videos = make([]*db.Video, len(ids))
+	var valid []string
+	for i, id := range ids {
+		items[i] = mapper.BatchItem{Index: i, VideoID: id}
+		if _, err := uuid.Parse(id); err != nil {
+			items[i].Status = http.StatusBadRequest
+			items[i].Err = "Invalid video ID format"
+			continue
+		}
+		valid = append(valid, id)
+	}
+	if len(valid) == 0 {
+		return items, videos, true
+	}
+
+	found, err := vh.DB.BatchGetVideos(c.Request.Context(), valid)
+	if err != nil {
+		log.Println("Error getting videos:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get videos"})
+		return nil, nil, false
+	}
+	tenantId := auth.TenantID(c)
+	for i := range items {
+		if items[i].Status != 0 {
+			continue
+		}
+		video, exists := found[items[i].VideoID]
+		if !exists || video.TenantID != tenantId {
+			items[i].Status = http.StatusNotFound
+			items[i].Err = "Video not found"
+			continue
+		}
+		videos[i] = video
+	}
+	return items, videos, true
+}
+
+func (vh *VideoHandler) maxUploadBytes() int64 {
+	if vh.MaxUploadBytes <= 0 {
+		return defaultMaxUploadBytes
+	}
+	return vh.MaxUploadBytes
+}
+
+func parseBatchCreateRequest(c *gin.Context) (*batchCreateRequest, error) {
+	var req batchCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return nil, fmt.Errorf("invalid request body: %w", err)
+	}
+	if err := checkBatchSize(len(req.Videos)); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func parseBatchIDsRequest(c *gin.Context) (*batchIDsRequest, error) {
+	var req batchIDsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return nil, fmt.Errorf("invalid request body: %w", err)
+	}
+	if err := checkBatchSize(len(req.VideoIDs)); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func parseBatchTagsRequest(c *gin.Context) (*batchTagsRequest, error) {
+	var req batchTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return nil, fmt.Errorf("invalid request body: %w", err)
+	}
+	if err := checkBatchSize(len(req.VideoIDs)); err != nil {
+		return nil, err
+	}
+	if len(req.Add) == 0 && len(req.Remove) == 0 {
+		return nil, fmt.Errorf("add or remove must list at least one tag")
+	}
+	if len(req.Add) > maxImportTags || len(req.Remove) > maxImportTags {
+		return nil, fmt.Errorf("at most %d tags are allowed", maxImportTags)
+	}
+	for _, tag := range append(slices.Clone(req.Add), req.Remove...) {
+		if tag == "" {
+			return nil, fmt.Errorf("tags cannot be empty")
+		}
+	}
+	return &req, nil
+}
+
+func checkBatchSize(n int) error {
+	if n == 0 || n > maxBatchItems {
+		return fmt.Errorf("a batch must have between 1 and %d items", maxBatchItems)
+	}
+	return nil
+}
+
+// validateBatchVideo checks one video of a batch create, defaulting its
+// title to the file name.
+func validateBatchVideo(v *batchVideoRequest, maxBytes int64) error {
+	if v.Filename == "" {
+		return fmt.Errorf("filename is required")
+	}
+	if v.Size <= 0 {
+		return fmt.Errorf("size must be positive")
+	}
+	if v.Size > maxBytes {
+		return fmt.Errorf("file exceeds the %d byte upload limit", maxBytes)
+	}
+	if v.Title == "" {
+		v.Title = v.Filename
+		if len(v.Title) > maxImportTitleLength {
+			v.Title = v.Title[:maxImportTitleLength]
+		}
+	}
+	if len(v.Title) > maxImportTitleLength {
+		return fmt.Errorf("title must be at most %d characters", maxImportTitleLength)
+	}
+	if len(v.Tags) > maxImportTags {
+		return fmt.Errorf("at most %d tags are allowed", maxImportTags)
+	}
+	if v.Tags == nil {
+		v.Tags = []string{}
+	}
+	return nil
+}
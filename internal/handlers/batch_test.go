@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBatchTagsRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		body    string
+		wantErr string
+	}{
+		{`{"videoIds":["a"],"add":["pets"]}`, ""},
+		{`{"videoIds":["a"],"remove":["pets"]}`, ""},
+		{`{"add":["pets"]}`, "invalid request body"},
+		{`{"videoIds":[],"add":["pets"]}`, "between 1 and 100"},
+		{`{"videoIds":["a"]}`, "at least one tag"},
+		{`{"videoIds":["a"],"add":[""]}`, "cannot be empty"},
+		{`{"videoIds":["a"],"add":["x"` + strings.Repeat(`,"x"`, 50) + `]}`, "at most 50 tags"},
+		{`{"videoIds":["a"` + strings.Repeat(`,"a"`, 100) + `],"add":["pets"]}`, "between 1 and 100"},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("POST", "/videos/batch/tags", strings.NewReader(tt.body))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		_, err := parseBatchTagsRequest(c)
+		if tt.wantErr == "" {
+			assert.NoError(t, err, tt.body)
+		} else if assert.Error(t, err, tt.body) {
+			assert.Contains(t, err.Error(), tt.wantErr)
+		}
+	}
+}
+
+func TestValidateBatchVideo(t *testing.T) {
+	v := batchVideoRequest{Filename: "dog.mp4", Size: 100}
+	assert.NoError(t, validateBatchVideo(&v, 1000))
+	assert.Equal(t, "dog.mp4", v.Title)
+	assert.NotNil(t, v.Tags)
+
+	for _, tt := range []struct {
+		video   batchVideoRequest
+		wantErr string
+	}{
+		{batchVideoRequest{Size: 100}, "filename is required"},
+		{batchVideoRequest{Filename: "dog.mp4"}, "size must be positive"},
+		{batchVideoRequest{Filename: "dog.mp4", Size: 1001}, "upload limit"},
+		{batchVideoRequest{Filename: "dog.mp4", Size: 100, Title: strings.Repeat("a", 300)}, "title must be at most"},
+	} {
+		err := validateBatchVideo(&tt.video, 1000)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), tt.wantErr)
+		}
+	}
+}
+
+func TestEditTags(t *testing.T) {
+	assert.Equal(t, []string{"a", "c", "d"}, editTags([]string{"a", "b", "c"}, []string{"d", "a"}, []string{"b"}))
+	assert.Equal(t, []string{"x"}, editTags(nil, []string{"x", "x"}, nil))
+	assert.Equal(t, []string{}, editTags([]string{"a"}, nil, []string{"a"}))
+}
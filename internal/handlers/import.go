@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ryanschneiderman/video-api/internal/auth"
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/fetch"
+	"github.com/ryanschneiderman/video-api/internal/tenant"
+)
+
+const (
+	maxImportTitleLength = 256
+	maxImportTags        = 50
+	// maxImportFilenameLength bounds the part of the S3 key taken from the
+	// source URL or a client's file name.
+	maxImportFilenameLength = 100
+)
+
+// jobImport asks the worker to fetch the video's source before processing
+// it. See worker.JobImport.
+const jobImport = "import"
+
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+type importVideoRequest struct {
+	SourceURL   string   `json:"sourceUrl" binding:"required"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+
+	source *url.URL
+}
+
+// ImportVideo creates a video to be fetched from a URL. The worker fetches
+// it into S3 and then processes it like an upload, so the response only
+// reports that the import was accepted.
+func (vh *VideoHandler) ImportVideo(c *gin.Context) {
+	req, err := parseImportVideoRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tenantId := auth.TenantID(c)
+	if err := vh.checkProcessingQuota(c.Request.Context(), tenantId); err != nil {
+		if errors.Is(err, errProcessingQuota) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Monthly processing quota exceeded"})
+			return
+		}
+		log.Println("Error checking processing quota:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import video"})
+		return
+	}
+
+	videoID := uuid.New().String()
+	filename := tenant.ObjectKey(tenantId, fmt.Sprintf("%s-%s", videoID, importFilename(req.source)))
+	video := db.Video{
+		VideoID:     videoID,
+		TenantID:    tenantId,
+		Title:       req.Title,
+		Description: req.Description,
+		SourceURL:   req.SourceURL,
+		Tags:        req.Tags,
+		UploadDate:  time.Now(),
+		Status:      db.StatusImporting,
+	}
+	if err := vh.DB.PutVideo(c.Request.Context(), video); err != nil {
+		log.Println("Error saving video record:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save video metadata"})
+		return
+	}
+
+	if err := vh.sendImportMessage(c, videoID, filename); err != nil {
+		log.Println("Error sending SQS message:", err)
+		// Nothing would ever import the video, so it isn't left importing.
+		err = vh.DB.UpdateVideoIfStatus(c.Request.Context(), videoID, db.StatusImporting, map[string]interface{}{
+			"status":        db.StatusFailed,
+			"status_reason": "import job could not be enqueued",
+		})
+		if err != nil {
+			log.Printf("Failed to fail videoID %s: %v", videoID, err)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enqueue import job"})
+		return
+	}
+	log.Printf("Enqueued import of %s as videoID %s", req.SourceURL, videoID)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"videoId": videoID,
+		"status":  video.Status,
+	})
+}
+
+func (vh *VideoHandler) sendImportMessage(c *gin.Context, videoId string, filename string) error {
+	body, err := json.Marshal(queueMessage{VideoID: videoId, Filename: filename, Job: jobImport})
+	if err != nil {
+		return fmt.Errorf("failed to marshal SQS message: %w", err)
+	}
+	_, err = vh.SQSClient.SendMessage(c.Request.Context(), &sqs.SendMessageInput{
+		QueueUrl:    aws.String(vh.QueueURL),
+		MessageBody: aws.String(string(body)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send SQS message: %w", err)
+	}
+	return nil
+}
+
+// parseImportVideoRequest checks the source URL, so private addresses are
+// refused up front when the URL names one, and defaults the title to the
+// URL's file name.
+func parseImportVideoRequest(c *gin.Context) (*importVideoRequest, error) {
+	var req importVideoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return nil, fmt.Errorf("invalid request body: %w", err)
+	}
+
+	source, err := fetch.ValidateURL(req.SourceURL)
+	if err != nil {
+		return nil, fmt.Errorf("sourceUrl: %w", err)
+	}
+	req.source = source
+
+	if req.Title == "" {
+		req.Title = path.Base(source.Path)
+		if req.Title == "/" || req.Title == "." {
+			req.Title = source.Hostname()
+		}
+		if len(req.Title) > maxImportTitleLength {
+			req.Title = req.Title[:maxImportTitleLength]
+		}
+	}
+	if len(req.Title) > maxImportTitleLength {
+		return nil, fmt.Errorf("title must be at most %d characters", maxImportTitleLength)
+	}
+	if len(req.Tags) > maxImportTags {
+		return nil, fmt.Errorf("at most %d tags are allowed", maxImportTags)
+	}
+	if req.Tags == nil {
+		req.Tags = []string{}
+	}
+	return &req, nil
+}
+
+// importFilename is the source URL's file name made safe for an S3 key.
+func importFilename(source *url.URL) string {
+	return safeFilename(path.Base(source.Path), "source")
+}
+
+// safeFilename keeps the end of name, where the extension is, with anything
+// but letters, digits, dots, dashes and underscores replaced. A name with
+// nothing left becomes fallback.
+func safeFilename(name string, fallback string) string {
+	name = unsafeFilenameChars.ReplaceAllString(name, "_")
+	if len(name) > maxImportFilenameLength {
+		name = name[len(name)-maxImportFilenameLength:]
+	}
+	if name == "" || name == "." || name == ".." || name == "_" {
+		return fallback
+	}
+	return name
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseImportVideoRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		body      string
+		wantTitle string
+		wantErr   string
+	}{
+		{`{"sourceUrl":"https://cdn.example.com/clips/dog.mp4?sig=abc"}`, "dog.mp4", ""},
+		{`{"sourceUrl":"https://cdn.example.com/","title":"Dog","tags":["pets"]}`, "Dog", ""},
+		{`{"sourceUrl":"https://cdn.example.com/"}`, "cdn.example.com", ""},
+		{`not json`, "", "invalid request body"},
+		{`{"title":"Dog"}`, "", "invalid request body"},
+		{`{"sourceUrl":"file:///etc/passwd"}`, "", "absolute http or https URL"},
+		{`{"sourceUrl":"http://169.254.169.254/latest/meta-data/"}`, "", "not public"},
+		{`{"sourceUrl":"http://10.0.0.5:8080/a.mp4"}`, "", "not public"},
+		{`{"sourceUrl":"https://cdn.example.com/a.mp4","title":"` + strings.Repeat("a", 300) + `"}`, "", "title must be at most"},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("POST", "/videos/import", strings.NewReader(tt.body))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		req, err := parseImportVideoRequest(c)
+		if tt.wantErr == "" {
+			if assert.NoError(t, err, tt.body) {
+				assert.Equal(t, tt.wantTitle, req.Title)
+				assert.NotNil(t, req.Tags)
+			}
+		} else if assert.Error(t, err, tt.body) {
+			assert.Contains(t, err.Error(), tt.wantErr)
+		}
+	}
+}
+
+func TestImportFilename(t *testing.T) {
+	for raw, want := range map[string]string{
+		"https://example.com/clips/dog.mp4":                          "dog.mp4",
+		"https://example.com/clips/my%20dog!.mov":                    "my_dog_.mov",
+		"https://example.com/":                                       "source",
+		"https://example.com/a/" + strings.Repeat("x", 200) + ".mp4": strings.Repeat("x", 96) + ".mp4",
+	} {
+		u, _ := url.Parse(raw)
+		assert.Equal(t, want, importFilename(u), raw)
+	}
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/playback"
+)
+
+// ServePlayback serves a file of a video to anyone holding a playback token
+// for it, in place of API credentials. The token is checked against the
+// video ID in the path and its expiry before anything is looked up.
+func (vh *VideoHandler) ServePlayback(c *gin.Context) {
+	videoId := c.Param("id")
+
+	if _, err := uuid.Parse(videoId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid video ID format"})
+		return
+	}
+	expires, err := vh.PlaybackTokens.Verify(videoId, c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid or expired playback token"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	video, err := vh.DB.GetVideoById(ctx, videoId)
+	if err != nil {
+		if errors.Is(err, db.ErrVideoNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+			return
+		}
+		log.Printf("Failed to get video with ID: %s, error: %v", videoId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get video"})
+		return
+	}
+	key, err := playback.AssetKey(video, strings.TrimPrefix(c.Param("path"), "/"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+
+	// The URL embeds the token, so the response can be cached until the
+	// token expires but not shared between viewers.
+	maxAge := int(math.Max(0, time.Until(expires).Seconds()))
+	vh.streamObject(c, videoId, key, fmt.Sprintf("private, max-age=%d", maxAge))
+}
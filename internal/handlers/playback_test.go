@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ryanschneiderman/video-api/internal/playback"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServePlaybackRejectsBadTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokens := playback.NewTokenSigner([]byte(strings.Repeat("s", 32)), time.Minute, "")
+	vh := &VideoHandler{PlaybackTokens: tokens}
+	router := gin.New()
+	router.GET("/playback/:id/:token/*path", vh.ServePlayback)
+
+	videoId := "0b7c2a4e-5d8f-4e1a-9c3b-2f6d8e0a1b2c"
+	otherId := "9f1e2d3c-4b5a-4968-8776-a5b4c3d2e1f0"
+	for _, target := range []string{
+		"/playback/" + videoId + "/garbage/source",
+		"/playback/" + videoId + "/" + tokens.Token(otherId, time.Now().Add(time.Minute)) + "/source",
+		"/playback/" + videoId + "/" + tokens.Token(videoId, time.Now().Add(-time.Second)) + "/source",
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		assert.Equal(t, http.StatusForbidden, w.Code, target)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/playback/not-a-uuid/x/source", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
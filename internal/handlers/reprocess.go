@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ryanschneiderman/video-api/internal/auth"
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/reprocess"
+)
+
+// ReprocessVideo queues a processed video to run again from its stored
+// original, with an optional list of stages and a transcoding profile. The
+// video is uploaded until the worker picks the job up, and keeps serving its
+// current renditions until they are replaced.
+func (vh *VideoHandler) ReprocessVideo(c *gin.Context) {
+	videoId := c.Param("id")
+
+	if _, err := uuid.Parse(videoId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid video ID format"})
+		return
+	}
+
+	req, err := parseReprocessRequest(c, vh.Stages)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	tenantId := auth.TenantID(c)
+	video, err := vh.DB.GetVideoForTenant(ctx, tenantId, videoId)
+	if err != nil {
+		if errors.Is(err, db.ErrVideoNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+			return
+		}
+		log.Printf("Failed to get video with ID: %s, error: %v", videoId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get video"})
+		return
+	}
+
+	if err := vh.checkProcessingQuota(ctx, tenantId); err != nil {
+		if errors.Is(err, errProcessingQuota) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Monthly processing quota exceeded"})
+			return
+		}
+		log.Println("Error checking processing quota:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enqueue reprocess job"})
+		return
+	}
+
+	enqueuer := &reprocess.Enqueuer{DB: vh.DB, Queue: vh.SQSClient, QueueURL: vh.QueueURL}
+	msg, err := enqueuer.Enqueue(ctx, video, *req)
+	if err != nil {
+		switch {
+		case errors.Is(err, reprocess.ErrAlias):
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Video is a duplicate of %s, reprocess that video instead", video.AliasOf)})
+		case errors.Is(err, reprocess.ErrNoSource):
+			c.JSON(http.StatusConflict, gin.H{"error": "Video has no stored original to reprocess"})
+		case errors.Is(err, reprocess.ErrBusy):
+			c.JSON(http.StatusConflict, gin.H{"error": "Video is already being processed"})
+		default:
+			log.Println("Error enqueuing reprocess job:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enqueue reprocess job"})
+		}
+		return
+	}
+	log.Printf("Enqueued reprocessing of videoID %s with profile %s", videoId, msg.Profile)
+
+	stages := msg.Stages
+	if stages == nil {
+		stages = []string{}
+	}
+	c.JSON(http.StatusAccepted, gin.H{
+		"videoId": videoId,
+		"status":  db.StatusUploaded,
+		"stages":  stages,
+		"profile": msg.Profile,
+	})
+}
+
+// parseReprocessRequest accepts an empty body, which reprocesses every
+// stage with the video's current profile.
+func parseReprocessRequest(c *gin.Context, stages []string) (*reprocess.Request, error) {
+	var req reprocess.Request
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid request body: %w", err)
+	}
+	if err := req.Validate(stages); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseReprocessRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	stages := []string{"probe", "transcode", "analyze", "scenes"}
+
+	tests := []struct {
+		body    string
+		wantErr string
+	}{
+		{``, ""},
+		{`{}`, ""},
+		{`{"stages":["analyze","scenes"]}`, ""},
+		{`{"profile":"h264-720p"}`, ""},
+		{`{"stages":["thumbnail"]}`, "unknown stage"},
+		{`{"profile":"h265-4k"}`, "unknown transcoding profile"},
+		{`{"stages":"analyze"}`, "invalid request body"},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("POST", "/videos/id/reprocess", strings.NewReader(tt.body))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		_, err := parseReprocessRequest(c, stages)
+		if tt.wantErr == "" {
+			assert.NoError(t, err, tt.body)
+		} else if assert.Error(t, err, tt.body) {
+			assert.Contains(t, err.Error(), tt.wantErr)
+		}
+	}
+}
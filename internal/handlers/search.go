@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ryanschneiderman/video-api/internal/auth"
+	"github.com/ryanschneiderman/video-api/internal/mapper"
+	"github.com/ryanschneiderman/video-api/internal/search"
+)
+
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 50
+	maxSearchOffset    = 1000
+)
+
+// SearchVideos ranks the caller's videos by how well their title, description, tags,
+// labels and transcript match ?q=. Double-quoted phrases must match exactly
+// and ?tag= narrows the results to videos with every given tag.
+func (vh *VideoHandler) SearchVideos(c *gin.Context) {
+	query := search.ParseQuery(c.Query("q"))
+	if query.Empty() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing search query q"})
+		return
+	}
+
+	limit, err := parseIntQuery(c, "limit", defaultSearchLimit, 1, maxSearchLimit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	offset, err := parseIntQuery(c, "offset", 0, 0, maxSearchOffset)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var tags []string
+	for _, param := range c.QueryArray("tag") {
+		for _, tag := range strings.Split(param, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	results, err := search.Search(c.Request.Context(), vh.DB.SearchIndex(auth.TenantID(c)), query, search.Options{
+		Tags:   tags,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		log.Printf("Failed to search videos for %q: %v", c.Query("q"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search videos"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapper.ToSearchResponse(c.Query("q"), results))
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ryanschneiderman/video-api/internal/auth"
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/mapper"
+	"github.com/ryanschneiderman/video-api/internal/semantic"
+)
+
+const (
+	defaultSemanticLimit   = 10
+	maxSemanticLimit       = 50
+	maxSemanticQueryLength = 1000
+)
+
+type semanticSearchRequest struct {
+	Query    string  `json:"query"`
+	Limit    int     `json:"limit"`
+	MinScore float64 `json:"minScore"`
+}
+
+// SemanticSearch finds the caller's videos with scenes that look like the query text
+// describes, even when no word of it appears in their metadata.
+func (vh *VideoHandler) SemanticSearch(c *gin.Context) {
+	if vh.Semantic == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Semantic search is not configured"})
+		return
+	}
+
+	req, err := parseSemanticRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	tenantId := auth.TenantID(c)
+	matches, err := vh.Semantic.Search(ctx, tenantId, req.Query, semantic.Options{Limit: req.Limit, MinScore: req.MinScore})
+	if err != nil {
+		log.Printf("Failed semantic search for %q: %v", req.Query, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search videos"})
+		return
+	}
+
+	// The index can lag behind deletions, so only videos that still exist
+	// are returned.
+	videos := make(map[string]*db.Video, len(matches))
+	for _, m := range matches {
+		video, err := vh.DB.GetVideoForTenant(ctx, tenantId, m.VideoID)
+		if errors.Is(err, db.ErrVideoNotFound) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Failed to get video with ID: %s, error: %v", m.VideoID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search videos"})
+			return
+		}
+		videos[m.VideoID] = video
+	}
+
+	c.JSON(http.StatusOK, mapper.ToSemanticSearchResponse(req.Query, matches, videos))
+}
+
+func parseSemanticRequest(c *gin.Context) (*semanticSearchRequest, error) {
+	var req semanticSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return nil, fmt.Errorf("invalid request body: %w", err)
+	}
+
+	req.Query = strings.TrimSpace(req.Query)
+	if req.Query == "" {
+		return nil, errors.New("missing search query")
+	}
+	if len(req.Query) > maxSemanticQueryLength {
+		return nil, fmt.Errorf("query must be at most %d bytes", maxSemanticQueryLength)
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultSemanticLimit
+	}
+	if req.Limit < 1 || req.Limit > maxSemanticLimit {
+		return nil, fmt.Errorf("limit must be between 1 and %d", maxSemanticLimit)
+	}
+	if req.MinScore < -1 || req.MinScore > 1 {
+		return nil, errors.New("minScore must be between -1 and 1")
+	}
+	return &req, nil
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func semanticContext(body string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/videos/search/semantic", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	return c
+}
+
+func TestParseSemanticRequest(t *testing.T) {
+	req, err := parseSemanticRequest(semanticContext(`{"query": "  a dog on the beach "}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "a dog on the beach", req.Query)
+	assert.Equal(t, defaultSemanticLimit, req.Limit)
+
+	req, err = parseSemanticRequest(semanticContext(`{"query": "sunset", "limit": 5, "minScore": 0.3}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 5, req.Limit)
+	assert.Equal(t, 0.3, req.MinScore)
+}
+
+func TestParseSemanticRequestInvalid(t *testing.T) {
+	for _, body := range []string{
+		`not json`,
+		`{"query": "   "}`,
+		`{"query": "x", "limit": 51}`,
+		`{"query": "x", "limit": -1}`,
+		`{"query": "x", "minScore": 2}`,
+		`{"query": "` + strings.Repeat("a", maxSemanticQueryLength+1) + `"}`,
+	} {
+		_, err := parseSemanticRequest(semanticContext(body))
+		assert.Error(t, err, body)
+	}
+}
+
+func TestSemanticSearchNotConfigured(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/videos/search/semantic", strings.NewReader(`{"query": "x"}`))
+
+	(&VideoHandler{}).SemanticSearch(c)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
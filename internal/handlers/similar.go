@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ryanschneiderman/video-api/internal/auth"
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/mapper"
+)
+
+const (
+	defaultSimilarDistance = 8
+	maxSimilarDistance     = 16
+	defaultSimilarLimit    = 10
+	maxSimilarLimit        = 50
+)
+
+// GetSimilarVideos returns videos whose sampled frames are perceptually close
+// to this video's, within ?maxDistance= bits of a 64-bit frame hash.
+func (vh *VideoHandler) GetSimilarVideos(c *gin.Context) {
+	videoId := c.Param("id")
+
+	if _, err := uuid.Parse(videoId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid video ID format"})
+		return
+	}
+
+	maxDistance, err := parseIntQuery(c, "maxDistance", defaultSimilarDistance, 0, maxSimilarDistance)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	limit, err := parseIntQuery(c, "limit", defaultSimilarLimit, 1, maxSimilarLimit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	tenantId := auth.TenantID(c)
+	video, err := vh.DB.GetVideoForTenant(ctx, tenantId, videoId)
+	if err != nil {
+		if errors.Is(err, db.ErrVideoNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+			return
+		}
+		log.Printf("Failed to get video with ID: %s, error: %v", videoId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get video"})
+		return
+	}
+
+	// Aliases of duplicate uploads share the original's fingerprint.
+	sourceId := videoId
+	if video.AliasOf != "" {
+		sourceId = video.AliasOf
+	}
+	similar, err := vh.DB.FindSimilarVideos(ctx, tenantId, sourceId, maxDistance)
+	if err != nil {
+		log.Printf("Failed to find similar videos for video ID: %s, error: %v", videoId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find similar videos"})
+		return
+	}
+	if len(similar) > limit {
+		similar = similar[:limit]
+	}
+
+	c.JSON(http.StatusOK, mapper.ToSimilarVideosResponse(videoId, similar))
+}
+
+func parseIntQuery(c *gin.Context, name string, fallback, min, max int) (int, error) {
+	value, ok := c.GetQuery(name)
+	if !ok || value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("Invalid %s, expected an integer from %d to %d", name, min, max)
+	}
+	return n, nil
+}
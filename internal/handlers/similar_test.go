@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIntQuery(t *testing.T) {
+	n, err := parseIntQuery(testContext("/videos/x/similar"), "limit", 10, 1, 50)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, n)
+
+	n, err = parseIntQuery(testContext("/videos/x/similar?limit=25"), "limit", 10, 1, 50)
+	assert.NoError(t, err)
+	assert.Equal(t, 25, n)
+
+	for _, target := range []string{"/videos/x/similar?limit=0", "/videos/x/similar?limit=51", "/videos/x/similar?limit=ten"} {
+		_, err := parseIntQuery(testContext(target), "limit", 10, 1, 50)
+		assert.Error(t, err, target)
+	}
+}
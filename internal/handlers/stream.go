@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ryanschneiderman/video-api/internal/auth"
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/playback"
+)
+
+// streamContentTypes are the types players expect for streaming files. They
+// win over the type stored with the object, which HLS packagers often leave
+// generic, and over the system MIME table, which may map .ts to TypeScript.
+var streamContentTypes = map[string]string{
+	".m3u8": "application/vnd.apple.mpegurl",
+	".ts":   "video/mp2t",
+	".m4s":  "video/iso.segment",
+	".mp4":  "video/mp4",
+	".m4a":  "audio/mp4",
+	".vtt":  "text/vtt",
+}
+
+// StreamVideo streams one of a video's files, such as v1/transcoded.mp4,
+// or the HLS playlists and segments under hls/ for videos packaged for HLS,
+// for clients that can't reach S3.
+// The caller's credentials and tenant are checked on every request, segments
+// included.
+func (vh *VideoHandler) StreamVideo(c *gin.Context) {
+	videoId := c.Param("id")
+
+	if _, err := uuid.Parse(videoId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid video ID format"})
+		return
+	}
+
+	video, err := vh.DB.GetVideoForTenant(c.Request.Context(), auth.TenantID(c), videoId)
+	if err != nil {
+		if errors.Is(err, db.ErrVideoNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+			return
+		}
+		log.Printf("Failed to get video with ID: %s, error: %v", videoId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get video"})
+		return
+	}
+	key, err := playback.AssetKey(video, strings.TrimPrefix(c.Param("path"), "/"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+
+	// Responses depend on the caller's credentials, so caches must come
+	// back to revalidate, which the ETag makes cheap.
+	vh.streamObject(c, videoId, key, "private, no-cache")
+}
+
+// streamObject copies an object from S3 to the response. Range and
+// If-None-Match are passed on to S3, which answers them with 206 or 304.
+func (vh *VideoHandler) streamObject(c *gin.Context, videoId, key, cacheControl string) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(vh.S3Bucket),
+		Key:    aws.String(key),
+	}
+	if r := c.GetHeader("Range"); r != "" {
+		input.Range = aws.String(r)
+	}
+	if etag := c.GetHeader("If-None-Match"); etag != "" {
+		input.IfNoneMatch = aws.String(etag)
+	}
+
+	out, err := vh.S3Client.GetObject(c.Request.Context(), input)
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		var respErr *awshttp.ResponseError
+		switch {
+		case errors.As(err, &noSuchKey):
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		case errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotModified:
+			c.Header("Cache-Control", cacheControl)
+			if etag := respErr.Response.Header.Get("ETag"); etag != "" {
+				c.Header("ETag", etag)
+			}
+			c.AbortWithStatus(http.StatusNotModified)
+		case errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusRequestedRangeNotSatisfiable:
+			c.JSON(http.StatusRequestedRangeNotSatisfiable, gin.H{"error": "Range not satisfiable"})
+		default:
+			log.Printf("Failed to get %s for video ID: %s, error: %v", key, videoId, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get file"})
+		}
+		return
+	}
+	defer out.Body.Close()
+
+	headers := map[string]string{
+		"Accept-Ranges": "bytes",
+		"Cache-Control": cacheControl,
+	}
+	if out.ETag != nil {
+		headers["ETag"] = *out.ETag
+	}
+	if out.LastModified != nil {
+		headers["Last-Modified"] = out.LastModified.UTC().Format(http.TimeFormat)
+	}
+	status := http.StatusOK
+	if out.ContentRange != nil {
+		status = http.StatusPartialContent
+		headers["Content-Range"] = *out.ContentRange
+	}
+	c.DataFromReader(status, aws.ToInt64(out.ContentLength), streamContentType(key, aws.ToString(out.ContentType)), out.Body, headers)
+}
+
+// streamContentType picks from streamContentTypes first, then the stored
+// type, then one guessed from the extension.
+func streamContentType(key, stored string) string {
+	ext := strings.ToLower(path.Ext(key))
+	if t, ok := streamContentTypes[ext]; ok {
+		return t
+	}
+	if stored != "" && stored != "binary/octet-stream" && stored != "application/octet-stream" {
+		return stored
+	}
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
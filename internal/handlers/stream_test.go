@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// s3Stub serves the objects in files, keyed by "/<bucket>/<key>", with
+// http.ServeContent, which answers Range and If-None-Match like S3 does.
+func s3Stub(t *testing.T, files map[string]string) *s3.Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := files[r.URL.Path]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`))
+			return
+		}
+		w.Header().Set("ETag", `"abc123"`)
+		w.Header().Set("Content-Type", "binary/octet-stream")
+		http.ServeContent(w, r, "", time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC), bytes.NewReader([]byte(body)))
+	}))
+	t.Cleanup(server.Close)
+
+	return s3.New(s3.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL),
+		UsePathStyle: true,
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, nil
+		}),
+	})
+}
+
+func TestStreamObject(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	vh := &VideoHandler{
+		S3Bucket: "bucket",
+		S3Client: s3Stub(t, map[string]string{
+			"/bucket/processed/v1/hls/segment-001.ts": "0123456789",
+		}),
+	}
+
+	stream := func(key string, header http.Header) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/", nil)
+		c.Request.Header = header
+		vh.streamObject(c, "v1", key, "private, no-cache")
+		return w
+	}
+
+	w := stream("processed/v1/hls/segment-001.ts", http.Header{})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0123456789", w.Body.String())
+	assert.Equal(t, "video/mp2t", w.Header().Get("Content-Type"))
+	assert.Equal(t, `"abc123"`, w.Header().Get("ETag"))
+	assert.Equal(t, "bytes", w.Header().Get("Accept-Ranges"))
+	assert.Equal(t, "private, no-cache", w.Header().Get("Cache-Control"))
+	assert.Equal(t, "Sun, 01 Jun 2025 12:00:00 GMT", w.Header().Get("Last-Modified"))
+
+	w = stream("processed/v1/hls/segment-001.ts", http.Header{"Range": {"bytes=2-5"}})
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "2345", w.Body.String())
+	assert.Equal(t, "bytes 2-5/10", w.Header().Get("Content-Range"))
+	assert.Equal(t, "4", w.Header().Get("Content-Length"))
+
+	w = stream("processed/v1/hls/segment-001.ts", http.Header{"If-None-Match": {`"abc123"`}})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, `"abc123"`, w.Header().Get("ETag"))
+
+	w = stream("processed/v1/hls/segment-001.ts", http.Header{"Range": {"bytes=20-30"}})
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, w.Code)
+
+	w = stream("processed/v1/hls/segment-002.ts", http.Header{})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestStreamContentType(t *testing.T) {
+	assert.Equal(t, "application/vnd.apple.mpegurl", streamContentType("hls/master.m3u8", "binary/octet-stream"))
+	assert.Equal(t, "video/mp2t", streamContentType("hls/segment-001.TS", "text/plain"))
+	assert.Equal(t, "video/iso.segment", streamContentType("hls/segment-001.m4s", ""))
+	assert.Equal(t, "application/json", streamContentType("waveform.json", "application/json"))
+	assert.Equal(t, "image/jpeg", streamContentType("scenes/scene-0001.jpg", "binary/octet-stream"))
+	assert.Equal(t, "application/octet-stream", streamContentType("blob", ""))
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/ryanschneiderman/video-api/internal/media"
+)
+
+const (
+	defaultMaxUploadBytes = 5 << 30
+	// multipartOverhead leaves room for the form boundaries and headers
+	// around the file when the whole request body is capped.
+	multipartOverhead = 1 << 20
+)
+
+// Dedupe modes select what an upload whose content matches an already
+// processed video does. The mode comes from the dedupe form or query field.
+const (
+	// dedupeReference returns the existing video instead of creating one.
+	dedupeReference = "reference"
+	// dedupeAlias creates a new record that shares the existing outputs.
+	dedupeAlias = "alias"
+	// dedupeOff always uploads and processes the file.
+	dedupeOff = "off"
+)
+
+var errUnsupportedType = media.ErrUnsupportedType
+
+// sniffVideo detects the container from the file's magic bytes, ignoring
+// the client's Content-Type and extension, and rewinds the file for upload.
+func sniffVideo(file io.ReadSeeker) (string, error) {
+	mtype, err := mimetype.DetectReader(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read upload: %w", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("failed to rewind upload: %w", err)
+	}
+
+	return media.VideoType(mtype)
+}
+
+// hashFile returns the hex SHA-256 of the file and rewinds it for upload.
+func hashFile(file io.ReadSeeker) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", fmt.Errorf("failed to hash upload: %w", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("failed to rewind upload: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func parseDedupeMode(value string) (string, error) {
+	switch value {
+	case "":
+		return dedupeReference, nil
+	case dedupeReference, dedupeAlias, dedupeOff:
+		return value, nil
+	}
+	return "", fmt.Errorf("invalid dedupe mode %q: must be one of %s, %s or %s", value, dedupeReference, dedupeAlias, dedupeOff)
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// mp4Header is the start of an ISO BMFF file: an ftyp box with the isom brand.
+var mp4Header = []byte{
+	0x00, 0x00, 0x00, 0x20, 'f', 't', 'y', 'p', 'i', 's', 'o', 'm',
+	0x00, 0x00, 0x02, 0x00, 'i', 's', 'o', 'm', 'i', 's', 'o', '2',
+	'a', 'v', 'c', '1', 'm', 'p', '4', '1',
+}
+
+func TestSniffVideo(t *testing.T) {
+	file := bytes.NewReader(append(mp4Header, make([]byte, 64)...))
+
+	contentType, err := sniffVideo(file)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "video/mp4", contentType)
+	offset, _ := file.Seek(0, 1)
+	assert.Equal(t, int64(0), offset)
+}
+
+func TestSniffVideoRejectsOtherTypes(t *testing.T) {
+	for name, content := range map[string][]byte{
+		"text": []byte("definitely not a video"),
+		"png":  {0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'},
+		"mp3":  append([]byte("ID3\x03\x00\x00\x00\x00\x00\x00"), make([]byte, 32)...),
+	} {
+		_, err := sniffVideo(bytes.NewReader(content))
+		assert.ErrorIs(t, err, errUnsupportedType, name)
+	}
+}
+
+func uploadRequest(t *testing.T, filename string, content []byte) *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", filename)
+	assert.NoError(t, err)
+	part.Write(content)
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/videos", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestUploadVideoRejectsNonVideo(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	// Named like a video, but the bytes are what count.
+	c.Request = uploadRequest(t, "clip.mp4", []byte("#!/bin/sh\necho hello\n"))
+
+	(&VideoHandler{}).UploadVideo(c)
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
+
+func TestUploadVideoRejectsLargeFiles(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = uploadRequest(t, "clip.mp4", append(mp4Header, make([]byte, 4096)...))
+
+	(&VideoHandler{MaxUploadBytes: 1024}).UploadVideo(c)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestHashFile(t *testing.T) {
+	file := bytes.NewReader([]byte("hello"))
+
+	hash, err := hashFile(file)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", hash)
+	offset, _ := file.Seek(0, 1)
+	assert.Equal(t, int64(0), offset)
+}
+
+func TestParseDedupeMode(t *testing.T) {
+	mode, err := parseDedupeMode("")
+	assert.NoError(t, err)
+	assert.Equal(t, dedupeReference, mode)
+
+	mode, err = parseDedupeMode("alias")
+	assert.NoError(t, err)
+	assert.Equal(t, dedupeAlias, mode)
+
+	_, err = parseDedupeMode("skip")
+	assert.Error(t, err)
+}
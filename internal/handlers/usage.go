@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ryanschneiderman/video-api/internal/auth"
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/mapper"
+)
+
+var errProcessingQuota = errors.New("monthly processing quota exceeded")
+
+// GetUsage reports the caller's tenant's storage and processing against its
+// quotas, for the current month or the one given as ?period=YYYY-MM.
+func (vh *VideoHandler) GetUsage(c *gin.Context) {
+	period := db.UsagePeriod(time.Now())
+	if value := c.Query("period"); value != "" {
+		if _, err := time.Parse("2006-01", value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid period: expected YYYY-MM"})
+			return
+		}
+		period = value
+	}
+
+	usage, err := vh.DB.GetUsage(c.Request.Context(), auth.TenantID(c), period)
+	if err != nil {
+		log.Printf("Failed to get usage: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get usage"})
+		return
+	}
+	c.JSON(http.StatusOK, mapper.ToUsageResponse(usage, vh.StorageQuotaBytes, vh.ProcessingQuotaMinutes))
+}
+
+// checkProcessingQuota fails with errProcessingQuota once the tenant has
+// used up this month's processing minutes. The duration of an upload isn't
+// known until it is probed, so the upload that crosses the quota is still
+// accepted.
+func (vh *VideoHandler) checkProcessingQuota(ctx context.Context, tenantId string) error {
+	if vh.ProcessingQuotaMinutes <= 0 {
+		return nil
+	}
+	usage, err := vh.DB.GetUsage(ctx, tenantId, db.UsagePeriod(time.Now()))
+	if err != nil {
+		return err
+	}
+	if usage.ProcessingSeconds >= float64(vh.ProcessingQuotaMinutes*60) {
+		return errProcessingQuota
+	}
+	return nil
+}
+
+// releaseStorage gives back the storage reserved for an upload that failed.
+func (vh *VideoHandler) releaseStorage(ctx context.Context, tenantId string, bytes int64) {
+	if err := vh.DB.ReleaseStorage(ctx, tenantId, bytes); err != nil {
+		log.Printf("Failed to release %d bytes of storage for tenant %s: %v", bytes, tenantId, err)
+	}
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetUsageInvalidPeriod(t *testing.T) {
+	for _, period := range []string{"june", "2025-13", "2025-06-01"} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/usage?period="+period, nil)
+
+		(&VideoHandler{}).GetUsage(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, period)
+	}
+}
+
+func TestCheckProcessingQuotaUnlimited(t *testing.T) {
+	// Without a quota the usage isn't even looked up.
+	assert.NoError(t, (&VideoHandler{}).checkProcessingQuota(context.Background(), "acme"))
+}
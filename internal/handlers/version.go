@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ryanschneiderman/video-api/internal/auth"
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/mapper"
+	"github.com/ryanschneiderman/video-api/internal/reprocess"
+)
+
+// GetVideoVersions lists the processing runs of a video, newest first.
+// Aliases of duplicate uploads list the original's.
+func (vh *VideoHandler) GetVideoVersions(c *gin.Context) {
+	videoId := c.Param("id")
+
+	if _, err := uuid.Parse(videoId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid video ID format"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	tenantId := auth.TenantID(c)
+	video, err := vh.DB.GetVideoForTenant(ctx, tenantId, videoId)
+	if err == nil && video.AliasOf != "" {
+		video, err = vh.DB.GetVideoForTenant(ctx, tenantId, video.AliasOf)
+	}
+	if err != nil {
+		if errors.Is(err, db.ErrVideoNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+			return
+		}
+		log.Printf("Failed to get video with ID: %s, error: %v", videoId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get video"})
+		return
+	}
+
+	versions, err := vh.DB.ListVideoVersions(ctx, video.VideoID)
+	if err != nil {
+		log.Printf("Failed to list versions for video ID: %s, error: %v", videoId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list video versions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapper.ToVideoVersionsResponse(videoId, video.Version, versions))
+}
+
+// PromoteVideoVersion queues a ready earlier version of a video to become
+// its current one again. Like a reprocess, the video is uploaded until the
+// worker picks the job up.
+func (vh *VideoHandler) PromoteVideoVersion(c *gin.Context) {
+	videoId := c.Param("id")
+
+	if _, err := uuid.Parse(videoId); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid video ID format"})
+		return
+	}
+
+	number, err := parseVersion(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	video, err := vh.DB.GetVideoForTenant(ctx, auth.TenantID(c), videoId)
+	if err != nil {
+		if errors.Is(err, db.ErrVideoNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+			return
+		}
+		log.Printf("Failed to get video with ID: %s, error: %v", videoId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get video"})
+		return
+	}
+	if video.AliasOf != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Video is a duplicate of %s, promote a version of that video instead", video.AliasOf)})
+		return
+	}
+
+	version, err := vh.DB.GetVideoVersion(ctx, videoId, number)
+	if err != nil {
+		if errors.Is(err, db.ErrVersionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+			return
+		}
+		log.Printf("Failed to get version %d of video ID: %s, error: %v", number, videoId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get video version"})
+		return
+	}
+
+	enqueuer := &reprocess.Enqueuer{DB: vh.DB, Queue: vh.SQSClient, QueueURL: vh.QueueURL}
+	msg, err := enqueuer.Promote(ctx, video, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, reprocess.ErrVersionNotReady):
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Version %d is %s, only ready versions can be promoted", number, version.State)})
+		case errors.Is(err, reprocess.ErrCurrentVersion):
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Version %d is already current", number)})
+		case errors.Is(err, reprocess.ErrNoSource):
+			c.JSON(http.StatusConflict, gin.H{"error": "Video has no stored original to reprocess"})
+		case errors.Is(err, reprocess.ErrBusy):
+			c.JSON(http.StatusConflict, gin.H{"error": "Video is already being processed"})
+		default:
+			log.Println("Error enqueuing promote job:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enqueue promote job"})
+		}
+		return
+	}
+	log.Printf("Enqueued promotion of version %d of videoID %s", number, videoId)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"videoId": videoId,
+		"status":  db.StatusUploaded,
+		"version": msg.Version,
+		"profile": msg.Profile,
+	})
+}
+
+func parseVersion(c *gin.Context) (int, error) {
+	number, err := strconv.Atoi(c.Param("version"))
+	if err != nil || number < 1 {
+		return 0, fmt.Errorf("Invalid version %q, expected a positive number", c.Param("version"))
+	}
+	return number, nil
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVersion(t *testing.T) {
+	for _, tt := range []struct {
+		param string
+		want  int
+	}{
+		{"3", 3},
+		{"12", 12},
+	} {
+		c := testContext("/videos/x/versions/" + tt.param + "/promote")
+		c.Params = gin.Params{{Key: "version", Value: tt.param}}
+		number, err := parseVersion(c)
+		assert.NoError(t, err, tt.param)
+		assert.Equal(t, tt.want, number)
+	}
+
+	for _, param := range []string{"0", "-1", "latest", ""} {
+		c := testContext("/videos/x/versions/" + param + "/promote")
+		c.Params = gin.Params{{Key: "version", Value: param}}
+		_, err := parseVersion(c)
+		if assert.Error(t, err, param) {
+			assert.Contains(t, err.Error(), "Invalid version")
+		}
+	}
+}
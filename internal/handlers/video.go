@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
@@ -15,63 +16,178 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/ryanschneiderman/video-api/internal/app"
+	"github.com/ryanschneiderman/video-api/internal/auth"
 	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/embedder"
+	"github.com/ryanschneiderman/video-api/internal/events"
 	"github.com/ryanschneiderman/video-api/internal/mapper"
+	"github.com/ryanschneiderman/video-api/internal/playback"
+	"github.com/ryanschneiderman/video-api/internal/semantic"
+	"github.com/ryanschneiderman/video-api/internal/tenant"
+	"github.com/ryanschneiderman/video-api/internal/webhook"
 )
 
 type VideoHandler struct {
-	DB       *db.DB
-	S3Client  *s3.Client
-	SQSClient *sqs.Client
-	TableName string
-	S3Bucket  string
-	QueueURL  string
+	DB                     *db.DB
+	S3Client               *s3.Client
+	SQSClient              *sqs.Client
+	TableName              string
+	S3Bucket               string
+	QueueURL               string
+	MaxUploadBytes         int64
+	UploadURLTTL           time.Duration
+	StorageQuotaBytes      int64
+	ProcessingQuotaMinutes int64
+	// Stages names the processing stages a reprocess can pick, see
+	// worker.StageNames.
+	Stages   []string
+	Semantic *semantic.Searcher
+	// Playback signs the URLs in responses. PlaybackTokens verifies the
+	// tokens of the playback proxy, and is only set in token mode.
+	Playback       playback.Signer
+	PlaybackTokens *playback.TokenSigner
+	Webhooks       *webhook.Dispatcher
+	// Events is nil unless an event sink is configured.
+	Events *events.Publisher
 }
 
 func NewVideoHandler(app *app.App) *VideoHandler {
-	return &VideoHandler{
-		DB:        app.DB,
-		S3Client:  app.S3Client,
-		SQSClient: app.SQSClient,
-		TableName: app.TableName,
-		S3Bucket:  app.S3Bucket,
-		QueueURL:  app.QueueURL,
+	vh := &VideoHandler{
+		DB:                     app.DB,
+		S3Client:               app.S3Client,
+		SQSClient:              app.SQSClient,
+		TableName:              app.TableName,
+		S3Bucket:               app.S3Bucket,
+		QueueURL:               app.QueueURL,
+		MaxUploadBytes:         app.MaxUploadBytes,
+		UploadURLTTL:           app.UploadURLTTL,
+		StorageQuotaBytes:      app.StorageQuotaBytes,
+		ProcessingQuotaMinutes: app.ProcessingQuotaMinutes,
+		Playback:               playback.NewSigner(app.Playback, app.S3Client, app.S3Bucket),
+		Webhooks:               webhook.NewDispatcher(app.DB, app.Webhooks),
+		Events:                 events.NewPublisher(app.Events, app.SNSClient, app.SQSClient),
 	}
+	if tokens, ok := vh.Playback.(*playback.TokenSigner); ok {
+		vh.PlaybackTokens = tokens
+	}
+
+	e, err := embedder.New(app.Embedder)
+	if err != nil {
+		log.Printf("Semantic search disabled: %v", err)
+		return vh
+	}
+	vh.Semantic = semantic.NewSearcher(semantic.NewStore(app.S3Client, app.S3Bucket, e), e)
+	return vh
 }
 
 func (vh *VideoHandler) UploadVideo(c *gin.Context) {
+	maxBytes := vh.maxUploadBytes()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+multipartOverhead)
+
 	file, header, err := c.Request.FormFile("file")
 	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("File exceeds the %d byte upload limit", maxBytes)})
+			return
+		}
 		log.Println("Error retrieving file from request:", err)
 		c.JSON(400, gin.H{"error": "Invalid file upload"})
 		return
 	}
 	defer file.Close()
 
+	if header.Size > maxBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("File exceeds the %d byte upload limit", maxBytes)})
+		return
+	}
+	contentType, err := sniffVideo(file)
+	if err != nil {
+		log.Printf("Rejected upload %s: %v", header.Filename, err)
+		if errors.Is(err, errUnsupportedType) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "File is not a supported video format"})
+			return
+		}
+		c.JSON(400, gin.H{"error": "Invalid file upload"})
+		return
+	}
+	mode, err := parseDedupeMode(c.Request.FormValue("dedupe"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	hash, err := hashFile(file)
+	if err != nil {
+		log.Println("Error hashing upload:", err)
+		c.JSON(400, gin.H{"error": "Invalid file upload"})
+		return
+	}
+
+	tenantId := auth.TenantID(c)
+	if mode != dedupeOff {
+		existing, err := vh.findDuplicate(c.Request.Context(), tenantId, hash)
+		if err != nil {
+			log.Println("Error looking up content hash:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload video"})
+			return
+		}
+		if existing != nil {
+			vh.respondDuplicate(c, mode, existing, header.Filename)
+			return
+		}
+	}
+
+	// Quotas are checked, and the upload's storage reserved, before anything
+	// is written to S3.
+	if err := vh.checkProcessingQuota(c.Request.Context(), tenantId); err != nil {
+		if errors.Is(err, errProcessingQuota) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Monthly processing quota exceeded"})
+			return
+		}
+		log.Println("Error checking processing quota:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload video"})
+		return
+	}
+	if err := vh.DB.ReserveStorage(c.Request.Context(), tenantId, header.Size, vh.StorageQuotaBytes); err != nil {
+		if errors.Is(err, db.ErrQuotaExceeded) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Storage quota exceeded"})
+			return
+		}
+		log.Println("Error reserving storage:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload video"})
+		return
+	}
+
 	videoID := uuid.New().String()
-	filename := fmt.Sprintf("%s-%s", videoID, header.Filename)
+	filename := tenant.ObjectKey(tenantId, fmt.Sprintf("%s-%s", videoID, safeFilename(header.Filename, "upload")))
 	log.Println("Uploading file:", filename)
 
-	url, s3err := vh.uploadToS3(file, filename)
+	url, s3err := vh.uploadToS3(file, filename, contentType)
 	if s3err != nil {
 		log.Println("Error uploading to S3:", s3err)
+		vh.releaseStorage(c.Request.Context(), tenantId, header.Size)
 		c.JSON(500, gin.H{"error": "Failed to upload video"})
 		return
 	}
 
 	videoRecord := db.Video{
 		VideoID:     videoID,
+		TenantID:    tenantId,
 		Title:       header.Filename,
 		Description: "A newly uploaded video",
 		URL:         url,
-		Metadata: nil,
+		Metadata:    nil,
 		Tags:        []string{},
 		UploadDate:  time.Now(),
+		Status:      db.StatusUploaded,
+		ContentHash: hash,
+		Size:        header.Size,
 	}
 
 	ctx := context.TODO()
 	if err = vh.DB.PutVideo(ctx, videoRecord); err != nil {
 		log.Println("Error saving video record:", err)
+		vh.releaseStorage(c.Request.Context(), tenantId, header.Size)
 		c.JSON(500, gin.H{"error": "Failed to save video metadata"})
 		return
 	}
@@ -84,41 +200,104 @@ func (vh *VideoHandler) UploadVideo(c *gin.Context) {
 	}
 
 	log.Println("Successfully enqueued video processing message")
+	vh.notify(c.Request.Context(), webhook.EventVideoUploaded, &videoRecord)
 
 	c.JSON(201, gin.H{
 		"videoId": videoID,
 	})
 }
 
-func (vh *VideoHandler) GetVideo(c *gin.Context){
+// findDuplicate returns the tenant's processed video with the given content
+// hash, or nil when there is none. Videos still processing are not matched,
+// so their duplicates are processed normally.
+func (vh *VideoHandler) findDuplicate(ctx context.Context, tenantId string, hash string) (*db.Video, error) {
+	existing, err := vh.DB.GetVideoByHash(ctx, tenantId, hash)
+	if errors.Is(err, db.ErrVideoNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if existing.Status != db.StatusReady {
+		return nil, nil
+	}
+	return existing, nil
+}
+
+func (vh *VideoHandler) respondDuplicate(c *gin.Context, mode string, existing *db.Video, filename string) {
+	if mode == dedupeReference {
+		log.Printf("Upload %s duplicates videoID %s, returning it", filename, existing.VideoID)
+		c.JSON(http.StatusOK, gin.H{
+			"videoId":     existing.VideoID,
+			"duplicateOf": existing.VideoID,
+		})
+		return
+	}
+
+	alias := db.Video{
+		VideoID:     uuid.New().String(),
+		TenantID:    existing.TenantID,
+		Title:       filename,
+		Description: "A newly uploaded video",
+		URL:         existing.URL,
+		Tags:        existing.Tags,
+		UploadDate:  time.Now(),
+		Status:      db.StatusReady,
+		ContentHash: existing.ContentHash,
+		AliasOf:     existing.VideoID,
+	}
+	if err := vh.DB.PutAlias(c.Request.Context(), alias); err != nil {
+		log.Println("Error saving alias record:", err)
+		c.JSON(500, gin.H{"error": "Failed to save video metadata"})
+		return
+	}
+	// The alias's renditions and analysis are the original's, read with it.
+	alias.Renditions, alias.Analysis, alias.Loudness = existing.Renditions, existing.Analysis, existing.Loudness
+	log.Printf("Upload %s duplicates videoID %s, created alias %s", filename, existing.VideoID, alias.VideoID)
+	// The alias is ready as soon as it exists.
+	vh.notify(c.Request.Context(), webhook.EventVideoUploaded, &alias)
+	vh.notify(c.Request.Context(), webhook.EventVideoReady, &alias)
+	c.JSON(201, gin.H{
+		"videoId":     alias.VideoID,
+		"duplicateOf": existing.VideoID,
+	})
+}
+
+func (vh *VideoHandler) GetVideo(c *gin.Context) {
 	videoId := c.Param("id")
-	
+
 	if _, err := uuid.Parse(videoId); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid video ID format"})
 		return
 	}
 
-	ctx := context.TODO() 
-	video, err := vh.DB.GetVideoById(ctx, videoId)
-	videoResponse := mapper.ToVideoResponse(video)
-	if err != nil{
+	ctx := context.TODO()
+	video, err := vh.DB.GetVideoForTenant(ctx, auth.TenantID(c), videoId)
+	if err != nil {
 		if errors.Is(err, db.ErrVideoNotFound) {
 			log.Printf("Video not found with ID: %s", videoId)
 			c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
 			return
 		}
 		log.Printf("Failed to get video with ID: %s, error: %v", videoId, err)
-		c.JSON(500, gin.H{"error": "Failed to save video metadata"})
+		c.JSON(500, gin.H{"error": "Failed to get video"})
+		return
 	}
-	c.JSON(200, videoResponse)
+	if err := vh.DB.ResolveAliases(ctx, video); err != nil {
+		log.Printf("Failed to get original of video with ID: %s, error: %v", videoId, err)
+		c.JSON(500, gin.H{"error": "Failed to get video"})
+		return
+	}
+	c.JSON(200, mapper.ToVideoResponse(ctx, video, vh.Playback))
 }
 
-func (vh *VideoHandler) uploadToS3(file multipart.File, filename string) (string, error) {
+func (vh *VideoHandler) uploadToS3(file multipart.File, filename, contentType string) (string, error) {
 	_, err := vh.S3Client.PutObject(context.TODO(), &s3.PutObjectInput{
-		Bucket: aws.String(vh.S3Bucket),
-		Key:    aws.String(filename),
-		Body:   file,
-		ACL:    "private",
+		Bucket:      aws.String(vh.S3Bucket),
+		Key:         aws.String(filename),
+		Body:        file,
+		ACL:         "private",
+		ContentType: aws.String(contentType),
 	})
 	if err != nil {
 		return "", fmt.Errorf("failed to upload file to S3: %w", err)
@@ -128,18 +307,43 @@ func (vh *VideoHandler) uploadToS3(file multipart.File, filename string) (string
 	return url, nil
 }
 
-func (vh *VideoHandler) sendSQSMessage(videoId string, filename string) error {
+// queueMessage is a job for the worker, see worker.SQSMessage.
+type queueMessage struct {
+	VideoID  string `json:"video_id"`
+	Filename string `json:"filename"`
+	Job      string `json:"job,omitempty"`
+}
 
-	messageBody := fmt.Sprintf(`{"video_id": "%s", "filename": "%s"}`, videoId, filename)
+func (vh *VideoHandler) sendSQSMessage(videoId string, filename string) error {
+	body, err := json.Marshal(queueMessage{VideoID: videoId, Filename: filename})
+	if err != nil {
+		return fmt.Errorf("failed to marshal SQS message: %w", err)
+	}
 
 	sqsInput := &sqs.SendMessageInput{
 		QueueUrl:    aws.String(vh.QueueURL),
-		MessageBody: aws.String(messageBody),
+		MessageBody: aws.String(string(body)),
 	}
 
-	_, err := vh.SQSClient.SendMessage(context.TODO(), sqsInput)
+	_, err = vh.SQSClient.SendMessage(context.TODO(), sqsInput)
 	if err != nil {
 		return fmt.Errorf("failed to send SQS message: %w", err)
 	}
 	return nil
 }
+
+// notify publishes a lifecycle event to the video's tenant's webhooks and
+// the event sink. The request has already succeeded, so a failure is only
+// logged.
+func (vh *VideoHandler) notify(ctx context.Context, eventType string, video *db.Video) {
+	if vh.Webhooks != nil {
+		if err := vh.Webhooks.Publish(ctx, webhook.NewVideoEvent(eventType, video)); err != nil {
+			log.Printf("Failed to publish %s event for videoID %s: %v", eventType, video.VideoID, err)
+		}
+	}
+	if vh.Events != nil {
+		if err := vh.Events.Publish(ctx, eventType, video); err != nil {
+			log.Printf("Failed to publish %s event for videoID %s: %v", eventType, video.VideoID, err)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ryanschneiderman/video-api/internal/auth"
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/fetch"
+	"github.com/ryanschneiderman/video-api/internal/mapper"
+	"github.com/ryanschneiderman/video-api/internal/webhook"
+)
+
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 100
+)
+
+type createWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+// CreateWebhook registers an endpoint for the tenant's video events. The
+// signing secret is only ever returned here.
+func (vh *VideoHandler) CreateWebhook(c *gin.Context) {
+	req, err := parseCreateWebhookRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		log.Printf("Failed to create webhook: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+	hook := db.Webhook{
+		TenantID:  auth.TenantID(c),
+		WebhookID: uuid.NewString(),
+		URL:       req.URL,
+		Events:    req.Events,
+		Secret:    secret,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := vh.DB.PutWebhook(c.Request.Context(), hook); err != nil {
+		log.Printf("Failed to save webhook: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	response := mapper.ToWebhookResponse(&hook)
+	response.Secret = hook.Secret
+	c.JSON(http.StatusCreated, response)
+}
+
+func (vh *VideoHandler) ListWebhooks(c *gin.Context) {
+	hooks, err := vh.DB.ListWebhooks(c.Request.Context(), auth.TenantID(c))
+	if err != nil {
+		log.Printf("Failed to list webhooks: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list webhooks"})
+		return
+	}
+	c.JSON(http.StatusOK, mapper.ToWebhooksResponse(hooks))
+}
+
+func (vh *VideoHandler) DeleteWebhook(c *gin.Context) {
+	err := vh.DB.DeleteWebhook(c.Request.Context(), auth.TenantID(c), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, db.ErrWebhookNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+			return
+		}
+		log.Printf("Failed to delete webhook %s: %v", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListWebhookDeliveries pages through a webhook's delivery log, newest
+// first, optionally filtered by ?status=.
+func (vh *VideoHandler) ListWebhookDeliveries(c *gin.Context) {
+	filter, err := parseDeliveryFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	tenantId := auth.TenantID(c)
+	if _, err := vh.DB.GetWebhook(ctx, tenantId, c.Param("id")); err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+	deliveries, next, err := vh.DB.ListWebhookDeliveries(ctx, tenantId, c.Param("id"), filter)
+	if err != nil {
+		log.Printf("Failed to list deliveries of webhook %s: %v", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list webhook deliveries"})
+		return
+	}
+	c.JSON(http.StatusOK, mapper.ToWebhookDeliveriesResponse(deliveries, next))
+}
+
+// ReplayWebhookDelivery sends a past delivery's payload to the webhook
+// again, as a new delivery.
+func (vh *VideoHandler) ReplayWebhookDelivery(c *gin.Context) {
+	ctx := c.Request.Context()
+	tenantId := auth.TenantID(c)
+	hook, err := vh.DB.GetWebhook(ctx, tenantId, c.Param("id"))
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+	past, err := vh.DB.GetWebhookDelivery(ctx, tenantId, hook.WebhookID, c.Param("deliveryId"))
+	if err != nil {
+		if errors.Is(err, db.ErrDeliveryNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+			return
+		}
+		log.Printf("Failed to get delivery %s: %v", c.Param("deliveryId"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to replay delivery"})
+		return
+	}
+
+	delivery, err := vh.Webhooks.Replay(ctx, *hook, *past)
+	if err != nil {
+		log.Printf("Failed to replay delivery %s: %v", past.DeliveryID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to replay delivery"})
+		return
+	}
+	c.JSON(http.StatusAccepted, mapper.ToWebhookDeliveryResponse(delivery))
+}
+
+func respondWebhookError(c *gin.Context, err error) {
+	if errors.Is(err, db.ErrWebhookNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	log.Printf("Failed to get webhook %s: %v", c.Param("id"), err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get webhook"})
+}
+
+func parseCreateWebhookRequest(c *gin.Context) (*createWebhookRequest, error) {
+	var req createWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return nil, fmt.Errorf("invalid request body: %w", err)
+	}
+
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, errors.New("url must be an absolute http or https URL")
+	}
+	if _, err := fetch.ValidateURL(req.URL); errors.Is(err, fetch.ErrBlockedAddress) {
+		return nil, errors.New("url must not point at a private or local address")
+	}
+	if err := webhook.ValidateEvents(req.Events); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func parseDeliveryFilter(c *gin.Context) (db.DeliveryFilter, error) {
+	filter := db.DeliveryFilter{
+		Status: c.Query("status"),
+		Limit:  defaultDeliveryLimit,
+		After:  c.Query("cursor"),
+	}
+	switch filter.Status {
+	case "", db.DeliveryPending, db.DeliverySucceeded, db.DeliveryFailed:
+	default:
+		return filter, fmt.Errorf("invalid status %q: use pending, succeeded or failed", filter.Status)
+	}
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxDeliveryLimit {
+			return filter, fmt.Errorf("limit must be between 1 and %d", maxDeliveryLimit)
+		}
+		filter.Limit = int32(limit)
+	}
+	return filter, nil
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCreateWebhookRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		body    string
+		wantErr string
+	}{
+		{`{"url":"https://example.com/hook","events":["video.ready","video.failed"]}`, ""},
+		{`{"url":"http://example.com/hook","events":["video.uploaded"]}`, ""},
+		{`not json`, "invalid request body"},
+		{`{"url":"/hook","events":["video.ready"]}`, "absolute http or https URL"},
+		{`{"url":"ftp://example.com","events":["video.ready"]}`, "absolute http or https URL"},
+		{`{"url":"http://169.254.169.254/latest/meta-data/","events":["video.ready"]}`, "private or local address"},
+		{`{"url":"http://10.0.0.5:8080/hook","events":["video.ready"]}`, "private or local address"},
+		{`{"url":"http://localhost/hook","events":["video.ready"]}`, "private or local address"},
+		{`{"url":"https://example.com/hook"}`, "at least one event"},
+		{`{"url":"https://example.com/hook","events":["video.renamed"]}`, "unknown event type"},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("POST", "/webhooks", strings.NewReader(tt.body))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		_, err := parseCreateWebhookRequest(c)
+		if tt.wantErr == "" {
+			assert.NoError(t, err, tt.body)
+		} else if assert.Error(t, err, tt.body) {
+			assert.Contains(t, err.Error(), tt.wantErr)
+		}
+	}
+}
+
+func TestParseDeliveryFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	parse := func(query string) (db.DeliveryFilter, error) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/webhooks/h1/deliveries?"+query, nil)
+		return parseDeliveryFilter(c)
+	}
+
+	filter, err := parse("")
+	assert.NoError(t, err)
+	assert.Equal(t, db.DeliveryFilter{Limit: defaultDeliveryLimit}, filter)
+
+	filter, err = parse("status=failed&limit=10&cursor=d1")
+	assert.NoError(t, err)
+	assert.Equal(t, db.DeliveryFilter{Status: db.DeliveryFailed, Limit: 10, After: "d1"}, filter)
+
+	for _, query := range []string{"status=lost", "limit=0", "limit=101", "limit=ten"} {
+		_, err := parse(query)
+		assert.Error(t, err, query)
+	}
+}
+
+func TestListWebhookDeliveriesBadRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	vh := &VideoHandler{}
+	router.GET("/webhooks/:id/deliveries", vh.ListWebhookDeliveries)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/webhooks/h1/deliveries?status=lost", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,92 @@
+package hnsw
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"math/rand/v2"
+)
+
+// formatVersion is bumped whenever the encoded layout changes incompatibly.
+const formatVersion = 1
+
+type snapshot struct {
+	Version  int
+	Config   Config
+	Dim      int
+	Entry    int32
+	MaxLevel int
+	Nodes    []nodeSnapshot
+}
+
+type nodeSnapshot struct {
+	ID        string
+	Vector    []float32
+	Neighbors [][]int32
+	Deleted   bool
+}
+
+// Save writes the graph, including tombstones, so Load restores it without
+// rebuilding.
+func (idx *Index) Save(w io.Writer) error {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	snap := snapshot{
+		Version:  formatVersion,
+		Config:   idx.cfg,
+		Dim:      idx.dim,
+		Entry:    idx.entry,
+		MaxLevel: idx.maxLevel,
+		Nodes:    make([]nodeSnapshot, len(idx.nodes)),
+	}
+	for i, n := range idx.nodes {
+		snap.Nodes[i] = nodeSnapshot{ID: n.id, Vector: n.vector, Neighbors: n.neighbors, Deleted: n.deleted}
+	}
+	if err := gob.NewEncoder(w).Encode(&snap); err != nil {
+		return fmt.Errorf("failed to encode index: %w", err)
+	}
+	return nil
+}
+
+// Load reads an index written by Save.
+func Load(r io.Reader) (*Index, error) {
+	var snap snapshot
+	if err := gob.NewDecoder(r).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("failed to decode index: %w", err)
+	}
+	if snap.Version != formatVersion {
+		return nil, fmt.Errorf("unsupported index format version %d", snap.Version)
+	}
+
+	idx := New(snap.Config)
+	// Continue the level sequence somewhere other than where a fresh index
+	// with the same seed would start.
+	idx.rng = rand.New(rand.NewPCG(snap.Config.Seed, uint64(len(snap.Nodes))))
+	idx.dim = snap.Dim
+	idx.entry = snap.Entry
+	idx.maxLevel = snap.MaxLevel
+	idx.nodes = make([]*node, len(snap.Nodes))
+	for i, n := range snap.Nodes {
+		if len(n.Vector) != snap.Dim || len(n.Neighbors) == 0 {
+			return nil, fmt.Errorf("corrupt index: node %d is malformed", i)
+		}
+		for _, links := range n.Neighbors {
+			for _, other := range links {
+				if other < 0 || int(other) >= len(snap.Nodes) {
+					return nil, fmt.Errorf("corrupt index: node %d links to missing node %d", i, other)
+				}
+			}
+		}
+		idx.nodes[i] = &node{id: n.ID, vector: n.Vector, neighbors: n.Neighbors, deleted: n.Deleted}
+		if n.Deleted {
+			idx.deleted++
+		} else {
+			idx.ids[n.ID] = int32(i)
+		}
+	}
+	if len(idx.nodes) > 0 && (idx.entry < 0 || int(idx.entry) >= len(idx.nodes)) {
+		return nil, fmt.Errorf("corrupt index: entry point %d out of range", idx.entry)
+	}
+	return idx, nil
+}
@@ -0,0 +1,33 @@
+package hnsw
+
+import "container/heap"
+
+// candidateHeap orders candidates by distance; less picks the direction.
+type candidateHeap struct {
+	items []candidate
+	less  func(a, b float32) bool
+}
+
+func (h *candidateHeap) Len() int           { return len(h.items) }
+func (h *candidateHeap) Less(i, j int) bool { return h.less(h.items[i].dist, h.items[j].dist) }
+func (h *candidateHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *candidateHeap) Push(x any)         { h.items = append(h.items, x.(candidate)) }
+func (h *candidateHeap) Pop() any {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+func (h *candidateHeap) push(c candidate) { heap.Push(h, c) }
+func (h *candidateHeap) pop() candidate   { return heap.Pop(h).(candidate) }
+func (h *candidateHeap) top() candidate   { return h.items[0] }
+
+// newMinHeap pops the nearest candidate first.
+func newMinHeap() *candidateHeap {
+	return &candidateHeap{less: func(a, b float32) bool { return a < b }}
+}
+
+// newMaxHeap pops the farthest candidate first.
+func newMaxHeap() *candidateHeap {
+	return &candidateHeap{less: func(a, b float32) bool { return a > b }}
+}
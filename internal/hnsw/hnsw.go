@@ -0,0 +1,392 @@
+// Package hnsw is an in-memory approximate nearest neighbor index over unit
+// vectors, built as a Hierarchical Navigable Small World graph. Each vector
+// is linked to its nearest neighbors on layer 0 and, with exponentially
+// falling probability, on sparser layers above it; a search descends
+// greedily from the top layer and then explores the neighborhood of the best
+// match on layer 0.
+package hnsw
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"sync"
+)
+
+const (
+	defaultM              = 16
+	defaultEfConstruction = 200
+	defaultEfSearch       = 64
+)
+
+var (
+	ErrDimensionMismatch = errors.New("vector dimension mismatch")
+	ErrEmptyVector       = errors.New("vector is empty")
+)
+
+// Config tunes the graph. M is the number of links per node on the upper
+// layers (layer 0 gets twice as many); EfConstruction and EfSearch are the
+// candidate list sizes used when inserting and searching. Larger values trade
+// speed for recall. Zero values use the defaults.
+type Config struct {
+	M              int
+	EfConstruction int
+	EfSearch       int
+	Seed           uint64
+}
+
+// Result is a stored vector close to the query. Score is the cosine
+// similarity, 1 for an identical direction.
+type Result struct {
+	ID    string
+	Score float64
+}
+
+type node struct {
+	id        string
+	vector    []float32
+	neighbors [][]int32
+	deleted   bool
+}
+
+// Index is safe for concurrent use. Removed vectors are tombstoned and still
+// route searches until Compact rebuilds the graph without them.
+type Index struct {
+	mu sync.RWMutex
+
+	cfg       Config
+	levelMult float64
+	rng       *rand.Rand
+
+	dim      int
+	nodes    []*node
+	ids      map[string]int32
+	entry    int32
+	maxLevel int
+	deleted  int
+}
+
+func New(cfg Config) *Index {
+	if cfg.M <= 0 {
+		cfg.M = defaultM
+	}
+	if cfg.EfConstruction <= 0 {
+		cfg.EfConstruction = defaultEfConstruction
+	}
+	if cfg.EfSearch <= 0 {
+		cfg.EfSearch = defaultEfSearch
+	}
+	return &Index{
+		cfg:       cfg,
+		levelMult: 1 / math.Log(float64(cfg.M)),
+		rng:       rand.New(rand.NewPCG(cfg.Seed, cfg.Seed)),
+		ids:       make(map[string]int32),
+		entry:     -1,
+	}
+}
+
+// Len is the number of vectors that haven't been removed.
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.ids)
+}
+
+// Deleted is the number of tombstoned vectors Compact would drop.
+func (idx *Index) Deleted() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.deleted
+}
+
+// Dimensions is the length of the stored vectors, 0 until the first Add.
+func (idx *Index) Dimensions() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.dim
+}
+
+func (idx *Index) Contains(id string) bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	_, ok := idx.ids[id]
+	return ok
+}
+
+// Add inserts a vector, replacing any vector already stored under id. The
+// vector is copied and normalized.
+func (idx *Index) Add(id string, vector []float32) error {
+	if len(vector) == 0 {
+		return ErrEmptyVector
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if idx.dim == 0 {
+		idx.dim = len(vector)
+	} else if len(vector) != idx.dim {
+		return fmt.Errorf("%w: expected %d, got %d", ErrDimensionMismatch, idx.dim, len(vector))
+	}
+	idx.remove(id)
+	idx.insert(id, normalized(vector))
+	return nil
+}
+
+// Remove tombstones the vector stored under id and reports whether there was
+// one.
+func (idx *Index) Remove(id string) bool {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.remove(id)
+}
+
+func (idx *Index) remove(id string) bool {
+	n, ok := idx.ids[id]
+	if !ok {
+		return false
+	}
+	idx.nodes[n].deleted = true
+	delete(idx.ids, id)
+	idx.deleted++
+	return true
+}
+
+// Search returns up to k stored vectors closest to the query, best first.
+func (idx *Index) Search(query []float32, k int) ([]Result, error) {
+	if len(query) == 0 {
+		return nil, ErrEmptyVector
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	if k <= 0 || len(idx.ids) == 0 {
+		return []Result{}, nil
+	}
+	if len(query) != idx.dim {
+		return nil, fmt.Errorf("%w: expected %d, got %d", ErrDimensionMismatch, idx.dim, len(query))
+	}
+
+	q := normalized(query)
+	ep := []candidate{{id: idx.entry, dist: idx.distance(q, idx.entry)}}
+	for level := idx.maxLevel; level > 0; level-- {
+		ep = idx.searchLayer(q, ep, 1, level)
+	}
+	// Tombstones take up room in the candidate list, so widen it a little
+	// when there are some.
+	ef := max(idx.cfg.EfSearch, k) + min(idx.deleted, idx.cfg.EfSearch)
+	found := idx.searchLayer(q, ep, ef, 0)
+
+	results := make([]Result, 0, k)
+	for _, c := range found {
+		n := idx.nodes[c.id]
+		if n.deleted {
+			continue
+		}
+		results = append(results, Result{ID: n.id, Score: 1 - float64(c.dist)})
+		if len(results) == k {
+			break
+		}
+	}
+	return results, nil
+}
+
+// Compact rebuilds the graph without the removed vectors.
+func (idx *Index) Compact() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if idx.deleted == 0 {
+		return
+	}
+	nodes := idx.nodes
+	idx.nodes = nil
+	idx.ids = make(map[string]int32, len(nodes)-idx.deleted)
+	idx.entry = -1
+	idx.maxLevel = 0
+	idx.deleted = 0
+	for _, n := range nodes {
+		if !n.deleted {
+			idx.insert(n.id, n.vector)
+		}
+	}
+}
+
+func (idx *Index) maxLinks(level int) int {
+	if level == 0 {
+		return 2 * idx.cfg.M
+	}
+	return idx.cfg.M
+}
+
+func (idx *Index) randomLevel() int {
+	return int(-math.Log(1-idx.rng.Float64()) * idx.levelMult)
+}
+
+func (idx *Index) insert(id string, vector []float32) {
+	level := idx.randomLevel()
+	n := int32(len(idx.nodes))
+	idx.nodes = append(idx.nodes, &node{id: id, vector: vector, neighbors: make([][]int32, level+1)})
+	idx.ids[id] = n
+
+	if idx.entry < 0 {
+		idx.entry = n
+		idx.maxLevel = level
+		return
+	}
+
+	ep := []candidate{{id: idx.entry, dist: idx.distance(vector, idx.entry)}}
+	for l := idx.maxLevel; l > level; l-- {
+		ep = idx.searchLayer(vector, ep, 1, l)
+	}
+	for l := min(level, idx.maxLevel); l >= 0; l-- {
+		ep = idx.searchLayer(vector, ep, idx.cfg.EfConstruction, l)
+		neighbors := idx.selectNeighbors(ep, idx.cfg.M)
+		idx.nodes[n].neighbors[l] = neighbors
+		for _, other := range neighbors {
+			idx.link(other, n, l)
+		}
+	}
+
+	if level > idx.maxLevel {
+		idx.entry = n
+		idx.maxLevel = level
+	}
+}
+
+// link adds a link from one node to another, pruning the node's links back
+// to the layer's limit if needed.
+func (idx *Index) link(from, to int32, level int) {
+	n := idx.nodes[from]
+	n.neighbors[level] = append(n.neighbors[level], to)
+	if len(n.neighbors[level]) <= idx.maxLinks(level) {
+		return
+	}
+
+	candidates := make([]candidate, 0, len(n.neighbors[level]))
+	for _, other := range n.neighbors[level] {
+		candidates = append(candidates, candidate{id: other, dist: idx.distance(n.vector, other)})
+	}
+	sortCandidates(candidates)
+	n.neighbors[level] = idx.selectNeighbors(candidates, idx.maxLinks(level))
+}
+
+// selectNeighbors picks up to m of the candidates, sorted nearest first,
+// preferring ones that aren't closer to an already picked neighbor than to
+// the node itself. That spreads links in different directions and keeps the
+// graph navigable across clusters. Skipped candidates fill any room left.
+func (idx *Index) selectNeighbors(candidates []candidate, m int) []int32 {
+	selected := make([]int32, 0, m)
+	var skipped []int32
+	for _, c := range candidates {
+		if len(selected) == m {
+			break
+		}
+		diverse := true
+		for _, s := range selected {
+			if idx.distance(idx.nodes[c.id].vector, s) < c.dist {
+				diverse = false
+				break
+			}
+		}
+		if diverse {
+			selected = append(selected, c.id)
+		} else {
+			skipped = append(skipped, c.id)
+		}
+	}
+	for _, s := range skipped {
+		if len(selected) == m {
+			break
+		}
+		selected = append(selected, s)
+	}
+	return selected
+}
+
+// searchLayer returns the ef nodes nearest to q on one layer, nearest first,
+// found by expanding from the entry points.
+func (idx *Index) searchLayer(q []float32, entryPoints []candidate, ef int, level int) []candidate {
+	visited := make(map[int32]bool, ef*4)
+	candidates := newMinHeap()
+	results := newMaxHeap()
+	for _, ep := range entryPoints {
+		visited[ep.id] = true
+		candidates.push(ep)
+		results.push(ep)
+	}
+	for results.Len() > ef {
+		results.pop()
+	}
+
+	for candidates.Len() > 0 {
+		c := candidates.pop()
+		if results.Len() >= ef && c.dist > results.top().dist {
+			break
+		}
+		for _, other := range idx.nodes[c.id].neighbors[level] {
+			if visited[other] {
+				continue
+			}
+			visited[other] = true
+			d := idx.distance(q, other)
+			if results.Len() < ef || d < results.top().dist {
+				candidates.push(candidate{id: other, dist: d})
+				results.push(candidate{id: other, dist: d})
+				if results.Len() > ef {
+					results.pop()
+				}
+			}
+		}
+	}
+
+	found := make([]candidate, results.Len())
+	copy(found, results.items)
+	sortCandidates(found)
+	return found
+}
+
+// distance is the cosine distance between q and a stored node. Both are
+// normalized, so it's one minus their dot product.
+func (idx *Index) distance(q []float32, n int32) float32 {
+	v := idx.nodes[n].vector
+	var dot float32
+	for i := range q {
+		dot += q[i] * v[i]
+	}
+	return 1 - dot
+}
+
+func normalized(vector []float32) []float32 {
+	out := make([]float32, len(vector))
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		copy(out, vector)
+		return out
+	}
+	scale := 1 / math.Sqrt(norm)
+	for i, v := range vector {
+		out[i] = float32(float64(v) * scale)
+	}
+	return out
+}
+
+type candidate struct {
+	id   int32
+	dist float32
+}
+
+func sortCandidates(c []candidate) {
+	sort.Slice(c, func(i, j int) bool {
+		if c[i].dist != c[j].dist {
+			return c[i].dist < c[j].dist
+		}
+		return c[i].id < c[j].id
+	})
+}
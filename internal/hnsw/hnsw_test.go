@@ -0,0 +1,184 @@
+package hnsw
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func randomVectors(n, dim int, seed uint64) [][]float32 {
+	rng := rand.New(rand.NewPCG(seed, seed))
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		v := make([]float32, dim)
+		for j := range v {
+			v[j] = float32(rng.NormFloat64())
+		}
+		vectors[i] = normalized(v)
+	}
+	return vectors
+}
+
+func bruteForce(vectors [][]float32, q []float32, k int) []string {
+	type scored struct {
+		id    string
+		score float32
+	}
+	all := make([]scored, len(vectors))
+	for i, v := range vectors {
+		var dot float32
+		for j := range v {
+			dot += v[j] * q[j]
+		}
+		all[i] = scored{id: fmt.Sprint(i), score: dot}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].score > all[j].score })
+	ids := make([]string, k)
+	for i := range ids {
+		ids[i] = all[i].id
+	}
+	return ids
+}
+
+func TestSearchRecall(t *testing.T) {
+	vectors := randomVectors(2000, 32, 1)
+	idx := New(Config{Seed: 42})
+	for i, v := range vectors {
+		assert.NoError(t, idx.Add(fmt.Sprint(i), v))
+	}
+
+	const k = 10
+	queries := randomVectors(50, 32, 2)
+	var hits int
+	for _, q := range queries {
+		results, err := idx.Search(q, k)
+		assert.NoError(t, err)
+		assert.Len(t, results, k)
+		want := bruteForce(vectors, q, k)
+		for _, r := range results {
+			for _, id := range want {
+				if r.ID == id {
+					hits++
+				}
+			}
+		}
+	}
+	recall := float64(hits) / float64(len(queries)*k)
+	assert.Greater(t, recall, 0.95)
+}
+
+func TestSearchFindsExactMatch(t *testing.T) {
+	vectors := randomVectors(500, 16, 3)
+	idx := New(Config{Seed: 1})
+	for i, v := range vectors {
+		assert.NoError(t, idx.Add(fmt.Sprint(i), v))
+	}
+
+	results, err := idx.Search(vectors[123], 1)
+	assert.NoError(t, err)
+	if !assert.Len(t, results, 1) {
+		return
+	}
+	assert.Equal(t, "123", results[0].ID)
+	assert.InDelta(t, 1.0, results[0].Score, 1e-5)
+}
+
+func TestAddValidatesVectors(t *testing.T) {
+	idx := New(Config{})
+	assert.ErrorIs(t, idx.Add("a", nil), ErrEmptyVector)
+	assert.NoError(t, idx.Add("a", []float32{1, 0}))
+	assert.ErrorIs(t, idx.Add("b", []float32{1, 0, 0}), ErrDimensionMismatch)
+
+	_, err := idx.Search([]float32{1}, 1)
+	assert.ErrorIs(t, err, ErrDimensionMismatch)
+}
+
+func TestAddReplacesExistingID(t *testing.T) {
+	idx := New(Config{})
+	assert.NoError(t, idx.Add("a", []float32{1, 0}))
+	assert.NoError(t, idx.Add("b", []float32{0, 1}))
+	assert.NoError(t, idx.Add("a", []float32{0, 1}))
+
+	assert.Equal(t, 2, idx.Len())
+	assert.Equal(t, 1, idx.Deleted())
+	results, err := idx.Search([]float32{1, 0}, 5)
+	assert.NoError(t, err)
+	if !assert.Len(t, results, 2) {
+		return
+	}
+	assert.InDelta(t, 0, results[0].Score, 1e-6)
+}
+
+func TestRemoveAndCompact(t *testing.T) {
+	vectors := randomVectors(300, 16, 4)
+	idx := New(Config{Seed: 7})
+	for i, v := range vectors {
+		assert.NoError(t, idx.Add(fmt.Sprint(i), v))
+	}
+	removed := make(map[string]bool)
+	for i := 0; i < 150; i++ {
+		removed[fmt.Sprint(i)] = true
+		assert.True(t, idx.Remove(fmt.Sprint(i)))
+	}
+	assert.False(t, idx.Remove("0"))
+	assert.Equal(t, 150, idx.Len())
+
+	results, err := idx.Search(vectors[10], 20)
+	assert.NoError(t, err)
+	for _, r := range results {
+		assert.False(t, removed[r.ID], "removed vector %s returned", r.ID)
+	}
+
+	idx.Compact()
+	assert.Equal(t, 0, idx.Deleted())
+	assert.Equal(t, 150, idx.Len())
+	results, err = idx.Search(vectors[200], 1)
+	assert.NoError(t, err)
+	if !assert.Len(t, results, 1) {
+		return
+	}
+	assert.Equal(t, "200", results[0].ID)
+}
+
+func TestSearchEmptyIndex(t *testing.T) {
+	results, err := New(Config{}).Search([]float32{1, 0}, 3)
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+func TestSaveLoadRoundTrip(t *testing.T) {
+	vectors := randomVectors(400, 16, 5)
+	idx := New(Config{Seed: 9})
+	for i, v := range vectors {
+		assert.NoError(t, idx.Add(fmt.Sprint(i), v))
+	}
+	idx.Remove("17")
+
+	var buf bytes.Buffer
+	assert.NoError(t, idx.Save(&buf))
+	loaded, err := Load(&buf)
+	assert.NoError(t, err)
+
+	assert.Equal(t, idx.Len(), loaded.Len())
+	assert.Equal(t, 1, loaded.Deleted())
+	assert.False(t, loaded.Contains("17"))
+	for _, q := range randomVectors(10, 16, 6) {
+		want, err := idx.Search(q, 5)
+		assert.NoError(t, err)
+		got, err := loaded.Search(q, 5)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	assert.NoError(t, loaded.Add("new", vectors[0]))
+	assert.True(t, loaded.Contains("new"))
+}
+
+func TestLoadRejectsGarbage(t *testing.T) {
+	_, err := Load(bytes.NewReader([]byte("not an index")))
+	assert.Error(t, err)
+}
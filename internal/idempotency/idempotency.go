@@ -0,0 +1,294 @@
+// Package idempotency lets clients retry POST requests without repeating
+// their effects. A request sent with an Idempotency-Key header claims the key
+// for its tenant; retries with the same key and the same request get the
+// first response back instead of running again, while it is kept.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ryanschneiderman/video-api/internal/auth"
+	"github.com/ryanschneiderman/video-api/internal/db"
+)
+
+const (
+	Header = "Idempotency-Key"
+	// ReplayedHeader is set on responses replayed from an earlier request.
+	ReplayedHeader = "Idempotent-Replayed"
+
+	defaultTTL          = 24 * time.Hour
+	defaultLockTimeout  = time.Hour
+	defaultMaxBodyBytes = 5<<30 + 1<<20
+	maxKeyLength        = 255
+	// maxStoredBody keeps a stored response well under DynamoDB's item size
+	// limit. Larger responses aren't kept, and their requests can run again.
+	maxStoredBody = 300 << 10
+)
+
+var errBodyTooLarge = errors.New("request body too large")
+
+type Config struct {
+	// TTL is how long a response is replayed. Defaults to 24h.
+	TTL time.Duration
+	// LockTimeout is how long a request holds its key. A retry after it can
+	// run again, in case the process handling the request died. Defaults to
+	// 1h, to outlast the largest uploads.
+	LockTimeout time.Duration
+	// MaxBodyBytes caps the request bodies read to fingerprint them.
+	// Defaults to 5 GiB plus room for multipart framing.
+	MaxBodyBytes int64
+}
+
+// Store keeps the requests made with each key.
+type Store interface {
+	ClaimIdempotencyKey(ctx context.Context, record db.IdempotencyRecord) (*db.IdempotencyRecord, error)
+	CompleteIdempotencyKey(ctx context.Context, tenantId, key, token string, statusCode int, contentType string, body []byte, expiresAt time.Time) error
+	ReleaseIdempotencyKey(ctx context.Context, tenantId, key, token string) error
+}
+
+// Middleware makes POST requests that carry an Idempotency-Key header
+// idempotent. It must run after auth.Middleware, since keys are scoped to
+// the tenant. The request is fingerprinted by its method, URL and body: a
+// key reused for a different request gets 422, and a retry while the first
+// request is still running gets 409. Responses are kept, and replayed, unless
+// they are server errors, so those requests can be retried.
+func Middleware(store Store, cfg Config) gin.HandlerFunc {
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultTTL
+	}
+	if cfg.LockTimeout <= 0 {
+		cfg.LockTimeout = defaultLockTimeout
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = defaultMaxBodyBytes
+	}
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(Header)
+		if key == "" || c.Request.Method != http.MethodPost {
+			c.Next()
+			return
+		}
+		if err := validateKey(key); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		body, fingerprint, err := spool(c.Request, cfg.MaxBodyBytes)
+		if err != nil {
+			if errors.Is(err, errBodyTooLarge) {
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Request body exceeds %d bytes", cfg.MaxBodyBytes)})
+				return
+			}
+			log.Println("Error reading request body:", err)
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			return
+		}
+		defer body.remove()
+		c.Request.Body = body
+
+		ctx := c.Request.Context()
+		tenantId := auth.TenantID(c)
+		token := uuid.New().String()
+		now := time.Now()
+		holder, err := store.ClaimIdempotencyKey(ctx, db.IdempotencyRecord{
+			TenantID:    tenantId,
+			Key:         key,
+			Fingerprint: fingerprint,
+			Token:       token,
+			LockedUntil: now.Add(cfg.LockTimeout).Unix(),
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(cfg.TTL).Unix(),
+		})
+		if err != nil {
+			if errors.Is(err, db.ErrIdempotencyKeyLost) {
+				inProgress(c)
+				return
+			}
+			log.Println("Error claiming idempotency key:", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check idempotency key"})
+			return
+		}
+		if holder != nil {
+			switch {
+			case holder.Fingerprint != fingerprint:
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used for a different request"})
+			case holder.State != db.IdempotencyCompleted:
+				inProgress(c)
+			default:
+				c.Header(ReplayedHeader, "true")
+				c.Data(holder.StatusCode, holder.ContentType, holder.Body)
+				c.Abort()
+			}
+			return
+		}
+
+		// The response has been written by the time the key is released or
+		// completed, so that happens even if the client has gone. A handler
+		// that panics releases the key too.
+		ctx = context.WithoutCancel(ctx)
+		release := func() {
+			if err := store.ReleaseIdempotencyKey(ctx, tenantId, key, token); err != nil {
+				log.Printf("Failed to release idempotency key for tenant %s: %v", tenantId, err)
+			}
+		}
+		finished := false
+		defer func() {
+			if !finished {
+				release()
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+		finished = true
+
+		status := recorder.Status()
+		if status >= 500 || recorder.body.Len() > maxStoredBody {
+			release()
+			return
+		}
+		err = store.CompleteIdempotencyKey(ctx, tenantId, key, token, status,
+			recorder.Header().Get("Content-Type"), recorder.body.Bytes(), time.Now().Add(cfg.TTL))
+		if err != nil {
+			log.Printf("Failed to store response for idempotency key of tenant %s: %v", tenantId, err)
+		}
+	}
+}
+
+func inProgress(c *gin.Context) {
+	c.Header("Retry-After", "1")
+	c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is in progress"})
+}
+
+// validateKey accepts up to 255 printable ASCII characters, enough for a
+// UUID or any client-generated token.
+func validateKey(key string) error {
+	if len(key) > maxKeyLength {
+		return fmt.Errorf("%s must be at most %d characters", Header, maxKeyLength)
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x21 || key[i] > 0x7e {
+			return fmt.Errorf("%s must be printable ASCII", Header)
+		}
+	}
+	return nil
+}
+
+// spooledBody is a request body copied to a temporary file, which it
+// removes once the request is done.
+type spooledBody struct {
+	*os.File
+}
+
+func (b spooledBody) remove() {
+	b.File.Close()
+	os.Remove(b.File.Name())
+}
+
+// spool copies the request body to a temporary file while fingerprinting
+// the request, so the handler can still read the body. The body is read
+// before the key is claimed, so the whole request is compared, not a prefix.
+func spool(r *http.Request, limit int64) (spooledBody, string, error) {
+	file, err := os.CreateTemp("", "idempotency-*")
+	if err != nil {
+		return spooledBody{}, "", fmt.Errorf("failed to create spool file: %w", err)
+	}
+	body := spooledBody{file}
+
+	counter := &countingWriter{w: file}
+	src := io.TeeReader(io.LimitReader(r.Body, limit+1), counter)
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", r.Method, r.URL.RequestURI())
+	err = fingerprintBody(h, src, r.Header.Get("Content-Type"))
+	if err == nil {
+		_, err = io.Copy(io.Discard, src)
+	}
+	r.Body.Close()
+	if err == nil && counter.n > limit {
+		err = errBodyTooLarge
+	}
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		body.remove()
+		return spooledBody{}, "", err
+	}
+	return body, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// fingerprintBody hashes a multipart form by its parts rather than its
+// bytes, since clients pick a new boundary each time they encode it. Other
+// bodies are hashed as they are.
+func fingerprintBody(h hash.Hash, body io.Reader, contentType string) error {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") || params["boundary"] == "" {
+		_, err := io.Copy(h, body)
+		return err
+	}
+
+	reader := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			// A malformed form is left for the handler to reject. Whatever
+			// is left is hashed as is, so it is still compared.
+			_, err := io.Copy(h, body)
+			return err
+		}
+		fmt.Fprintf(h, "%q %q %q\n", part.FormName(), part.FileName(), part.Header.Get("Content-Type"))
+		n, err := io.Copy(h, part)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "\n%d\n", n)
+	}
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// responseRecorder keeps a copy of the response body as it is written.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,179 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryStore keeps records like the DynamoDB store, without expiry.
+type memoryStore struct {
+	mu      sync.Mutex
+	records map[string]db.IdempotencyRecord
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{records: make(map[string]db.IdempotencyRecord)}
+}
+
+func (s *memoryStore) ClaimIdempotencyKey(ctx context.Context, record db.IdempotencyRecord) (*db.IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := record.TenantID + "/" + record.Key
+	if holder, ok := s.records[id]; ok {
+		return &holder, nil
+	}
+	record.State = db.IdempotencyInProgress
+	s.records[id] = record
+	return nil, nil
+}
+
+func (s *memoryStore) CompleteIdempotencyKey(ctx context.Context, tenantId, key, token string, statusCode int, contentType string, body []byte, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record := s.records[tenantId+"/"+key]
+	record.State = db.IdempotencyCompleted
+	record.StatusCode = statusCode
+	record.ContentType = contentType
+	record.Body = append([]byte(nil), body...)
+	s.records[tenantId+"/"+key] = record
+	return nil
+}
+
+func (s *memoryStore) ReleaseIdempotencyKey(ctx context.Context, tenantId, key, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, tenantId+"/"+key)
+	return nil
+}
+
+// newRouter counts the requests that reach the handler, which answers with
+// the count and the body it read.
+func newRouter(store Store, status int) (*gin.Engine, *int) {
+	gin.SetMode(gin.TestMode)
+	calls := 0
+	router := gin.New()
+	router.POST("/videos", Middleware(store, Config{MaxBodyBytes: 1 << 20}), func(c *gin.Context) {
+		calls++
+		body, _ := c.GetRawData()
+		c.JSON(status, gin.H{"calls": calls, "body": string(body)})
+	})
+	return router, &calls
+}
+
+func post(router http.Handler, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/videos", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(Header, key)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestMiddlewareReplays(t *testing.T) {
+	router, calls := newRouter(newMemoryStore(), http.StatusCreated)
+
+	first := post(router, "key-1", `{"a":1}`)
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Contains(t, first.Body.String(), `"body":"{\"a\":1}"`)
+
+	replay := post(router, "key-1", `{"a":1}`)
+	assert.Equal(t, http.StatusCreated, replay.Code)
+	assert.Equal(t, first.Body.String(), replay.Body.String())
+	assert.Equal(t, "true", replay.Header().Get(ReplayedHeader))
+	assert.Equal(t, 1, *calls)
+
+	mismatch := post(router, "key-1", `{"a":2}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, mismatch.Code)
+
+	// Requests without a key, or with another key, always run.
+	post(router, "", `{"a":1}`)
+	post(router, "key-2", `{"a":1}`)
+	assert.Equal(t, 3, *calls)
+
+	assert.Equal(t, http.StatusBadRequest, post(router, "bad key", `{}`).Code)
+}
+
+func TestMiddlewareReleasesServerErrors(t *testing.T) {
+	router, calls := newRouter(newMemoryStore(), http.StatusInternalServerError)
+
+	post(router, "key-1", `{}`)
+	post(router, "key-1", `{}`)
+	assert.Equal(t, 2, *calls)
+}
+
+func TestMiddlewareRefusesConcurrentDuplicates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	started, release := make(chan struct{}), make(chan struct{})
+	router := gin.New()
+	router.POST("/videos", Middleware(newMemoryStore(), Config{}), func(c *gin.Context) {
+		close(started)
+		<-release
+		c.JSON(http.StatusCreated, gin.H{})
+	})
+
+	done := make(chan int)
+	go func() { done <- post(router, "key-1", `{}`).Code }()
+	<-started
+
+	duplicate := post(router, "key-1", `{}`)
+	assert.Equal(t, http.StatusConflict, duplicate.Code)
+	assert.NotEmpty(t, duplicate.Header().Get("Retry-After"))
+
+	close(release)
+	assert.Equal(t, http.StatusCreated, <-done)
+}
+
+func TestMiddlewareLimitsBody(t *testing.T) {
+	router, calls := newRouter(newMemoryStore(), http.StatusCreated)
+
+	rr := post(router, "key-1", strings.Repeat("a", 1<<20+1))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	assert.Equal(t, 0, *calls)
+}
+
+func TestFingerprintIgnoresMultipartBoundary(t *testing.T) {
+	encode := func(boundary, content string) (*http.Request, string) {
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		w.SetBoundary(boundary)
+		part, _ := w.CreateFormFile("file", "dog.mp4")
+		part.Write([]byte(content))
+		w.Close()
+		req := httptest.NewRequest("POST", "/videos", &buf)
+		req.Header.Set("Content-Type", w.FormDataContentType())
+		return req, buf.String()
+	}
+
+	first, raw := encode("boundary-one", "video bytes")
+	body, a, err := spool(first, 1<<20)
+	assert.NoError(t, err)
+	spooled := new(strings.Builder)
+	_, err = body.WriteTo(spooled)
+	body.remove()
+	assert.NoError(t, err)
+	// The handler still reads the original body.
+	assert.Equal(t, raw, spooled.String())
+
+	second, _ := encode("boundary-two", "video bytes")
+	body, b, _ := spool(second, 1<<20)
+	body.remove()
+	assert.Equal(t, a, b)
+
+	third, _ := encode("boundary-one", "other bytes")
+	body, c, _ := spool(third, 1<<20)
+	body.remove()
+	assert.NotEqual(t, a, c)
+}
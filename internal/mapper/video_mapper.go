@@ -1,20 +1,370 @@
 package mapper
 
 import (
+	"context"
+	"log"
+	"math"
 	"time"
 
 	db "github.com/ryanschneiderman/video-api/internal/db"
 	models "github.com/ryanschneiderman/video-api/internal/models/dto"
+	"github.com/ryanschneiderman/video-api/internal/playback"
+	"github.com/ryanschneiderman/video-api/internal/search"
+	"github.com/ryanschneiderman/video-api/internal/semantic"
 )
 
-func ToVideoResponse(video *db.Video) *models.VideoResponse {
-	return &models.VideoResponse{
+// ToVideoResponse links the video's source and renditions with URLs from
+// signer, since the objects themselves are private.
+func ToVideoResponse(ctx context.Context, video *db.Video, signer playback.Signer) *models.VideoResponse {
+	urls := urlSigner{ctx: ctx, video: video, signer: signer}
+	response := &models.VideoResponse{
 		VideoID:     video.VideoID,
 		Title:       video.Title,
 		Description: video.Description,
 		Tags:        video.Tags,
-		URL:         video.URL,
-		Metadata:    video.Metadata,
+		SourceURL:   video.SourceURL,
+		Metadata:    toMetadata(video),
 		UploadDate:  video.UploadDate.Format(time.RFC3339),
+		Status:      video.Status,
+		AliasOf:     video.AliasOf,
+		Analysis:    ToAnalysisResponse(video.Analysis),
+		Profile:     video.Profile,
+		Version:     video.Version,
 	}
+	// A reason left over from an earlier failed run is not shown once the
+	// video has been reprocessed.
+	if video.Status == db.StatusFailed {
+		response.Error = video.StatusReason
+	}
+	// A video awaiting upload has a key but no file yet.
+	if key, ok := playback.SourceKey(video); ok && video.Status != db.StatusAwaitingUpload {
+		response.URL = urls.sign(key)
+	}
+	if len(video.Renditions) > 0 {
+		response.Renditions = make(map[string]string, len(video.Renditions))
+		for name, key := range video.Renditions {
+			if u := urls.sign(key); u != "" {
+				response.Renditions[name] = u
+			}
+		}
+	}
+	response.URLExpiresAt = urls.expiresAt()
+	return response
+}
+
+// urlSigner signs the URLs of one response. A URL that can't be signed is
+// left out rather than failing the whole response.
+type urlSigner struct {
+	ctx     context.Context
+	video   *db.Video
+	signer  playback.Signer
+	expires time.Time
+}
+
+func (u *urlSigner) sign(key string) string {
+	signed, expires, err := u.signer.SignURL(u.ctx, u.video, key)
+	if err != nil {
+		log.Printf("Failed to sign URL for videoID: %s, error: %v", u.video.VideoID, err)
+		return ""
+	}
+	if u.expires.IsZero() || expires.Before(u.expires) {
+		u.expires = expires
+	}
+	return signed
+}
+
+// expiresAt is when the first of the signed URLs expires.
+func (u *urlSigner) expiresAt() string {
+	if u.expires.IsZero() {
+		return ""
+	}
+	return u.expires.UTC().Format(time.RFC3339)
+}
+
+// toMetadata merges the measurements the worker stores as typed attributes
+// into the free-form metadata object.
+func toMetadata(video *db.Video) map[string]interface{} {
+	if video.Loudness == nil {
+		return video.Metadata
+	}
+
+	metadata := make(map[string]interface{}, len(video.Metadata)+1)
+	for k, v := range video.Metadata {
+		metadata[k] = v
+	}
+	metadata["loudness"] = models.LoudnessResponse{
+		TargetLUFS:     video.Loudness.TargetLUFS,
+		IntegratedLUFS: video.Loudness.IntegratedLUFS,
+		TruePeak:       video.Loudness.TruePeak,
+		LoudnessRange:  video.Loudness.LoudnessRange,
+		Threshold:      video.Loudness.Threshold,
+		TargetOffset:   video.Loudness.TargetOffset,
+	}
+	return metadata
+}
+
+func ToAnalysisResponse(analysis *db.Analysis) *models.AnalysisResponse {
+	if analysis == nil {
+		return nil
+	}
+
+	response := &models.AnalysisResponse{
+		Analyzer:     analysis.Analyzer,
+		ModelVersion: analysis.ModelVersion,
+		Summary:      analysis.Summary,
+		Labels:       make([]models.LabelResponse, 0, len(analysis.Labels)),
+		SegmentCount: analysis.SegmentCount,
+		AnalyzedAt:   analysis.AnalyzedAt.Format(time.RFC3339),
+	}
+	for _, l := range analysis.Labels {
+		response.Labels = append(response.Labels, models.LabelResponse{Name: l.Name, Confidence: l.Confidence})
+	}
+	return response
+}
+
+// ToSegmentsResponse links scene keyframes with URLs from signer.
+func ToSegmentsResponse(ctx context.Context, video *db.Video, segments []db.AnalysisSegment, signer playback.Signer) *models.SegmentsResponse {
+	urls := urlSigner{ctx: ctx, video: video, signer: signer}
+	response := &models.SegmentsResponse{
+		VideoID:  video.VideoID,
+		Segments: make([]models.SegmentResponse, 0, len(segments)),
+	}
+	for _, seg := range segments {
+		segment := models.SegmentResponse{
+			Type:        seg.Type,
+			Start:       seg.Start,
+			End:         seg.End,
+			Label:       seg.Label,
+			Text:        seg.Text,
+			Confidence:  seg.Confidence,
+			KeyframeKey: seg.KeyframeKey,
+		}
+		if seg.KeyframeKey != "" {
+			segment.KeyframeURL = urls.sign(seg.KeyframeKey)
+		}
+		response.Segments = append(response.Segments, segment)
+	}
+	response.URLExpiresAt = urls.expiresAt()
+	return response
+}
+
+// ToVideoVersionsResponse marks the version current is at. Renditions are
+// storage keys rather than URLs, since older versions aren't served.
+func ToVideoVersionsResponse(videoID string, current int, versions []db.VideoVersion) *models.VideoVersionsResponse {
+	response := &models.VideoVersionsResponse{
+		VideoID:        videoID,
+		CurrentVersion: current,
+		Versions:       make([]models.VideoVersionResponse, 0, len(versions)),
+	}
+	for _, v := range versions {
+		response.Versions = append(response.Versions, models.VideoVersionResponse{
+			Version:     v.Version,
+			Current:     v.Version == current,
+			State:       v.State,
+			Profile:     v.Profile,
+			Analyzers:   v.Analyzers,
+			Prefixes:    v.Prefixes,
+			Renditions:  v.Renditions,
+			StartedAt:   v.StartedAt.Format(time.RFC3339),
+			CompletedAt: formatTime(v.CompletedAt),
+			PromotedAt:  formatTime(v.PromotedAt),
+			ExpiredAt:   formatTime(v.ExpiredAt),
+		})
+	}
+	return response
+}
+
+// formatTime leaves out times that haven't happened.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// ToSimilarVideosResponse scores each match by the share of frames that
+// matched.
+func ToSimilarVideosResponse(videoID string, similar []db.SimilarVideo) *models.SimilarVideosResponse {
+	response := &models.SimilarVideosResponse{
+		VideoID: videoID,
+		Similar: make([]models.SimilarVideoResponse, 0, len(similar)),
+	}
+	for _, s := range similar {
+		var score float64
+		if s.Frames > 0 {
+			score = float64(s.MatchedFrames) / float64(s.Frames)
+		}
+		response.Similar = append(response.Similar, models.SimilarVideoResponse{
+			VideoID:       s.VideoID,
+			Score:         score,
+			MatchedFrames: s.MatchedFrames,
+			MinDistance:   s.MinDistance,
+		})
+	}
+	return response
+}
+
+func ToSearchResponse(query string, results *search.Results) *models.SearchResponse {
+	response := &models.SearchResponse{
+		Query:   query,
+		Total:   results.Total,
+		Results: make([]models.SearchHitResponse, 0, len(results.Hits)),
+		Facets:  models.FacetsResponse{Tags: make([]models.FacetResponse, 0, len(results.TagFacets))},
+	}
+	for _, hit := range results.Hits {
+		h := models.SearchHitResponse{
+			VideoID:    hit.VideoID,
+			Title:      hit.Title,
+			Score:      hit.Score,
+			Highlights: make([]models.HighlightResponse, 0, len(hit.Highlights)),
+		}
+		for _, hl := range hit.Highlights {
+			h.Highlights = append(h.Highlights, models.HighlightResponse{Field: hl.Field, Snippet: hl.Snippet})
+		}
+		response.Results = append(response.Results, h)
+	}
+	for _, f := range results.TagFacets {
+		response.Facets.Tags = append(response.Facets.Tags, models.FacetResponse{Value: f.Value, Count: f.Count})
+	}
+	return response
+}
+
+// ToSemanticSearchResponse lists the matches found in videos, in order, and
+// drops the rest.
+func ToSemanticSearchResponse(query string, matches []semantic.Match, videos map[string]*db.Video) *models.SemanticSearchResponse {
+	response := &models.SemanticSearchResponse{
+		Query:   query,
+		Results: make([]models.SemanticMatchResponse, 0, len(matches)),
+	}
+	for _, m := range matches {
+		video, ok := videos[m.VideoID]
+		if !ok {
+			continue
+		}
+		match := models.SemanticMatchResponse{
+			VideoID: m.VideoID,
+			Title:   video.Title,
+			Score:   m.Score,
+			Ranges:  make([]models.TimeRangeResponse, 0, len(m.Ranges)),
+		}
+		for _, r := range m.Ranges {
+			match.Ranges = append(match.Ranges, models.TimeRangeResponse{Start: r.Start, End: r.End, Score: r.Score})
+		}
+		response.Results = append(response.Results, match)
+	}
+	return response
+}
+
+// ToUsageResponse reports usage against the given quotas, where zero means
+// unlimited.
+func ToUsageResponse(usage *db.Usage, storageQuotaBytes, processingQuotaMinutes int64) *models.UsageResponse {
+	response := &models.UsageResponse{
+		TenantID: usage.TenantID,
+		Period:   usage.Period,
+		Storage: models.StorageUsageResponse{
+			UsedBytes: usage.StorageBytes,
+			Videos:    usage.Videos,
+		},
+		Processing: models.ProcessingUsageResponse{
+			UsedMinutes: math.Round(usage.ProcessingSeconds/60*100) / 100,
+			Videos:      usage.ProcessedVideos,
+		},
+	}
+	if storageQuotaBytes > 0 {
+		response.Storage.QuotaBytes = &storageQuotaBytes
+	}
+	if processingQuotaMinutes > 0 {
+		response.Processing.QuotaMinutes = &processingQuotaMinutes
+	}
+	return response
+}
+
+// BatchItem is the outcome of one item of a batch request. Video is set to
+// return the whole video, and Tags to return only its tags.
+type BatchItem struct {
+	Index            int
+	VideoID          string
+	Status           int
+	Err              string
+	Video            *db.Video
+	VideoStatus      string
+	Tags             []string
+	UploadURL        string
+	UploadURLExpires time.Time
+}
+
+// ToBatchResponse counts the items with a 2xx status as succeeded.
+func ToBatchResponse(ctx context.Context, items []BatchItem, signer playback.Signer) *models.BatchResponse {
+	response := &models.BatchResponse{
+		Items: make([]models.BatchItemResponse, 0, len(items)),
+	}
+	for _, item := range items {
+		result := models.BatchItemResponse{
+			Index:       item.Index,
+			VideoID:     item.VideoID,
+			Status:      item.Status,
+			Error:       item.Err,
+			VideoStatus: item.VideoStatus,
+			Tags:        item.Tags,
+			UploadURL:   item.UploadURL,
+		}
+		if item.Video != nil {
+			result.Video = ToVideoResponse(ctx, item.Video, signer)
+		}
+		if !item.UploadURLExpires.IsZero() {
+			result.UploadURLExpiresAt = item.UploadURLExpires.UTC().Format(time.RFC3339)
+		}
+		if item.Status >= 200 && item.Status < 300 {
+			response.Succeeded++
+		} else {
+			response.Failed++
+		}
+		response.Items = append(response.Items, result)
+	}
+	return response
+}
+
+func ToWebhookResponse(hook *db.Webhook) *models.WebhookResponse {
+	return &models.WebhookResponse{
+		WebhookID: hook.WebhookID,
+		URL:       hook.URL,
+		Events:    hook.Events,
+		CreatedAt: hook.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func ToWebhooksResponse(hooks []db.Webhook) *models.WebhooksResponse {
+	response := &models.WebhooksResponse{
+		Webhooks: make([]models.WebhookResponse, 0, len(hooks)),
+	}
+	for i := range hooks {
+		response.Webhooks = append(response.Webhooks, *ToWebhookResponse(&hooks[i]))
+	}
+	return response
+}
+
+func ToWebhookDeliveryResponse(delivery *db.WebhookDelivery) *models.WebhookDeliveryResponse {
+	return &models.WebhookDeliveryResponse{
+		DeliveryID:     delivery.DeliveryID,
+		EventID:        delivery.EventID,
+		EventType:      delivery.EventType,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		ResponseStatus: delivery.ResponseStatus,
+		LastError:      delivery.LastError,
+		ReplayOf:       delivery.ReplayOf,
+		CreatedAt:      delivery.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:      delivery.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+func ToWebhookDeliveriesResponse(deliveries []db.WebhookDelivery, next string) *models.WebhookDeliveriesResponse {
+	response := &models.WebhookDeliveriesResponse{
+		Deliveries: make([]models.WebhookDeliveryResponse, 0, len(deliveries)),
+		NextCursor: next,
+	}
+	for i := range deliveries {
+		response.Deliveries = append(response.Deliveries, *ToWebhookDeliveryResponse(&deliveries[i]))
+	}
+	return response
 }
@@ -0,0 +1,38 @@
+// Package media lists the video containers the service accepts, whether
+// they arrive as uploads or are imported from a URL.
+package media
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+var ErrUnsupportedType = errors.New("unsupported media type")
+
+// VideoTypes are the containers ffmpeg can transcode that we accept.
+var VideoTypes = []string{
+	"video/mp4",
+	"video/quicktime",
+	"video/x-matroska",
+	"video/webm",
+	"video/x-msvideo",
+	"video/mpeg",
+	"video/3gpp",
+	"video/3gpp2",
+	"video/x-m4v",
+	"video/x-flv",
+	"video/ogg",
+}
+
+// VideoType returns the accepted container that mtype, detected from a
+// file's magic bytes, is or descends from.
+func VideoType(mtype *mimetype.MIME) (string, error) {
+	for _, allowed := range VideoTypes {
+		if mtype.Is(allowed) {
+			return allowed, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnsupportedType, mtype.String())
+}
@@ -1,11 +1,227 @@
 package models
 
 type VideoResponse struct {
-	VideoID     string                 `json:"videoId"`
-	Title       string                 `json:"title"`
-	Description string                 `json:"description,omitempty"`
-	Tags        []string               `json:"tags,omitempty"`
-	URL         string                 `json:"url"`
-	Metadata    map[string]interface{} `json:"metadata,omitempty"`
-	UploadDate  string            `json:"uploadDate,omitempty"`
-}
\ No newline at end of file
+	VideoID     string   `json:"videoId"`
+	Title       string   `json:"title"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	URL         string   `json:"url"`
+	// SourceURL is where an imported video was fetched from.
+	SourceURL string `json:"sourceUrl,omitempty"`
+	// URLExpiresAt is when the signed URLs in the response stop working.
+	URLExpiresAt string            `json:"urlExpiresAt,omitempty"`
+	Renditions   map[string]string `json:"renditions,omitempty"`
+	// Profile is the transcoding profile of the renditions.
+	Profile string `json:"profile,omitempty"`
+	// Version is the processing run the renditions come from.
+	Version    int                    `json:"version,omitempty"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	UploadDate string                 `json:"uploadDate,omitempty"`
+	Status     string                 `json:"status,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	AliasOf    string                 `json:"aliasOf,omitempty"`
+	Analysis   *AnalysisResponse      `json:"analysis,omitempty"`
+}
+
+type LoudnessResponse struct {
+	TargetLUFS     float64 `json:"targetLufs"`
+	IntegratedLUFS float64 `json:"integratedLufs"`
+	TruePeak       float64 `json:"truePeak"`
+	LoudnessRange  float64 `json:"loudnessRange"`
+	Threshold      float64 `json:"threshold"`
+	TargetOffset   float64 `json:"targetOffset"`
+}
+
+type AnalysisResponse struct {
+	Analyzer     string          `json:"analyzer"`
+	ModelVersion string          `json:"modelVersion,omitempty"`
+	Summary      string          `json:"summary"`
+	Labels       []LabelResponse `json:"labels"`
+	SegmentCount int             `json:"segmentCount"`
+	AnalyzedAt   string          `json:"analyzedAt"`
+}
+
+type LabelResponse struct {
+	Name       string  `json:"name"`
+	Confidence float64 `json:"confidence"`
+}
+
+type SegmentsResponse struct {
+	VideoID      string            `json:"videoId"`
+	Segments     []SegmentResponse `json:"segments"`
+	URLExpiresAt string            `json:"urlExpiresAt,omitempty"`
+}
+
+type SegmentResponse struct {
+	Type        string  `json:"type"`
+	Start       float64 `json:"start"`
+	End         float64 `json:"end"`
+	Label       string  `json:"label,omitempty"`
+	Text        string  `json:"text,omitempty"`
+	Confidence  float64 `json:"confidence,omitempty"`
+	KeyframeKey string  `json:"keyframeKey,omitempty"`
+	KeyframeURL string  `json:"keyframeUrl,omitempty"`
+}
+
+// VideoVersionsResponse lists the processing runs of a video, newest first.
+// CurrentVersion is the one the video serves, or 0 for a video processed
+// before versions were kept.
+type VideoVersionsResponse struct {
+	VideoID        string                 `json:"videoId"`
+	CurrentVersion int                    `json:"currentVersion"`
+	Versions       []VideoVersionResponse `json:"versions"`
+}
+
+// VideoVersionResponse describes one run. Analyzers names the model behind
+// each analysis stage, and Prefixes are the storage prefixes of the
+// version's files, which include earlier runs' for stages it didn't redo.
+type VideoVersionResponse struct {
+	Version     int               `json:"version"`
+	Current     bool              `json:"current"`
+	State       string            `json:"state"`
+	Profile     string            `json:"profile"`
+	Analyzers   map[string]string `json:"analyzers,omitempty"`
+	Prefixes    []string          `json:"prefixes,omitempty"`
+	Renditions  map[string]string `json:"renditions,omitempty"`
+	StartedAt   string            `json:"startedAt"`
+	CompletedAt string            `json:"completedAt,omitempty"`
+	PromotedAt  string            `json:"promotedAt,omitempty"`
+	ExpiredAt   string            `json:"expiredAt,omitempty"`
+}
+
+type SimilarVideosResponse struct {
+	VideoID string                 `json:"videoId"`
+	Similar []SimilarVideoResponse `json:"similar"`
+}
+
+type SimilarVideoResponse struct {
+	VideoID       string  `json:"videoId"`
+	Score         float64 `json:"score"`
+	MatchedFrames int     `json:"matchedFrames"`
+	MinDistance   int     `json:"minDistance"`
+}
+
+type SearchResponse struct {
+	Query   string              `json:"query"`
+	Total   int                 `json:"total"`
+	Results []SearchHitResponse `json:"results"`
+	Facets  FacetsResponse      `json:"facets"`
+}
+
+type SearchHitResponse struct {
+	VideoID    string              `json:"videoId"`
+	Title      string              `json:"title"`
+	Score      float64             `json:"score"`
+	Highlights []HighlightResponse `json:"highlights"`
+}
+
+type SemanticSearchResponse struct {
+	Query   string                  `json:"query"`
+	Results []SemanticMatchResponse `json:"results"`
+}
+
+// SemanticMatchResponse is a video with scenes matching the query. Ranges
+// are the matching spans in seconds, best first.
+type SemanticMatchResponse struct {
+	VideoID string              `json:"videoId"`
+	Title   string              `json:"title"`
+	Score   float64             `json:"score"`
+	Ranges  []TimeRangeResponse `json:"ranges"`
+}
+
+type TimeRangeResponse struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Score float64 `json:"score"`
+}
+
+type HighlightResponse struct {
+	Field   string `json:"field"`
+	Snippet string `json:"snippet"`
+}
+
+type FacetsResponse struct {
+	Tags []FacetResponse `json:"tags"`
+}
+
+type FacetResponse struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// UsageResponse reports a tenant's usage against its quotas. A null quota
+// means unlimited.
+type UsageResponse struct {
+	TenantID   string                  `json:"tenantId"`
+	Period     string                  `json:"period"`
+	Storage    StorageUsageResponse    `json:"storage"`
+	Processing ProcessingUsageResponse `json:"processing"`
+}
+
+type StorageUsageResponse struct {
+	UsedBytes  int64  `json:"usedBytes"`
+	QuotaBytes *int64 `json:"quotaBytes"`
+	Videos     int64  `json:"videos"`
+}
+
+type ProcessingUsageResponse struct {
+	UsedMinutes  float64 `json:"usedMinutes"`
+	QuotaMinutes *int64  `json:"quotaMinutes"`
+	Videos       int64   `json:"videos"`
+}
+
+// WebhookResponse describes a registered webhook. Secret is only set when
+// the webhook is created.
+type WebhookResponse struct {
+	WebhookID string   `json:"webhookId"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	Secret    string   `json:"secret,omitempty"`
+	CreatedAt string   `json:"createdAt"`
+}
+
+type WebhooksResponse struct {
+	Webhooks []WebhookResponse `json:"webhooks"`
+}
+
+type WebhookDeliveryResponse struct {
+	DeliveryID     string `json:"deliveryId"`
+	EventID        string `json:"eventId"`
+	EventType      string `json:"eventType"`
+	Status         string `json:"status"`
+	Attempts       int    `json:"attempts"`
+	ResponseStatus int    `json:"responseStatus,omitempty"`
+	LastError      string `json:"lastError,omitempty"`
+	ReplayOf       string `json:"replayOf,omitempty"`
+	CreatedAt      string `json:"createdAt"`
+	UpdatedAt      string `json:"updatedAt"`
+}
+
+// WebhookDeliveriesResponse is a page of a delivery log. NextCursor is
+// empty on the last page.
+type WebhookDeliveriesResponse struct {
+	Deliveries []WebhookDeliveryResponse `json:"deliveries"`
+	NextCursor string                    `json:"nextCursor,omitempty"`
+}
+
+// BatchResponse reports each item of a batch request on its own. Status is
+// the HTTP status the item would have had as a single request.
+type BatchResponse struct {
+	Items     []BatchItemResponse `json:"items"`
+	Succeeded int                 `json:"succeeded"`
+	Failed    int                 `json:"failed"`
+}
+
+type BatchItemResponse struct {
+	Index   int            `json:"index"`
+	VideoID string         `json:"videoId,omitempty"`
+	Status  int            `json:"status"`
+	Error   string         `json:"error,omitempty"`
+	Video   *VideoResponse `json:"video,omitempty"`
+	// VideoStatus is the video's processing state after the operation.
+	VideoStatus string   `json:"videoStatus,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	// UploadURL accepts a PUT of the file until UploadURLExpiresAt.
+	UploadURL          string `json:"uploadUrl,omitempty"`
+	UploadURLExpiresAt string `json:"uploadUrlExpiresAt,omitempty"`
+}
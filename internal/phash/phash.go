@@ -0,0 +1,78 @@
+// Package phash computes 64-bit perceptual hashes of video frames. Frames
+// that look alike hash to values with a small Hamming distance, which
+// survives re-encoding, scaling and mild color changes.
+package phash
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// Size is the width and height of the grayscale frames Hash expects.
+const Size = 32
+
+// lowFrequencies is the side of the top-left block of DCT coefficients that
+// make up the hash.
+const lowFrequencies = 8
+
+var dctCos = func() [Size][Size]float64 {
+	var table [Size][Size]float64
+	for u := 0; u < Size; u++ {
+		for x := 0; x < Size; x++ {
+			table[u][x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / (2 * Size))
+		}
+	}
+	return table
+}()
+
+// Hash returns the DCT hash of a Size x Size 8-bit grayscale frame. Each bit
+// records whether one of the 64 lowest-frequency coefficients is above the
+// median of the others.
+func Hash(gray []byte) (uint64, error) {
+	if len(gray) != Size*Size {
+		return 0, fmt.Errorf("expected %d pixels, got %d", Size*Size, len(gray))
+	}
+
+	// The 2D DCT is separable: transform the rows, then the columns of the
+	// low frequencies we keep.
+	var rows [Size][lowFrequencies]float64
+	for y := 0; y < Size; y++ {
+		for u := 0; u < lowFrequencies; u++ {
+			var sum float64
+			for x := 0; x < Size; x++ {
+				sum += float64(gray[y*Size+x]) * dctCos[u][x]
+			}
+			rows[y][u] = sum
+		}
+	}
+	coeffs := make([]float64, 0, lowFrequencies*lowFrequencies)
+	for v := 0; v < lowFrequencies; v++ {
+		for u := 0; u < lowFrequencies; u++ {
+			var sum float64
+			for y := 0; y < Size; y++ {
+				sum += rows[y][u] * dctCos[v][y]
+			}
+			coeffs = append(coeffs, sum)
+		}
+	}
+
+	// The DC term is the average brightness and would dominate the median.
+	sorted := append([]float64(nil), coeffs[1:]...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+
+	var hash uint64
+	for i, c := range coeffs {
+		if c > median {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash, nil
+}
+
+// Distance is the number of bits that differ between two hashes.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
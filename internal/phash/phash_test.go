@@ -0,0 +1,62 @@
+package phash
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// frame draws a test pattern: a bright disc on a horizontal gradient.
+func frame(cx, cy, radius int) []byte {
+	gray := make([]byte, Size*Size)
+	for y := 0; y < Size; y++ {
+		for x := 0; x < Size; x++ {
+			v := x * 4
+			if (x-cx)*(x-cx)+(y-cy)*(y-cy) < radius*radius {
+				v = 240
+			}
+			gray[y*Size+x] = byte(v)
+		}
+	}
+	return gray
+}
+
+func TestHashToleratesNoiseAndBrightness(t *testing.T) {
+	original := frame(10, 12, 6)
+	reencoded := make([]byte, len(original))
+	rng := rand.New(rand.NewSource(1))
+	for i, v := range original {
+		// Brighten slightly and add compression-like noise.
+		n := int(v) + 8 + rng.Intn(7) - 3
+		if n > 255 {
+			n = 255
+		}
+		reencoded[i] = byte(n)
+	}
+
+	a, err := Hash(original)
+	assert.NoError(t, err)
+	b, err := Hash(reencoded)
+	assert.NoError(t, err)
+
+	assert.LessOrEqual(t, Distance(a, b), 4)
+}
+
+func TestHashSeparatesDifferentFrames(t *testing.T) {
+	a, _ := Hash(frame(8, 8, 6))
+	b, _ := Hash(frame(24, 22, 9))
+
+	assert.Greater(t, Distance(a, b), 12)
+}
+
+func TestHashRejectsWrongSize(t *testing.T) {
+	_, err := Hash(make([]byte, 10))
+	assert.Error(t, err)
+}
+
+func TestDistance(t *testing.T) {
+	assert.Equal(t, 0, Distance(0xff, 0xff))
+	assert.Equal(t, 64, Distance(0, ^uint64(0)))
+	assert.Equal(t, 2, Distance(0b1010, 0b0000))
+}
@@ -0,0 +1,494 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	ErrDuplicateStage  = errors.New("duplicate stage")
+	ErrDuplicateOutput = errors.New("artifact produced by more than one stage")
+	ErrMissingInput    = errors.New("missing input artifact")
+	ErrMissingOutput   = errors.New("stage did not produce declared output")
+	ErrCycle           = errors.New("pipeline contains a cycle")
+	ErrUnknownStage    = errors.New("unknown stage")
+)
+
+// Stage is a single unit of work in a pipeline. Inputs and Outputs name the
+// artifacts a stage consumes and produces; the engine uses them to order
+// stages and to run independent branches in parallel.
+type Stage interface {
+	Name() string
+	Inputs() []string
+	Outputs() []string
+	Run(ctx context.Context, job *Job) error
+}
+
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks a stage error as one that retrying cannot fix, such as an
+// input that is not a video. The engine gives up on the stage immediately.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+func IsPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
+
+// Resumable is implemented by stages whose outputs survive the process, such
+// as objects written to S3. After a successful run their outputs are recorded
+// through the pipeline's Checkpointer, and on a later run the stage is skipped
+// as long as Validate accepts the recorded outputs.
+type Resumable interface {
+	Validate(ctx context.Context, outputs map[string]string) error
+}
+
+// Restorable is implemented by resumable stages that save results outside
+// their outputs, such as database records. When a job has Restore set, a
+// stage skipped for its checkpoint saves them again from its outputs.
+type Restorable interface {
+	Restore(ctx context.Context, job *Job) error
+}
+
+type Checkpoint struct {
+	Stage       string
+	Artifacts   map[string]string
+	CompletedAt time.Time
+}
+
+type Checkpointer interface {
+	LoadCheckpoints(ctx context.Context, jobID string) (map[string]Checkpoint, error)
+	SaveCheckpoint(ctx context.Context, jobID string, checkpoint Checkpoint) error
+}
+
+// Job carries the artifacts passed between the stages of a single run. Dir is
+// an optional scratch directory stages may use for local files, and Tenant
+// names the owner whose namespace stages write their outputs to. Version
+// numbers the run, for stages that keep each run's outputs apart. Restore
+// makes skipped stages restore their results, see Restorable.
+type Job struct {
+	ID      string
+	Dir     string
+	Tenant  string
+	Version int
+	Restore bool
+
+	mu        sync.RWMutex
+	artifacts map[string]string
+}
+
+func NewJob(id string, artifacts map[string]string) *Job {
+	job := &Job{
+		ID:        id,
+		artifacts: make(map[string]string, len(artifacts)),
+	}
+	for name, value := range artifacts {
+		job.artifacts[name] = value
+	}
+	return job
+}
+
+func (j *Job) Artifact(name string) (string, error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	value, ok := j.artifacts[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrMissingInput, name)
+	}
+	return value, nil
+}
+
+func (j *Job) SetArtifact(name, value string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.artifacts[name] = value
+}
+
+func (j *Job) hasArtifact(name string) bool {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	_, ok := j.artifacts[name]
+	return ok
+}
+
+type registeredStage struct {
+	stage Stage
+	retry RetryPolicy
+}
+
+type Pipeline struct {
+	Checkpointer Checkpointer
+
+	stages []registeredStage
+}
+
+func New() *Pipeline {
+	return &Pipeline{}
+}
+
+func (p *Pipeline) Register(stage Stage, retry RetryPolicy) error {
+	for _, rs := range p.stages {
+		if rs.stage.Name() == stage.Name() {
+			return fmt.Errorf("%w: %s", ErrDuplicateStage, stage.Name())
+		}
+	}
+	if retry.MaxAttempts < 1 {
+		retry.MaxAttempts = 1
+	}
+	p.stages = append(p.stages, registeredStage{stage: stage, retry: retry})
+	return nil
+}
+
+// MustRegister is like Register but panics if the stage cannot be added.
+func (p *Pipeline) MustRegister(stage Stage, retry RetryPolicy) {
+	if err := p.Register(stage, retry); err != nil {
+		panic(err)
+	}
+}
+
+func (p *Pipeline) Stages() []string {
+	names := make([]string, len(p.stages))
+	for i, rs := range p.stages {
+		names[i] = rs.stage.Name()
+	}
+	return names
+}
+
+// Downstream returns the named stages and every stage that consumes their
+// outputs, directly or through other stages, in registration order. These
+// are the stages whose results are stale once the named ones run again.
+func (p *Pipeline) Downstream(names ...string) ([]string, error) {
+	index := make(map[string]int, len(p.stages))
+	for i, rs := range p.stages {
+		index[rs.stage.Name()] = i
+	}
+
+	selected := make([]bool, len(p.stages))
+	stale := make(map[string]bool)
+	for _, name := range names {
+		i, ok := index[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownStage, name)
+		}
+		selected[i] = true
+	}
+
+	// Stages are selected until a pass adds none, which takes at most one
+	// pass per stage.
+	for changed := true; changed; {
+		changed = false
+		for i, rs := range p.stages {
+			if !selected[i] {
+				continue
+			}
+			for _, out := range rs.stage.Outputs() {
+				stale[out] = true
+			}
+		}
+		for i, rs := range p.stages {
+			if selected[i] {
+				continue
+			}
+			for _, in := range rs.stage.Inputs() {
+				if stale[in] {
+					selected[i] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	var downstream []string
+	for i, rs := range p.stages {
+		if selected[i] {
+			downstream = append(downstream, rs.stage.Name())
+		}
+	}
+	return downstream, nil
+}
+
+// plan resolves the dependency graph. deps[i] holds the indexes of the stages
+// that stage i waits on.
+func (p *Pipeline) plan(job *Job) ([][]int, error) {
+	producers := make(map[string]int)
+	for i, rs := range p.stages {
+		for _, out := range rs.stage.Outputs() {
+			if other, ok := producers[out]; ok {
+				return nil, fmt.Errorf("%w: %s (%s, %s)", ErrDuplicateOutput, out, p.stages[other].stage.Name(), rs.stage.Name())
+			}
+			producers[out] = i
+		}
+	}
+
+	deps := make([][]int, len(p.stages))
+	dependents := make([][]int, len(p.stages))
+	indegree := make([]int, len(p.stages))
+	for i, rs := range p.stages {
+		seen := make(map[int]bool)
+		for _, in := range rs.stage.Inputs() {
+			producer, ok := producers[in]
+			if !ok {
+				if !job.hasArtifact(in) {
+					return nil, fmt.Errorf("%w: %s (required by %s)", ErrMissingInput, in, rs.stage.Name())
+				}
+				continue
+			}
+			if seen[producer] {
+				continue
+			}
+			seen[producer] = true
+			deps[i] = append(deps[i], producer)
+			dependents[producer] = append(dependents[producer], i)
+			indegree[i]++
+		}
+	}
+
+	visited := 0
+	var ready []int
+	for i := range p.stages {
+		if indegree[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	for len(ready) > 0 {
+		next := ready[0]
+		ready = ready[1:]
+		visited++
+		for _, d := range dependents[next] {
+			indegree[d]--
+			if indegree[d] == 0 {
+				ready = append(ready, d)
+			}
+		}
+	}
+	if visited != len(p.stages) {
+		return nil, ErrCycle
+	}
+
+	return deps, nil
+}
+
+// Validate checks that the registered stages form a runnable graph given the
+// initial artifacts of job.
+func (p *Pipeline) Validate(job *Job) error {
+	_, err := p.plan(job)
+	return err
+}
+
+// Run executes every registered stage, starting each one as soon as the
+// stages producing its inputs have finished. The first stage failure cancels
+// the remaining work and is returned once in-flight stages have stopped.
+func (p *Pipeline) Run(ctx context.Context, job *Job) error {
+	deps, err := p.plan(job)
+	if err != nil {
+		return err
+	}
+
+	checkpoints := map[string]Checkpoint{}
+	if p.Checkpointer != nil {
+		checkpoints, err = p.Checkpointer.LoadCheckpoints(ctx, job.ID)
+		if err != nil {
+			return fmt.Errorf("failed to load checkpoints: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		index int
+		err   error
+	}
+
+	remaining := make([]int, len(p.stages))
+	dependents := make([][]int, len(p.stages))
+	for i, d := range deps {
+		remaining[i] = len(d)
+		for _, producer := range d {
+			dependents[producer] = append(dependents[producer], i)
+		}
+	}
+
+	results := make(chan result)
+	running := 0
+	start := func(i int) {
+		running++
+		go func() {
+			rs := p.stages[i]
+			if p.resume(ctx, rs.stage, job, checkpoints) {
+				results <- result{index: i, err: p.restore(ctx, rs.stage, job)}
+				return
+			}
+			results <- result{index: i, err: p.runStage(ctx, rs, job)}
+		}()
+	}
+
+	for i := range p.stages {
+		if remaining[i] == 0 {
+			start(i)
+		}
+	}
+
+	var firstErr error
+	for running > 0 {
+		res := <-results
+		running--
+
+		if res.err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("stage %s: %w", p.stages[res.index].stage.Name(), res.err)
+				cancel()
+			}
+			continue
+		}
+		if firstErr != nil {
+			continue
+		}
+
+		for _, d := range dependents[res.index] {
+			remaining[d]--
+			if remaining[d] == 0 {
+				start(d)
+			}
+		}
+	}
+
+	return firstErr
+}
+
+func (p *Pipeline) runStage(ctx context.Context, rs registeredStage, job *Job) error {
+	name := rs.stage.Name()
+	backoff := rs.retry.Backoff
+
+	var err error
+	for attempt := 1; attempt <= rs.retry.MaxAttempts; attempt++ {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		log.Printf("Running stage %s for job %s (attempt %d/%d)", name, job.ID, attempt, rs.retry.MaxAttempts)
+		start := time.Now()
+		err = rs.stage.Run(ctx, job)
+		if err == nil {
+			err = checkOutputs(rs.stage, job)
+		}
+		if err == nil {
+			log.Printf("Stage %s for job %s finished in %s", name, job.ID, time.Since(start))
+			p.checkpoint(ctx, rs.stage, job)
+			return nil
+		}
+		log.Printf("Stage %s for job %s failed: %v", name, job.ID, err)
+		if IsPermanent(err) {
+			return err
+		}
+
+		if attempt < rs.retry.MaxAttempts && backoff > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+	}
+	return err
+}
+
+// resume restores the outputs of a previously completed stage into job and
+// reports whether the stage can be skipped.
+func (p *Pipeline) resume(ctx context.Context, stage Stage, job *Job, checkpoints map[string]Checkpoint) bool {
+	resumable, ok := stage.(Resumable)
+	if !ok {
+		return false
+	}
+	checkpoint, ok := checkpoints[stage.Name()]
+	if !ok {
+		return false
+	}
+	for _, out := range stage.Outputs() {
+		if _, ok := checkpoint.Artifacts[out]; !ok {
+			return false
+		}
+	}
+	if err := resumable.Validate(ctx, checkpoint.Artifacts); err != nil {
+		log.Printf("Checkpoint for stage %s of job %s is no longer valid, rerunning: %v", stage.Name(), job.ID, err)
+		return false
+	}
+
+	for _, out := range stage.Outputs() {
+		job.SetArtifact(out, checkpoint.Artifacts[out])
+	}
+	log.Printf("Skipping stage %s for job %s, completed at %s", stage.Name(), job.ID, checkpoint.CompletedAt.Format(time.RFC3339))
+	return true
+}
+
+// restore saves the results of a skipped stage again from its outputs when
+// the job asks for it.
+func (p *Pipeline) restore(ctx context.Context, stage Stage, job *Job) error {
+	restorable, ok := stage.(Restorable)
+	if !job.Restore || !ok {
+		return nil
+	}
+	if err := restorable.Restore(ctx, job); err != nil {
+		log.Printf("Restoring stage %s for job %s failed: %v", stage.Name(), job.ID, err)
+		return err
+	}
+	log.Printf("Restored stage %s for job %s", stage.Name(), job.ID)
+	return nil
+}
+
+// checkpoint records the outputs of a resumable stage. A failure to save only
+// costs a rerun on redelivery, so it is logged rather than failing the stage.
+func (p *Pipeline) checkpoint(ctx context.Context, stage Stage, job *Job) {
+	if p.Checkpointer == nil {
+		return
+	}
+	if _, ok := stage.(Resumable); !ok {
+		return
+	}
+
+	artifacts := make(map[string]string, len(stage.Outputs()))
+	for _, out := range stage.Outputs() {
+		artifacts[out], _ = job.Artifact(out)
+	}
+	checkpoint := Checkpoint{
+		Stage:       stage.Name(),
+		Artifacts:   artifacts,
+		CompletedAt: time.Now().UTC(),
+	}
+	if err := p.Checkpointer.SaveCheckpoint(ctx, job.ID, checkpoint); err != nil {
+		log.Printf("Failed to save checkpoint for stage %s of job %s: %v", stage.Name(), job.ID, err)
+	}
+}
+
+func checkOutputs(stage Stage, job *Job) error {
+	for _, out := range stage.Outputs() {
+		if !job.hasArtifact(out) {
+			return fmt.Errorf("%w: %s", ErrMissingOutput, out)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,389 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeStage struct {
+	name    string
+	inputs  []string
+	outputs []string
+	run     func(ctx context.Context, job *Job) error
+}
+
+func (s *fakeStage) Name() string      { return s.name }
+func (s *fakeStage) Inputs() []string  { return s.inputs }
+func (s *fakeStage) Outputs() []string { return s.outputs }
+
+func (s *fakeStage) Run(ctx context.Context, job *Job) error {
+	if s.run != nil {
+		if err := s.run(ctx, job); err != nil {
+			return err
+		}
+	}
+	for _, out := range s.outputs {
+		job.SetArtifact(out, s.name+":"+out)
+	}
+	return nil
+}
+
+type recorder struct {
+	mu    sync.Mutex
+	order []string
+}
+
+func (r *recorder) stage(name string, inputs, outputs []string) *fakeStage {
+	return &fakeStage{
+		name:    name,
+		inputs:  inputs,
+		outputs: outputs,
+		run: func(ctx context.Context, job *Job) error {
+			r.mu.Lock()
+			r.order = append(r.order, name)
+			r.mu.Unlock()
+			return nil
+		},
+	}
+}
+
+func (r *recorder) index(name string) int {
+	for i, n := range r.order {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestRunOrdersStagesByArtifacts(t *testing.T) {
+	rec := &recorder{}
+	p := New()
+	// Registered out of order on purpose.
+	p.MustRegister(rec.stage("save", []string{"analysis", "thumbs"}, nil), NoRetry)
+	p.MustRegister(rec.stage("analyze", []string{"mp4"}, []string{"analysis"}), NoRetry)
+	p.MustRegister(rec.stage("thumbnail", []string{"mp4"}, []string{"thumbs"}), NoRetry)
+	p.MustRegister(rec.stage("transcode", []string{"source"}, []string{"mp4"}), NoRetry)
+
+	job := NewJob("job-1", map[string]string{"source": "in.mov"})
+	err := p.Run(context.Background(), job)
+
+	assert.NoError(t, err)
+	assert.Len(t, rec.order, 4)
+	assert.Equal(t, "transcode", rec.order[0])
+	assert.Equal(t, "save", rec.order[3])
+	assert.Less(t, rec.index("transcode"), rec.index("analyze"))
+	assert.Less(t, rec.index("transcode"), rec.index("thumbnail"))
+
+	analysis, err := job.Artifact("analysis")
+	assert.NoError(t, err)
+	assert.Equal(t, "analyze:analysis", analysis)
+}
+
+func TestRunExecutesBranchesInParallel(t *testing.T) {
+	var started sync.WaitGroup
+	started.Add(2)
+	release := make(chan struct{})
+
+	branch := func(name, out string) *fakeStage {
+		return &fakeStage{
+			name:    name,
+			inputs:  []string{"source"},
+			outputs: []string{out},
+			run: func(ctx context.Context, job *Job) error {
+				started.Done()
+				<-release
+				return nil
+			},
+		}
+	}
+
+	p := New()
+	p.MustRegister(branch("left", "a"), NoRetry)
+	p.MustRegister(branch("right", "b"), NoRetry)
+
+	done := make(chan error)
+	go func() {
+		done <- p.Run(context.Background(), NewJob("job-1", map[string]string{"source": "x"}))
+	}()
+
+	// Both branches must be running at the same time for this to return.
+	started.Wait()
+	close(release)
+	assert.NoError(t, <-done)
+}
+
+func TestRunRetriesFailedStage(t *testing.T) {
+	var attempts int32
+	p := New()
+	p.MustRegister(&fakeStage{
+		name:    "flaky",
+		outputs: []string{"out"},
+		run: func(ctx context.Context, job *Job) error {
+			if atomic.AddInt32(&attempts, 1) < 3 {
+				return errors.New("transient")
+			}
+			return nil
+		},
+	}, RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond})
+
+	err := p.Run(context.Background(), NewJob("job-1", nil))
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), attempts)
+}
+
+func TestRunStopsOnFailure(t *testing.T) {
+	var downstreamRan bool
+	p := New()
+	p.MustRegister(&fakeStage{
+		name:    "broken",
+		outputs: []string{"out"},
+		run: func(ctx context.Context, job *Job) error {
+			return errors.New("boom")
+		},
+	}, RetryPolicy{MaxAttempts: 2})
+	p.MustRegister(&fakeStage{
+		name:   "downstream",
+		inputs: []string{"out"},
+		run: func(ctx context.Context, job *Job) error {
+			downstreamRan = true
+			return nil
+		},
+	}, NoRetry)
+
+	err := p.Run(context.Background(), NewJob("job-1", nil))
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "stage broken: boom")
+	assert.False(t, downstreamRan)
+}
+
+func TestRunRequiresDeclaredOutputs(t *testing.T) {
+	p := New()
+	p.MustRegister(&lazyStage{}, NoRetry)
+
+	err := p.Run(context.Background(), NewJob("job-1", nil))
+
+	assert.ErrorIs(t, err, ErrMissingOutput)
+}
+
+type lazyStage struct{}
+
+func (s *lazyStage) Name() string                            { return "lazy" }
+func (s *lazyStage) Inputs() []string                        { return nil }
+func (s *lazyStage) Outputs() []string                       { return []string{"never"} }
+func (s *lazyStage) Run(ctx context.Context, job *Job) error { return nil }
+
+func TestValidate(t *testing.T) {
+	t.Run("missing input", func(t *testing.T) {
+		p := New()
+		p.MustRegister(&fakeStage{name: "a", inputs: []string{"source"}}, NoRetry)
+		assert.ErrorIs(t, p.Validate(NewJob("job-1", nil)), ErrMissingInput)
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		p := New()
+		p.MustRegister(&fakeStage{name: "a", inputs: []string{"y"}, outputs: []string{"x"}}, NoRetry)
+		p.MustRegister(&fakeStage{name: "b", inputs: []string{"x"}, outputs: []string{"y"}}, NoRetry)
+		assert.ErrorIs(t, p.Validate(NewJob("job-1", nil)), ErrCycle)
+	})
+
+	t.Run("duplicate output", func(t *testing.T) {
+		p := New()
+		p.MustRegister(&fakeStage{name: "a", outputs: []string{"x"}}, NoRetry)
+		p.MustRegister(&fakeStage{name: "b", outputs: []string{"x"}}, NoRetry)
+		assert.ErrorIs(t, p.Validate(NewJob("job-1", nil)), ErrDuplicateOutput)
+	})
+
+	t.Run("duplicate stage", func(t *testing.T) {
+		p := New()
+		p.MustRegister(&fakeStage{name: "a"}, NoRetry)
+		assert.ErrorIs(t, p.Register(&fakeStage{name: "a"}, NoRetry), ErrDuplicateStage)
+	})
+}
+
+func TestDownstream(t *testing.T) {
+	p := New()
+	p.MustRegister(&fakeStage{name: "probe", inputs: []string{"source"}, outputs: []string{"probe"}}, NoRetry)
+	p.MustRegister(&fakeStage{name: "transcode", inputs: []string{"source", "probe"}, outputs: []string{"mp4"}}, NoRetry)
+	p.MustRegister(&fakeStage{name: "scenes", inputs: []string{"mp4"}, outputs: []string{"scenes"}}, NoRetry)
+	p.MustRegister(&fakeStage{name: "embed", inputs: []string{"scenes"}, outputs: []string{"vectors"}}, NoRetry)
+	p.MustRegister(&fakeStage{name: "transcribe", inputs: []string{"source"}, outputs: []string{"text"}}, NoRetry)
+
+	stages, err := p.Downstream("transcode")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"transcode", "scenes", "embed"}, stages)
+
+	stages, err = p.Downstream("embed", "probe")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"probe", "transcode", "scenes", "embed"}, stages)
+
+	stages, err = p.Downstream()
+	assert.NoError(t, err)
+	assert.Empty(t, stages)
+
+	_, err = p.Downstream("thumbnail")
+	assert.ErrorIs(t, err, ErrUnknownStage)
+}
+
+type memoryCheckpointer struct {
+	mu          sync.Mutex
+	checkpoints map[string]Checkpoint
+}
+
+func (c *memoryCheckpointer) LoadCheckpoints(ctx context.Context, jobID string) (map[string]Checkpoint, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	loaded := make(map[string]Checkpoint, len(c.checkpoints))
+	for name, cp := range c.checkpoints {
+		loaded[name] = cp
+	}
+	return loaded, nil
+}
+
+func (c *memoryCheckpointer) SaveCheckpoint(ctx context.Context, jobID string, checkpoint Checkpoint) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checkpoints[checkpoint.Stage] = checkpoint
+	return nil
+}
+
+type resumableStage struct {
+	fakeStage
+	runs    int32
+	invalid bool
+}
+
+func (s *resumableStage) Run(ctx context.Context, job *Job) error {
+	atomic.AddInt32(&s.runs, 1)
+	return s.fakeStage.Run(ctx, job)
+}
+
+func (s *resumableStage) Validate(ctx context.Context, outputs map[string]string) error {
+	if s.invalid {
+		return errors.New("artifact missing")
+	}
+	return nil
+}
+
+func TestRunResumesFromCheckpoints(t *testing.T) {
+	checkpointer := &memoryCheckpointer{checkpoints: map[string]Checkpoint{}}
+	transcode := &resumableStage{fakeStage: fakeStage{name: "transcode", inputs: []string{"source"}, outputs: []string{"mp4"}}}
+
+	var saveAttempts int32
+	save := &fakeStage{
+		name:   "save",
+		inputs: []string{"mp4"},
+		run: func(ctx context.Context, job *Job) error {
+			if atomic.AddInt32(&saveAttempts, 1) == 1 {
+				return errors.New("DynamoDB unavailable")
+			}
+			mp4, _ := job.Artifact("mp4")
+			assert.Equal(t, "transcode:mp4", mp4)
+			return nil
+		},
+	}
+
+	p := New()
+	p.Checkpointer = checkpointer
+	p.MustRegister(transcode, NoRetry)
+	p.MustRegister(save, NoRetry)
+
+	err := p.Run(context.Background(), NewJob("job-1", map[string]string{"source": "in.mov"}))
+	assert.Error(t, err)
+	assert.Contains(t, checkpointer.checkpoints, "transcode")
+	assert.NotContains(t, checkpointer.checkpoints, "save")
+
+	// A redelivery skips the transcode and restores its output.
+	err = p.Run(context.Background(), NewJob("job-1", map[string]string{"source": "in.mov"}))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), transcode.runs)
+	assert.Equal(t, int32(2), saveAttempts)
+}
+
+func TestRunReplaysInvalidCheckpoint(t *testing.T) {
+	checkpointer := &memoryCheckpointer{checkpoints: map[string]Checkpoint{
+		"transcode": {Stage: "transcode", Artifacts: map[string]string{"mp4": "gone.mp4"}},
+	}}
+	transcode := &resumableStage{
+		fakeStage: fakeStage{name: "transcode", inputs: []string{"source"}, outputs: []string{"mp4"}},
+		invalid:   true,
+	}
+
+	p := New()
+	p.Checkpointer = checkpointer
+	p.MustRegister(transcode, NoRetry)
+
+	job := NewJob("job-1", map[string]string{"source": "in.mov"})
+	err := p.Run(context.Background(), job)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), transcode.runs)
+	mp4, _ := job.Artifact("mp4")
+	assert.Equal(t, "transcode:mp4", mp4)
+	assert.Equal(t, "transcode:mp4", checkpointer.checkpoints["transcode"].Artifacts["mp4"])
+}
+
+type restorableStage struct {
+	resumableStage
+	restores int32
+}
+
+func (s *restorableStage) Restore(ctx context.Context, job *Job) error {
+	atomic.AddInt32(&s.restores, 1)
+	return nil
+}
+
+func TestRunRestoresSkippedStages(t *testing.T) {
+	checkpointer := &memoryCheckpointer{checkpoints: map[string]Checkpoint{
+		"analyze": {Stage: "analyze", Artifacts: map[string]string{"analysis": "v2/analysis.json"}},
+	}}
+	analyze := &restorableStage{resumableStage: resumableStage{
+		fakeStage: fakeStage{name: "analyze", inputs: []string{"source"}, outputs: []string{"analysis"}},
+	}}
+
+	p := New()
+	p.Checkpointer = checkpointer
+	p.MustRegister(analyze, NoRetry)
+
+	// Resuming a redelivered run leaves the stage's results alone.
+	err := p.Run(context.Background(), NewJob("job-1", map[string]string{"source": "in.mov"}))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(0), analyze.runs)
+	assert.Equal(t, int32(0), analyze.restores)
+
+	// A restoring run saves them again without rerunning the stage.
+	job := NewJob("job-1", map[string]string{"source": "in.mov"})
+	job.Restore = true
+	err = p.Run(context.Background(), job)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(0), analyze.runs)
+	assert.Equal(t, int32(1), analyze.restores)
+	analysis, _ := job.Artifact("analysis")
+	assert.Equal(t, "v2/analysis.json", analysis)
+}
+
+func TestRunDoesNotRetryPermanentErrors(t *testing.T) {
+	var attempts int32
+	p := New()
+	p.MustRegister(&fakeStage{
+		name: "probe",
+		run: func(ctx context.Context, job *Job) error {
+			atomic.AddInt32(&attempts, 1)
+			return Permanent(errors.New("no video stream"))
+		},
+	}, RetryPolicy{MaxAttempts: 3})
+
+	err := p.Run(context.Background(), NewJob("job-1", nil))
+
+	assert.True(t, IsPermanent(err))
+	assert.Equal(t, "stage probe: no video stream", err.Error())
+	assert.Equal(t, int32(1), attempts)
+}
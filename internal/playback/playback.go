@@ -0,0 +1,127 @@
+// Package playback hands out short-lived URLs for a video's files. Objects
+// in the bucket are private, so clients get either presigned S3 URLs or, in
+// token mode, URLs on the API's playback proxy carrying an HMAC token.
+package playback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/tenant"
+)
+
+const (
+	ModePresign = "presign"
+	ModeToken   = "token"
+
+	// SourceAsset names a video's original upload on the playback proxy.
+	// Every other asset is a path under the video's processed prefix.
+	SourceAsset = "source"
+
+	defaultTTL = 15 * time.Minute
+	// minSecretBytes keeps token secrets at least as long as the HMAC-SHA256
+	// output.
+	minSecretBytes = 32
+)
+
+var ErrInvalidAsset = errors.New("invalid playback asset")
+
+// Config picks how playback URLs are signed and how long they last.
+// BaseURL is the public address of the API, used to build proxy URLs in
+// token mode; without it they are relative to the API.
+type Config struct {
+	Mode    string
+	TTL     time.Duration
+	Secret  []byte
+	BaseURL string
+}
+
+func (c Config) Validate() error {
+	switch c.Mode {
+	case ModePresign:
+	case ModeToken:
+		if len(c.Secret) < minSecretBytes {
+			return fmt.Errorf("playback token secret must be at least %d bytes", minSecretBytes)
+		}
+	default:
+		return fmt.Errorf("unknown playback URL mode %q: use %s or %s", c.Mode, ModePresign, ModeToken)
+	}
+	if c.TTL < 0 {
+		return fmt.Errorf("playback URL TTL must not be negative")
+	}
+	return nil
+}
+
+// Signer issues URLs for the objects of a video.
+type Signer interface {
+	// SignURL returns a URL that fetches the object at key, which belongs to
+	// video, and the time it stops working.
+	SignURL(ctx context.Context, video *db.Video, key string) (string, time.Time, error)
+}
+
+// NewSigner returns the signer for cfg's mode, which must be valid.
+func NewSigner(cfg Config, client *s3.Client, bucket string) Signer {
+	ttl := cfg.TTL
+	if ttl == 0 {
+		ttl = defaultTTL
+	}
+	if cfg.Mode == ModeToken {
+		return NewTokenSigner(cfg.Secret, ttl, cfg.BaseURL)
+	}
+	return NewS3Signer(client, bucket, ttl)
+}
+
+// SourceKey is the S3 key of the video's original upload, taken from its
+// https://<bucket>.s3.amazonaws.com/<key> URL.
+func SourceKey(video *db.Video) (string, bool) {
+	rest, ok := strings.CutPrefix(video.URL, "https://")
+	if !ok {
+		return "", false
+	}
+	_, key, ok := strings.Cut(rest, "/")
+	return key, ok && key != ""
+}
+
+// processedPrefix is where the video's processed files are. Aliases of
+// duplicate uploads share the original's files.
+func processedPrefix(video *db.Video) string {
+	owner := video.VideoID
+	if video.AliasOf != "" {
+		owner = video.AliasOf
+	}
+	return tenant.ProcessedKey(video.TenantID, owner, "")
+}
+
+// AssetKey resolves an asset name from a playback URL to the S3 key of one
+// of the video's files. Assets can't climb out of the video's prefix.
+func AssetKey(video *db.Video, asset string) (string, error) {
+	if asset == SourceAsset {
+		key, ok := SourceKey(video)
+		if !ok {
+			return "", fmt.Errorf("%w: video %s has no source", ErrInvalidAsset, video.VideoID)
+		}
+		return key, nil
+	}
+	if asset == "" || path.Clean("/"+asset) != "/"+asset {
+		return "", fmt.Errorf("%w %q", ErrInvalidAsset, asset)
+	}
+	return processedPrefix(video) + asset, nil
+}
+
+// assetName is the inverse of AssetKey.
+func assetName(video *db.Video, key string) (string, error) {
+	if source, ok := SourceKey(video); ok && key == source {
+		return SourceAsset, nil
+	}
+	asset, ok := strings.CutPrefix(key, processedPrefix(video))
+	if !ok || asset == "" {
+		return "", fmt.Errorf("%w: %s is not a file of video %s", ErrInvalidAsset, key, video.VideoID)
+	}
+	return asset, nil
+}
@@ -0,0 +1,128 @@
+package playback
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/stretchr/testify/assert"
+)
+
+var testSecret = []byte(strings.Repeat("s", minSecretBytes))
+
+func testVideo() *db.Video {
+	return &db.Video{
+		VideoID:  "v1",
+		TenantID: "acme",
+		URL:      "https://bucket.s3.amazonaws.com/tenants/acme/v1-clip one.mp4",
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{Mode: ModePresign}.Validate())
+	assert.NoError(t, Config{Mode: ModeToken, Secret: testSecret}.Validate())
+	assert.Error(t, Config{Mode: ModeToken, Secret: []byte("short")}.Validate())
+	assert.Error(t, Config{Mode: "cdn"}.Validate())
+	assert.Error(t, Config{Mode: ModePresign, TTL: -time.Second}.Validate())
+}
+
+func TestAssetKey(t *testing.T) {
+	video := testVideo()
+
+	key, err := AssetKey(video, SourceAsset)
+	assert.NoError(t, err)
+	assert.Equal(t, "tenants/acme/v1-clip one.mp4", key)
+
+	key, err = AssetKey(video, "hls/master.m3u8")
+	assert.NoError(t, err)
+	assert.Equal(t, "tenants/acme/processed/v1/hls/master.m3u8", key)
+
+	// Aliases resolve to the original's files.
+	alias := testVideo()
+	alias.VideoID, alias.AliasOf = "v2", "v1"
+	key, err = AssetKey(alias, "transcoded.mp4")
+	assert.NoError(t, err)
+	assert.Equal(t, "tenants/acme/processed/v1/transcoded.mp4", key)
+
+	for _, asset := range []string{"", "../v2/transcoded.mp4", "hls/../../x", "/etc", "hls//a.ts", "hls/"} {
+		_, err := AssetKey(video, asset)
+		assert.ErrorIs(t, err, ErrInvalidAsset, asset)
+	}
+}
+
+func TestTokenSignerSignURL(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := NewTokenSigner(testSecret, 10*time.Minute, "https://api.example.com/")
+	s.now = func() time.Time { return now }
+	video := testVideo()
+
+	signed, expires, err := s.SignURL(context.Background(), video, "tenants/acme/v1-clip one.mp4")
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(10*time.Minute), expires)
+	assert.Equal(t, "https://api.example.com/playback/v1/"+s.Token("v1", expires)+"/source", signed)
+
+	signed, _, err = s.SignURL(context.Background(), video, "tenants/acme/processed/v1/scenes/scene 1.jpg")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(signed, "/scenes/scene%201.jpg"), signed)
+
+	_, _, err = s.SignURL(context.Background(), video, "tenants/other/processed/v1/transcoded.mp4")
+	assert.ErrorIs(t, err, ErrInvalidAsset)
+}
+
+func TestTokenSignerVerify(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := NewTokenSigner(testSecret, 10*time.Minute, "")
+	s.now = func() time.Time { return now }
+	token := s.Token("v1", now.Add(time.Minute))
+
+	expires, err := s.Verify("v1", token)
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(time.Minute).Unix(), expires.Unix())
+
+	// Tokens are bound to the video and the key.
+	_, err = s.Verify("v2", token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	other := NewTokenSigner([]byte(strings.Repeat("o", minSecretBytes)), time.Minute, "")
+	_, err = other.Verify("v1", token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	// The expiry is covered by the MAC.
+	exp, mac, _ := strings.Cut(token, ".")
+	_, err = s.Verify("v1", exp+"0."+mac)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	for _, bad := range []string{"", "nodot", "123.!!!", "x." + mac} {
+		_, err := s.Verify("v1", bad)
+		assert.ErrorIs(t, err, ErrInvalidToken, bad)
+	}
+
+	now = now.Add(time.Minute)
+	_, err = s.Verify("v1", token)
+	assert.ErrorIs(t, err, ErrTokenExpired)
+}
+
+func TestS3SignerSignURL(t *testing.T) {
+	client := s3.New(s3.Options{
+		Region: "us-east-1",
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, nil
+		}),
+	})
+	s := NewS3Signer(client, "bucket", 5*time.Minute)
+
+	signed, expires, err := s.SignURL(context.Background(), testVideo(), "tenants/acme/processed/v1/transcoded.mp4")
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(5*time.Minute), expires, time.Minute)
+
+	u, err := url.Parse(signed)
+	assert.NoError(t, err)
+	assert.Equal(t, "bucket.s3.us-east-1.amazonaws.com", u.Host)
+	assert.Equal(t, "/tenants/acme/processed/v1/transcoded.mp4", u.Path)
+	assert.Equal(t, "300", u.Query().Get("X-Amz-Expires"))
+	assert.NotEmpty(t, u.Query().Get("X-Amz-Signature"))
+}
@@ -0,0 +1,42 @@
+package playback
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/ryanschneiderman/video-api/internal/db"
+)
+
+// S3Signer presigns GET requests for objects in the bucket. Presigning is
+// done locally and makes no request to S3.
+type S3Signer struct {
+	Client *s3.Client
+	Bucket string
+	TTL    time.Duration
+
+	now func() time.Time
+}
+
+func NewS3Signer(client *s3.Client, bucket string, ttl time.Duration) *S3Signer {
+	return &S3Signer{
+		Client: client,
+		Bucket: bucket,
+		TTL:    ttl,
+		now:    time.Now,
+	}
+}
+
+func (s *S3Signer) SignURL(ctx context.Context, video *db.Video, key string) (string, time.Time, error) {
+	expires := s.now().Add(s.TTL)
+	req, err := s3.NewPresignClient(s.Client).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(s.TTL))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to presign %s: %w", key, err)
+	}
+	return req.URL, expires, nil
+}
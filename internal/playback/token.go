@@ -0,0 +1,94 @@
+package playback
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ryanschneiderman/video-api/internal/db"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid playback token")
+	ErrTokenExpired = errors.New("playback token expired")
+)
+
+// TokenSigner issues URLs on the playback proxy:
+//
+//	<BaseURL>/playback/<video ID>/<token>/<asset>
+//
+// The token is "<expiry>.<mac>": the expiry in Unix seconds and an
+// HMAC-SHA256 of the video ID and expiry. It grants every asset of the one
+// video until it expires. It sits in the path rather than the query so that
+// the relative URIs in HLS playlists resolve to URLs carrying it too.
+type TokenSigner struct {
+	Secret  []byte
+	TTL     time.Duration
+	BaseURL string
+
+	now func() time.Time
+}
+
+func NewTokenSigner(secret []byte, ttl time.Duration, baseURL string) *TokenSigner {
+	return &TokenSigner{
+		Secret:  secret,
+		TTL:     ttl,
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		now:     time.Now,
+	}
+}
+
+func (s *TokenSigner) SignURL(ctx context.Context, video *db.Video, key string) (string, time.Time, error) {
+	asset, err := assetName(video, key)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expires := s.now().Add(s.TTL).Truncate(time.Second)
+
+	segments := strings.Split(asset, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return fmt.Sprintf("%s/playback/%s/%s/%s", s.BaseURL, video.VideoID, s.Token(video.VideoID, expires), strings.Join(segments, "/")), expires, nil
+}
+
+// Token returns a token for the video that expires at expires.
+func (s *TokenSigner) Token(videoID string, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	return exp + "." + base64.RawURLEncoding.EncodeToString(s.mac(videoID, exp))
+}
+
+// Verify checks that token was issued for the video and hasn't expired, and
+// returns its expiry.
+func (s *TokenSigner) Verify(videoID, token string) (time.Time, error) {
+	exp, encoded, ok := strings.Cut(token, ".")
+	if !ok {
+		return time.Time{}, ErrInvalidToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || !hmac.Equal(mac, s.mac(videoID, exp)) {
+		return time.Time{}, ErrInvalidToken
+	}
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return time.Time{}, ErrInvalidToken
+	}
+	expires := time.Unix(unix, 0)
+	if !s.now().Before(expires) {
+		return time.Time{}, ErrTokenExpired
+	}
+	return expires, nil
+}
+
+func (s *TokenSigner) mac(videoID, exp string) []byte {
+	h := hmac.New(sha256.New, s.Secret)
+	h.Write([]byte(videoID + "\n" + exp))
+	return h.Sum(nil)
+}
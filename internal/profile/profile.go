@@ -0,0 +1,69 @@
+// Package profile holds the transcoding profiles videos can be processed
+// with. The default profile is the encoding every video got before profiles
+// existed, so videos without a recorded profile used it.
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+const Default = "default"
+
+var ErrUnknown = errors.New("unknown transcoding profile")
+
+// Profile is an H.264/AAC encoding. A zero Height keeps the source's
+// resolution, and a zero CRF leaves the quality to the encoder's default.
+type Profile struct {
+	Name   string
+	Height int
+	CRF    int
+	Preset string
+}
+
+var profiles = map[string]Profile{
+	Default:      {Name: Default},
+	"h264-1080p": {Name: "h264-1080p", Height: 1080, CRF: 21, Preset: "medium"},
+	"h264-720p":  {Name: "h264-720p", Height: 720, CRF: 23, Preset: "medium"},
+	"h264-480p":  {Name: "h264-480p", Height: 480, CRF: 24, Preset: "fast"},
+}
+
+// Get returns the named profile. An empty name is the default profile.
+func Get(name string) (Profile, error) {
+	if name == "" {
+		name = Default
+	}
+	p, ok := profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("%w %q", ErrUnknown, name)
+	}
+	return p, nil
+}
+
+// Names lists the profiles in alphabetical order.
+func Names() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Args are the ffmpeg output options of the profile. Scaling keeps the
+// aspect ratio and never enlarges the source.
+func (p Profile) Args() []string {
+	args := []string{"-vcodec", "libx264", "-acodec", "aac"}
+	if p.Height > 0 {
+		args = append(args, "-vf", fmt.Sprintf("scale=-2:'min(%d,ih)'", p.Height))
+	}
+	if p.CRF > 0 {
+		args = append(args, "-crf", strconv.Itoa(p.CRF))
+	}
+	if p.Preset != "" {
+		args = append(args, "-preset", p.Preset)
+	}
+	return args
+}
@@ -0,0 +1,22 @@
+package profile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGet(t *testing.T) {
+	p, err := Get("")
+	assert.NoError(t, err)
+	assert.Equal(t, Default, p.Name)
+	assert.Equal(t, []string{"-vcodec", "libx264", "-acodec", "aac"}, p.Args())
+
+	p, err = Get("h264-720p")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-vcodec", "libx264", "-acodec", "aac",
+		"-vf", "scale=-2:'min(720,ih)'", "-crf", "23", "-preset", "medium"}, p.Args())
+
+	_, err = Get("h265-4k")
+	assert.ErrorIs(t, err, ErrUnknown)
+}
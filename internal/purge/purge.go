@@ -0,0 +1,127 @@
+// Package purge deletes what a video leaves behind once its record is gone:
+// its files in S3 and its entries in the indexes that find videos by
+// content. Every step can be repeated, so a purge that failed part way is
+// simply run again.
+package purge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/playback"
+	"github.com/ryanschneiderman/video-api/internal/semantic"
+	"github.com/ryanschneiderman/video-api/internal/tenant"
+)
+
+// Store is the part of the database a purge uses.
+type Store interface {
+	DeleteSearchDocument(ctx context.Context, tenantId string, videoId string) error
+	DeleteContentHash(ctx context.Context, tenantId string, hash string, videoId string) error
+	DeleteVideoItems(ctx context.Context, tenantId string, videoId string) error
+}
+
+// Objects is the part of the S3 client a purge uses.
+type Objects interface {
+	s3.ListObjectsV2APIClient
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
+}
+
+type Purger struct {
+	DB     Store
+	S3     Objects
+	Bucket string
+	// SemanticIndex is nil when semantic search is disabled.
+	SemanticIndex *semantic.Store
+}
+
+// errNotIndexed skips writing back a semantic index the video wasn't in.
+var errNotIndexed = errors.New("video is not in the semantic index")
+
+// Purge deletes a deleted video's search document, content hash, frame
+// hashes, versions, analysis segments and semantic index entries, then its
+// processed files and its original. Aliases of duplicate uploads only have
+// a search document; the rest belongs to their original.
+func (p *Purger) Purge(ctx context.Context, video *db.Video) error {
+	if err := p.DB.DeleteSearchDocument(ctx, video.TenantID, video.VideoID); err != nil {
+		return err
+	}
+	if video.AliasOf != "" {
+		return nil
+	}
+
+	if video.ContentHash != "" {
+		if err := p.DB.DeleteContentHash(ctx, video.TenantID, video.ContentHash, video.VideoID); err != nil {
+			return err
+		}
+	}
+	if err := p.DB.DeleteVideoItems(ctx, video.TenantID, video.VideoID); err != nil {
+		return err
+	}
+	if p.SemanticIndex != nil && video.TenantID != "" {
+		err := p.SemanticIndex.Update(ctx, video.TenantID, func(idx *semantic.Index) error {
+			if idx.RemoveVideo(video.VideoID) == 0 {
+				return errNotIndexed
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errNotIndexed) {
+			return err
+		}
+	}
+
+	if err := DeletePrefix(ctx, p.S3, p.Bucket, tenant.ProcessedKey(video.TenantID, video.VideoID, "")); err != nil {
+		return err
+	}
+	if source, ok := playback.SourceKey(video); ok {
+		_, err := p.S3.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(p.Bucket),
+			Key:    aws.String(source),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete %s: %w", source, err)
+		}
+	}
+	return nil
+}
+
+// DeletePrefix deletes every object under prefix, a page at a time. List
+// pages hold at most 1000 keys, the most DeleteObjects takes.
+func DeletePrefix(ctx context.Context, client Objects, bucket string, prefix string) error {
+	if prefix == "" {
+		return fmt.Errorf("refusing to delete an empty prefix")
+	}
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list %s: %w", prefix, err)
+		}
+		if len(page.Contents) == 0 {
+			continue
+		}
+		objects := make([]s3types.ObjectIdentifier, 0, len(page.Contents))
+		for _, obj := range page.Contents {
+			objects = append(objects, s3types.ObjectIdentifier{Key: obj.Key})
+		}
+		output, err := client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(bucket),
+			Delete: &s3types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete objects under %s: %w", prefix, err)
+		}
+		if len(output.Errors) > 0 {
+			return fmt.Errorf("failed to delete %d objects under %s: %s", len(output.Errors), prefix, aws.ToString(output.Errors[0].Message))
+		}
+	}
+	return nil
+}
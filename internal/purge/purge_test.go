@@ -0,0 +1,89 @@
+package purge
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeStore records what was deleted.
+type fakeStore struct{ calls []string }
+
+func (s *fakeStore) DeleteSearchDocument(ctx context.Context, tenantId string, videoId string) error {
+	s.calls = append(s.calls, "search "+videoId)
+	return nil
+}
+
+func (s *fakeStore) DeleteContentHash(ctx context.Context, tenantId string, hash string, videoId string) error {
+	s.calls = append(s.calls, "hash "+hash)
+	return nil
+}
+
+func (s *fakeStore) DeleteVideoItems(ctx context.Context, tenantId string, videoId string) error {
+	s.calls = append(s.calls, "items "+videoId)
+	return nil
+}
+
+// memoryObjects is a bucket in memory.
+type memoryObjects struct{ keys map[string]bool }
+
+func (m *memoryObjects) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	out := &s3.ListObjectsV2Output{}
+	for key := range m.keys {
+		if strings.HasPrefix(key, aws.ToString(params.Prefix)) {
+			out.Contents = append(out.Contents, s3types.Object{Key: aws.String(key)})
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryObjects) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	delete(m.keys, aws.ToString(params.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func (m *memoryObjects) DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	for _, obj := range params.Delete.Objects {
+		delete(m.keys, aws.ToString(obj.Key))
+	}
+	return &s3.DeleteObjectsOutput{}, nil
+}
+
+func TestPurge(t *testing.T) {
+	objects := &memoryObjects{keys: map[string]bool{
+		"tenants/acme/v1-clip.mp4":                       true,
+		"tenants/acme/processed/v1/v1/video.mp4":         true,
+		"tenants/acme/processed/v1/v2/captions/captions": true,
+		"tenants/acme/processed/v10/v1/video.mp4":        true,
+		"tenants/acme/v10-clip.mp4":                      true,
+	}}
+	store := &fakeStore{}
+	p := &Purger{DB: store, S3: objects, Bucket: "bucket"}
+	ctx := context.Background()
+
+	video := &db.Video{
+		VideoID:     "v1",
+		TenantID:    "acme",
+		URL:         "https://bucket.s3.amazonaws.com/tenants/acme/v1-clip.mp4",
+		ContentHash: "abc",
+	}
+	assert.NoError(t, p.Purge(ctx, video))
+	assert.Equal(t, []string{"search v1", "hash abc", "items v1"}, store.calls)
+	assert.Equal(t, map[string]bool{
+		"tenants/acme/processed/v10/v1/video.mp4": true,
+		"tenants/acme/v10-clip.mp4":               true,
+	}, objects.keys)
+
+	// An alias shares the files and index entries of its original.
+	store.calls = nil
+	alias := &db.Video{VideoID: "v2", TenantID: "acme", AliasOf: "v10", URL: "https://bucket.s3.amazonaws.com/tenants/acme/v10-clip.mp4", ContentHash: "def"}
+	assert.NoError(t, p.Purge(ctx, alias))
+	assert.Equal(t, []string{"search v2"}, store.calls)
+	assert.Len(t, objects.keys, 2)
+}
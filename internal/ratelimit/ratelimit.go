@@ -0,0 +1,142 @@
+// Package ratelimit limits how often each caller may hit a group of routes
+// with in-memory token buckets. Limits are per API process, so a deployment
+// with N replicas allows up to N times the configured rate.
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrInvalidLimit = errors.New("invalid rate limit")
+
+// Limit allows bursts of up to Burst requests, refilled at Burst per Per.
+// The zero Limit allows everything.
+type Limit struct {
+	Burst int
+	Per   time.Duration
+}
+
+// ParseLimit parses a limit written as "<requests>/<duration>", such as
+// "60/1m". An empty string or "0" disables limiting.
+func ParseLimit(value string) (Limit, error) {
+	if value == "" || value == "0" {
+		return Limit{}, nil
+	}
+	count, period, ok := strings.Cut(value, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("%w %q: expected <requests>/<duration>", ErrInvalidLimit, value)
+	}
+	burst, err := strconv.Atoi(count)
+	if err != nil || burst < 1 {
+		return Limit{}, fmt.Errorf("%w %q: requests must be a positive integer", ErrInvalidLimit, value)
+	}
+	per, err := time.ParseDuration(period)
+	if err != nil || per <= 0 {
+		return Limit{}, fmt.Errorf("%w %q: duration must be positive", ErrInvalidLimit, value)
+	}
+	return Limit{Burst: burst, Per: per}, nil
+}
+
+func (l Limit) Enabled() bool {
+	return l.Burst > 0 && l.Per > 0
+}
+
+// perSecond is the refill rate in tokens per second.
+func (l Limit) perSecond() float64 {
+	return float64(l.Burst) / l.Per.Seconds()
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Limiter keeps one token bucket per key.
+type Limiter struct {
+	limit Limit
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewLimiter(limit Limit) *Limiter {
+	return &Limiter{
+		limit:   limit,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token from key's bucket. When the bucket is empty it reports
+// how long until the next token is available instead.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if !l.limit.Enabled() {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.limit.Burst), updated: now}
+		l.buckets[key] = b
+	}
+	b.tokens = l.refilled(b, now)
+	b.updated = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / l.limit.perSecond() * float64(time.Second))
+	return false, wait
+}
+
+func (l *Limiter) refilled(b *bucket, now time.Time) float64 {
+	elapsed := now.Sub(b.updated).Seconds()
+	return math.Min(float64(l.limit.Burst), b.tokens+elapsed*l.limit.perSecond())
+}
+
+// sweep drops the buckets that have refilled completely, which behave the
+// same as a missing bucket, so idle callers don't accumulate. It runs at
+// most once per limit period.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.limit.Per {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if l.refilled(b, now) >= float64(l.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Middleware rejects requests over the limiter's limit with 429 and a
+// Retry-After header. key identifies the caller; each caller has its own
+// bucket.
+func Middleware(l *Limiter, key func(*gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ok, wait := l.Allow(key(c))
+		if !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
+			return
+		}
+		c.Next()
+	}
+}
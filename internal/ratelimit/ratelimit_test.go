@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLimit(t *testing.T) {
+	limit, err := ParseLimit("60/1m")
+	assert.NoError(t, err)
+	assert.Equal(t, Limit{Burst: 60, Per: time.Minute}, limit)
+
+	for _, value := range []string{"", "0"} {
+		limit, err := ParseLimit(value)
+		assert.NoError(t, err)
+		assert.False(t, limit.Enabled())
+	}
+	for _, value := range []string{"60", "x/1m", "0/1m", "10/0s", "10/soon"} {
+		_, err := ParseLimit(value)
+		assert.ErrorIs(t, err, ErrInvalidLimit, value)
+	}
+}
+
+func TestLimiterAllow(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	l := NewLimiter(Limit{Burst: 3, Per: 3 * time.Second})
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		ok, _ := l.Allow("a")
+		assert.True(t, ok, "request %d", i)
+	}
+	ok, wait := l.Allow("a")
+	assert.False(t, ok)
+	assert.Equal(t, time.Second, wait)
+
+	// Other keys have their own bucket.
+	ok, _ = l.Allow("b")
+	assert.True(t, ok)
+
+	// Tokens refill at Burst per Per.
+	now = now.Add(1500 * time.Millisecond)
+	ok, _ = l.Allow("a")
+	assert.True(t, ok)
+	ok, wait = l.Allow("a")
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, wait)
+}
+
+func TestLimiterSweepsIdleBuckets(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	l := NewLimiter(Limit{Burst: 2, Per: time.Second})
+	l.now = func() time.Time { return now }
+
+	l.Allow("a")
+	l.Allow("b")
+	assert.Len(t, l.buckets, 2)
+
+	now = now.Add(2 * time.Second)
+	l.Allow("c")
+	assert.Len(t, l.buckets, 1)
+}
+
+func TestDisabledLimiter(t *testing.T) {
+	l := NewLimiter(Limit{})
+	for i := 0; i < 100; i++ {
+		ok, _ := l.Allow("a")
+		assert.True(t, ok)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	l := NewLimiter(Limit{Burst: 1, Per: 10 * time.Second})
+
+	router := gin.New()
+	router.GET("/", Middleware(l, func(c *gin.Context) string {
+		return c.GetHeader("X-Caller")
+	}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	request := func(caller string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Caller", caller)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, request("a").Code)
+	w := request("a")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "10", w.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, request("b").Code)
+}
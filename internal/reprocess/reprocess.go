@@ -0,0 +1,212 @@
+// Package reprocess runs a processed video's stages again from its stored
+// original, for instance after a transcoding profile or an analyzer
+// changes, or makes one of its earlier versions current again. Both the API
+// and the backfill command enqueue jobs through it, and the worker runs
+// them.
+package reprocess
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/playback"
+	"github.com/ryanschneiderman/video-api/internal/profile"
+)
+
+// Job marks a queue message asking to reprocess a video, and JobPromote one
+// asking to promote a version of it.
+const (
+	Job        = "reprocess"
+	JobPromote = "promote"
+)
+
+var (
+	ErrUnknownStage = errors.New("unknown stage")
+	// ErrAlias means the video shares the files of the video it duplicates,
+	// which is the one to reprocess.
+	ErrAlias    = errors.New("video is an alias of another video")
+	ErrNoSource = errors.New("video has no stored original")
+	// ErrBusy means the video is not ready or failed, so a job may already
+	// be running for it.
+	ErrBusy = errors.New("video is being processed")
+	// ErrVersionNotReady means the version failed, is still processing or
+	// has had its files deleted, so there is nothing to promote.
+	ErrVersionNotReady = errors.New("version is not ready")
+	ErrCurrentVersion  = errors.New("version is already current")
+)
+
+// Request picks what to run again. No stages means every stage, and no
+// profile keeps the video's current one. Changing the profile reruns the
+// transcode whichever stages are picked. Stages that depend on the ones
+// picked always run again too.
+type Request struct {
+	Stages  []string `json:"stages"`
+	Profile string   `json:"profile"`
+}
+
+// Validate checks the stage and profile names and drops repeated stages.
+// known names the worker's processing stages, see worker.StageNames.
+func (r *Request) Validate(known []string) error {
+	seen := make(map[string]bool, len(r.Stages))
+	stages := make([]string, 0, len(r.Stages))
+	for _, stage := range r.Stages {
+		if !slices.Contains(known, stage) {
+			return fmt.Errorf("%w %q", ErrUnknownStage, stage)
+		}
+		if !seen[stage] {
+			seen[stage] = true
+			stages = append(stages, stage)
+		}
+	}
+	r.Stages = stages
+
+	if r.Profile != "" {
+		if _, err := profile.Get(r.Profile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Message is the queue message of a reprocess or promote job. Filename is
+// the stored original. The worker only reruns stages that completed before
+// RequestedAt, so a redelivered job resumes rather than starting over.
+// Version is the version a promote job makes current.
+type Message struct {
+	VideoID     string    `json:"video_id"`
+	Filename    string    `json:"filename"`
+	Job         string    `json:"job"`
+	Stages      []string  `json:"stages,omitempty"`
+	Profile     string    `json:"profile"`
+	RequestedAt time.Time `json:"requested_at"`
+	Version     int       `json:"version,omitempty"`
+}
+
+type Store interface {
+	UpdateVideoIfStatus(ctx context.Context, videoId string, status string, fields map[string]interface{}) error
+}
+
+type Queue interface {
+	SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+}
+
+// Enqueuer sends reprocess and promote jobs to the processing queue.
+type Enqueuer struct {
+	DB       Store
+	Queue    Queue
+	QueueURL string
+}
+
+// Enqueue queues a job to reprocess a ready or failed video and moves it back
+// to uploaded, so the same video can't be queued twice at once. Its current
+// renditions are served until the job replaces them. req must have been
+// validated.
+func (e *Enqueuer) Enqueue(ctx context.Context, video *db.Video, req Request) (*Message, error) {
+	source, err := checkVideo(video)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &Message{
+		VideoID:     video.VideoID,
+		Filename:    source,
+		Job:         Job,
+		Stages:      req.Stages,
+		Profile:     req.Profile,
+		RequestedAt: time.Now().UTC(),
+	}
+	if msg.Profile == "" {
+		msg.Profile = video.Profile
+	}
+	if msg.Profile == "" {
+		msg.Profile = profile.Default
+	}
+	if err := e.send(ctx, video, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// Promote queues a job to make a ready version of a ready or failed video
+// its current one, the same way Enqueue queues a reprocess. Stages whose
+// files are gone run again from the stored original.
+func (e *Enqueuer) Promote(ctx context.Context, video *db.Video, version *db.VideoVersion) (*Message, error) {
+	source, err := checkVideo(video)
+	if err != nil {
+		return nil, err
+	}
+	if version.State != db.VersionReady {
+		return nil, fmt.Errorf("%w: version %d is %s", ErrVersionNotReady, version.Version, version.State)
+	}
+	if version.Version == video.Version {
+		return nil, ErrCurrentVersion
+	}
+
+	msg := &Message{
+		VideoID:     video.VideoID,
+		Filename:    source,
+		Job:         JobPromote,
+		Profile:     version.Profile,
+		RequestedAt: time.Now().UTC(),
+		Version:     version.Version,
+	}
+	if err := e.send(ctx, video, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// checkVideo returns the stored original of a video that can be queued.
+func checkVideo(video *db.Video) (string, error) {
+	if video.AliasOf != "" {
+		return "", fmt.Errorf("%w %s", ErrAlias, video.AliasOf)
+	}
+	source, ok := playback.SourceKey(video)
+	if !ok {
+		return "", ErrNoSource
+	}
+	if video.Status != db.StatusReady && video.Status != db.StatusFailed {
+		return "", ErrBusy
+	}
+	return source, nil
+}
+
+// send moves the video to uploaded and queues msg, putting the status back
+// if the message can't be sent.
+func (e *Enqueuer) send(ctx context.Context, video *db.Video, msg *Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s message: %w", msg.Job, err)
+	}
+
+	err = e.DB.UpdateVideoIfStatus(ctx, video.VideoID, video.Status, map[string]interface{}{
+		"status": db.StatusUploaded,
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrConflict) {
+			return ErrBusy
+		}
+		return fmt.Errorf("failed to update video status: %w", err)
+	}
+
+	_, err = e.Queue.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:    aws.String(e.QueueURL),
+		MessageBody: aws.String(string(body)),
+	})
+	if err != nil {
+		// Put the video back so it can be queued again.
+		restore := map[string]interface{}{"status": video.Status}
+		if rerr := e.DB.UpdateVideoIfStatus(ctx, video.VideoID, db.StatusUploaded, restore); rerr != nil {
+			return fmt.Errorf("failed to send SQS message: %w (and to restore status: %v)", err, rerr)
+		}
+		return fmt.Errorf("failed to send SQS message: %w", err)
+	}
+	return nil
+}
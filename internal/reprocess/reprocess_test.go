@@ -0,0 +1,139 @@
+package reprocess
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/profile"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeStore struct {
+	statuses map[string]string
+}
+
+func (s *fakeStore) UpdateVideoIfStatus(ctx context.Context, videoId string, status string, fields map[string]interface{}) error {
+	if s.statuses[videoId] != status {
+		return db.ErrConflict
+	}
+	s.statuses[videoId] = fields["status"].(string)
+	return nil
+}
+
+type fakeQueue struct {
+	bodies []string
+	err    error
+}
+
+func (q *fakeQueue) SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	q.bodies = append(q.bodies, aws.ToString(params.MessageBody))
+	return &sqs.SendMessageOutput{}, nil
+}
+
+func TestRequestValidate(t *testing.T) {
+	known := []string{"probe", "transcode", "analyze"}
+	req := Request{Stages: []string{"transcode", "analyze", "transcode"}, Profile: "h264-720p"}
+	assert.NoError(t, req.Validate(known))
+	assert.Equal(t, []string{"transcode", "analyze"}, req.Stages)
+
+	req = Request{Stages: []string{"thumbnail"}}
+	assert.ErrorIs(t, req.Validate(known), ErrUnknownStage)
+
+	req = Request{Profile: "h265-4k"}
+	assert.ErrorIs(t, req.Validate(known), profile.ErrUnknown)
+}
+
+func TestEnqueue(t *testing.T) {
+	ctx := context.Background()
+	video := &db.Video{
+		VideoID: "v1",
+		URL:     "https://bucket.s3.amazonaws.com/tenants/acme/v1-clip.mp4",
+		Status:  db.StatusReady,
+	}
+	store := &fakeStore{statuses: map[string]string{"v1": db.StatusReady}}
+	queue := &fakeQueue{}
+	e := &Enqueuer{DB: store, Queue: queue, QueueURL: "queue"}
+
+	msg, err := e.Enqueue(ctx, video, Request{Stages: []string{"analyze"}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "tenants/acme/v1-clip.mp4", msg.Filename)
+	assert.Equal(t, profile.Default, msg.Profile)
+	assert.Equal(t, db.StatusUploaded, store.statuses["v1"])
+	var sent Message
+	assert.NoError(t, json.Unmarshal([]byte(queue.bodies[0]), &sent))
+	assert.Equal(t, Job, sent.Job)
+	assert.Equal(t, []string{"analyze"}, sent.Stages)
+
+	// The stale copy still reads ready, but the video has moved on.
+	_, err = e.Enqueue(ctx, video, Request{})
+	assert.ErrorIs(t, err, ErrBusy)
+}
+
+func TestEnqueueRestoresStatusWhenSendFails(t *testing.T) {
+	video := &db.Video{
+		VideoID: "v1",
+		URL:     "https://bucket.s3.amazonaws.com/v1-clip.mp4",
+		Status:  db.StatusFailed,
+	}
+	store := &fakeStore{statuses: map[string]string{"v1": db.StatusFailed}}
+	e := &Enqueuer{DB: store, Queue: &fakeQueue{err: errors.New("throttled")}}
+
+	_, err := e.Enqueue(context.Background(), video, Request{})
+
+	assert.Error(t, err)
+	assert.Equal(t, db.StatusFailed, store.statuses["v1"])
+}
+
+func TestEnqueueRefusesAliasesAndBusyVideos(t *testing.T) {
+	e := &Enqueuer{DB: &fakeStore{}, Queue: &fakeQueue{}}
+	ctx := context.Background()
+
+	_, err := e.Enqueue(ctx, &db.Video{VideoID: "v2", AliasOf: "v1", URL: "https://b.s3.amazonaws.com/k", Status: db.StatusReady}, Request{})
+	assert.ErrorIs(t, err, ErrAlias)
+
+	_, err = e.Enqueue(ctx, &db.Video{VideoID: "v3", Status: db.StatusReady}, Request{})
+	assert.ErrorIs(t, err, ErrNoSource)
+
+	_, err = e.Enqueue(ctx, &db.Video{VideoID: "v4", URL: "https://b.s3.amazonaws.com/k", Status: db.StatusProcessing}, Request{})
+	assert.ErrorIs(t, err, ErrBusy)
+}
+
+func TestPromote(t *testing.T) {
+	ctx := context.Background()
+	video := &db.Video{
+		VideoID: "v1",
+		URL:     "https://bucket.s3.amazonaws.com/v1-clip.mp4",
+		Status:  db.StatusReady,
+		Version: 3,
+	}
+	store := &fakeStore{statuses: map[string]string{"v1": db.StatusReady}}
+	queue := &fakeQueue{}
+	e := &Enqueuer{DB: store, Queue: queue, QueueURL: "queue"}
+
+	_, err := e.Promote(ctx, video, &db.VideoVersion{VideoID: "v1", Version: 3, State: db.VersionReady})
+	assert.ErrorIs(t, err, ErrCurrentVersion)
+
+	_, err = e.Promote(ctx, video, &db.VideoVersion{VideoID: "v1", Version: 1, State: db.VersionExpired})
+	assert.ErrorIs(t, err, ErrVersionNotReady)
+	assert.Empty(t, queue.bodies)
+
+	msg, err := e.Promote(ctx, video, &db.VideoVersion{VideoID: "v1", Version: 2, State: db.VersionReady, Profile: "h264-720p"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, db.StatusUploaded, store.statuses["v1"])
+	var sent Message
+	assert.NoError(t, json.Unmarshal([]byte(queue.bodies[0]), &sent))
+	assert.Equal(t, JobPromote, sent.Job)
+	assert.Equal(t, 2, sent.Version)
+	assert.Equal(t, "h264-720p", msg.Profile)
+	assert.Equal(t, "v1-clip.mp4", sent.Filename)
+}
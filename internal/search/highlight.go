@@ -0,0 +1,84 @@
+package search
+
+import (
+	"html"
+	"strings"
+)
+
+// Tokens of context kept around the first match in a snippet.
+const (
+	snippetBefore = 8
+	snippetAfter  = 16
+)
+
+// highlightFields are the free-text fields snippets are taken from, in order
+// of preference. Tags and labels are returned whole by the API already.
+var highlightFields = []string{FieldTitle, FieldDescription, FieldTranscript}
+
+func highlights(doc *Document, terms []string) []Highlight {
+	wanted := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		wanted[term] = true
+	}
+
+	texts := map[string]string{
+		FieldTitle:       doc.Title,
+		FieldDescription: doc.Description,
+		FieldTranscript:  doc.Transcript,
+	}
+	var found []Highlight
+	for _, field := range highlightFields {
+		if snippet, ok := Snippet(texts[field], wanted); ok {
+			found = append(found, Highlight{Field: field, Snippet: snippet})
+		}
+	}
+	return found
+}
+
+// Snippet cuts a window of text around the first token in terms and wraps
+// every matching token in it with <mark>. The text is HTML-escaped, so the
+// marks are the only markup. It reports false when nothing matches.
+func Snippet(text string, terms map[string]bool) (string, bool) {
+	tokens := Tokenize(text)
+	first := -1
+	for i, tok := range tokens {
+		if terms[tok.Term] {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return "", false
+	}
+
+	from := max(first-snippetBefore, 0)
+	to := min(first+snippetAfter, len(tokens)-1)
+	start, end := tokens[from].Start, tokens[to].End
+	if from == 0 {
+		start = 0
+	}
+	if to == len(tokens)-1 {
+		end = len(text)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	cursor := start
+	for _, tok := range tokens[from : to+1] {
+		if !terms[tok.Term] {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[cursor:tok.Start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[tok.Start:tok.End]))
+		b.WriteString("</mark>")
+		cursor = tok.End
+	}
+	b.WriteString(html.EscapeString(text[cursor:end]))
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return strings.TrimSpace(b.String()), true
+}
@@ -0,0 +1,306 @@
+// Package search is a small inverted index over video metadata and
+// transcripts. Documents are broken into per-term postings that record where
+// each term occurs; a Store persists them and Search ranks the videos that
+// match a query.
+package search
+
+import (
+	"context"
+	"math"
+	"slices"
+	"sort"
+)
+
+// Searchable fields, in the order they are preferred for snippets.
+const (
+	FieldTitle       = "title"
+	FieldDescription = "description"
+	FieldTags        = "tags"
+	FieldLabels      = "labels"
+	FieldTranscript  = "transcript"
+)
+
+// fieldWeights boosts matches in short, deliberate fields over matches deep
+// in a transcript.
+var fieldWeights = map[string]float64{
+	FieldTitle:       3,
+	FieldTags:        2.5,
+	FieldLabels:      2,
+	FieldDescription: 1.5,
+	FieldTranscript:  1,
+}
+
+// Terms longer than this are dropped; they are almost always hashes or URLs
+// and would make for oversized index keys.
+const maxTermLength = 64
+
+// BM25 term frequency saturation. Field lengths aren't normalized, so there
+// is no b parameter.
+const k1 = 1.2
+
+// Document is the searchable text of a video.
+type Document struct {
+	VideoID     string
+	Title       string
+	Description string
+	Tags        []string
+	Labels      []string
+	Transcript  string
+}
+
+// Posting records the positions of a term in each field of one document.
+// Tags are copied onto every posting so matches can be filtered and faceted
+// without loading the documents.
+type Posting struct {
+	Term      string
+	VideoID   string
+	Positions map[string][]int
+	Tags      []string
+}
+
+// Store reads the index. db.DB implements it on top of DynamoDB.
+type Store interface {
+	SearchPostings(ctx context.Context, term string) ([]Posting, error)
+	SearchDocument(ctx context.Context, videoID string) (*Document, error)
+	SearchDocumentCount(ctx context.Context) (int, error)
+}
+
+// fieldValues lists the values of each field. Tags and labels have one value
+// each.
+func (d *Document) fieldValues() map[string][]string {
+	return map[string][]string{
+		FieldTitle:       {d.Title},
+		FieldDescription: {d.Description},
+		FieldTags:        d.Tags,
+		FieldLabels:      d.Labels,
+		FieldTranscript:  {d.Transcript},
+	}
+}
+
+// Postings breaks a document into one posting per distinct term. Positions
+// skip one between the values of a list field so a phrase can't span two
+// tags.
+func Postings(doc *Document) []Posting {
+	byTerm := make(map[string]*Posting)
+	var terms []string
+	for field, values := range doc.fieldValues() {
+		offset := 0
+		for _, value := range values {
+			tokens := Tokenize(value)
+			for _, tok := range tokens {
+				if len(tok.Term) > maxTermLength {
+					continue
+				}
+				p, ok := byTerm[tok.Term]
+				if !ok {
+					p = &Posting{Term: tok.Term, VideoID: doc.VideoID, Positions: map[string][]int{}, Tags: doc.Tags}
+					byTerm[tok.Term] = p
+					terms = append(terms, tok.Term)
+				}
+				p.Positions[field] = append(p.Positions[field], offset+tok.Pos)
+			}
+			offset += len(tokens) + 1
+		}
+	}
+
+	sort.Strings(terms)
+	postings := make([]Posting, 0, len(terms))
+	for _, term := range terms {
+		postings = append(postings, *byTerm[term])
+	}
+	return postings
+}
+
+// Options page and filter a search. Tags restricts results to videos
+// carrying every listed tag.
+type Options struct {
+	Tags   []string
+	Limit  int
+	Offset int
+}
+
+type Hit struct {
+	VideoID    string
+	Title      string
+	Score      float64
+	Highlights []Highlight
+}
+
+// Highlight is a snippet of a field with the matched terms wrapped in
+// <mark> tags.
+type Highlight struct {
+	Field   string
+	Snippet string
+}
+
+type FacetCount struct {
+	Value string
+	Count int
+}
+
+// Results holds one page of hits. Total and TagFacets cover every match, not
+// only the page.
+type Results struct {
+	Total     int
+	Hits      []Hit
+	TagFacets []FacetCount
+}
+
+// Search returns the videos matching every term and phrase of query, best
+// first.
+func Search(ctx context.Context, store Store, query Query, opts Options) (*Results, error) {
+	results := &Results{Hits: []Hit{}, TagFacets: []FacetCount{}}
+	terms := query.AllTerms()
+	if len(terms) == 0 {
+		return results, nil
+	}
+
+	docCount, err := store.SearchDocumentCount(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every term must match, so candidates start from the first term's
+	// postings and are narrowed by each following term.
+	postings := make(map[string]map[string]*Posting, len(terms))
+	var candidates map[string]bool
+	idf := make(map[string]float64, len(terms))
+	for _, term := range terms {
+		found, err := store.SearchPostings(ctx, term)
+		if err != nil {
+			return nil, err
+		}
+		byVideo := make(map[string]*Posting, len(found))
+		for i := range found {
+			byVideo[found[i].VideoID] = &found[i]
+		}
+		postings[term] = byVideo
+		idf[term] = inverseDocumentFrequency(docCount, len(found))
+
+		next := make(map[string]bool)
+		for id := range byVideo {
+			if candidates == nil || candidates[id] {
+				next[id] = true
+			}
+		}
+		candidates = next
+		if len(candidates) == 0 {
+			return results, nil
+		}
+	}
+
+	type scored struct {
+		id    string
+		score float64
+	}
+	var matches []scored
+	tagCounts := make(map[string]int)
+	for id := range candidates {
+		first := postings[terms[0]][id]
+		if !hasTags(first.Tags, opts.Tags) {
+			continue
+		}
+		matched := true
+		for _, phrase := range query.Phrases {
+			if !matchesPhrase(postings, id, phrase) {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		var score float64
+		for _, term := range terms {
+			for field, positions := range postings[term][id].Positions {
+				tf := float64(len(positions))
+				score += idf[term] * fieldWeights[field] * tf * (k1 + 1) / (tf + k1)
+			}
+		}
+		matches = append(matches, scored{id: id, score: score})
+		for _, tag := range slices.Compact(slices.Sorted(slices.Values(first.Tags))) {
+			tagCounts[tag]++
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].id < matches[j].id
+	})
+	results.Total = len(matches)
+	results.TagFacets = facetCounts(tagCounts)
+
+	start := min(opts.Offset, len(matches))
+	end := len(matches)
+	if opts.Limit > 0 {
+		end = min(start+opts.Limit, len(matches))
+	}
+	for _, m := range matches[start:end] {
+		hit := Hit{VideoID: m.id, Score: math.Round(m.score*1000) / 1000}
+		doc, err := store.SearchDocument(ctx, m.id)
+		if err != nil {
+			return nil, err
+		}
+		if doc != nil {
+			hit.Title = doc.Title
+			hit.Highlights = highlights(doc, terms)
+		}
+		results.Hits = append(results.Hits, hit)
+	}
+	return results, nil
+}
+
+func inverseDocumentFrequency(docCount, docFreq int) float64 {
+	if docCount < docFreq {
+		docCount = docFreq
+	}
+	n, df := float64(docCount), float64(docFreq)
+	return math.Log(1 + (n-df+0.5)/(df+0.5))
+}
+
+// matchesPhrase reports whether the phrase's terms occur at consecutive
+// positions of one field.
+func matchesPhrase(postings map[string]map[string]*Posting, id string, phrase []string) bool {
+	first := postings[phrase[0]][id]
+	for field, starts := range first.Positions {
+		for _, start := range starts {
+			found := true
+			for offset, term := range phrase[1:] {
+				if !slices.Contains(postings[term][id].Positions[field], start+offset+1) {
+					found = false
+					break
+				}
+			}
+			if found {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasTags(tags, required []string) bool {
+	for _, r := range required {
+		if !slices.Contains(tags, r) {
+			return false
+		}
+	}
+	return true
+}
+
+func facetCounts(counts map[string]int) []FacetCount {
+	facets := make([]FacetCount, 0, len(counts))
+	for value, count := range counts {
+		facets = append(facets, FacetCount{Value: value, Count: count})
+	}
+	sort.Slice(facets, func(i, j int) bool {
+		if facets[i].Count != facets[j].Count {
+			return facets[i].Count > facets[j].Count
+		}
+		return facets[i].Value < facets[j].Value
+	})
+	return facets
+}
@@ -0,0 +1,151 @@
+package search
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryStore struct {
+	docs     map[string]*Document
+	postings map[string][]Posting
+}
+
+func newMemoryStore(docs ...*Document) *memoryStore {
+	s := &memoryStore{docs: map[string]*Document{}, postings: map[string][]Posting{}}
+	for _, doc := range docs {
+		s.docs[doc.VideoID] = doc
+		for _, p := range Postings(doc) {
+			s.postings[p.Term] = append(s.postings[p.Term], p)
+		}
+	}
+	return s
+}
+
+func (s *memoryStore) SearchPostings(ctx context.Context, term string) ([]Posting, error) {
+	return s.postings[term], nil
+}
+
+func (s *memoryStore) SearchDocument(ctx context.Context, videoID string) (*Document, error) {
+	return s.docs[videoID], nil
+}
+
+func (s *memoryStore) SearchDocumentCount(ctx context.Context) (int, error) {
+	return len(s.docs), nil
+}
+
+var testDocs = []*Document{
+	{
+		VideoID:    "soccer",
+		Title:      "Kids playing soccer",
+		Tags:       []string{"sports", "outdoor"},
+		Labels:     []string{"ball", "grass"},
+		Transcript: "Great pass! And the kids score at sunset.",
+	},
+	{
+		VideoID:     "sunset",
+		Title:       "Beach at sunset",
+		Description: "Waves and a soccer ball rolling on the sand.",
+		Tags:        []string{"outdoor", "travel"},
+	},
+	{
+		VideoID:    "cooking",
+		Title:      "Pasta night",
+		Tags:       []string{"food"},
+		Transcript: "Playing some music while the kids set the table.",
+	},
+}
+
+func TestSearchRanksTitleMatchesFirst(t *testing.T) {
+	results, err := Search(context.Background(), newMemoryStore(testDocs...), ParseQuery("soccer"), Options{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, results.Total)
+	assert.Equal(t, "soccer", results.Hits[0].VideoID)
+	assert.Equal(t, "sunset", results.Hits[1].VideoID)
+	assert.Equal(t, "Kids playing soccer", results.Hits[0].Title)
+}
+
+func TestSearchRequiresEveryTerm(t *testing.T) {
+	results, err := Search(context.Background(), newMemoryStore(testDocs...), ParseQuery("kids playing"), Options{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, results.Total)
+
+	results, err = Search(context.Background(), newMemoryStore(testDocs...), ParseQuery("kids pasta soccer"), Options{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, results.Total)
+	assert.Empty(t, results.Hits)
+}
+
+func TestSearchPhrase(t *testing.T) {
+	results, err := Search(context.Background(), newMemoryStore(testDocs...), ParseQuery(`"playing soccer"`), Options{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, results.Total)
+	assert.Equal(t, "soccer", results.Hits[0].VideoID)
+
+	// Both words appear in two videos, but never in this order.
+	results, err = Search(context.Background(), newMemoryStore(testDocs...), ParseQuery(`"playing kids"`), Options{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, results.Total)
+}
+
+func TestSearchPhraseDoesNotSpanTags(t *testing.T) {
+	results, err := Search(context.Background(), newMemoryStore(testDocs...), ParseQuery(`"sports outdoor"`), Options{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, results.Total)
+}
+
+func TestSearchTagFilterAndFacets(t *testing.T) {
+	store := newMemoryStore(testDocs...)
+
+	results, err := Search(context.Background(), store, ParseQuery("sunset"), Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, []FacetCount{{"outdoor", 2}, {"sports", 1}, {"travel", 1}}, results.TagFacets)
+
+	results, err = Search(context.Background(), store, ParseQuery("sunset"), Options{Tags: []string{"travel"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, results.Total)
+	assert.Equal(t, "sunset", results.Hits[0].VideoID)
+}
+
+func TestSearchPaging(t *testing.T) {
+	results, err := Search(context.Background(), newMemoryStore(testDocs...), ParseQuery("sunset"), Options{Limit: 1, Offset: 1})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, results.Total)
+	assert.Len(t, results.Hits, 1)
+}
+
+func TestSearchHighlights(t *testing.T) {
+	results, err := Search(context.Background(), newMemoryStore(testDocs...), ParseQuery("kids sunset"), Options{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Highlight{
+		{Field: FieldTitle, Snippet: "<mark>Kids</mark> playing soccer"},
+		{Field: FieldTranscript, Snippet: "Great pass! And the <mark>kids</mark> score at <mark>sunset</mark>."},
+	}, results.Hits[0].Highlights)
+}
+
+func TestParseQuery(t *testing.T) {
+	q := ParseQuery(`Soccer "at Sunset" kids "ball`)
+
+	assert.Equal(t, []string{"soccer", "kids", "ball"}, q.Terms)
+	assert.Equal(t, [][]string{{"at", "sunset"}}, q.Phrases)
+	assert.Equal(t, []string{"soccer", "kids", "ball", "at", "sunset"}, q.AllTerms())
+	assert.True(t, ParseQuery(` "" !! `).Empty())
+}
+
+func TestSnippetTruncatesAndEscapes(t *testing.T) {
+	text := "one two three four five six seven eight nine ten <b>match</b> a b c d e f g h i j k l m n o p q r s"
+
+	snippet, ok := Snippet(text, map[string]bool{"match": true})
+
+	assert.True(t, ok)
+	assert.Equal(t, "…four five six seven eight nine ten &lt;b&gt;<mark>match</mark>&lt;/b&gt; a b c d e f g h i j k l m n o…", snippet)
+}
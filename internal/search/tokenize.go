@@ -0,0 +1,90 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Token is a normalized term and where it was found. Pos counts tokens and
+// Start and End are byte offsets into the original text.
+type Token struct {
+	Term  string
+	Pos   int
+	Start int
+	End   int
+}
+
+// Tokenize splits text into lowercase runs of letters and digits.
+func Tokenize(text string) []Token {
+	var tokens []Token
+	start := -1
+	for i, r := range text {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if word && start < 0 {
+			start = i
+		}
+		if !word && start >= 0 {
+			tokens = append(tokens, newToken(text, start, i, len(tokens)))
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, newToken(text, start, len(text), len(tokens)))
+	}
+	return tokens
+}
+
+func newToken(text string, start, end, pos int) Token {
+	return Token{Term: strings.ToLower(text[start:end]), Pos: pos, Start: start, End: end}
+}
+
+// Query is a parsed search string. Bare words are Terms and double-quoted
+// runs are Phrases; a video matches when it contains every term and every
+// phrase.
+type Query struct {
+	Terms   []string
+	Phrases [][]string
+}
+
+func ParseQuery(q string) Query {
+	var query Query
+	for i, part := range strings.Split(q, `"`) {
+		var terms []string
+		for _, tok := range Tokenize(part) {
+			terms = append(terms, tok.Term)
+		}
+		// Odd parts sit between quotes. An unclosed quote runs to the end.
+		if i%2 == 1 && len(terms) > 1 {
+			query.Phrases = append(query.Phrases, terms)
+			continue
+		}
+		query.Terms = append(query.Terms, terms...)
+	}
+	return query
+}
+
+// AllTerms returns the distinct terms of the query, including those in
+// phrases, in the order they appear.
+func (q Query) AllTerms() []string {
+	seen := make(map[string]bool)
+	var terms []string
+	add := func(term string) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	for _, term := range q.Terms {
+		add(term)
+	}
+	for _, phrase := range q.Phrases {
+		for _, term := range phrase {
+			add(term)
+		}
+	}
+	return terms
+}
+
+func (q Query) Empty() bool {
+	return len(q.AllTerms()) == 0
+}
@@ -0,0 +1,207 @@
+// Package semantic indexes embeddings of video scenes for similarity search
+// by meaning. Each scene's keyframe embedding is stored in an HNSW graph
+// alongside the time range it covers, and the whole index is persisted as a
+// single S3 object that the worker updates and the API reads.
+package semantic
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/ryanschneiderman/video-api/internal/hnsw"
+)
+
+const (
+	// candidatesPerVideo is how many scenes are fetched from the graph for
+	// each video asked for, since one video often has several close scenes.
+	candidatesPerVideo = 8
+	maxCandidates      = 512
+	// maxRanges caps the time ranges returned for one video.
+	maxRanges = 5
+)
+
+// Segment is an indexed span of a video.
+type Segment struct {
+	VideoID string
+	Start   float64
+	End     float64
+}
+
+// Options limit a search. Limit is the number of videos to return; scenes
+// scoring below MinScore are ignored.
+type Options struct {
+	Limit    int
+	MinScore float64
+}
+
+// Range is a span of a matching video and its cosine similarity to the
+// query.
+type Range struct {
+	Start float64
+	End   float64
+	Score float64
+}
+
+// Match is a video with scenes close to the query. Score is that of its best
+// range; ranges are ordered best first.
+type Match struct {
+	VideoID string
+	Score   float64
+	Ranges  []Range
+}
+
+// Index maps scene embeddings to the video spans they came from. Like the
+// graph underneath it, it is safe for concurrent searches but callers must
+// serialize changes.
+type Index struct {
+	graph    *hnsw.Index
+	segments map[string]Segment
+}
+
+func NewIndex() *Index {
+	return &Index{graph: hnsw.New(hnsw.Config{}), segments: make(map[string]Segment)}
+}
+
+func segmentID(videoID string, i int) string {
+	return fmt.Sprintf("%s#%04d", videoID, i)
+}
+
+// Len is the number of indexed segments.
+func (idx *Index) Len() int {
+	return len(idx.segments)
+}
+
+// PutVideo replaces the indexed segments of a video. vectors[i] is the
+// embedding of segments[i].
+func (idx *Index) PutVideo(videoID string, segments []Segment, vectors [][]float32) error {
+	if len(segments) != len(vectors) {
+		return fmt.Errorf("got %d segments but %d vectors", len(segments), len(vectors))
+	}
+
+	idx.RemoveVideo(videoID)
+	for i, seg := range segments {
+		id := segmentID(videoID, i)
+		seg.VideoID = videoID
+		if err := idx.graph.Add(id, vectors[i]); err != nil {
+			return fmt.Errorf("failed to index segment %d of %s: %w", i, videoID, err)
+		}
+		idx.segments[id] = seg
+	}
+	return nil
+}
+
+// RemoveVideo drops every segment of a video and returns how many there
+// were.
+func (idx *Index) RemoveVideo(videoID string) int {
+	prefix := videoID + "#"
+	removed := 0
+	for id := range idx.segments {
+		if strings.HasPrefix(id, prefix) {
+			idx.graph.Remove(id)
+			delete(idx.segments, id)
+			removed++
+		}
+	}
+	return removed
+}
+
+// compact rebuilds the graph once removed segments make up a quarter of it,
+// before they start to slow searches down.
+func (idx *Index) compact() {
+	if idx.graph.Deleted() > 0 && idx.graph.Deleted()*3 >= idx.graph.Len() {
+		idx.graph.Compact()
+	}
+}
+
+// Search returns the videos whose scenes are closest to the query vector,
+// best first.
+func (idx *Index) Search(query []float32, opts Options) ([]Match, error) {
+	if opts.Limit <= 0 || idx.Len() == 0 {
+		return []Match{}, nil
+	}
+
+	found, err := idx.graph.Search(query, min(opts.Limit*candidatesPerVideo, maxCandidates))
+	if err != nil {
+		return nil, err
+	}
+
+	byVideo := make(map[string]*Match)
+	var order []string
+	for _, r := range found {
+		if r.Score < opts.MinScore {
+			continue
+		}
+		seg, ok := idx.segments[r.ID]
+		if !ok {
+			continue
+		}
+		m, ok := byVideo[seg.VideoID]
+		if !ok {
+			m = &Match{VideoID: seg.VideoID, Score: round(r.Score)}
+			byVideo[seg.VideoID] = m
+			order = append(order, seg.VideoID)
+		}
+		if len(m.Ranges) < maxRanges {
+			m.Ranges = append(m.Ranges, Range{Start: seg.Start, End: seg.End, Score: round(r.Score)})
+		}
+	}
+
+	matches := make([]Match, 0, len(order))
+	for _, id := range order {
+		matches = append(matches, *byVideo[id])
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if len(matches) > opts.Limit {
+		matches = matches[:opts.Limit]
+	}
+	return matches, nil
+}
+
+func round(score float64) float64 {
+	return math.Round(score*1000) / 1000
+}
+
+// formatVersion is bumped whenever the encoded layout changes incompatibly.
+const formatVersion = 1
+
+type snapshot struct {
+	Version  int
+	Segments map[string]Segment
+	Graph    []byte
+}
+
+// Save writes the index for Load.
+func (idx *Index) Save(w io.Writer) error {
+	var graph bytes.Buffer
+	if err := idx.graph.Save(&graph); err != nil {
+		return err
+	}
+	snap := snapshot{Version: formatVersion, Segments: idx.segments, Graph: graph.Bytes()}
+	if err := gob.NewEncoder(w).Encode(&snap); err != nil {
+		return fmt.Errorf("failed to encode semantic index: %w", err)
+	}
+	return nil
+}
+
+func Load(r io.Reader) (*Index, error) {
+	var snap snapshot
+	if err := gob.NewDecoder(r).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("failed to decode semantic index: %w", err)
+	}
+	if snap.Version != formatVersion {
+		return nil, fmt.Errorf("unsupported semantic index version %d", snap.Version)
+	}
+	graph, err := hnsw.Load(bytes.NewReader(snap.Graph))
+	if err != nil {
+		return nil, err
+	}
+	if snap.Segments == nil {
+		snap.Segments = make(map[string]Segment)
+	}
+	return &Index{graph: graph, segments: snap.Segments}, nil
+}
@@ -0,0 +1,83 @@
+package semantic
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ryanschneiderman/video-api/internal/embedder"
+)
+
+const defaultRefreshInterval = 30 * time.Second
+
+// Searcher answers text queries from cached copies of the tenants' stored
+// indexes, checking for a newer copy at most once per RefreshInterval.
+type Searcher struct {
+	Store           *Store
+	Embedder        embedder.Embedder
+	RefreshInterval time.Duration
+
+	mu      sync.Mutex
+	tenants map[string]*cachedIndex
+}
+
+type cachedIndex struct {
+	mu      sync.Mutex
+	index   *Index
+	etag    string
+	checked time.Time
+}
+
+func NewSearcher(store *Store, e embedder.Embedder) *Searcher {
+	return &Searcher{Store: store, Embedder: e, RefreshInterval: defaultRefreshInterval}
+}
+
+// Search embeds the text and returns the tenant's videos with the closest
+// scenes.
+func (s *Searcher) Search(ctx context.Context, tenantID string, text string, opts Options) ([]Match, error) {
+	idx, err := s.current(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	vector, err := s.Embedder.EmbedText(ctx, text)
+	if err != nil {
+		return nil, fmt.Errorf("%s embedder failed: %w", s.Embedder.Name(), err)
+	}
+	return idx.Search(vector, opts)
+}
+
+func (s *Searcher) current(ctx context.Context, tenantID string) (*Index, error) {
+	s.mu.Lock()
+	if s.tenants == nil {
+		s.tenants = make(map[string]*cachedIndex)
+	}
+	cached, ok := s.tenants[tenantID]
+	if !ok {
+		cached = &cachedIndex{}
+		s.tenants[tenantID] = cached
+	}
+	s.mu.Unlock()
+
+	cached.mu.Lock()
+	defer cached.mu.Unlock()
+
+	if cached.index != nil && time.Since(cached.checked) < s.RefreshInterval {
+		return cached.index, nil
+	}
+	idx, etag, changed, err := s.Store.loadIfChanged(ctx, tenantID, cached.etag)
+	if err != nil {
+		// A stale index beats failing the search.
+		if cached.index != nil {
+			log.Printf("Using cached semantic index for tenant %s: %v", tenantID, err)
+			return cached.index, nil
+		}
+		return nil, err
+	}
+	if changed || cached.index == nil {
+		cached.index, cached.etag = idx, etag
+	}
+	cached.checked = time.Now()
+	return cached.index, nil
+}
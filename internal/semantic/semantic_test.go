@@ -0,0 +1,246 @@
+package semantic
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/ryanschneiderman/video-api/internal/embedder"
+	"github.com/stretchr/testify/assert"
+)
+
+type statusError int
+
+func (e statusError) Error() string       { return fmt.Sprintf("status %d", int(e)) }
+func (e statusError) HTTPStatusCode() int { return int(e) }
+
+// memoryObjectStore mimics S3's conditional reads and writes for one bucket.
+type memoryObjectStore struct {
+	mu       sync.Mutex
+	objects  map[string][]byte
+	versions map[string]int
+	gets     int
+	// beforePut runs before each write is checked, letting a test sneak in
+	// a concurrent write.
+	beforePut func()
+}
+
+func newMemoryObjectStore() *memoryObjectStore {
+	return &memoryObjectStore{objects: map[string][]byte{}, versions: map[string]int{}}
+}
+
+func (m *memoryObjectStore) etag(key string) string {
+	return fmt.Sprintf(`"v%d"`, m.versions[key])
+}
+
+func (m *memoryObjectStore) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.gets++
+	key := aws.ToString(params.Key)
+	data, ok := m.objects[key]
+	if !ok {
+		return nil, statusError(http.StatusNotFound)
+	}
+	if aws.ToString(params.IfNoneMatch) == m.etag(key) {
+		return nil, statusError(http.StatusNotModified)
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data)), ETag: aws.String(m.etag(key))}, nil
+}
+
+func (m *memoryObjectStore) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	if m.beforePut != nil {
+		hook := m.beforePut
+		m.beforePut = nil
+		hook()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := aws.ToString(params.Key)
+	_, exists := m.objects[key]
+	if params.IfNoneMatch != nil && exists {
+		return nil, statusError(http.StatusPreconditionFailed)
+	}
+	if params.IfMatch != nil && (!exists || aws.ToString(params.IfMatch) != m.etag(key)) {
+		return nil, statusError(http.StatusPreconditionFailed)
+	}
+	data, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	m.objects[key] = data
+	m.versions[key]++
+	return &s3.PutObjectOutput{ETag: aws.String(m.etag(key))}, nil
+}
+
+func embed(t *testing.T, text string) []float32 {
+	v, err := (&embedder.Fake{}).EmbedText(context.Background(), text)
+	assert.NoError(t, err)
+	return v
+}
+
+func TestIndexSearchGroupsByVideo(t *testing.T) {
+	idx := NewIndex()
+	assert.NoError(t, idx.PutVideo("beach", []Segment{
+		{Start: 0, End: 4},
+		{Start: 4, End: 9},
+		{Start: 9, End: 12},
+	}, [][]float32{
+		embed(t, "dog running on the beach"),
+		embed(t, "waves on the beach at sunset"),
+		embed(t, "office meeting"),
+	}))
+	assert.NoError(t, idx.PutVideo("city", []Segment{{Start: 0, End: 5}}, [][]float32{
+		embed(t, "traffic in the city"),
+	}))
+	assert.Equal(t, 4, idx.Len())
+
+	matches, err := idx.Search(embed(t, "dog on the beach"), Options{Limit: 10, MinScore: 0.2})
+	assert.NoError(t, err)
+	if !assert.NotEmpty(t, matches) {
+		return
+	}
+	assert.Equal(t, "beach", matches[0].VideoID)
+	assert.Equal(t, Range{Start: 0, End: 4, Score: matches[0].Score}, matches[0].Ranges[0])
+	for i := 1; i < len(matches[0].Ranges); i++ {
+		assert.LessOrEqual(t, matches[0].Ranges[i].Score, matches[0].Ranges[i-1].Score)
+	}
+	for _, m := range matches {
+		for _, r := range m.Ranges {
+			assert.GreaterOrEqual(t, r.Score, 0.2)
+		}
+	}
+
+	limited, err := idx.Search(embed(t, "beach city"), Options{Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, limited, 1)
+}
+
+func TestIndexPutVideoReplacesSegments(t *testing.T) {
+	idx := NewIndex()
+	assert.NoError(t, idx.PutVideo("v1", []Segment{{Start: 0, End: 1}, {Start: 1, End: 2}}, [][]float32{
+		embed(t, "cat"), embed(t, "dog"),
+	}))
+	assert.NoError(t, idx.PutVideo("v1", []Segment{{Start: 0, End: 2}}, [][]float32{embed(t, "bird")}))
+	assert.Equal(t, 1, idx.Len())
+
+	matches, err := idx.Search(embed(t, "bird"), Options{Limit: 5})
+	assert.NoError(t, err)
+	if assert.Len(t, matches, 1) {
+		assert.Equal(t, []Range{{Start: 0, End: 2, Score: 1}}, matches[0].Ranges)
+	}
+
+	assert.Equal(t, 1, idx.RemoveVideo("v1"))
+	assert.Equal(t, 0, idx.Len())
+
+	err = idx.PutVideo("v2", []Segment{{}}, nil)
+	assert.Error(t, err)
+}
+
+func TestStoreUpdateCreatesAndRetriesOnConflict(t *testing.T) {
+	objects := newMemoryObjectStore()
+	store := &Store{Client: objects, Bucket: "bucket", Embedder: "fake"}
+	ctx := context.Background()
+
+	err := store.Update(ctx, "acme", func(idx *Index) error {
+		return idx.PutVideo("v1", []Segment{{Start: 0, End: 3}}, [][]float32{embed(t, "cat")})
+	})
+	assert.NoError(t, err)
+
+	// Another worker indexes v2 between our read and write; our update must
+	// be reapplied on top of it rather than dropping it.
+	objects.beforePut = func() {
+		assert.NoError(t, store.Update(ctx, "acme", func(idx *Index) error {
+			return idx.PutVideo("v2", []Segment{{Start: 0, End: 3}}, [][]float32{embed(t, "dog")})
+		}))
+	}
+	calls := 0
+	err = store.Update(ctx, "acme", func(idx *Index) error {
+		calls++
+		return idx.PutVideo("v3", []Segment{{Start: 0, End: 3}}, [][]float32{embed(t, "bird")})
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	idx, _, err := store.Load(ctx, "acme")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, idx.Len())
+	assert.Contains(t, objects.objects, "tenants/acme/index/semantic/fake.idx")
+
+	// Other tenants don't see it.
+	idx, _, err = store.Load(ctx, "globex")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, idx.Len())
+}
+
+func TestStoreLoadMissingIndex(t *testing.T) {
+	store := &Store{Client: newMemoryObjectStore(), Bucket: "bucket", Embedder: "fake"}
+	idx, etag, err := store.Load(context.Background(), "acme")
+	assert.NoError(t, err)
+	assert.Equal(t, "", etag)
+	assert.Equal(t, 0, idx.Len())
+}
+
+func TestSearcherCachesIndex(t *testing.T) {
+	objects := newMemoryObjectStore()
+	fake := &embedder.Fake{}
+	store := NewStore(objects, "bucket", fake)
+	ctx := context.Background()
+	assert.NoError(t, store.Update(ctx, "acme", func(idx *Index) error {
+		return idx.PutVideo("v1", []Segment{{Start: 0, End: 3}}, [][]float32{embed(t, "cat on a sofa")})
+	}))
+
+	searcher := NewSearcher(store, fake)
+	matches, err := searcher.Search(ctx, "acme", "cat sofa", Options{Limit: 5})
+	assert.NoError(t, err)
+	if assert.Len(t, matches, 1) {
+		assert.Equal(t, "v1", matches[0].VideoID)
+	}
+	matches, err = searcher.Search(ctx, "globex", "cat sofa", Options{Limit: 5})
+	assert.NoError(t, err)
+	assert.Empty(t, matches)
+
+	// Within the refresh interval the cached copy is used.
+	assert.NoError(t, store.Update(ctx, "acme", func(idx *Index) error {
+		return idx.PutVideo("v2", []Segment{{Start: 0, End: 3}}, [][]float32{embed(t, "cat on a chair")})
+	}))
+	gets := objects.gets
+	matches, err = searcher.Search(ctx, "acme", "cat", Options{Limit: 5})
+	assert.NoError(t, err)
+	assert.Len(t, matches, 1)
+	assert.Equal(t, gets, objects.gets)
+
+	searcher.RefreshInterval = 0
+	matches, err = searcher.Search(ctx, "acme", "cat", Options{Limit: 5})
+	assert.NoError(t, err)
+	assert.Len(t, matches, 2)
+
+	// An unchanged index isn't downloaded again.
+	matches, err = searcher.Search(ctx, "acme", "cat", Options{Limit: 5})
+	assert.NoError(t, err)
+	assert.Len(t, matches, 2)
+}
+
+func TestSaveLoadRoundTrip(t *testing.T) {
+	idx := NewIndex()
+	assert.NoError(t, idx.PutVideo("v1", []Segment{{Start: 1, End: 2}}, [][]float32{embed(t, "cat")}))
+
+	var buf bytes.Buffer
+	assert.NoError(t, idx.Save(&buf))
+	loaded, err := Load(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, loaded.Len())
+
+	matches, err := loaded.Search(embed(t, "cat"), Options{Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []Match{{VideoID: "v1", Score: 1, Ranges: []Range{{Start: 1, End: 2, Score: 1}}}}, matches)
+}
@@ -0,0 +1,139 @@
+package semantic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/ryanschneiderman/video-api/internal/embedder"
+	"github.com/ryanschneiderman/video-api/internal/tenant"
+)
+
+// maxUpdateAttempts bounds the retries when another worker writes the index
+// between our read and write.
+const maxUpdateAttempts = 5
+
+var ErrConflict = errors.New("semantic index was modified concurrently")
+
+// ObjectStore is the part of the S3 client the store uses.
+type ObjectStore interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// Store keeps each tenant's index in one S3 object. Writes are conditional on
+// the ETag that was read, so concurrent workers retry instead of overwriting
+// each other's segments.
+type Store struct {
+	Client   ObjectStore
+	Bucket   string
+	Embedder string
+}
+
+// IndexKey is where a tenant's index of vectors from the named embedder
+// lives. Vectors from different embedders aren't comparable, so switching
+// embedder starts a new index rather than mixing them.
+func IndexKey(tenantID, embedderName string) string {
+	return tenant.ObjectKey(tenantID, fmt.Sprintf("index/semantic/%s.idx", embedderName))
+}
+
+func NewStore(client ObjectStore, bucket string, e embedder.Embedder) *Store {
+	return &Store{Client: client, Bucket: bucket, Embedder: e.Name()}
+}
+
+// Load returns the tenant's stored index and its ETag, or an empty index and
+// an empty ETag if none has been written yet.
+func (s *Store) Load(ctx context.Context, tenantID string) (*Index, string, error) {
+	idx, etag, _, err := s.loadIfChanged(ctx, tenantID, "")
+	return idx, etag, err
+}
+
+// loadIfChanged skips the download when the object still has the given
+// ETag, returning changed false.
+func (s *Store) loadIfChanged(ctx context.Context, tenantID, etag string) (*Index, string, bool, error) {
+	input := &s3.GetObjectInput{Bucket: aws.String(s.Bucket), Key: aws.String(IndexKey(tenantID, s.Embedder))}
+	if etag != "" {
+		input.IfNoneMatch = aws.String(etag)
+	}
+
+	output, err := s.Client.GetObject(ctx, input)
+	switch status := httpStatus(err); {
+	case err == nil:
+	case status == http.StatusNotModified:
+		return nil, etag, false, nil
+	case status == http.StatusNotFound:
+		return NewIndex(), "", etag != "", nil
+	default:
+		return nil, "", false, fmt.Errorf("failed to get semantic index: %w", err)
+	}
+	defer output.Body.Close()
+
+	idx, err := Load(output.Body)
+	if err != nil {
+		return nil, "", false, err
+	}
+	return idx, aws.ToString(output.ETag), true, nil
+}
+
+// save writes the index if the stored object still has the given ETag, or
+// doesn't exist when etag is empty.
+func (s *Store) save(ctx context.Context, tenantID string, idx *Index, etag string) error {
+	var buf bytes.Buffer
+	if err := idx.Save(&buf); err != nil {
+		return err
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(s.Bucket),
+		Key:         aws.String(IndexKey(tenantID, s.Embedder)),
+		Body:        bytes.NewReader(buf.Bytes()),
+		ContentType: aws.String("application/octet-stream"),
+	}
+	if etag == "" {
+		input.IfNoneMatch = aws.String("*")
+	} else {
+		input.IfMatch = aws.String(etag)
+	}
+
+	if _, err := s.Client.PutObject(ctx, input); err != nil {
+		if status := httpStatus(err); status == http.StatusPreconditionFailed || status == http.StatusConflict {
+			return ErrConflict
+		}
+		return fmt.Errorf("failed to put semantic index: %w", err)
+	}
+	return nil
+}
+
+// Update applies fn to the tenant's latest index and writes the result,
+// starting over from a fresh read if someone else wrote in the meantime.
+func (s *Store) Update(ctx context.Context, tenantID string, fn func(*Index) error) error {
+	for attempt := 1; ; attempt++ {
+		idx, etag, err := s.Load(ctx, tenantID)
+		if err != nil {
+			return err
+		}
+		if err := fn(idx); err != nil {
+			return err
+		}
+		idx.compact()
+
+		err = s.save(ctx, tenantID, idx, etag)
+		if !errors.Is(err, ErrConflict) || attempt == maxUpdateAttempts {
+			return err
+		}
+		log.Printf("Semantic index changed while updating, retrying (attempt %d)", attempt)
+	}
+}
+
+func httpStatus(err error) int {
+	var respErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &respErr) {
+		return respErr.HTTPStatusCode()
+	}
+	return 0
+}
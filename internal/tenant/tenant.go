@@ -0,0 +1,36 @@
+// Package tenant holds the conventions that keep tenants' data apart.
+package tenant
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+var ErrInvalidID = errors.New("invalid tenant ID")
+
+// Tenant IDs end up in S3 keys and DynamoDB partition keys, so they are kept
+// to a safe alphabet without the '#' separator.
+var idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+func ValidateID(id string) error {
+	if !idPattern.MatchString(id) {
+		return fmt.Errorf("%w %q: use 1-63 lowercase letters, digits and dashes", ErrInvalidID, id)
+	}
+	return nil
+}
+
+// ObjectKey scopes an S3 key to a tenant. Videos uploaded before tenants
+// existed have no tenant and keep their unscoped keys.
+func ObjectKey(tenantID, key string) string {
+	if tenantID == "" {
+		return key
+	}
+	return fmt.Sprintf("tenants/%s/%s", tenantID, key)
+}
+
+// ProcessedKey is the S3 key of a file the worker derived from a video. With
+// an empty name it is the prefix all of the video's files share.
+func ProcessedKey(tenantID, videoID, name string) string {
+	return ObjectKey(tenantID, fmt.Sprintf("processed/%s/%s", videoID, name))
+}
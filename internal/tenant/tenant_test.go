@@ -0,0 +1,27 @@
+package tenant
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateID(t *testing.T) {
+	for _, id := range []string{"acme", "acme-corp", "t1"} {
+		assert.NoError(t, ValidateID(id), id)
+	}
+	for _, id := range []string{"", "Acme", "acme#1", "-acme", "a/b", strings.Repeat("a", 64)} {
+		assert.ErrorIs(t, ValidateID(id), ErrInvalidID, id)
+	}
+}
+
+func TestObjectKey(t *testing.T) {
+	assert.Equal(t, "tenants/acme/processed/v1/probe.json", ObjectKey("acme", "processed/v1/probe.json"))
+	assert.Equal(t, "processed/v1/probe.json", ObjectKey("", "processed/v1/probe.json"))
+}
+
+func TestProcessedKey(t *testing.T) {
+	assert.Equal(t, "tenants/acme/processed/v1/probe.json", ProcessedKey("acme", "v1", "probe.json"))
+	assert.Equal(t, "processed/v1/", ProcessedKey("", "v1", ""))
+}
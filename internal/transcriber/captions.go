@@ -0,0 +1,40 @@
+package transcriber
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WebVTT renders segments as a WebVTT caption file.
+func WebVTT(segments []Segment) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+	for _, seg := range segments {
+		fmt.Fprintf(&b, "\n%s --> %s\n%s\n", timestamp(seg.Start, "."), timestamp(seg.End, "."), strings.TrimSpace(seg.Text))
+	}
+	return b.String()
+}
+
+// SRT renders segments as a SubRip caption file.
+func SRT(segments []Segment) string {
+	var b strings.Builder
+	for i, seg := range segments {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n", i+1, timestamp(seg.Start, ","), timestamp(seg.End, ","), strings.TrimSpace(seg.Text))
+	}
+	return b.String()
+}
+
+// timestamp formats seconds as HH:MM:SS followed by sep and milliseconds.
+func timestamp(seconds float64, sep string) string {
+	if seconds < 0 {
+		seconds = 0
+	}
+	ms := int64(seconds*1000 + 0.5)
+	h := ms / 3_600_000
+	m := ms / 60_000 % 60
+	s := ms / 1000 % 60
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", h, m, s, sep, ms%1000)
+}
@@ -0,0 +1,36 @@
+package transcriber
+
+import "context"
+
+func init() {
+	Register("fake", func(cfg Config) (Transcriber, error) {
+		return &Fake{}, nil
+	})
+}
+
+// Fake returns the same canned transcript for every file.
+type Fake struct {
+	// Transcript overrides the canned transcript when set.
+	Transcript *Transcript
+}
+
+func (f *Fake) Name() string { return "fake" }
+
+func (f *Fake) Transcribe(ctx context.Context, audioPath string) (*Transcript, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if f.Transcript != nil {
+		transcript := *f.Transcript
+		return &transcript, nil
+	}
+
+	return &Transcript{
+		Transcriber: f.Name(),
+		Language:    "en",
+		Segments: []Segment{
+			{Start: 0, End: 2.5, Text: "Welcome back to the channel."},
+			{Start: 2.5, End: 6, Text: "Today we are heading outside for some sports."},
+		},
+	}, nil
+}
@@ -0,0 +1,84 @@
+package transcriber
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+const defaultHTTPTimeout = 10 * time.Minute
+
+func init() {
+	Register("http", func(cfg Config) (Transcriber, error) {
+		return NewHTTPTranscriber(cfg)
+	})
+}
+
+// HTTPTranscriber posts WAV audio to a speech-to-text endpoint and expects a
+// JSON Transcript in return.
+type HTTPTranscriber struct {
+	Endpoint string
+	APIKey   string
+	Client   *http.Client
+}
+
+func NewHTTPTranscriber(cfg Config) (*HTTPTranscriber, error) {
+	if cfg.Endpoint == "" {
+		return nil, fmt.Errorf("%w: http transcriber requires an endpoint", ErrInvalidConfig)
+	}
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = defaultHTTPTimeout
+	}
+
+	return &HTTPTranscriber{
+		Endpoint: cfg.Endpoint,
+		APIKey:   cfg.APIKey,
+		Client:   &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (t *HTTPTranscriber) Name() string { return "http" }
+
+func (t *HTTPTranscriber) Transcribe(ctx context.Context, audioPath string) (*Transcript, error) {
+	file, err := os.Open(audioPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audio: %w", err)
+	}
+	defer file.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.Endpoint, file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build transcriber request: %w", err)
+	}
+	req.Header.Set("Content-Type", "audio/wav")
+	req.Header.Set("Accept", "application/json")
+	if t.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+t.APIKey)
+	}
+
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("transcriber request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("transcriber returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var transcript Transcript
+	if err := json.NewDecoder(resp.Body).Decode(&transcript); err != nil {
+		return nil, fmt.Errorf("failed to decode transcriber response: %w", err)
+	}
+	if transcript.Transcriber == "" {
+		transcript.Transcriber = t.Name()
+	}
+
+	return &transcript, nil
+}
@@ -0,0 +1,85 @@
+package transcriber
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnknownTranscriber = errors.New("unknown transcriber")
+	ErrInvalidConfig      = errors.New("invalid transcriber config")
+)
+
+// Segment is a span of recognized speech. Start and End are offsets into the
+// audio in seconds.
+type Segment struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+type Transcript struct {
+	Transcriber string    `json:"transcriber"`
+	Language    string    `json:"language"`
+	Segments    []Segment `json:"segments"`
+}
+
+// Transcriber turns an audio file extracted from a video into time-coded
+// text.
+type Transcriber interface {
+	Name() string
+	Transcribe(ctx context.Context, audioPath string) (*Transcript, error)
+}
+
+type Config struct {
+	Name     string
+	Endpoint string
+	APIKey   string
+	Timeout  time.Duration
+}
+
+type Factory func(cfg Config) (Transcriber, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register makes a transcriber implementation available to New under name.
+// It panics if name is already taken.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("transcriber %q registered twice", name))
+	}
+	registry[name] = factory
+}
+
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func New(cfg Config) (Transcriber, error) {
+	registryMu.RLock()
+	factory, ok := registry[cfg.Name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %q (registered: %v)", ErrUnknownTranscriber, cfg.Name, Registered())
+	}
+	return factory(cfg)
+}
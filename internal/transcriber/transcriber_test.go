@@ -0,0 +1,47 @@
+package transcriber
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testSegments = []Segment{
+	{Start: 0, End: 2.5, Text: "Welcome back."},
+	{Start: 3661.25, End: 3663, Text: " An hour in. "},
+}
+
+func TestWebVTT(t *testing.T) {
+	expected := "WEBVTT\n" +
+		"\n00:00:00.000 --> 00:00:02.500\nWelcome back.\n" +
+		"\n01:01:01.250 --> 01:01:03.000\nAn hour in.\n"
+	assert.Equal(t, expected, WebVTT(testSegments))
+}
+
+func TestSRT(t *testing.T) {
+	expected := "1\n00:00:00,000 --> 00:00:02,500\nWelcome back.\n" +
+		"\n2\n01:01:01,250 --> 01:01:03,000\nAn hour in.\n"
+	assert.Equal(t, expected, SRT(testSegments))
+}
+
+func TestEmptyCaptions(t *testing.T) {
+	assert.Equal(t, "WEBVTT\n", WebVTT(nil))
+	assert.Equal(t, "", SRT(nil))
+}
+
+func TestNewUsesRegistry(t *testing.T) {
+	tr, err := New(Config{Name: "fake"})
+	assert.NoError(t, err)
+
+	transcript, err := tr.Transcribe(context.Background(), "audio.wav")
+	assert.NoError(t, err)
+	assert.Equal(t, "en", transcript.Language)
+	assert.NotEmpty(t, transcript.Segments)
+
+	_, err = New(Config{Name: "whisper-local"})
+	assert.ErrorIs(t, err, ErrUnknownTranscriber)
+
+	_, err = New(Config{Name: "http"})
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+}
@@ -0,0 +1,219 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/fetch"
+)
+
+const (
+	defaultMaxAttempts = 5
+	defaultBackoff     = 30 * time.Second
+	defaultTimeout     = 10 * time.Second
+	defaultRetention   = 30 * 24 * time.Hour
+
+	// maxResponseBytes of a receiver's response are read, so the connection
+	// can be reused, and the rest is dropped.
+	maxResponseBytes = 64 << 10
+)
+
+// Config controls retries and the delivery log. Attempts are spaced by
+// Backoff, doubling each time. Zero values get the defaults.
+type Config struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	Timeout     time.Duration
+	// Retention is how long deliveries stay in the log.
+	Retention time.Duration
+}
+
+// Dispatcher sends events to the webhooks subscribed to them. Deliveries run
+// in the background of the process that published the event; a delivery
+// cut short by a restart stays pending in the log and can be replayed.
+type Dispatcher struct {
+	Store  Store
+	Client *http.Client
+	Config Config
+
+	wg    sync.WaitGroup
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+func NewDispatcher(store Store, cfg Config) *Dispatcher {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultMaxAttempts
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = defaultBackoff
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = defaultRetention
+	}
+	return &Dispatcher{
+		Store: store,
+		Client: &http.Client{
+			Timeout: cfg.Timeout,
+			// Receivers' URLs come from tenants, so deliveries can't reach
+			// the cluster's or the cloud's internal addresses.
+			Transport: fetch.NewTransport(fetch.IsPublic),
+			// A redirect is reported as a failed attempt rather than
+			// followed to wherever it points.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		Config: cfg,
+		now:    time.Now,
+		sleep:  time.Sleep,
+	}
+}
+
+// Publish records a pending delivery of event for each of its tenant's
+// webhooks subscribed to it, then sends them in the background. Events of
+// videos without a tenant go nowhere.
+func (d *Dispatcher) Publish(ctx context.Context, event Event) error {
+	if event.TenantID == "" {
+		return nil
+	}
+	hooks, err := d.Store.ListWebhooks(ctx, event.TenantID)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %w", event.Type, err)
+	}
+
+	var errs []error
+	for _, hook := range hooks {
+		if !hook.Subscribed(event.Type) {
+			continue
+		}
+		delivery := d.newDelivery(hook, event.ID, event.Type, string(payload))
+		if err := d.Store.PutWebhookDelivery(ctx, delivery); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		d.send(hook, delivery)
+	}
+	return errors.Join(errs...)
+}
+
+// Replay sends the payload of a past delivery to its webhook again, as a new
+// delivery with its own log entry.
+func (d *Dispatcher) Replay(ctx context.Context, hook db.Webhook, past db.WebhookDelivery) (*db.WebhookDelivery, error) {
+	delivery := d.newDelivery(hook, past.EventID, past.EventType, past.Payload)
+	delivery.ReplayOf = past.DeliveryID
+	if err := d.Store.PutWebhookDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+	d.send(hook, delivery)
+	return &delivery, nil
+}
+
+// Wait blocks until the deliveries in flight are done.
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) newDelivery(hook db.Webhook, eventId, eventType, payload string) db.WebhookDelivery {
+	now := d.now().UTC()
+	return db.WebhookDelivery{
+		TenantID:  hook.TenantID,
+		WebhookID: hook.WebhookID,
+		// Version 7 UUIDs sort by time, which orders the delivery log.
+		DeliveryID: uuid.Must(uuid.NewV7()).String(),
+		EventID:    eventId,
+		EventType:  eventType,
+		Payload:    payload,
+		Status:     db.DeliveryPending,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		ExpiresAt:  now.Add(d.Config.Retention).Unix(),
+	}
+}
+
+func (d *Dispatcher) send(hook db.Webhook, delivery db.WebhookDelivery) {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		d.deliver(context.Background(), hook, delivery)
+	}()
+}
+
+// deliver makes the delivery's attempts, saving the outcome of each to the
+// log, until one succeeds or they run out.
+func (d *Dispatcher) deliver(ctx context.Context, hook db.Webhook, delivery db.WebhookDelivery) {
+	backoff := d.Config.Backoff
+	for {
+		delivery.Attempts++
+		status, err := d.attempt(ctx, hook, delivery)
+		delivery.ResponseStatus = status
+		delivery.UpdatedAt = d.now().UTC()
+		delivery.LastError = ""
+		switch {
+		case err == nil:
+			delivery.Status = db.DeliverySucceeded
+		case delivery.Attempts >= d.Config.MaxAttempts:
+			delivery.Status = db.DeliveryFailed
+			delivery.LastError = err.Error()
+		default:
+			delivery.LastError = err.Error()
+		}
+		if err := d.Store.PutWebhookDelivery(ctx, delivery); err != nil {
+			log.Printf("Failed to save webhook delivery %s: %v", delivery.DeliveryID, err)
+		}
+
+		if delivery.Status != db.DeliveryPending {
+			log.Printf("Webhook delivery %s of %s to %s %s after %d attempts",
+				delivery.DeliveryID, delivery.EventType, hook.WebhookID, delivery.Status, delivery.Attempts)
+			return
+		}
+		d.sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// attempt posts the payload once. Any 2xx response is a success.
+func (d *Dispatcher) attempt(ctx context.Context, hook db.Webhook, delivery db.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to build request: %w", err)
+	}
+	sentAt := d.now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "video-api-webhooks/1.0")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, delivery.DeliveryID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(sentAt.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, sentAt, body))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBytes))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,116 @@
+// Package webhook notifies tenants' registered endpoints of video lifecycle
+// events. Every delivery is signed, retried with backoff and recorded in the
+// webhook's delivery log.
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ryanschneiderman/video-api/internal/db"
+)
+
+const (
+	EventVideoUploaded = "video.uploaded"
+	EventVideoReady    = "video.ready"
+	EventVideoFailed   = "video.failed"
+	EventVideoDeleted  = "video.deleted"
+)
+
+// Events lists the event types webhooks can subscribe to.
+var Events = []string{EventVideoUploaded, EventVideoReady, EventVideoFailed, EventVideoDeleted}
+
+var ErrUnknownEvent = errors.New("unknown event type")
+
+// Headers sent with every delivery. The signature is
+// "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + body)), so receivers
+// can check the body and reject stale timestamps.
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+func ValidateEvents(events []string) error {
+	if len(events) == 0 {
+		return fmt.Errorf("%w: at least one event is required", ErrUnknownEvent)
+	}
+	for _, e := range events {
+		if !slices.Contains(Events, e) {
+			return fmt.Errorf("%w %q", ErrUnknownEvent, e)
+		}
+	}
+	return nil
+}
+
+// Event is the body of a delivery. ID identifies the event, and stays the
+// same across retries and replays of its deliveries.
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"createdAt"`
+	TenantID  string    `json:"tenantId"`
+	Data      VideoData `json:"data"`
+}
+
+// VideoData is the video an event is about, as it was when the event
+// happened.
+type VideoData struct {
+	VideoID string `json:"videoId"`
+	Title   string `json:"title,omitempty"`
+	Status  string `json:"status,omitempty"`
+	Error   string `json:"error,omitempty"`
+	AliasOf string `json:"aliasOf,omitempty"`
+}
+
+func NewVideoEvent(eventType string, video *db.Video) Event {
+	data := VideoData{
+		VideoID: video.VideoID,
+		Title:   video.Title,
+		Status:  video.Status,
+		AliasOf: video.AliasOf,
+	}
+	if video.Status == db.StatusFailed {
+		data.Error = video.StatusReason
+	}
+	return Event{
+		ID:        uuid.NewString(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		TenantID:  video.TenantID,
+		Data:      data,
+	}
+}
+
+// NewSecret returns a random signing secret for a new webhook.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return "whsec_" + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Sign returns the signature header value for body sent at timestamp.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(h, "%d.", timestamp.Unix())
+	h.Write(body)
+	return "sha256=" + hex.EncodeToString(h.Sum(nil))
+}
+
+// Store is where webhooks and their delivery logs are kept.
+type Store interface {
+	ListWebhooks(ctx context.Context, tenantId string) ([]db.Webhook, error)
+	PutWebhookDelivery(ctx context.Context, delivery db.WebhookDelivery) error
+}
@@ -0,0 +1,238 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/fetch"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryStore keeps every saved version of each delivery.
+type memoryStore struct {
+	mu      sync.Mutex
+	hooks   []db.Webhook
+	history map[string][]db.WebhookDelivery
+}
+
+func (s *memoryStore) ListWebhooks(ctx context.Context, tenantId string) ([]db.Webhook, error) {
+	var hooks []db.Webhook
+	for _, h := range s.hooks {
+		if h.TenantID == tenantId {
+			hooks = append(hooks, h)
+		}
+	}
+	return hooks, nil
+}
+
+func (s *memoryStore) PutWebhookDelivery(ctx context.Context, delivery db.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.history == nil {
+		s.history = map[string][]db.WebhookDelivery{}
+	}
+	s.history[delivery.DeliveryID] = append(s.history[delivery.DeliveryID], delivery)
+	return nil
+}
+
+// latest returns the last saved version of each delivery.
+func (s *memoryStore) latest() []db.WebhookDelivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []db.WebhookDelivery
+	for _, versions := range s.history {
+		out = append(out, versions[len(versions)-1])
+	}
+	return out
+}
+
+// receiver fails the first failures requests and records the rest.
+type receiver struct {
+	mu       sync.Mutex
+	failures int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	body, _ := io.ReadAll(req.Body)
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	if len(r.requests) <= r.failures {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func testDispatcher(store Store, maxAttempts int) (*Dispatcher, *[]time.Duration) {
+	d := NewDispatcher(store, Config{MaxAttempts: maxAttempts, Backoff: time.Second})
+	// The receivers are test servers on loopback.
+	d.Client.Transport = fetch.NewTransport(func(addr netip.Addr) bool { return addr.IsLoopback() })
+	var mu sync.Mutex
+	var sleeps []time.Duration
+	d.sleep = func(wait time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		sleeps = append(sleeps, wait)
+	}
+	return d, &sleeps
+}
+
+func TestValidateEvents(t *testing.T) {
+	assert.NoError(t, ValidateEvents([]string{EventVideoReady, EventVideoFailed}))
+	assert.ErrorIs(t, ValidateEvents(nil), ErrUnknownEvent)
+	assert.ErrorIs(t, ValidateEvents([]string{"video.renamed"}), ErrUnknownEvent)
+}
+
+func TestPublishSignsAndDelivers(t *testing.T) {
+	recv := &receiver{}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	store := &memoryStore{hooks: []db.Webhook{
+		{TenantID: "acme", WebhookID: "ready", URL: server.URL, Events: []string{EventVideoReady}, Secret: "whsec_a"},
+		{TenantID: "acme", WebhookID: "failed", URL: server.URL, Events: []string{EventVideoFailed}, Secret: "whsec_b"},
+		{TenantID: "other", WebhookID: "other", URL: server.URL, Events: []string{EventVideoReady}, Secret: "whsec_c"},
+	}}
+	d, _ := testDispatcher(store, 3)
+
+	event := NewVideoEvent(EventVideoReady, &db.Video{VideoID: "v1", TenantID: "acme", Title: "clip.mp4", Status: db.StatusReady})
+	assert.NoError(t, d.Publish(context.Background(), event))
+	d.Wait()
+
+	// Only the tenant's webhook subscribed to the event gets it.
+	assert.Len(t, recv.requests, 1)
+	req, body := recv.requests[0], recv.bodies[0]
+	assert.Equal(t, EventVideoReady, req.Header.Get(HeaderEvent))
+	timestamp, err := strconv.ParseInt(req.Header.Get(HeaderTimestamp), 10, 64)
+	assert.NoError(t, err)
+	assert.Equal(t, Sign("whsec_a", time.Unix(timestamp, 0), body), req.Header.Get(HeaderSignature))
+
+	var got Event
+	assert.NoError(t, json.Unmarshal(body, &got))
+	assert.Equal(t, event.ID, got.ID)
+	assert.Equal(t, VideoData{VideoID: "v1", Title: "clip.mp4", Status: db.StatusReady}, got.Data)
+
+	deliveries := store.latest()
+	assert.Len(t, deliveries, 1)
+	assert.Equal(t, db.DeliverySucceeded, deliveries[0].Status)
+	assert.Equal(t, "ready", deliveries[0].WebhookID)
+	assert.Equal(t, req.Header.Get(HeaderDelivery), deliveries[0].DeliveryID)
+	assert.Equal(t, http.StatusNoContent, deliveries[0].ResponseStatus)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+	assert.NotZero(t, deliveries[0].ExpiresAt)
+}
+
+func TestDeliveryRetriesWithBackoff(t *testing.T) {
+	recv := &receiver{failures: 2}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	store := &memoryStore{hooks: []db.Webhook{
+		{TenantID: "acme", WebhookID: "h1", URL: server.URL, Events: []string{EventVideoFailed}, Secret: "whsec_a"},
+	}}
+	d, sleeps := testDispatcher(store, 5)
+
+	video := &db.Video{VideoID: "v1", TenantID: "acme", Status: db.StatusFailed, StatusReason: "not a video"}
+	assert.NoError(t, d.Publish(context.Background(), NewVideoEvent(EventVideoFailed, video)))
+	d.Wait()
+
+	assert.Len(t, recv.requests, 3)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, *sleeps)
+	// Retries are the same delivery with the same body.
+	assert.Equal(t, recv.bodies[0], recv.bodies[2])
+	assert.Equal(t, recv.requests[0].Header.Get(HeaderDelivery), recv.requests[2].Header.Get(HeaderDelivery))
+
+	deliveries := store.latest()
+	assert.Len(t, deliveries, 1)
+	assert.Equal(t, db.DeliverySucceeded, deliveries[0].Status)
+	assert.Equal(t, 3, deliveries[0].Attempts)
+	assert.Empty(t, deliveries[0].LastError)
+
+	// Each attempt was saved to the log.
+	versions := store.history[deliveries[0].DeliveryID]
+	assert.Len(t, versions, 4)
+	assert.Equal(t, db.DeliveryPending, versions[1].Status)
+	assert.Equal(t, http.StatusServiceUnavailable, versions[1].ResponseStatus)
+	assert.Contains(t, versions[1].LastError, "503")
+}
+
+func TestDeliveryGivesUp(t *testing.T) {
+	recv := &receiver{failures: 10}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	store := &memoryStore{hooks: []db.Webhook{
+		{TenantID: "acme", WebhookID: "h1", URL: server.URL, Events: []string{EventVideoReady}, Secret: "whsec_a"},
+	}}
+	d, _ := testDispatcher(store, 2)
+
+	assert.NoError(t, d.Publish(context.Background(), NewVideoEvent(EventVideoReady, &db.Video{VideoID: "v1", TenantID: "acme"})))
+	d.Wait()
+
+	assert.Len(t, recv.requests, 2)
+	deliveries := store.latest()
+	assert.Equal(t, db.DeliveryFailed, deliveries[0].Status)
+	assert.Equal(t, 2, deliveries[0].Attempts)
+	assert.NotEmpty(t, deliveries[0].LastError)
+}
+
+func TestDeliveryRefusesInternalAddresses(t *testing.T) {
+	recv := &receiver{}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	store := &memoryStore{hooks: []db.Webhook{
+		{TenantID: "acme", WebhookID: "h1", URL: server.URL, Events: []string{EventVideoReady}, Secret: "whsec_a"},
+	}}
+	d := NewDispatcher(store, Config{MaxAttempts: 1})
+
+	assert.NoError(t, d.Publish(context.Background(), NewVideoEvent(EventVideoReady, &db.Video{VideoID: "v1", TenantID: "acme"})))
+	d.Wait()
+
+	assert.Empty(t, recv.requests)
+	deliveries := store.latest()
+	assert.Equal(t, db.DeliveryFailed, deliveries[0].Status)
+	assert.Contains(t, deliveries[0].LastError, "not public")
+}
+
+func TestReplay(t *testing.T) {
+	recv := &receiver{}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	store := &memoryStore{}
+	d, _ := testDispatcher(store, 1)
+	hook := db.Webhook{TenantID: "acme", WebhookID: "h1", URL: server.URL, Events: []string{EventVideoReady}, Secret: "whsec_a"}
+	past := db.WebhookDelivery{
+		TenantID: "acme", WebhookID: "h1", DeliveryID: "d1",
+		EventID: "e1", EventType: EventVideoReady, Payload: `{"id":"e1"}`, Status: db.DeliveryFailed,
+	}
+
+	replay, err := d.Replay(context.Background(), hook, past)
+	assert.NoError(t, err)
+	d.Wait()
+
+	assert.Equal(t, "d1", replay.ReplayOf)
+	assert.NotEqual(t, "d1", replay.DeliveryID)
+	assert.Len(t, recv.requests, 1)
+	assert.Equal(t, `{"id":"e1"}`, string(recv.bodies[0]))
+	assert.Equal(t, db.DeliverySucceeded, store.latest()[0].Status)
+}
+
+func TestPublishWithoutTenant(t *testing.T) {
+	d, _ := testDispatcher(&memoryStore{}, 1)
+	assert.NoError(t, d.Publish(context.Background(), NewVideoEvent(EventVideoReady, &db.Video{VideoID: "v1"})))
+}
@@ -0,0 +1,194 @@
+package worker
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/ryanschneiderman/video-api/internal/pipeline"
+)
+
+const (
+	waveformSampleRate      = 8000
+	waveformSamplesPerPixel = 800
+)
+
+// Waveform is a min/max peaks file in the audiowaveform JSON format, which
+// the player reads to draw the scrubbing bar.
+type Waveform struct {
+	Version         int    `json:"version"`
+	Channels        int    `json:"channels"`
+	SampleRate      int    `json:"sample_rate"`
+	SamplesPerPixel int    `json:"samples_per_pixel"`
+	Bits            int    `json:"bits"`
+	Length          int    `json:"length"`
+	Data            []int8 `json:"data"`
+}
+
+// audioStage produces an audio-only AAC rendition and a waveform peaks file,
+// both from the loudness-normalized audio.
+type audioStage struct {
+	p *Processor
+}
+
+func (s *audioStage) Name() string      { return "audio" }
+func (s *audioStage) Inputs() []string  { return []string{ArtifactSourceKey, ArtifactLoudnessKey} }
+func (s *audioStage) Outputs() []string { return []string{ArtifactAudioKey, ArtifactWaveformKey} }
+
+func (s *audioStage) Run(ctx context.Context, job *pipeline.Job) error {
+	loudness, err := s.p.readLoudness(ctx, job)
+	if err != nil {
+		return err
+	}
+
+	waveformKey := processedKey(job, "audio/waveform.json")
+	if !loudness.HasAudio {
+		log.Printf("No audio stream in videoID: %s, skipping audio rendition", job.ID)
+		if err := uploadJSONToS3(ctx, s.p.S3Client, s.p.S3Bucket, waveformKey, newWaveform(nil)); err != nil {
+			return err
+		}
+		job.SetArtifact(ArtifactAudioKey, "")
+		job.SetArtifact(ArtifactWaveformKey, waveformKey)
+		return nil
+	}
+
+	sourceKey, err := job.Artifact(ArtifactSourceKey)
+	if err != nil {
+		return err
+	}
+	source, err := s.p.localCopy(ctx, job, sourceKey)
+	if err != nil {
+		return err
+	}
+	audioFilter := ""
+	if loudness.normalize() {
+		audioFilter = loudness.filter()
+	}
+
+	rendition := filepath.Join(job.Dir, "audio.m4a")
+	if err := encodeAudio(ctx, source, rendition, audioFilter); err != nil {
+		return err
+	}
+	audioKey := processedKey(job, "audio/audio.m4a")
+	if err := uploadFileToS3(ctx, s.p.S3Client, s.p.S3Bucket, audioKey, rendition, "audio/mp4"); err != nil {
+		return err
+	}
+
+	waveform, err := computeWaveform(ctx, rendition)
+	if err != nil {
+		return err
+	}
+	if err := uploadJSONToS3(ctx, s.p.S3Client, s.p.S3Bucket, waveformKey, waveform); err != nil {
+		return err
+	}
+	log.Printf("Wrote audio rendition and %d waveform peaks for videoID: %s", waveform.Length, job.ID)
+
+	job.SetArtifact(ArtifactAudioKey, audioKey)
+	job.SetArtifact(ArtifactWaveformKey, waveformKey)
+	return nil
+}
+
+func (s *audioStage) Validate(ctx context.Context, outputs map[string]string) error {
+	if key := outputs[ArtifactAudioKey]; key != "" {
+		if err := checkObject(ctx, s.p.S3Client, s.p.S3Bucket, key); err != nil {
+			return err
+		}
+	}
+	return checkObject(ctx, s.p.S3Client, s.p.S3Bucket, outputs[ArtifactWaveformKey])
+}
+
+func encodeAudio(ctx context.Context, inputFile, outputFile, audioFilter string) error {
+	args := []string{"-y", "-i", inputFile, "-vn"}
+	if audioFilter != "" {
+		args = append(args, "-af", audioFilter)
+	}
+	args = append(args, "-c:a", "aac", "-b:a", "128k", "-ar", "48000", "-movflags", "+faststart", outputFile)
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		log.Printf("FFmpeg audio encoding failed: %v", err)
+		log.Printf("FFmpeg Output:\n%s", string(output))
+		return fmt.Errorf("failed to encode audio rendition: %w", err)
+	}
+	return nil
+}
+
+// computeWaveform decodes audio to 8kHz mono PCM and reduces it to peaks.
+func computeWaveform(ctx context.Context, inputFile string) (*Waveform, error) {
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-v", "error", "-i", inputFile, "-vn",
+		"-ac", "1", "-ar", fmt.Sprint(waveformSampleRate), "-f", "s16le", "-")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read decoded audio: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to decode audio: %w", err)
+	}
+
+	peaks, readErr := readPeaks(stdout, waveformSamplesPerPixel)
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("failed to decode audio: %w", err)
+	}
+	if readErr != nil {
+		return nil, readErr
+	}
+	return newWaveform(peaks), nil
+}
+
+func newWaveform(peaks []int8) *Waveform {
+	if peaks == nil {
+		peaks = []int8{}
+	}
+	return &Waveform{
+		Version:         2,
+		Channels:        1,
+		SampleRate:      waveformSampleRate,
+		SamplesPerPixel: waveformSamplesPerPixel,
+		Bits:            8,
+		Length:          len(peaks) / 2,
+		Data:            peaks,
+	}
+}
+
+// readPeaks reads little-endian 16-bit samples and returns a min, max pair,
+// scaled to 8 bits, for every samplesPerPixel samples.
+func readPeaks(r io.Reader, samplesPerPixel int) ([]int8, error) {
+	reader := bufio.NewReader(r)
+	var peaks []int8
+	var lo, hi int16
+	count := 0
+
+	for {
+		var sample int16
+		err := binary.Read(reader, binary.LittleEndian, &sample)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read audio samples: %w", err)
+		}
+
+		if count == 0 || sample < lo {
+			lo = sample
+		}
+		if count == 0 || sample > hi {
+			hi = sample
+		}
+		count++
+		if count == samplesPerPixel {
+			peaks = append(peaks, int8(lo>>8), int8(hi>>8))
+			count = 0
+		}
+	}
+	if count > 0 {
+		peaks = append(peaks, int8(lo>>8), int8(hi>>8))
+	}
+	return peaks, nil
+}
@@ -0,0 +1,31 @@
+package worker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func pcm(samples ...int16) *bytes.Reader {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, samples)
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestReadPeaks(t *testing.T) {
+	peaks, err := readPeaks(pcm(0, 256, -512, 32767, -32768, 1024, 2048), 3)
+
+	assert.NoError(t, err)
+	// Two full pixels and a partial one, each as a min/max pair.
+	assert.Equal(t, []int8{-2, 1, -128, 127, 8, 8}, peaks)
+}
+
+func TestNewWaveformEmpty(t *testing.T) {
+	waveform := newWaveform(nil)
+
+	assert.Equal(t, 0, waveform.Length)
+	assert.NotNil(t, waveform.Data)
+	assert.Equal(t, waveformSampleRate, waveform.SampleRate)
+}
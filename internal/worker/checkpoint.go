@@ -0,0 +1,42 @@
+package worker
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/pipeline"
+)
+
+// videoCheckpointer stores pipeline checkpoints on the video record, which
+// doubles as the job record for processing.
+type videoCheckpointer struct {
+	db *db.DB
+}
+
+func (c *videoCheckpointer) LoadCheckpoints(ctx context.Context, videoID string) (map[string]pipeline.Checkpoint, error) {
+	video, err := c.db.GetVideoById(ctx, videoID)
+	if errors.Is(err, db.ErrVideoNotFound) {
+		return map[string]pipeline.Checkpoint{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoints := make(map[string]pipeline.Checkpoint, len(video.Checkpoints))
+	for stage, cp := range video.Checkpoints {
+		checkpoints[stage] = pipeline.Checkpoint{
+			Stage:       stage,
+			Artifacts:   cp.Artifacts,
+			CompletedAt: cp.CompletedAt,
+		}
+	}
+	return checkpoints, nil
+}
+
+func (c *videoCheckpointer) SaveCheckpoint(ctx context.Context, videoID string, checkpoint pipeline.Checkpoint) error {
+	return c.db.SaveCheckpoint(ctx, videoID, checkpoint.Stage, db.StageCheckpoint{
+		Artifacts:   checkpoint.Artifacts,
+		CompletedAt: checkpoint.CompletedAt,
+	})
+}
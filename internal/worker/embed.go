@@ -0,0 +1,106 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/ryanschneiderman/video-api/internal/pipeline"
+	"github.com/ryanschneiderman/video-api/internal/semantic"
+)
+
+// Embeddings holds one vector per scene keyframe, from the named embedder.
+type Embeddings struct {
+	Embedder string           `json:"embedder"`
+	Scenes   []SceneEmbedding `json:"scenes"`
+}
+
+type SceneEmbedding struct {
+	Index  int       `json:"index"`
+	Start  float64   `json:"start"`
+	End    float64   `json:"end"`
+	Vector []float32 `json:"vector"`
+}
+
+// embedStage embeds the keyframe of every scene and adds the vectors to the
+// semantic search index.
+type embedStage struct {
+	p *Processor
+}
+
+func (s *embedStage) Name() string      { return "embed" }
+func (s *embedStage) Inputs() []string  { return []string{ArtifactScenesKey} }
+func (s *embedStage) Outputs() []string { return []string{ArtifactEmbeddingsKey} }
+
+func (s *embedStage) Run(ctx context.Context, job *pipeline.Job) error {
+	var scenes []Scene
+	if err := s.p.readJSONArtifact(ctx, job, ArtifactScenesKey, &scenes); err != nil {
+		return err
+	}
+
+	embeddings := &Embeddings{Embedder: s.p.Embedder.Name(), Scenes: make([]SceneEmbedding, 0, len(scenes))}
+	for _, scene := range scenes {
+		keyframe, err := s.p.localCopy(ctx, job, scene.KeyframeKey)
+		if err != nil {
+			return err
+		}
+		vector, err := s.p.Embedder.EmbedImage(ctx, keyframe)
+		if err != nil {
+			return fmt.Errorf("%s embedder failed on scene %d: %w", s.p.Embedder.Name(), scene.Index, err)
+		}
+		embeddings.Scenes = append(embeddings.Scenes, SceneEmbedding{
+			Index:  scene.Index,
+			Start:  scene.Start,
+			End:    scene.End,
+			Vector: vector,
+		})
+	}
+	log.Printf("Embedder %s embedded %d scenes for videoID: %s", embeddings.Embedder, len(embeddings.Scenes), job.ID)
+
+	if err := s.save(ctx, job, embeddings); err != nil {
+		return err
+	}
+
+	key := processedKey(job, "embeddings.json")
+	if err := uploadJSONToS3(ctx, s.p.S3Client, s.p.S3Bucket, key, embeddings); err != nil {
+		return err
+	}
+
+	job.SetArtifact(ArtifactEmbeddingsKey, key)
+	return nil
+}
+
+func (s *embedStage) Validate(ctx context.Context, outputs map[string]string) error {
+	return checkObject(ctx, s.p.S3Client, s.p.S3Bucket, outputs[ArtifactEmbeddingsKey])
+}
+
+func (s *embedStage) Restore(ctx context.Context, job *pipeline.Job) error {
+	var embeddings Embeddings
+	if err := s.p.readJSONArtifact(ctx, job, ArtifactEmbeddingsKey, &embeddings); err != nil {
+		return err
+	}
+	return s.save(ctx, job, &embeddings)
+}
+
+func (s *embedStage) save(ctx context.Context, job *pipeline.Job, embeddings *Embeddings) error {
+	segments, vectors := embeddings.segments()
+	err := s.p.SemanticIndex.Update(ctx, job.Tenant, func(idx *semantic.Index) error {
+		return idx.PutVideo(job.ID, segments, vectors)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update semantic index: %w", err)
+	}
+	return nil
+}
+
+// segments splits the embeddings into the spans and vectors the semantic
+// index takes.
+func (e *Embeddings) segments() ([]semantic.Segment, [][]float32) {
+	segments := make([]semantic.Segment, 0, len(e.Scenes))
+	vectors := make([][]float32, 0, len(e.Scenes))
+	for _, scene := range e.Scenes {
+		segments = append(segments, semantic.Segment{Start: scene.Start, End: scene.End})
+		vectors = append(vectors, scene.Vector)
+	}
+	return segments, vectors
+}
@@ -0,0 +1,20 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/ryanschneiderman/video-api/internal/semantic"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmbeddingsSegments(t *testing.T) {
+	embeddings := &Embeddings{Embedder: "fake", Scenes: []SceneEmbedding{
+		{Index: 0, Start: 0, End: 2.5, Vector: []float32{1, 0}},
+		{Index: 1, Start: 2.5, End: 6, Vector: []float32{0, 1}},
+	}}
+
+	segments, vectors := embeddings.segments()
+
+	assert.Equal(t, []semantic.Segment{{Start: 0, End: 2.5}, {Start: 2.5, End: 6}}, segments)
+	assert.Equal(t, [][]float32{{1, 0}, {0, 1}}, vectors)
+}
@@ -0,0 +1,139 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"strconv"
+
+	"github.com/ryanschneiderman/video-api/internal/phash"
+	"github.com/ryanschneiderman/video-api/internal/pipeline"
+)
+
+// fingerprintFrames is how many evenly spaced frames are hashed per video.
+const fingerprintFrames = 8
+
+// Fingerprint is the perceptual hashes of frames sampled from a video, as hex
+// strings in frame order.
+type Fingerprint struct {
+	Times  []float64 `json:"times"`
+	Hashes []string  `json:"hashes"`
+}
+
+// fingerprintStage hashes sampled frames of the transcoded video and indexes
+// them for near-duplicate lookups.
+type fingerprintStage struct {
+	p *Processor
+}
+
+func (s *fingerprintStage) Name() string { return "fingerprint" }
+func (s *fingerprintStage) Inputs() []string {
+	return []string{ArtifactTranscodedKey, ArtifactProbeKey}
+}
+func (s *fingerprintStage) Outputs() []string { return []string{ArtifactFingerprintKey} }
+
+func (s *fingerprintStage) Run(ctx context.Context, job *pipeline.Job) error {
+	transcodedKey, err := job.Artifact(ArtifactTranscodedKey)
+	if err != nil {
+		return err
+	}
+	transcoded, err := s.p.localCopy(ctx, job, transcodedKey)
+	if err != nil {
+		return err
+	}
+	probe, err := s.p.readProbe(ctx, job)
+	if err != nil {
+		return err
+	}
+
+	fingerprint := &Fingerprint{}
+	for _, at := range sampleTimes(probe.Duration, fingerprintFrames) {
+		gray, err := extractGrayFrame(ctx, transcoded, at)
+		if err != nil {
+			return err
+		}
+		hash, err := phash.Hash(gray)
+		if err != nil {
+			return fmt.Errorf("failed to hash frame at %.3fs: %w", at, err)
+		}
+		fingerprint.Times = append(fingerprint.Times, at)
+		fingerprint.Hashes = append(fingerprint.Hashes, strconv.FormatUint(hash, 16))
+	}
+	log.Printf("Hashed %d frames for videoID: %s", len(fingerprint.Hashes), job.ID)
+
+	if err := s.save(ctx, job, fingerprint); err != nil {
+		return err
+	}
+
+	key := processedKey(job, "fingerprint.json")
+	if err := uploadJSONToS3(ctx, s.p.S3Client, s.p.S3Bucket, key, fingerprint); err != nil {
+		return err
+	}
+
+	job.SetArtifact(ArtifactFingerprintKey, key)
+	return nil
+}
+
+func (s *fingerprintStage) Validate(ctx context.Context, outputs map[string]string) error {
+	return checkObject(ctx, s.p.S3Client, s.p.S3Bucket, outputs[ArtifactFingerprintKey])
+}
+
+func (s *fingerprintStage) Restore(ctx context.Context, job *pipeline.Job) error {
+	var fingerprint Fingerprint
+	if err := s.p.readJSONArtifact(ctx, job, ArtifactFingerprintKey, &fingerprint); err != nil {
+		return err
+	}
+	return s.save(ctx, job, &fingerprint)
+}
+
+func (s *fingerprintStage) save(ctx context.Context, job *pipeline.Job, fingerprint *Fingerprint) error {
+	hashes, err := fingerprint.hashes()
+	if err != nil {
+		return err
+	}
+	if err := s.p.DB.PutFrameHashes(ctx, job.Tenant, job.ID, hashes); err != nil {
+		return fmt.Errorf("failed to save frame hashes: %w", err)
+	}
+	return nil
+}
+
+// hashes parses the hex hashes back into the values the index stores.
+func (f *Fingerprint) hashes() ([]uint64, error) {
+	hashes := make([]uint64, 0, len(f.Hashes))
+	for _, h := range f.Hashes {
+		hash, err := strconv.ParseUint(h, 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse frame hash %q: %w", h, err)
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
+
+// sampleTimes spreads n timestamps across the middle of each of n equal
+// slices of the video, which keeps them clear of black lead-in and fade-out
+// frames.
+func sampleTimes(duration float64, n int) []float64 {
+	times := make([]float64, n)
+	for i := range times {
+		times[i] = duration * (float64(i) + 0.5) / float64(n)
+	}
+	return times
+}
+
+// extractGrayFrame decodes the frame at the given time as raw 8-bit grayscale
+// scaled to the size phash expects.
+func extractGrayFrame(ctx context.Context, inputFile string, at float64) ([]byte, error) {
+	size := strconv.Itoa(phash.Size)
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-v", "error", "-ss", strconv.FormatFloat(at, 'f', 3, 64),
+		"-i", inputFile, "-frames:v", "1", "-vf", "scale="+size+":"+size+":flags=area,format=gray",
+		"-f", "rawvideo", "-")
+
+	output, err := cmd.Output()
+	if err != nil {
+		log.Printf("FFmpeg frame extraction failed: %v", err)
+		return nil, fmt.Errorf("failed to extract frame at %.3fs: %w", at, err)
+	}
+	return output, nil
+}
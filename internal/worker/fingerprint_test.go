@@ -0,0 +1,20 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSampleTimes(t *testing.T) {
+	assert.Equal(t, []float64{1, 3, 5, 7}, sampleTimes(8, 4))
+}
+
+func TestFingerprintHashes(t *testing.T) {
+	hashes, err := (&Fingerprint{Hashes: []string{"ffffffffffffffff", "1a"}}).hashes()
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{1<<64 - 1, 26}, hashes)
+
+	_, err = (&Fingerprint{Hashes: []string{"xyz"}}).hashes()
+	assert.Error(t, err)
+}
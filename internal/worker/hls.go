@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	// hlsMasterName is where HLS packaging is expected to write the master
+	// playlist, relative to the video's processed prefix.
+	hlsMasterName  = "hls/master.m3u8"
+	hlsContentType = "application/vnd.apple.mpegurl"
+
+	subtitleGroupID = "subs"
+)
+
+// addSubtitleRendition declares a subtitle rendition in an HLS master
+// playlist and attaches it to every variant stream. Playlists that already
+// carry the rendition are returned unchanged.
+func addSubtitleRendition(master, uri, language string) string {
+	if strings.Contains(master, fmt.Sprintf(`GROUP-ID="%s"`, subtitleGroupID)) {
+		return master
+	}
+
+	media := fmt.Sprintf(`#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="%s",NAME="%s",LANGUAGE="%s",DEFAULT=NO,AUTOSELECT=YES,URI="%s"`,
+		subtitleGroupID, strings.ToUpper(language), language, uri)
+
+	lines := strings.Split(strings.TrimRight(master, "\n"), "\n")
+	out := make([]string, 0, len(lines)+1)
+	inserted := false
+	for _, line := range lines {
+		if strings.HasPrefix(line, "#EXT-X-STREAM-INF:") {
+			if !inserted {
+				out = append(out, media)
+				inserted = true
+			}
+			line += fmt.Sprintf(`,SUBTITLES="%s"`, subtitleGroupID)
+		}
+		out = append(out, line)
+	}
+	if !inserted {
+		out = append(out, media)
+	}
+	return strings.Join(out, "\n") + "\n"
+}
+
+// subtitlePlaylist is a single-segment media playlist pointing at a WebVTT
+// file that covers the whole video.
+func subtitlePlaylist(vttURI string, duration float64) string {
+	target := int(math.Ceil(duration))
+	if target < 1 {
+		target = 1
+	}
+	return fmt.Sprintf("#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:%d\n#EXT-X-MEDIA-SEQUENCE:0\n#EXT-X-PLAYLIST-TYPE:VOD\n#EXTINF:%.3f,\n%s\n#EXT-X-ENDLIST\n",
+		target, duration, vttURI)
+}
@@ -0,0 +1,37 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testMaster = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360
+360p.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=2800000,RESOLUTION=1280x720
+720p.m3u8
+`
+
+func TestAddSubtitleRendition(t *testing.T) {
+	expected := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="EN",LANGUAGE="en",DEFAULT=NO,AUTOSELECT=YES,URI="subtitles.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360,SUBTITLES="subs"
+360p.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=2800000,RESOLUTION=1280x720,SUBTITLES="subs"
+720p.m3u8
+`
+	linked := addSubtitleRendition(testMaster, "subtitles.m3u8", "en")
+	assert.Equal(t, expected, linked)
+
+	// Linking again must not add a second rendition.
+	assert.Equal(t, linked, addSubtitleRendition(linked, "subtitles.m3u8", "en"))
+}
+
+func TestSubtitlePlaylist(t *testing.T) {
+	expected := "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:13\n#EXT-X-MEDIA-SEQUENCE:0\n" +
+		"#EXT-X-PLAYLIST-TYPE:VOD\n#EXTINF:12.400,\n../captions/captions.vtt\n#EXT-X-ENDLIST\n"
+	assert.Equal(t, expected, subtitlePlaylist("../captions/captions.vtt", 12.4))
+}
@@ -0,0 +1,124 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/fetch"
+	"github.com/ryanschneiderman/video-api/internal/pipeline"
+	"github.com/ryanschneiderman/video-api/internal/webhook"
+)
+
+// JobImport marks a message asking to fetch a video's source from its
+// SourceURL into the message's filename, before it is processed.
+const JobImport = "import"
+
+// ImportVideo fetches an importing video's source into S3 and enqueues its
+// processing. The source is streamed to the scratch directory, not memory,
+// and checked against the fetcher's limits as it arrives. Refused sources
+// fail permanently.
+func (p *Processor) ImportVideo(ctx context.Context, videoID string, filename string) error {
+	video, err := p.DB.GetVideoById(ctx, videoID)
+	if err != nil {
+		if errors.Is(err, db.ErrVideoNotFound) {
+			return pipeline.Permanent(err)
+		}
+		return fmt.Errorf("failed to load video: %w", err)
+	}
+	if err := checkSourceKey(video, filename); err != nil {
+		log.Printf("Dropping message for videoID %s: %v", video.VideoID, err)
+		return nil
+	}
+	switch video.Status {
+	case db.StatusImporting:
+	case db.StatusUploaded:
+		// The source was imported but the processing job may not have been
+		// sent.
+		return p.finishImport(ctx, video, filename)
+	default:
+		log.Printf("VideoID %s is %s, not importing it again", videoID, video.Status)
+		return nil
+	}
+
+	dir, err := os.MkdirTemp("", videoID+"-")
+	if err != nil {
+		return fmt.Errorf("failed to create scratch directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	localPath := filepath.Join(dir, "source")
+	file, err := os.Create(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to create local file: %w", err)
+	}
+	result, err := p.Fetcher.Fetch(ctx, video.SourceURL, file)
+	file.Close()
+	if err != nil {
+		err = fmt.Errorf("failed to import %s: %w", video.SourceURL, err)
+		if fetch.Permanent(err) {
+			return pipeline.Permanent(err)
+		}
+		return err
+	}
+	log.Printf("Fetched %d bytes of %s for videoID %s", result.Size, result.ContentType, videoID)
+
+	if err := p.DB.ReserveStorage(ctx, video.TenantID, result.Size, p.StorageQuotaBytes); err != nil {
+		if errors.Is(err, db.ErrQuotaExceeded) {
+			return pipeline.Permanent(fmt.Errorf("storage quota exceeded by %d byte import: %w", result.Size, err))
+		}
+		return err
+	}
+	if err := uploadFileToS3(ctx, p.S3Client, p.S3Bucket, filename, localPath, result.ContentType); err != nil {
+		p.releaseStorage(ctx, video.TenantID, result.Size)
+		return err
+	}
+
+	video.URL = fmt.Sprintf("https://%s.s3.amazonaws.com/%s", p.S3Bucket, filename)
+	video.ContentHash = result.SHA256
+	video.Status = db.StatusUploaded
+	video.Size = result.Size
+	err = p.DB.UpdateVideo(ctx, videoID, map[string]interface{}{
+		"url":          video.URL,
+		"content_hash": video.ContentHash,
+		"status":       video.Status,
+		"size":         video.Size,
+	})
+	if err != nil {
+		// The next attempt fetches and reserves the source again.
+		p.releaseStorage(ctx, video.TenantID, result.Size)
+		return fmt.Errorf("failed to update imported video: %w", err)
+	}
+	return p.finishImport(ctx, video, filename)
+}
+
+// finishImport enqueues an imported video's processing, like an upload.
+func (p *Processor) finishImport(ctx context.Context, video *db.Video, filename string) error {
+	body, err := json.Marshal(SQSMessage{VideoID: video.VideoID, Filename: filename})
+	if err != nil {
+		return fmt.Errorf("failed to marshal processing message: %w", err)
+	}
+	_, err = p.SQSClient.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:    aws.String(p.QueueURL),
+		MessageBody: aws.String(string(body)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enqueue processing of imported video: %w", err)
+	}
+	log.Printf("Imported videoID %s, enqueued processing", video.VideoID)
+	p.notify(ctx, webhook.EventVideoUploaded, video)
+	return nil
+}
+
+func (p *Processor) releaseStorage(ctx context.Context, tenantId string, bytes int64) {
+	if err := p.DB.ReleaseStorage(ctx, tenantId, bytes); err != nil {
+		log.Printf("Failed to release %d bytes of storage for tenant %s: %v", bytes, tenantId, err)
+	}
+}
@@ -0,0 +1,195 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/pipeline"
+)
+
+const (
+	defaultLoudnessTarget = -16.0
+	loudnessTruePeak      = -1.5
+	loudnessRange         = 11.0
+)
+
+// Loudness is the first loudnorm pass over a video's audio. HasAudio is false
+// when there is no audio stream and Silent when the track is digital silence;
+// neither is normalized.
+type Loudness struct {
+	HasAudio     bool    `json:"hasAudio"`
+	Silent       bool    `json:"silent"`
+	TargetLUFS   float64 `json:"targetLufs"`
+	InputI       float64 `json:"inputI"`
+	InputTP      float64 `json:"inputTp"`
+	InputLRA     float64 `json:"inputLra"`
+	InputThresh  float64 `json:"inputThresh"`
+	TargetOffset float64 `json:"targetOffset"`
+}
+
+func (l *Loudness) normalize() bool {
+	return l.HasAudio && !l.Silent
+}
+
+// filter returns the second-pass loudnorm filter that applies the measured
+// values linearly.
+func (l *Loudness) filter() string {
+	return fmt.Sprintf("loudnorm=I=%g:TP=%g:LRA=%g:measured_I=%g:measured_TP=%g:measured_LRA=%g:measured_thresh=%g:offset=%g:linear=true",
+		l.TargetLUFS, loudnessTruePeak, loudnessRange, l.InputI, l.InputTP, l.InputLRA, l.InputThresh, l.TargetOffset)
+}
+
+type loudnessStage struct {
+	p *Processor
+}
+
+func (s *loudnessStage) Name() string      { return "loudness" }
+func (s *loudnessStage) Inputs() []string  { return []string{ArtifactSourceKey, ArtifactProbeKey} }
+func (s *loudnessStage) Outputs() []string { return []string{ArtifactLoudnessKey} }
+
+func (s *loudnessStage) Run(ctx context.Context, job *pipeline.Job) error {
+	sourceKey, err := job.Artifact(ArtifactSourceKey)
+	if err != nil {
+		return err
+	}
+	source, err := s.p.localCopy(ctx, job, sourceKey)
+	if err != nil {
+		return err
+	}
+
+	probe, err := s.p.readProbe(ctx, job)
+	if err != nil {
+		return err
+	}
+	loudness := &Loudness{TargetLUFS: s.p.LoudnessTarget, HasAudio: probe.HasAudio}
+	if loudness.HasAudio {
+		if err := measureLoudness(ctx, source, loudness); err != nil {
+			return err
+		}
+		log.Printf("Measured %.2f LUFS (target %.2f) for videoID: %s", loudness.InputI, loudness.TargetLUFS, job.ID)
+	}
+
+	if err := s.save(ctx, job, loudness); err != nil {
+		return err
+	}
+
+	key := processedKey(job, "loudness.json")
+	if err := uploadJSONToS3(ctx, s.p.S3Client, s.p.S3Bucket, key, loudness); err != nil {
+		return err
+	}
+
+	job.SetArtifact(ArtifactLoudnessKey, key)
+	return nil
+}
+
+func (s *loudnessStage) Validate(ctx context.Context, outputs map[string]string) error {
+	return checkObject(ctx, s.p.S3Client, s.p.S3Bucket, outputs[ArtifactLoudnessKey])
+}
+
+func (s *loudnessStage) Restore(ctx context.Context, job *pipeline.Job) error {
+	loudness, err := s.p.readLoudness(ctx, job)
+	if err != nil {
+		return err
+	}
+	return s.save(ctx, job, loudness)
+}
+
+// save records the measurement on the video when its audio is normalized.
+func (s *loudnessStage) save(ctx context.Context, job *pipeline.Job, loudness *Loudness) error {
+	if loudness.normalize() {
+		err := s.p.DB.UpdateVideo(ctx, job.ID, map[string]interface{}{
+			"loudness": db.Loudness{
+				TargetLUFS:     loudness.TargetLUFS,
+				IntegratedLUFS: loudness.InputI,
+				TruePeak:       loudness.InputTP,
+				LoudnessRange:  loudness.InputLRA,
+				Threshold:      loudness.InputThresh,
+				TargetOffset:   loudness.TargetOffset,
+				MeasuredAt:     time.Now().UTC(),
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to save loudness: %w", err)
+		}
+	}
+	return nil
+}
+
+// readLoudness loads the measurement written by the loudness stage.
+func (p *Processor) readLoudness(ctx context.Context, job *pipeline.Job) (*Loudness, error) {
+	var loudness Loudness
+	if err := p.readJSONArtifact(ctx, job, ArtifactLoudnessKey, &loudness); err != nil {
+		return nil, err
+	}
+	return &loudness, nil
+}
+
+func measureLoudness(ctx context.Context, inputFile string, loudness *Loudness) error {
+	filter := fmt.Sprintf("loudnorm=I=%g:TP=%g:LRA=%g:print_format=json", loudness.TargetLUFS, loudnessTruePeak, loudnessRange)
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-hide_banner", "-i", inputFile, "-vn", "-af", filter, "-f", "null", "-")
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		log.Printf("FFmpeg loudness measurement failed: %v", err)
+		log.Printf("FFmpeg Output:\n%s", string(output))
+		return fmt.Errorf("failed to measure loudness: %w", err)
+	}
+
+	return parseLoudnorm(string(output), loudness)
+}
+
+// parseLoudnorm reads the JSON summary loudnorm prints at the end of the
+// first pass.
+func parseLoudnorm(output string, loudness *Loudness) error {
+	marker := strings.LastIndex(output, "Parsed_loudnorm")
+	if marker < 0 {
+		return fmt.Errorf("loudnorm summary not found in ffmpeg output")
+	}
+	start := strings.Index(output[marker:], "{")
+	end := strings.Index(output[marker:], "}")
+	if start < 0 || end < start {
+		return fmt.Errorf("loudnorm summary not found in ffmpeg output")
+	}
+
+	var summary struct {
+		InputI       string `json:"input_i"`
+		InputTP      string `json:"input_tp"`
+		InputLRA     string `json:"input_lra"`
+		InputThresh  string `json:"input_thresh"`
+		TargetOffset string `json:"target_offset"`
+	}
+	if err := json.Unmarshal([]byte(output[marker+start:marker+end+1]), &summary); err != nil {
+		return fmt.Errorf("failed to parse loudnorm summary: %w", err)
+	}
+
+	values := []struct {
+		raw string
+		dst *float64
+	}{
+		{summary.InputI, &loudness.InputI},
+		{summary.InputTP, &loudness.InputTP},
+		{summary.InputLRA, &loudness.InputLRA},
+		{summary.InputThresh, &loudness.InputThresh},
+		{summary.TargetOffset, &loudness.TargetOffset},
+	}
+	for _, v := range values {
+		f, err := strconv.ParseFloat(strings.TrimSpace(v.raw), 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse loudnorm value %q: %w", v.raw, err)
+		}
+		// Silence measures as -inf, which can't be normalized or stored.
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			loudness.Silent = true
+			f = 0
+		}
+		*v.dst = f
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const loudnormOutput = `Input #0, mov,mp4,m4a,3gp,3g2,mj2, from 'in.mp4':
+size=N/A time=00:00:20.00 bitrate=N/A speed= 212x
+[Parsed_loudnorm_0 @ 0x6000] 
+{
+	"input_i" : "-27.61",
+	"input_tp" : "-4.47",
+	"input_lra" : "18.06",
+	"input_thresh" : "-39.20",
+	"output_i" : "-16.58",
+	"output_tp" : "-1.50",
+	"output_lra" : "14.78",
+	"output_thresh" : "-27.71",
+	"normalization_type" : "dynamic",
+	"target_offset" : "0.58"
+}
+`
+
+func TestParseLoudnorm(t *testing.T) {
+	loudness := &Loudness{HasAudio: true, TargetLUFS: -16}
+	err := parseLoudnorm(loudnormOutput, loudness)
+
+	assert.NoError(t, err)
+	assert.Equal(t, -27.61, loudness.InputI)
+	assert.Equal(t, -4.47, loudness.InputTP)
+	assert.Equal(t, 18.06, loudness.InputLRA)
+	assert.Equal(t, -39.2, loudness.InputThresh)
+	assert.Equal(t, 0.58, loudness.TargetOffset)
+	assert.True(t, loudness.normalize())
+	assert.Equal(t,
+		"loudnorm=I=-16:TP=-1.5:LRA=11:measured_I=-27.61:measured_TP=-4.47:measured_LRA=18.06:measured_thresh=-39.2:offset=0.58:linear=true",
+		loudness.filter())
+}
+
+func TestParseLoudnormSilence(t *testing.T) {
+	silent := `[Parsed_loudnorm_0 @ 0x6000] 
+{
+	"input_i" : "-inf",
+	"input_tp" : "-inf",
+	"input_lra" : "0.00",
+	"input_thresh" : "-70.00",
+	"target_offset" : "inf"
+}`
+	loudness := &Loudness{HasAudio: true}
+	err := parseLoudnorm(silent, loudness)
+
+	assert.NoError(t, err)
+	assert.True(t, loudness.Silent)
+	assert.False(t, loudness.normalize())
+}
+
+func TestParseLoudnormMissingSummary(t *testing.T) {
+	assert.Error(t, parseLoudnorm("frame=100 fps=0.0", &Loudness{}))
+}
@@ -0,0 +1,167 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/ryanschneiderman/video-api/internal/pipeline"
+)
+
+var (
+	ErrNotMedia      = errors.New("not a readable media file")
+	ErrNoVideoStream = errors.New("no video stream")
+	ErrNoDuration    = errors.New("video has no duration")
+)
+
+// Probe summarizes the streams of an uploaded file. Later stages read it
+// instead of probing the source again.
+type Probe struct {
+	FormatName string  `json:"formatName"`
+	Duration   float64 `json:"duration"`
+	VideoCodec string  `json:"videoCodec"`
+	Width      int     `json:"width"`
+	Height     int     `json:"height"`
+	HasAudio   bool    `json:"hasAudio"`
+	AudioCodec string  `json:"audioCodec,omitempty"`
+}
+
+// probeStage checks that the upload is a video ffmpeg can decode before any
+// expensive work starts. Its failures are permanent: retrying the same bytes
+// can't produce a different answer.
+type probeStage struct {
+	p *Processor
+}
+
+func (s *probeStage) Name() string      { return "probe" }
+func (s *probeStage) Inputs() []string  { return []string{ArtifactSourceKey} }
+func (s *probeStage) Outputs() []string { return []string{ArtifactProbeKey} }
+
+func (s *probeStage) Run(ctx context.Context, job *pipeline.Job) error {
+	sourceKey, err := job.Artifact(ArtifactSourceKey)
+	if err != nil {
+		return err
+	}
+	source, err := s.p.localCopy(ctx, job, sourceKey)
+	if err != nil {
+		return err
+	}
+
+	probe, err := probeMedia(ctx, source)
+	if err != nil {
+		return err
+	}
+	log.Printf("Probed videoID %s: %s %s %dx%d, %.2fs, audio: %t",
+		job.ID, probe.FormatName, probe.VideoCodec, probe.Width, probe.Height, probe.Duration, probe.HasAudio)
+
+	key := processedKey(job, "probe.json")
+	if err := uploadJSONToS3(ctx, s.p.S3Client, s.p.S3Bucket, key, probe); err != nil {
+		return err
+	}
+
+	job.SetArtifact(ArtifactProbeKey, key)
+	return nil
+}
+
+func (s *probeStage) Validate(ctx context.Context, outputs map[string]string) error {
+	return checkObject(ctx, s.p.S3Client, s.p.S3Bucket, outputs[ArtifactProbeKey])
+}
+
+// readProbe loads the summary written by the probe stage.
+func (p *Processor) readProbe(ctx context.Context, job *pipeline.Job) (*Probe, error) {
+	var probe Probe
+	if err := p.readJSONArtifact(ctx, job, ArtifactProbeKey, &probe); err != nil {
+		return nil, err
+	}
+	return &probe, nil
+}
+
+func probeMedia(ctx context.Context, inputFile string) (*Probe, error) {
+	cmd := exec.CommandContext(ctx, "ffprobe", "-v", "error",
+		"-show_entries", "format=format_name,duration:stream=codec_type,codec_name,width,height:stream_disposition=attached_pic",
+		"-of", "json", inputFile)
+
+	output, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			reason := strings.TrimSpace(string(exitErr.Stderr))
+			return nil, pipeline.Permanent(fmt.Errorf("%w: %s", ErrNotMedia, reason))
+		}
+		return nil, fmt.Errorf("failed to run ffprobe: %w", err)
+	}
+
+	probe, err := parseProbe(output)
+	if err != nil {
+		return nil, pipeline.Permanent(err)
+	}
+	return probe, nil
+}
+
+// parseProbe reads ffprobe's JSON output and rejects files without a
+// decodable video stream. Cover art in audio files shows up as a video stream
+// with the attached_pic disposition and doesn't count.
+func parseProbe(output []byte) (*Probe, error) {
+	var raw struct {
+		Format struct {
+			FormatName string `json:"format_name"`
+			Duration   string `json:"duration"`
+		} `json:"format"`
+		Streams []struct {
+			CodecType   string `json:"codec_type"`
+			CodecName   string `json:"codec_name"`
+			Width       int    `json:"width"`
+			Height      int    `json:"height"`
+			Disposition struct {
+				AttachedPic int `json:"attached_pic"`
+			} `json:"disposition"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(output, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	probe := &Probe{FormatName: raw.Format.FormatName}
+	for _, stream := range raw.Streams {
+		switch stream.CodecType {
+		case "video":
+			if probe.VideoCodec != "" || stream.Disposition.AttachedPic == 1 {
+				continue
+			}
+			probe.VideoCodec = stream.CodecName
+			probe.Width = stream.Width
+			probe.Height = stream.Height
+		case "audio":
+			if !probe.HasAudio {
+				probe.HasAudio = true
+				probe.AudioCodec = stream.CodecName
+			}
+		}
+	}
+	if probe.VideoCodec == "" {
+		return nil, ErrNoVideoStream
+	}
+	if probe.Width <= 0 || probe.Height <= 0 {
+		return nil, fmt.Errorf("%w: invalid dimensions %dx%d", ErrNoVideoStream, probe.Width, probe.Height)
+	}
+
+	if raw.Format.Duration != "" && raw.Format.Duration != "N/A" {
+		duration, err := strconv.ParseFloat(raw.Format.Duration, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse duration %q: %w", raw.Format.Duration, err)
+		}
+		probe.Duration = duration
+	}
+	if probe.Duration <= 0 {
+		return nil, ErrNoDuration
+	}
+	return probe, nil
+}
@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProbe(t *testing.T) {
+	output := `{
+		"streams": [
+			{"codec_name": "h264", "codec_type": "video", "width": 1920, "height": 1080, "disposition": {"attached_pic": 0}},
+			{"codec_name": "aac", "codec_type": "audio", "disposition": {"attached_pic": 0}}
+		],
+		"format": {"format_name": "mov,mp4,m4a,3gp,3g2,mj2", "duration": "12.480000"}
+	}`
+
+	probe, err := parseProbe([]byte(output))
+
+	assert.NoError(t, err)
+	assert.Equal(t, &Probe{
+		FormatName: "mov,mp4,m4a,3gp,3g2,mj2",
+		Duration:   12.48,
+		VideoCodec: "h264",
+		Width:      1920,
+		Height:     1080,
+		HasAudio:   true,
+		AudioCodec: "aac",
+	}, probe)
+}
+
+func TestParseProbeRejectsAudioWithCoverArt(t *testing.T) {
+	output := `{
+		"streams": [
+			{"codec_name": "mp3", "codec_type": "audio", "disposition": {"attached_pic": 0}},
+			{"codec_name": "mjpeg", "codec_type": "video", "width": 600, "height": 600, "disposition": {"attached_pic": 1}}
+		],
+		"format": {"format_name": "mp3", "duration": "181.2"}
+	}`
+
+	_, err := parseProbe([]byte(output))
+
+	assert.ErrorIs(t, err, ErrNoVideoStream)
+}
+
+func TestParseProbeRejectsZeroDuration(t *testing.T) {
+	output := `{
+		"streams": [{"codec_name": "h264", "codec_type": "video", "width": 640, "height": 360}],
+		"format": {"format_name": "mov,mp4,m4a,3gp,3g2,mj2", "duration": "N/A"}
+	}`
+
+	_, err := parseProbe([]byte(output))
+
+	assert.ErrorIs(t, err, ErrNoDuration)
+}
@@ -0,0 +1,88 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/pipeline"
+	"github.com/ryanschneiderman/video-api/internal/profile"
+)
+
+// ReprocessVideo runs a reprocess job, see reprocess.Message. The picked
+// stages and the stages downstream of them lose their checkpoints, and the
+// pipeline runs from the stored original, skipping every stage that still
+// has one. Checkpoints saved since the job was requested are kept, so a
+// redelivered job resumes.
+func (p *Processor) ReprocessVideo(ctx context.Context, msg SQSMessage) error {
+	video, err := p.DB.GetVideoById(ctx, msg.VideoID)
+	if err != nil {
+		if errors.Is(err, db.ErrVideoNotFound) {
+			return pipeline.Permanent(err)
+		}
+		return fmt.Errorf("failed to load video: %w", err)
+	}
+	if video.Status != db.StatusUploaded && video.Status != db.StatusProcessing {
+		log.Printf("VideoID %s is %s, not reprocessing it again", video.VideoID, video.Status)
+		return nil
+	}
+	if err := checkSourceKey(video, msg.Filename); err != nil {
+		log.Printf("Dropping message for videoID %s: %v", video.VideoID, err)
+		return nil
+	}
+	prof, err := profile.Get(msg.Profile)
+	if err != nil {
+		return pipeline.Permanent(err)
+	}
+
+	stale, err := p.staleStages(video, msg.Stages, prof.Name)
+	if err != nil {
+		return pipeline.Permanent(err)
+	}
+	var clear []string
+	for _, stage := range stale {
+		if cp, ok := video.Checkpoints[stage]; ok && cp.CompletedAt.Before(msg.RequestedAt) {
+			clear = append(clear, stage)
+		}
+	}
+	if err := p.DB.ClearCheckpoints(ctx, video.VideoID, clear); err != nil {
+		return err
+	}
+	log.Printf("Reprocessing videoID %s with profile %s, rerunning %v", video.VideoID, prof.Name, stale)
+
+	return p.processVideo(ctx, video, msg.Filename, prof.Name)
+}
+
+// staleStages resolves the stages a reprocess job reruns: the requested
+// ones, or all of them, plus the transcode when the profile changes, plus
+// everything downstream. Stages this worker doesn't run, such as audio when
+// it is disabled, are left out.
+func (p *Processor) staleStages(video *db.Video, requested []string, profileName string) ([]string, error) {
+	registered := map[string]bool{}
+	for _, stage := range p.Pipeline.Stages() {
+		registered[stage] = true
+	}
+
+	var stages []string
+	if len(requested) == 0 {
+		stages = p.Pipeline.Stages()
+	}
+	for _, stage := range requested {
+		if !registered[stage] {
+			log.Printf("Stage %s requested for videoID %s is not enabled, skipping it", stage, video.VideoID)
+			continue
+		}
+		stages = append(stages, stage)
+	}
+
+	current := video.Profile
+	if current == "" {
+		current = profile.Default
+	}
+	if profileName != current {
+		stages = append(stages, "transcode")
+	}
+	return p.Pipeline.Downstream(stages...)
+}
@@ -0,0 +1,45 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/reprocess"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStageNamesMatchPipeline(t *testing.T) {
+	p := &Processor{AudioRenditions: true}
+	assert.Equal(t, p.buildPipeline().Stages(), StageNames())
+
+	// Every stage can be picked for a reprocess.
+	req := reprocess.Request{Stages: StageNames()}
+	assert.NoError(t, req.Validate(StageNames()))
+
+	p = &Processor{}
+	assert.Subset(t, StageNames(), p.buildPipeline().Stages())
+}
+
+func TestStaleStages(t *testing.T) {
+	p := &Processor{}
+	p.Pipeline = p.buildPipeline()
+	video := &db.Video{VideoID: "v1", Profile: "h264-720p"}
+
+	stages, err := p.staleStages(video, []string{"analyze"}, "h264-720p")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"analyze"}, stages)
+
+	// A new profile reruns the transcode and everything reading it.
+	stages, err = p.staleStages(video, []string{"transcribe"}, "h264-1080p")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"transcode", "analyze", "scenes", "fingerprint", "embed", "transcribe"}, stages)
+
+	// Audio is not enabled on this worker.
+	stages, err = p.staleStages(video, []string{"audio"}, "h264-720p")
+	assert.NoError(t, err)
+	assert.Empty(t, stages)
+
+	stages, err = p.staleStages(&db.Video{VideoID: "v2"}, nil, "default")
+	assert.NoError(t, err)
+	assert.Equal(t, p.Pipeline.Stages(), stages)
+}
@@ -0,0 +1,104 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/purge"
+)
+
+const defaultRetainCount = 3
+
+// Retention decides which versions of a video keep their files. The current
+// version and a run still in progress always do, and so do the newest
+// Keep-1 other ready versions. The files of every other version are deleted
+// once it is older than MinAge. Files from before versioning are never
+// deleted.
+type Retention struct {
+	Keep   int
+	MinAge time.Duration
+}
+
+// expiredVersions picks the versions whose files the policy lets go, from
+// versions listed newest first. latest is the newest version number, the
+// only one that can still be processing; older processing versions were
+// abandoned.
+func expiredVersions(versions []db.VideoVersion, current, latest int, policy Retention, now time.Time) []db.VideoVersion {
+	var expired []db.VideoVersion
+	kept := 1
+	for _, v := range versions {
+		switch {
+		case v.State == db.VersionExpired:
+			continue
+		case v.Version == current:
+			continue
+		case v.State == db.VersionProcessing && v.Version == latest:
+			continue
+		case v.State == db.VersionReady && kept < policy.Keep:
+			kept++
+			continue
+		}
+
+		finished := v.CompletedAt
+		if finished.IsZero() {
+			finished = v.StartedAt
+		}
+		if now.Sub(finished) >= policy.MinAge {
+			expired = append(expired, v)
+		}
+	}
+	return expired
+}
+
+// applyRetention deletes the files of the video's expired versions. A
+// version whose files another kept version still uses waits until that one
+// goes too. Aliases of duplicate uploads read their renditions from the
+// video when they are served, so they don't hold on to a version. Processing
+// has already finished, so failures are only logged.
+func (p *Processor) applyRetention(ctx context.Context, video *db.Video) {
+	versions, err := p.DB.ListVideoVersions(ctx, video.VideoID)
+	if err != nil {
+		log.Printf("Failed to list versions of videoID %s for retention: %v", video.VideoID, err)
+		return
+	}
+	expired := expiredVersions(versions, video.Version, video.LatestVersion, p.Retention, time.Now())
+	if len(expired) == 0 {
+		return
+	}
+
+	expiring := map[int]bool{}
+	for _, v := range expired {
+		expiring[v.Version] = true
+	}
+	inUse := map[string]bool{}
+	for _, v := range versions {
+		if v.State == db.VersionExpired || expiring[v.Version] {
+			continue
+		}
+		inUse[v.Prefix] = true
+		for _, prefix := range v.Prefixes {
+			inUse[prefix] = true
+		}
+	}
+
+	for _, v := range expired {
+		if inUse[v.Prefix] {
+			log.Printf("Keeping files of version %d of videoID %s, a newer version uses them", v.Version, video.VideoID)
+			continue
+		}
+		if err := purge.DeletePrefix(ctx, p.S3Client, p.S3Bucket, v.Prefix); err != nil {
+			log.Printf("Failed to delete files of version %d of videoID %s: %v", v.Version, video.VideoID, err)
+			continue
+		}
+		v.State = db.VersionExpired
+		v.ExpiredAt = time.Now().UTC()
+		v.Checkpoints = nil
+		if err := p.DB.PutVideoVersion(ctx, v); err != nil {
+			log.Printf("Failed to mark version %d of videoID %s as expired: %v", v.Version, video.VideoID, err)
+			continue
+		}
+		log.Printf("Expired version %d of videoID %s", v.Version, video.VideoID)
+	}
+}
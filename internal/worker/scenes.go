@@ -0,0 +1,209 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/pipeline"
+)
+
+const (
+	defaultSceneThreshold = 0.4
+	// Cuts closer together than this are treated as flashes or fades within
+	// a single shot.
+	minSceneDuration = 1.0
+)
+
+// Scene is a shot between two detected cuts. KeyframeKey points at a JPEG
+// taken from the middle of the shot.
+type Scene struct {
+	Index       int     `json:"index"`
+	Start       float64 `json:"start"`
+	End         float64 `json:"end"`
+	KeyframeKey string  `json:"keyframeKey"`
+}
+
+type scenesStage struct {
+	p *Processor
+}
+
+func (s *scenesStage) Name() string      { return "scenes" }
+func (s *scenesStage) Inputs() []string  { return []string{ArtifactTranscodedKey} }
+func (s *scenesStage) Outputs() []string { return []string{ArtifactScenesKey} }
+
+func (s *scenesStage) Run(ctx context.Context, job *pipeline.Job) error {
+	transcodedKey, err := job.Artifact(ArtifactTranscodedKey)
+	if err != nil {
+		return err
+	}
+	transcoded, err := s.p.localCopy(ctx, job, transcodedKey)
+	if err != nil {
+		return err
+	}
+
+	duration, err := probeDuration(ctx, transcoded)
+	if err != nil {
+		return err
+	}
+	cuts, err := detectSceneCuts(ctx, transcoded, s.p.SceneThreshold)
+	if err != nil {
+		return err
+	}
+	scenes := buildScenes(cuts, duration)
+	log.Printf("Detected %d scenes in videoID: %s", len(scenes), job.ID)
+
+	for i := range scenes {
+		scene := &scenes[i]
+		keyframe := filepath.Join(job.Dir, fmt.Sprintf("scene-%04d.jpg", scene.Index))
+		if err := extractFrame(ctx, transcoded, (scene.Start+scene.End)/2, keyframe); err != nil {
+			return err
+		}
+		scene.KeyframeKey = processedKey(job, fmt.Sprintf("scenes/scene-%04d.jpg", scene.Index))
+		if err := uploadFileToS3(ctx, s.p.S3Client, s.p.S3Bucket, scene.KeyframeKey, keyframe, "image/jpeg"); err != nil {
+			return err
+		}
+	}
+
+	if err := s.save(ctx, job, scenes); err != nil {
+		return err
+	}
+
+	key := processedKey(job, "scenes.json")
+	if err := uploadJSONToS3(ctx, s.p.S3Client, s.p.S3Bucket, key, scenes); err != nil {
+		return err
+	}
+
+	job.SetArtifact(ArtifactScenesKey, key)
+	return nil
+}
+
+func (s *scenesStage) Validate(ctx context.Context, outputs map[string]string) error {
+	return checkObject(ctx, s.p.S3Client, s.p.S3Bucket, outputs[ArtifactScenesKey])
+}
+
+func (s *scenesStage) Restore(ctx context.Context, job *pipeline.Job) error {
+	var scenes []Scene
+	if err := s.p.readJSONArtifact(ctx, job, ArtifactScenesKey, &scenes); err != nil {
+		return err
+	}
+	return s.save(ctx, job, scenes)
+}
+
+func (s *scenesStage) save(ctx context.Context, job *pipeline.Job, scenes []Scene) error {
+	segments := make([]db.AnalysisSegment, 0, len(scenes))
+	for _, scene := range scenes {
+		segments = append(segments, db.AnalysisSegment{
+			Type:        db.SegmentTypeScene,
+			Start:       scene.Start,
+			End:         scene.End,
+			Label:       fmt.Sprintf("scene %d", scene.Index),
+			KeyframeKey: scene.KeyframeKey,
+		})
+	}
+	if err := s.p.DB.PutAnalysisSegments(ctx, job.ID, db.SegmentTypeScene, segments); err != nil {
+		return fmt.Errorf("failed to save scenes: %w", err)
+	}
+	return nil
+}
+
+var ptsTimePattern = regexp.MustCompile(`pts_time:\s*([0-9.]+)`)
+
+// detectSceneCuts runs ffmpeg's scene score filter and returns the timestamps,
+// in seconds, of the frames whose score exceeds threshold.
+func detectSceneCuts(ctx context.Context, inputFile string, threshold float64) ([]float64, error) {
+	filter := fmt.Sprintf("select='gt(scene,%g)',showinfo", threshold)
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-hide_banner", "-i", inputFile, "-vf", filter, "-an", "-f", "null", "-")
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		log.Printf("FFmpeg scene detection failed: %v", err)
+		log.Printf("FFmpeg Output:\n%s", string(output))
+		return nil, fmt.Errorf("failed to detect scenes: %w", err)
+	}
+
+	return parseSceneCuts(string(output)), nil
+}
+
+func parseSceneCuts(output string) []float64 {
+	var cuts []float64
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.Contains(line, "Parsed_showinfo") {
+			continue
+		}
+		match := ptsTimePattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		t, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			continue
+		}
+		cuts = append(cuts, t)
+	}
+	sort.Float64s(cuts)
+	return cuts
+}
+
+// buildScenes splits [0, duration] at the given cuts, dropping cuts that
+// would leave a scene shorter than minSceneDuration.
+func buildScenes(cuts []float64, duration float64) []Scene {
+	boundaries := []float64{0}
+	for _, cut := range cuts {
+		if cut-boundaries[len(boundaries)-1] < minSceneDuration || duration-cut < minSceneDuration {
+			continue
+		}
+		boundaries = append(boundaries, cut)
+	}
+
+	end := duration
+	if end < boundaries[len(boundaries)-1] {
+		end = boundaries[len(boundaries)-1]
+	}
+	boundaries = append(boundaries, end)
+
+	scenes := make([]Scene, 0, len(boundaries)-1)
+	for i := 0; i < len(boundaries)-1; i++ {
+		scenes = append(scenes, Scene{
+			Index: i + 1,
+			Start: boundaries[i],
+			End:   boundaries[i+1],
+		})
+	}
+	return scenes
+}
+
+func extractFrame(ctx context.Context, inputFile string, at float64, outputFile string) error {
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-y", "-ss", strconv.FormatFloat(at, 'f', 3, 64),
+		"-i", inputFile, "-frames:v", "1", "-q:v", "2", outputFile)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		log.Printf("FFmpeg keyframe extraction failed: %v", err)
+		log.Printf("FFmpeg Output:\n%s", string(output))
+		return fmt.Errorf("failed to extract keyframe: %w", err)
+	}
+	return nil
+}
+
+func probeDuration(ctx context.Context, inputFile string) (float64, error) {
+	cmd := exec.CommandContext(ctx, "ffprobe", "-v", "error", "-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1", inputFile)
+
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("failed to probe duration: %w", err)
+	}
+	duration, err := strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse duration %q: %w", strings.TrimSpace(string(output)), err)
+	}
+	return duration, nil
+}
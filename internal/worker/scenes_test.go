@@ -0,0 +1,34 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const showinfoOutput = `Input #0, mov,mp4,m4a,3gp,3g2,mj2, from 'in.mp4':
+[Parsed_showinfo_1 @ 0x600] n:   0 pts: 153600 pts_time:12.5    duration:   512 pos:  1024 fmt:yuv420p
+[Parsed_showinfo_1 @ 0x600] n:   1 pts: 61440 pts_time:4.8     duration:   512 pos:  2048 fmt:yuv420p
+[Parsed_showinfo_1 @ 0x600] n:   2 pts: 65536 pts_time:5.2     duration:   512 pos:  4096 fmt:yuv420p
+frame=    3 fps=0.0 q=-0.0 Lsize=N/A time=00:00:20.00 bitrate=N/A speed= 150x
+`
+
+func TestParseSceneCuts(t *testing.T) {
+	assert.Equal(t, []float64{4.8, 5.2, 12.5}, parseSceneCuts(showinfoOutput))
+	assert.Empty(t, parseSceneCuts("no scene changes here"))
+}
+
+func TestBuildScenes(t *testing.T) {
+	scenes := buildScenes([]float64{4.8, 5.2, 12.5, 19.6}, 20)
+
+	// 5.2 is too close to 4.8 and 19.6 too close to the end to start a shot.
+	assert.Equal(t, []Scene{
+		{Index: 1, Start: 0, End: 4.8},
+		{Index: 2, Start: 4.8, End: 12.5},
+		{Index: 3, Start: 12.5, End: 20},
+	}, scenes)
+}
+
+func TestBuildScenesWithoutCuts(t *testing.T) {
+	assert.Equal(t, []Scene{{Index: 1, Start: 0, End: 7.5}}, buildScenes(nil, 7.5))
+}
@@ -0,0 +1,71 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/search"
+)
+
+// maxIndexedTranscript caps the transcript text kept in the search index so
+// the indexed document stays well under the DynamoDB item size limit.
+const maxIndexedTranscript = 64 << 10
+
+// indexSearch adds a processed video to the full-text search index.
+func (p *Processor) indexSearch(ctx context.Context, video *db.Video) error {
+	return IndexSearch(ctx, p.DB, video)
+}
+
+// IndexSearch (re)indexes a processed video for full-text search from its
+// stored metadata, labels and transcript.
+func IndexSearch(ctx context.Context, store *db.DB, video *db.Video) error {
+	transcript, err := store.GetAnalysisSegments(ctx, video.VideoID, db.SegmentFilter{
+		Types: []string{db.SegmentTypeTranscript},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load transcript for indexing: %w", err)
+	}
+
+	doc := searchDocument(video, transcript)
+	if err := store.PutSearchDocument(ctx, video.TenantID, doc); err != nil {
+		return fmt.Errorf("failed to index video for search: %w", err)
+	}
+	log.Printf("Indexed videoID %s for search", video.VideoID)
+	return nil
+}
+
+func searchDocument(video *db.Video, transcript []db.AnalysisSegment) *search.Document {
+	doc := &search.Document{
+		VideoID:     video.VideoID,
+		Title:       video.Title,
+		Description: video.Description,
+		Tags:        video.Tags,
+	}
+	if video.Analysis != nil {
+		for _, label := range video.Analysis.Labels {
+			doc.Labels = append(doc.Labels, label.Name)
+		}
+	}
+
+	texts := make([]string, 0, len(transcript))
+	for _, seg := range transcript {
+		texts = append(texts, strings.TrimSpace(seg.Text))
+	}
+	doc.Transcript = truncateText(strings.Join(texts, " "), maxIndexedTranscript)
+	return doc
+}
+
+// truncateText cuts text to at most limit bytes without splitting a rune.
+func truncateText(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	for limit > 0 && !utf8.RuneStart(text[limit]) {
+		limit--
+	}
+	return text[:limit]
+}
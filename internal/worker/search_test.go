@@ -0,0 +1,30 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchDocument(t *testing.T) {
+	video := &db.Video{
+		VideoID:  "vid-1",
+		Title:    "Kids playing soccer",
+		Tags:     []string{"transcoded"},
+		Analysis: &db.Analysis{Labels: []db.Label{{Name: "ball"}, {Name: "grass"}}},
+	}
+	transcript := []db.AnalysisSegment{{Text: " Great pass! "}, {Text: "And they score."}}
+
+	doc := searchDocument(video, transcript)
+
+	assert.Equal(t, "Kids playing soccer", doc.Title)
+	assert.Equal(t, []string{"ball", "grass"}, doc.Labels)
+	assert.Equal(t, "Great pass! And they score.", doc.Transcript)
+}
+
+func TestTruncateText(t *testing.T) {
+	assert.Equal(t, "short", truncateText("short", 10))
+	// "é" is two bytes and must not be split.
+	assert.Equal(t, "caf", truncateText("café", 4))
+}
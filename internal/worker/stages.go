@@ -0,0 +1,224 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/ryanschneiderman/video-api/internal/analyzer"
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/pipeline"
+	"github.com/ryanschneiderman/video-api/internal/profile"
+	"github.com/ryanschneiderman/video-api/internal/tenant"
+)
+
+// Artifact names passed between the processing stages.
+const (
+	ArtifactSourceKey      = "source_key"
+	ArtifactProbeKey       = "probe_key"
+	ArtifactTranscodedKey  = "transcoded_key"
+	ArtifactAnalysisKey    = "analysis_key"
+	ArtifactScenesKey      = "scenes_key"
+	ArtifactTranscriptKey  = "transcript_key"
+	ArtifactCaptionsVTTKey = "captions_vtt_key"
+	ArtifactCaptionsSRTKey = "captions_srt_key"
+	ArtifactLoudnessKey    = "loudness_key"
+	ArtifactAudioKey       = "audio_key"
+	ArtifactWaveformKey    = "waveform_key"
+	ArtifactFingerprintKey = "fingerprint_key"
+	ArtifactEmbeddingsKey  = "embeddings_key"
+	// ArtifactProfile is the name of the transcoding profile, not a key.
+	ArtifactProfile = "profile"
+)
+
+// renditionArtifacts are the artifacts recorded on the video as renditions
+// once processing finishes, keyed by rendition name.
+var renditionArtifacts = map[string]string{
+	"mp4":          ArtifactTranscodedKey,
+	"audio":        ArtifactAudioKey,
+	"waveform":     ArtifactWaveformKey,
+	"captions_vtt": ArtifactCaptionsVTTKey,
+	"captions_srt": ArtifactCaptionsSRTKey,
+}
+
+var defaultRetry = pipeline.RetryPolicy{MaxAttempts: 3, Backoff: 2 * time.Second}
+
+// buildPipeline registers the default processing stages. New stages only need
+// to declare their inputs and outputs to be slotted into the graph.
+func (p *Processor) buildPipeline() *pipeline.Pipeline {
+	pl := pipeline.New()
+	pl.Checkpointer = &videoCheckpointer{db: p.DB}
+	pl.MustRegister(&probeStage{p: p}, defaultRetry)
+	pl.MustRegister(&loudnessStage{p: p}, defaultRetry)
+	pl.MustRegister(&transcodeStage{p: p}, pipeline.NoRetry)
+	pl.MustRegister(&analyzeStage{p: p}, defaultRetry)
+	pl.MustRegister(&scenesStage{p: p}, defaultRetry)
+	pl.MustRegister(&fingerprintStage{p: p}, defaultRetry)
+	pl.MustRegister(&embedStage{p: p}, defaultRetry)
+	pl.MustRegister(&transcribeStage{p: p}, defaultRetry)
+	if p.AudioRenditions {
+		pl.MustRegister(&audioStage{p: p}, defaultRetry)
+	}
+	return pl
+}
+
+// StageNames lists every processing stage in pipeline order, including the
+// audio stage that only runs where audio renditions are enabled. A
+// reprocess can pick any of them.
+func StageNames() []string {
+	return (&Processor{AudioRenditions: true}).buildPipeline().Stages()
+}
+
+// processedKey is the S3 key of an artifact derived from a video, under its
+// tenant's prefix and the directory of the job's version.
+func processedKey(job *pipeline.Job, name string) string {
+	return tenant.ProcessedKey(job.Tenant, job.ID, versionDir(job.Version)+name)
+}
+
+type transcodeStage struct {
+	p *Processor
+}
+
+func (s *transcodeStage) Name() string { return "transcode" }
+func (s *transcodeStage) Inputs() []string {
+	return []string{ArtifactSourceKey, ArtifactLoudnessKey, ArtifactProfile}
+}
+func (s *transcodeStage) Outputs() []string { return []string{ArtifactTranscodedKey} }
+
+func (s *transcodeStage) Run(ctx context.Context, job *pipeline.Job) error {
+	sourceKey, err := job.Artifact(ArtifactSourceKey)
+	if err != nil {
+		return err
+	}
+	localInputFile, err := s.p.localCopy(ctx, job, sourceKey)
+	if err != nil {
+		return err
+	}
+	profileName, err := job.Artifact(ArtifactProfile)
+	if err != nil {
+		return err
+	}
+	prof, err := profile.Get(profileName)
+	if err != nil {
+		return pipeline.Permanent(err)
+	}
+	loudness, err := s.p.readLoudness(ctx, job)
+	if err != nil {
+		return err
+	}
+	audioFilter := ""
+	if loudness.normalize() {
+		audioFilter = loudness.filter()
+	}
+	localOutputFile := filepath.Join(job.Dir, "transcoded.mp4")
+
+	if err := transcodeVideo(localInputFile, localOutputFile, audioFilter, prof); err != nil {
+		return fmt.Errorf("failed to transcode video: %w", err)
+	}
+	log.Printf("Transcoding complete: %s", localOutputFile)
+
+	key := processedKey(job, "transcoded.mp4")
+	if err := uploadFileToS3(ctx, s.p.S3Client, s.p.S3Bucket, key, localOutputFile, "video/mp4"); err != nil {
+		return err
+	}
+
+	job.SetArtifact(ArtifactTranscodedKey, key)
+	return nil
+}
+
+func (s *transcodeStage) Validate(ctx context.Context, outputs map[string]string) error {
+	return checkObject(ctx, s.p.S3Client, s.p.S3Bucket, outputs[ArtifactTranscodedKey])
+}
+
+type analyzeStage struct {
+	p *Processor
+}
+
+func (s *analyzeStage) Name() string      { return "analyze" }
+func (s *analyzeStage) Inputs() []string  { return []string{ArtifactTranscodedKey} }
+func (s *analyzeStage) Outputs() []string { return []string{ArtifactAnalysisKey} }
+
+func (s *analyzeStage) Run(ctx context.Context, job *pipeline.Job) error {
+	transcodedKey, err := job.Artifact(ArtifactTranscodedKey)
+	if err != nil {
+		return err
+	}
+	transcoded, err := s.p.localCopy(ctx, job, transcodedKey)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.p.Analyzer.Analyze(ctx, transcoded)
+	if err != nil {
+		return fmt.Errorf("%s analyzer failed: %w", s.p.Analyzer.Name(), err)
+	}
+	log.Printf("Analyzer %s found %d labels and %d segments for videoID: %s",
+		result.Analyzer, len(result.Labels), len(result.Segments), job.ID)
+
+	key := processedKey(job, "analysis.json")
+	if err := uploadJSONToS3(ctx, s.p.S3Client, s.p.S3Bucket, key, result); err != nil {
+		return err
+	}
+
+	if err := s.save(ctx, job, result); err != nil {
+		return err
+	}
+
+	job.SetArtifact(ArtifactAnalysisKey, key)
+	return nil
+}
+
+func (s *analyzeStage) Validate(ctx context.Context, outputs map[string]string) error {
+	return checkObject(ctx, s.p.S3Client, s.p.S3Bucket, outputs[ArtifactAnalysisKey])
+}
+
+func (s *analyzeStage) Restore(ctx context.Context, job *pipeline.Job) error {
+	var result analyzer.Result
+	if err := s.p.readJSONArtifact(ctx, job, ArtifactAnalysisKey, &result); err != nil {
+		return err
+	}
+	return s.save(ctx, job, &result)
+}
+
+func (s *analyzeStage) save(ctx context.Context, job *pipeline.Job, result *analyzer.Result) error {
+	analysis, segments := toDBAnalysis(result)
+	if err := s.p.DB.PutAnalysisSegments(ctx, job.ID, db.SegmentTypeLabel, segments); err != nil {
+		return fmt.Errorf("failed to save analysis segments: %w", err)
+	}
+	err := s.p.DB.UpdateVideo(ctx, job.ID, map[string]interface{}{
+		"analysis": analysis,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save analysis: %w", err)
+	}
+	return nil
+}
+
+func toDBAnalysis(result *analyzer.Result) (db.Analysis, []db.AnalysisSegment) {
+	analysis := db.Analysis{
+		Analyzer:     result.Analyzer,
+		ModelVersion: result.ModelVersion,
+		Summary:      result.Summary,
+		Labels:       make([]db.Label, 0, len(result.Labels)),
+		SegmentCount: len(result.Segments),
+		AnalyzedAt:   time.Now().UTC(),
+	}
+	for _, l := range result.Labels {
+		analysis.Labels = append(analysis.Labels, db.Label{Name: l.Name, Confidence: l.Confidence})
+	}
+
+	segments := make([]db.AnalysisSegment, 0, len(result.Segments))
+	for _, seg := range result.Segments {
+		segments = append(segments, db.AnalysisSegment{
+			Type:       db.SegmentTypeLabel,
+			Start:      seg.Start,
+			End:        seg.End,
+			Label:      seg.Label,
+			Text:       seg.Text,
+			Confidence: seg.Confidence,
+		})
+	}
+	return analysis, segments
+}
@@ -0,0 +1,192 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/pipeline"
+	"github.com/ryanschneiderman/video-api/internal/tenant"
+	"github.com/ryanschneiderman/video-api/internal/transcriber"
+)
+
+type transcribeStage struct {
+	p *Processor
+}
+
+func (s *transcribeStage) Name() string     { return "transcribe" }
+func (s *transcribeStage) Inputs() []string { return []string{ArtifactSourceKey, ArtifactProbeKey} }
+func (s *transcribeStage) Outputs() []string {
+	return []string{ArtifactTranscriptKey, ArtifactCaptionsVTTKey, ArtifactCaptionsSRTKey}
+}
+
+func (s *transcribeStage) Run(ctx context.Context, job *pipeline.Job) error {
+	sourceKey, err := job.Artifact(ArtifactSourceKey)
+	if err != nil {
+		return err
+	}
+	source, err := s.p.localCopy(ctx, job, sourceKey)
+	if err != nil {
+		return err
+	}
+
+	transcript := &transcriber.Transcript{Transcriber: s.p.Transcriber.Name(), Segments: []transcriber.Segment{}}
+	probe, err := s.p.readProbe(ctx, job)
+	if err != nil {
+		return err
+	}
+	if probe.HasAudio {
+		audio := filepath.Join(job.Dir, "audio.wav")
+		if err := extractAudio(ctx, source, audio); err != nil {
+			return err
+		}
+		transcript, err = s.p.Transcriber.Transcribe(ctx, audio)
+		if err != nil {
+			return fmt.Errorf("%s transcriber failed: %w", s.p.Transcriber.Name(), err)
+		}
+	} else {
+		log.Printf("No audio stream in videoID: %s", job.ID)
+	}
+	log.Printf("Transcribed %d segments for videoID: %s", len(transcript.Segments), job.ID)
+
+	transcriptKey := processedKey(job, "transcript.json")
+	if err := uploadJSONToS3(ctx, s.p.S3Client, s.p.S3Bucket, transcriptKey, transcript); err != nil {
+		return err
+	}
+	job.SetArtifact(ArtifactTranscriptKey, transcriptKey)
+
+	// A video without speech has no captions. Its caption artifacts are
+	// recorded empty, so the checkpoint still holds and no rendition links
+	// to an empty file.
+	vttKey, srtKey := "", ""
+	if len(transcript.Segments) > 0 {
+		vttKey = processedKey(job, "captions/captions.vtt")
+		srtKey = processedKey(job, "captions/captions.srt")
+		if err := uploadBytesToS3(ctx, s.p.S3Client, s.p.S3Bucket, vttKey, []byte(transcriber.WebVTT(transcript.Segments)), "text/vtt"); err != nil {
+			return err
+		}
+		if err := uploadBytesToS3(ctx, s.p.S3Client, s.p.S3Bucket, srtKey, []byte(transcriber.SRT(transcript.Segments)), "application/x-subrip"); err != nil {
+			return err
+		}
+	}
+	job.SetArtifact(ArtifactCaptionsVTTKey, vttKey)
+	job.SetArtifact(ArtifactCaptionsSRTKey, srtKey)
+	return s.save(ctx, job, transcript)
+}
+
+// Validate checks the transcript and, unless the video had no speech, the
+// caption files.
+func (s *transcribeStage) Validate(ctx context.Context, outputs map[string]string) error {
+	if err := checkObject(ctx, s.p.S3Client, s.p.S3Bucket, outputs[ArtifactTranscriptKey]); err != nil {
+		return err
+	}
+	vttKey, srtKey := outputs[ArtifactCaptionsVTTKey], outputs[ArtifactCaptionsSRTKey]
+	if vttKey == "" && srtKey == "" {
+		return nil
+	}
+	for _, key := range []string{vttKey, srtKey} {
+		if err := checkObject(ctx, s.p.S3Client, s.p.S3Bucket, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *transcribeStage) Restore(ctx context.Context, job *pipeline.Job) error {
+	var transcript transcriber.Transcript
+	if err := s.p.readJSONArtifact(ctx, job, ArtifactTranscriptKey, &transcript); err != nil {
+		return err
+	}
+	return s.save(ctx, job, &transcript)
+}
+
+// save stores the transcript segments and points the HLS captions at the
+// job's captions.
+func (s *transcribeStage) save(ctx context.Context, job *pipeline.Job, transcript *transcriber.Transcript) error {
+	segments := make([]db.AnalysisSegment, 0, len(transcript.Segments))
+	for _, seg := range transcript.Segments {
+		segments = append(segments, db.AnalysisSegment{
+			Type:  db.SegmentTypeTranscript,
+			Start: seg.Start,
+			End:   seg.End,
+			Text:  seg.Text,
+		})
+	}
+	if err := s.p.DB.PutAnalysisSegments(ctx, job.ID, db.SegmentTypeTranscript, segments); err != nil {
+		return fmt.Errorf("failed to save transcript: %w", err)
+	}
+	return s.p.linkCaptions(ctx, job, transcript)
+}
+
+// linkCaptions adds the WebVTT captions to the video's HLS master playlist as
+// a subtitle rendition. Videos without HLS output or without captions are
+// left alone. Packaging isn't versioned, so the playlists stay outside the
+// version directories and point into the one the captions are in.
+func (p *Processor) linkCaptions(ctx context.Context, job *pipeline.Job, transcript *transcriber.Transcript) error {
+	vttKey, err := job.Artifact(ArtifactCaptionsVTTKey)
+	if err != nil || vttKey == "" {
+		return nil
+	}
+	prefix := tenant.ProcessedKey(job.Tenant, job.ID, "")
+	captions, ok := strings.CutPrefix(vttKey, prefix)
+	if !ok {
+		return fmt.Errorf("captions %s are not under %s", vttKey, prefix)
+	}
+	masterKey := prefix + hlsMasterName
+	exists, err := objectExists(ctx, p.S3Client, p.S3Bucket, masterKey)
+	if err != nil || !exists {
+		return err
+	}
+
+	local, err := p.localCopy(ctx, job, masterKey)
+	if err != nil {
+		return err
+	}
+	master, err := os.ReadFile(local)
+	if err != nil {
+		return fmt.Errorf("failed to read HLS master playlist: %w", err)
+	}
+
+	var duration float64
+	for _, seg := range transcript.Segments {
+		if seg.End > duration {
+			duration = seg.End
+		}
+	}
+	language := transcript.Language
+	if language == "" {
+		language = "en"
+	}
+
+	// Both paths are relative to the master playlist under the video's hls directory.
+	subtitles := subtitlePlaylist("../"+captions, duration)
+	subtitlesKey := prefix + "hls/subtitles.m3u8"
+	if err := uploadBytesToS3(ctx, p.S3Client, p.S3Bucket, subtitlesKey, []byte(subtitles), hlsContentType); err != nil {
+		return err
+	}
+	linked := addSubtitleRendition(string(master), "subtitles.m3u8", language)
+	if err := uploadBytesToS3(ctx, p.S3Client, p.S3Bucket, masterKey, []byte(linked), hlsContentType); err != nil {
+		return err
+	}
+	log.Printf("Linked captions into HLS master playlist for videoID: %s", job.ID)
+	return nil
+}
+
+// extractAudio writes the first audio track as 16kHz mono WAV, the format
+// speech models generally expect.
+func extractAudio(ctx context.Context, inputFile, outputFile string) error {
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-y", "-i", inputFile, "-vn", "-ac", "1", "-ar", "16000", "-f", "wav", outputFile)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		log.Printf("FFmpeg audio extraction failed: %v", err)
+		log.Printf("FFmpeg Output:\n%s", string(output))
+		return fmt.Errorf("failed to extract audio: %w", err)
+	}
+	return nil
+}
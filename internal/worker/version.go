@@ -0,0 +1,238 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/pipeline"
+	"github.com/ryanschneiderman/video-api/internal/profile"
+	"github.com/ryanschneiderman/video-api/internal/tenant"
+	"github.com/ryanschneiderman/video-api/internal/transcriber"
+)
+
+// versionDir is the directory a version's files are written to, relative to
+// the video's processed prefix. Version 0 is the layout from before videos
+// were versioned, with files directly under the prefix.
+func versionDir(version int) string {
+	if version < 1 {
+		return ""
+	}
+	return fmt.Sprintf("v%d/", version)
+}
+
+// versionPrefix is the S3 prefix of every file a version wrote.
+func versionPrefix(tenantID, videoID string, version int) string {
+	return tenant.ProcessedKey(tenantID, videoID, versionDir(version))
+}
+
+var versionDirPattern = regexp.MustCompile(`^v([0-9]+)/`)
+
+// keyVersion reports which version wrote the file at key, or false for
+// files of another video or from before versioning.
+func keyVersion(tenantID, videoID, key string) (int, bool) {
+	prefix := tenant.ProcessedKey(tenantID, videoID, "")
+	if len(key) <= len(prefix) || key[:len(prefix)] != prefix {
+		return 0, false
+	}
+	match := versionDirPattern.FindStringSubmatch(key[len(prefix):])
+	if match == nil {
+		return 0, false
+	}
+	version, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, false
+	}
+	return version, true
+}
+
+// startVersion records the run about to process the video. A redelivered
+// job carries on with the version its first delivery started.
+func (p *Processor) startVersion(ctx context.Context, video *db.Video, profileName string) (*db.VideoVersion, error) {
+	if video.Status == db.StatusProcessing && video.LatestVersion > video.Version {
+		version, err := p.DB.GetVideoVersion(ctx, video.VideoID, video.LatestVersion)
+		if err == nil && version.State == db.VersionProcessing {
+			log.Printf("Resuming version %d of videoID %s", version.Version, video.VideoID)
+			return version, nil
+		}
+		if err != nil && !errors.Is(err, db.ErrVersionNotFound) {
+			return nil, fmt.Errorf("failed to load version: %w", err)
+		}
+	}
+
+	number, err := p.DB.AllocateVersion(ctx, video.VideoID)
+	if err != nil {
+		if errors.Is(err, db.ErrVideoNotFound) {
+			return nil, pipeline.Permanent(err)
+		}
+		return nil, err
+	}
+	version := &db.VideoVersion{
+		VideoID:   video.VideoID,
+		Version:   number,
+		State:     db.VersionProcessing,
+		Profile:   profileName,
+		Prefix:    versionPrefix(video.TenantID, video.VideoID, number),
+		StartedAt: time.Now().UTC(),
+	}
+	if err := p.DB.PutVideoVersion(ctx, *version); err != nil {
+		return nil, err
+	}
+	log.Printf("Started version %d of videoID %s", number, video.VideoID)
+	return version, nil
+}
+
+// completeVersion marks a version ready and records what it is made of:
+// the video's checkpoints, which promoting the version restores, the
+// version prefixes its files are under and the models behind its results.
+func (p *Processor) completeVersion(ctx context.Context, job *pipeline.Job, video *db.Video, version *db.VideoVersion, renditions map[string]string) error {
+	version.State = db.VersionReady
+	version.Renditions = renditions
+	version.Checkpoints = video.Checkpoints
+	version.Prefixes = versionPrefixes(video, version)
+	version.Analyzers = p.versionAnalyzers(ctx, job, video)
+	// A promoted version keeps the time its run finished.
+	if version.CompletedAt.IsZero() {
+		version.CompletedAt = time.Now().UTC()
+	}
+
+	if err := p.DB.PutVideoVersion(ctx, *version); err != nil {
+		return fmt.Errorf("failed to save version: %w", err)
+	}
+	return nil
+}
+
+// versionPrefixes lists the prefixes of every version whose files the
+// version uses, including its own, in version order. Stages a run skipped
+// keep the files of the run that last did them.
+func versionPrefixes(video *db.Video, version *db.VideoVersion) []string {
+	seen := map[int]bool{version.Version: true}
+	for _, cp := range video.Checkpoints {
+		for _, key := range cp.Artifacts {
+			if n, ok := keyVersion(video.TenantID, video.VideoID, key); ok {
+				seen[n] = true
+			}
+		}
+	}
+
+	numbers := make([]int, 0, len(seen))
+	for n := range seen {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+	prefixes := make([]string, 0, len(numbers))
+	for _, n := range numbers {
+		prefixes = append(prefixes, versionPrefix(video.TenantID, video.VideoID, n))
+	}
+	return prefixes
+}
+
+// versionAnalyzers names the analyzer, transcriber and embedder whose
+// results the version uses, keyed by stage. They are read back from the
+// results rather than taken from this worker, since skipped stages keep the
+// results of earlier runs. Results that can't be read are left out.
+func (p *Processor) versionAnalyzers(ctx context.Context, job *pipeline.Job, video *db.Video) map[string]string {
+	analyzers := map[string]string{}
+	if video.Analysis != nil {
+		name := video.Analysis.Analyzer
+		if video.Analysis.ModelVersion != "" {
+			name += "@" + video.Analysis.ModelVersion
+		}
+		analyzers["analyze"] = name
+	}
+
+	var transcript transcriber.Transcript
+	if err := p.readJSONArtifact(ctx, job, ArtifactTranscriptKey, &transcript); err != nil {
+		log.Printf("Failed to read transcript to record the transcriber of videoID %s: %v", job.ID, err)
+	} else {
+		analyzers["transcribe"] = transcript.Transcriber
+	}
+
+	var embeddings Embeddings
+	if err := p.readJSONArtifact(ctx, job, ArtifactEmbeddingsKey, &embeddings); err != nil {
+		log.Printf("Failed to read embeddings to record the embedder of videoID %s: %v", job.ID, err)
+	} else {
+		analyzers["embed"] = embeddings.Embedder
+	}
+	return analyzers
+}
+
+// failVersion marks the run that was processing the video as failed. The
+// video's failure is already saved, so errors are only logged.
+func (p *Processor) failVersion(ctx context.Context, video *db.Video, cause error) {
+	if video.LatestVersion <= video.Version {
+		return
+	}
+	version, err := p.DB.GetVideoVersion(ctx, video.VideoID, video.LatestVersion)
+	if err != nil {
+		if !errors.Is(err, db.ErrVersionNotFound) {
+			log.Printf("Failed to load version %d of videoID %s: %v", video.LatestVersion, video.VideoID, err)
+		}
+		return
+	}
+	if version.State != db.VersionProcessing {
+		return
+	}
+	version.State = db.VersionFailed
+	version.CompletedAt = time.Now().UTC()
+	if err := p.DB.PutVideoVersion(ctx, *version); err != nil {
+		log.Printf("Failed to mark version %d of videoID %s as failed: %v", version.Version, video.VideoID, err)
+		return
+	}
+	log.Printf("Marked version %d of videoID %s as failed: %v", version.Version, video.VideoID, cause)
+}
+
+// PromoteVersion runs a promote job, see reprocess.Message. The version's
+// checkpoints replace the video's and the pipeline runs with them, so every
+// stage whose files are still there is skipped and restores its results
+// instead, and the rest run again into the version's directory.
+func (p *Processor) PromoteVersion(ctx context.Context, msg SQSMessage) error {
+	video, err := p.DB.GetVideoById(ctx, msg.VideoID)
+	if err != nil {
+		if errors.Is(err, db.ErrVideoNotFound) {
+			return pipeline.Permanent(err)
+		}
+		return fmt.Errorf("failed to load video: %w", err)
+	}
+	if video.Status != db.StatusUploaded && video.Status != db.StatusProcessing {
+		log.Printf("VideoID %s is %s, not promoting it again", video.VideoID, video.Status)
+		return nil
+	}
+	if err := checkSourceKey(video, msg.Filename); err != nil {
+		log.Printf("Dropping message for videoID %s: %v", video.VideoID, err)
+		return nil
+	}
+
+	version, err := p.DB.GetVideoVersion(ctx, video.VideoID, msg.Version)
+	if err != nil {
+		if errors.Is(err, db.ErrVersionNotFound) {
+			return pipeline.Permanent(err)
+		}
+		return err
+	}
+	if version.State != db.VersionReady {
+		return pipeline.Permanent(fmt.Errorf("version %d is %s, only ready versions can be promoted", version.Version, version.State))
+	}
+	prof, err := profile.Get(version.Profile)
+	if err != nil {
+		return pipeline.Permanent(err)
+	}
+
+	// A redelivered job already has the checkpoints, and maybe newer ones
+	// for stages that had to run again.
+	if video.Status == db.StatusUploaded {
+		if err := p.DB.SetCheckpoints(ctx, video.VideoID, version.Checkpoints); err != nil {
+			return err
+		}
+	}
+	log.Printf("Promoting version %d of videoID %s", version.Version, video.VideoID)
+
+	version.PromotedAt = time.Now().UTC()
+	return p.runVersion(ctx, video, msg.Filename, prof, version, true)
+}
@@ -0,0 +1,79 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/pipeline"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessedKeyIsVersioned(t *testing.T) {
+	job := pipeline.NewJob("v1", nil)
+	job.Tenant = "acme"
+	assert.Equal(t, "tenants/acme/processed/v1/transcoded.mp4", processedKey(job, "transcoded.mp4"))
+
+	job.Version = 4
+	assert.Equal(t, "tenants/acme/processed/v1/v4/transcoded.mp4", processedKey(job, "transcoded.mp4"))
+}
+
+func TestKeyVersion(t *testing.T) {
+	n, ok := keyVersion("acme", "v1", "tenants/acme/processed/v1/v12/scenes/scene-0001.jpg")
+	assert.True(t, ok)
+	assert.Equal(t, 12, n)
+
+	_, ok = keyVersion("acme", "v1", "tenants/acme/processed/v1/transcoded.mp4")
+	assert.False(t, ok, "files from before versioning have no version")
+	_, ok = keyVersion("acme", "v1", "tenants/acme/processed/v10/v2/transcoded.mp4")
+	assert.False(t, ok, "files of another video")
+	_, ok = keyVersion("acme", "v1", "h264-720p")
+	assert.False(t, ok)
+}
+
+func TestVersionPrefixes(t *testing.T) {
+	video := &db.Video{
+		VideoID:  "v1",
+		TenantID: "acme",
+		Checkpoints: map[string]db.StageCheckpoint{
+			"probe":     {Artifacts: map[string]string{ArtifactProbeKey: "tenants/acme/processed/v1/probe.json"}},
+			"transcode": {Artifacts: map[string]string{ArtifactTranscodedKey: "tenants/acme/processed/v1/v2/transcoded.mp4"}},
+			"analyze":   {Artifacts: map[string]string{ArtifactAnalysisKey: "tenants/acme/processed/v1/v3/analysis.json"}},
+		},
+	}
+
+	prefixes := versionPrefixes(video, &db.VideoVersion{Version: 3})
+
+	assert.Equal(t, []string{"tenants/acme/processed/v1/v2/", "tenants/acme/processed/v1/v3/"}, prefixes)
+}
+
+func TestExpiredVersions(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	version := func(n int, state string, age time.Duration) db.VideoVersion {
+		return db.VideoVersion{Version: n, State: state, StartedAt: now.Add(-age), CompletedAt: now.Add(-age)}
+	}
+	// Newest first, as listed.
+	versions := []db.VideoVersion{
+		{Version: 8, State: db.VersionProcessing, StartedAt: now},
+		version(7, db.VersionReady, time.Hour),
+		version(6, db.VersionFailed, 48*time.Hour),
+		version(5, db.VersionReady, 48*time.Hour),
+		version(4, db.VersionReady, 48*time.Hour),
+		version(3, db.VersionReady, time.Hour),
+		{Version: 2, State: db.VersionProcessing, StartedAt: now.Add(-72 * time.Hour)},
+		version(1, db.VersionExpired, 96*time.Hour),
+	}
+	policy := Retention{Keep: 3, MinAge: 24 * time.Hour}
+
+	expired := expiredVersions(versions, 4, 8, policy, now)
+
+	var numbers []int
+	for _, v := range expired {
+		numbers = append(numbers, v.Version)
+	}
+	// 4 is current and 7 and 5 are the newest other ready versions. 3 is
+	// past the count but too recent, and 2 is an abandoned run.
+	assert.Equal(t, []int{6, 2}, numbers)
+
+	assert.Empty(t, expiredVersions(versions, 4, 8, Retention{Keep: 3, MinAge: 30 * 24 * time.Hour}, now))
+}
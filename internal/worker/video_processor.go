@@ -1,45 +1,128 @@
 package worker
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/ryanschneiderman/video-api/internal/analyzer"
 	"github.com/ryanschneiderman/video-api/internal/app"
 	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/ryanschneiderman/video-api/internal/embedder"
+	"github.com/ryanschneiderman/video-api/internal/events"
+	"github.com/ryanschneiderman/video-api/internal/fetch"
+	"github.com/ryanschneiderman/video-api/internal/pipeline"
+	"github.com/ryanschneiderman/video-api/internal/playback"
+	"github.com/ryanschneiderman/video-api/internal/profile"
+	"github.com/ryanschneiderman/video-api/internal/reprocess"
+	"github.com/ryanschneiderman/video-api/internal/semantic"
+	"github.com/ryanschneiderman/video-api/internal/tenant"
+	"github.com/ryanschneiderman/video-api/internal/transcriber"
+	"github.com/ryanschneiderman/video-api/internal/webhook"
 )
 
 type Processor struct {
-	SQSClient *sqs.Client
-	S3Client *s3.Client
-	S3Bucket string
-	QueueURL  string
-	DB       *db.DB
+	SQSClient     *sqs.Client
+	S3Client      *s3.Client
+	S3Bucket      string
+	QueueURL      string
+	DB            *db.DB
+	Analyzer      analyzer.Analyzer
+	Transcriber   transcriber.Transcriber
+	Embedder      embedder.Embedder
+	SemanticIndex *semantic.Store
+	Pipeline      *pipeline.Pipeline
+	Webhooks      *webhook.Dispatcher
+	Events        *events.Publisher
+	// Fetcher downloads the sources of imported videos, which count
+	// towards StorageQuotaBytes like uploads.
+	Fetcher           *fetch.Fetcher
+	StorageQuotaBytes int64
+
+	SceneThreshold  float64
+	LoudnessTarget  float64
+	AudioRenditions bool
+	// Retention decides when the files of past versions are deleted.
+	Retention Retention
+
+	fetchLocks sync.Map
 }
 
 type SQSMessage struct {
-	VideoID  string `json:"video_id"`
-	Filename string `json:"filename"`
+	VideoID   string `json:"video_id"`
+	Filename  string `json:"filename"`
 	EventType string `json:"event_type,omitempty"`
+	// Job is JobImport for imports, reprocess.Job to reprocess,
+	// reprocess.JobPromote to promote a version, and empty for processing.
+	Job string `json:"job,omitempty"`
+	// Stages, Profile and RequestedAt describe a reprocess job, see
+	// reprocess.Message.
+	Stages      []string  `json:"stages,omitempty"`
+	Profile     string    `json:"profile,omitempty"`
+	RequestedAt time.Time `json:"requested_at,omitzero"`
+	// Version is the version a promote job makes current.
+	Version int `json:"version,omitempty"`
 }
 
-func NewProcessor(app *app.App) *Processor {
-	return &Processor{
-		SQSClient: app.SQSClient,
-		S3Client: app.S3Client,
-		S3Bucket: app.S3Bucket,
-		QueueURL:  app.QueueURL,
-		DB:        app.DB,
+func NewProcessor(app *app.App) (*Processor, error) {
+	a, err := analyzer.New(app.Analyzer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create analyzer: %w", err)
+	}
+	t, err := transcriber.New(app.Transcriber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transcriber: %w", err)
+	}
+	e, err := embedder.New(app.Embedder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedder: %w", err)
+	}
+
+	p := &Processor{
+		SQSClient:         app.SQSClient,
+		S3Client:          app.S3Client,
+		S3Bucket:          app.S3Bucket,
+		QueueURL:          app.QueueURL,
+		DB:                app.DB,
+		Analyzer:          a,
+		Transcriber:       t,
+		Embedder:          e,
+		SemanticIndex:     semantic.NewStore(app.S3Client, app.S3Bucket, e),
+		Webhooks:          webhook.NewDispatcher(app.DB, app.Webhooks),
+		Events:            events.NewPublisher(app.Events, app.SNSClient, app.SQSClient),
+		Fetcher:           fetch.New(app.Import),
+		StorageQuotaBytes: app.StorageQuotaBytes,
+		SceneThreshold:    app.SceneThreshold,
+		LoudnessTarget:    app.LoudnessTarget,
+		AudioRenditions:   app.AudioRenditions,
+		Retention:         Retention{Keep: int(app.VersionRetainCount), MinAge: app.VersionRetainFor},
 	}
+	if p.SceneThreshold <= 0 {
+		p.SceneThreshold = defaultSceneThreshold
+	}
+	if p.LoudnessTarget == 0 {
+		p.LoudnessTarget = defaultLoudnessTarget
+	}
+	if p.Retention.Keep < 1 {
+		p.Retention.Keep = defaultRetainCount
+	}
+	p.Pipeline = p.buildPipeline()
+	return p, nil
 }
 
 func (p *Processor) ProcessMessages(ctx context.Context) error {
@@ -48,7 +131,7 @@ func (p *Processor) ProcessMessages(ctx context.Context) error {
 		MaxNumberOfMessages: 5, // Processes 5 messages concurrently, increase this value to increase throughput
 		WaitTimeSeconds:     10,
 		AttributeNames: []types.QueueAttributeName{
-			types.QueueAttributeName("ApproximateReceiveCount"), 
+			types.QueueAttributeName("ApproximateReceiveCount"),
 		},
 	})
 	if err != nil {
@@ -80,11 +163,18 @@ func (p *Processor) HandleMessage(ctx context.Context, msg *types.Message) error
 
 	receiveCount := msg.Attributes["ApproximateReceiveCount"]
 	log.Printf("Processing video_id: %s, filename: %s, receive count: %s",
-			sqsMsg.VideoID, sqsMsg.Filename, receiveCount)
+		sqsMsg.VideoID, sqsMsg.Filename, receiveCount)
 
-	if err := p.ProcessVideo(ctx, sqsMsg.VideoID, sqsMsg.Filename); err != nil {
+	if err := p.runJob(ctx, sqsMsg); err != nil {
 		log.Printf("Error processing video %s: %v", sqsMsg.VideoID, err)
-		return err
+		if !pipeline.IsPermanent(err) {
+			return err
+		}
+		// Redelivering the message can't fix the input, so record why the
+		// video failed and drop it.
+		if err := p.markFailed(ctx, sqsMsg.VideoID, err); err != nil {
+			return err
+		}
 	}
 
 	_, err := p.SQSClient.DeleteMessage(ctx, &sqs.DeleteMessageInput{
@@ -100,50 +190,275 @@ func (p *Processor) HandleMessage(ctx context.Context, msg *types.Message) error
 	return nil
 }
 
+func (p *Processor) runJob(ctx context.Context, msg SQSMessage) error {
+	switch msg.Job {
+	case JobImport:
+		return p.ImportVideo(ctx, msg.VideoID, msg.Filename)
+	case reprocess.Job:
+		return p.ReprocessVideo(ctx, msg)
+	case reprocess.JobPromote:
+		return p.PromoteVersion(ctx, msg)
+	}
+	return p.ProcessVideo(ctx, msg.VideoID, msg.Filename)
+}
+
 func (p *Processor) ProcessVideo(ctx context.Context, videoID string, filename string) error {
-	localInputFile := fmt.Sprintf("/tmp/%s", filename)
-	localOutputFile := fmt.Sprintf("/tmp/%s-transcoded.mp4", videoID)
+	video, err := p.DB.GetVideoById(ctx, videoID)
+	if err != nil {
+		if errors.Is(err, db.ErrVideoNotFound) {
+			return pipeline.Permanent(err)
+		}
+		return fmt.Errorf("failed to load video: %w", err)
+	}
+	if video.Status != db.StatusUploaded && video.Status != db.StatusProcessing {
+		log.Printf("VideoID %s is %s, not processing it again", video.VideoID, video.Status)
+		return nil
+	}
+	if err := checkSourceKey(video, filename); err != nil {
+		log.Printf("Dropping message for videoID %s: %v", video.VideoID, err)
+		return nil
+	}
+	return p.processVideo(ctx, video, filename, video.Profile)
+}
+
+// checkSourceKey checks that a message's key is the video's source, or,
+// before the source is stored, a key in the video's place in its tenant's
+// prefix. Messages that fail it are dropped rather than failing the video
+// they name.
+func checkSourceKey(video *db.Video, key string) error {
+	if source, ok := playback.SourceKey(video); ok {
+		if key != source {
+			return fmt.Errorf("%s is not the source of videoID %s", key, video.VideoID)
+		}
+		return nil
+	}
+	if !strings.HasPrefix(key, tenant.ObjectKey(video.TenantID, video.VideoID+"-")) {
+		return fmt.Errorf("%s is not a source key of videoID %s", key, video.VideoID)
+	}
+	return nil
+}
 
-	err := downloadFromS3(ctx, p.S3Client, p.S3Bucket, filename, localInputFile)
+// processVideo runs the pipeline on the video's source with the named
+// transcoding profile, as a new version of the video. Stages with a valid
+// checkpoint are skipped.
+func (p *Processor) processVideo(ctx context.Context, video *db.Video, filename string, profileName string) error {
+	prof, err := profile.Get(profileName)
 	if err != nil {
-		return fmt.Errorf("failed to download file from S3: %w", err)
+		return pipeline.Permanent(err)
+	}
+	version, err := p.startVersion(ctx, video, prof.Name)
+	if err != nil {
+		return err
 	}
-	log.Printf("Downloaded %s from S3 to %s", filename, localInputFile)
+	return p.runVersion(ctx, video, filename, prof, version, false)
+}
 
-	err = transcodeVideo(localInputFile, localOutputFile)
+// runVersion runs the pipeline for a version of the video and makes it the
+// current one. With restore, skipped stages save their results again.
+func (p *Processor) runVersion(ctx context.Context, video *db.Video, filename string, prof profile.Profile, version *db.VideoVersion, restore bool) error {
+	videoID := video.VideoID
+	dir, err := os.MkdirTemp("", videoID+"-")
 	if err != nil {
-		return fmt.Errorf("failed to transcode video: %w", err)
+		return fmt.Errorf("failed to create scratch directory: %w", err)
 	}
-	log.Printf("Transcoding complete: %s", localOutputFile)
+	defer p.cleanupJob(dir)
+
+	job := pipeline.NewJob(videoID, map[string]string{
+		ArtifactSourceKey: filename,
+		ArtifactProfile:   prof.Name,
+	})
+	job.Dir = dir
+	job.Tenant = video.TenantID
+	job.Version = version.Version
+	job.Restore = restore
 
-	aiResult, err := simulateAIInference(localOutputFile)
+	err = p.DB.UpdateVideo(ctx, videoID, map[string]interface{}{
+		"status": db.StatusProcessing,
+	})
 	if err != nil {
-		return fmt.Errorf("AI inference failed: %w", err)
+		return fmt.Errorf("failed to update video status: %w", err)
+	}
+	if err := p.Pipeline.Run(ctx, job); err != nil {
+		return err
 	}
-	log.Printf("AI Inference result: %s", aiResult)
+	return p.finalize(ctx, job, version)
+}
 
-	// TODO: this does a PUT video, might want to keep the original UploadDate
-	updatedRecord := db.Video{
-		VideoID:     videoID,
-		Title:       filename,
-		Description: fmt.Sprintf("Transcoded and processed: %s", aiResult),
-		URL:         fmt.Sprintf("https://%s.s3.amazonaws.com/%s", p.S3Bucket, filename),
-		Tags:        []string{"transcoded", "ai-processed"},
-		UploadDate:  time.Now(), 
+// finalize runs once every stage has succeeded. Stages save their own
+// results, so only the rendition keys, the profile and the current version
+// are written here to keep the upload's title, tags, date and checkpoints
+// intact. The version is recorded as ready first, so the video
+// never points at an unfinished one.
+func (p *Processor) finalize(ctx context.Context, job *pipeline.Job, version *db.VideoVersion) error {
+	renditions := map[string]string{}
+	for name, artifact := range renditionArtifacts {
+		if key, err := job.Artifact(artifact); err == nil && key != "" {
+			renditions[name] = key
+		}
+	}
+
+	profileName, _ := job.Artifact(ArtifactProfile)
+
+	video, err := p.DB.GetVideoById(ctx, job.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load video to record its version: %w", err)
+	}
+	if err := p.completeVersion(ctx, job, video, version, renditions); err != nil {
+		return err
 	}
-	if err := p.DB.PutVideo(ctx, updatedRecord); err != nil {
+
+	err = p.DB.UpdateVideo(ctx, job.ID, map[string]interface{}{
+		"renditions": renditions,
+		"profile":    profileName,
+		"version":    version.Version,
+		"status":     db.StatusReady,
+	})
+	if err != nil {
 		return fmt.Errorf("failed to update video metadata: %w", err)
 	}
-	log.Printf("Updated video metadata in DynamoDB for videoID: %s", videoID)
+	log.Printf("Updated video metadata in DynamoDB for videoID %s, now at version %d", job.ID, version.Version)
+
+	video, err = p.DB.GetVideoById(ctx, job.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load video for indexing: %w", err)
+	}
+	if err := p.indexSearch(ctx, video); err != nil {
+		return err
+	}
+	p.applyRetention(ctx, video)
+	p.indexContentHash(ctx, video)
+	// Promotions restore earlier results rather than processing anything.
+	if !job.Restore {
+		p.recordUsage(ctx, job, video)
+	}
+	p.notify(ctx, webhook.EventVideoReady, video)
+	return nil
+}
+
+// notify publishes a lifecycle event to the video's tenant's webhooks and
+// the event sink. The video's state is already saved, so a failure is
+// logged rather than failing the job.
+func (p *Processor) notify(ctx context.Context, eventType string, video *db.Video) {
+	if p.Webhooks != nil {
+		if err := p.Webhooks.Publish(ctx, webhook.NewVideoEvent(eventType, video)); err != nil {
+			log.Printf("Failed to publish %s event for videoID %s: %v", eventType, video.VideoID, err)
+		}
+	}
+	if p.Events != nil {
+		if err := p.Events.Publish(ctx, eventType, video); err != nil {
+			log.Printf("Failed to publish %s event for videoID %s: %v", eventType, video.VideoID, err)
+		}
+	}
+}
+
+// recordUsage counts the video's duration towards its tenant's monthly
+// processing usage, once for every version processed. A failure
+// under-counts rather than failing a finished job.
+func (p *Processor) recordUsage(ctx context.Context, job *pipeline.Job, video *db.Video) {
+	if video.TenantID == "" {
+		return
+	}
+	probe, err := p.readProbe(ctx, job)
+	if err != nil {
+		log.Printf("Failed to read probe to record usage for videoID %s: %v", video.VideoID, err)
+		return
+	}
+	if err := p.DB.RecordProcessing(ctx, video, job.Version, probe.Duration, time.Now()); err != nil {
+		log.Printf("Failed to record processing usage for videoID %s: %v", video.VideoID, err)
+	}
+}
+
+// indexContentHash makes a finished video available to upload dedupe. A
+// failure only means a later duplicate gets processed again, so it is logged
+// rather than failing the job.
+func (p *Processor) indexContentHash(ctx context.Context, video *db.Video) {
+	if video.ContentHash == "" {
+		return
+	}
+	if err := p.DB.PutContentHash(ctx, video.TenantID, video.ContentHash, video.VideoID); err != nil {
+		log.Printf("Failed to index content hash for videoID %s: %v", video.VideoID, err)
+	}
+}
+
+func (p *Processor) markFailed(ctx context.Context, videoID string, cause error) error {
+	err := p.DB.UpdateVideo(ctx, videoID, map[string]interface{}{
+		"status":        db.StatusFailed,
+		"status_reason": cause.Error(),
+	})
+	if errors.Is(err, db.ErrVideoNotFound) {
+		// The record is gone, so there is nothing to mark.
+		log.Printf("Dropping message for missing videoID %s: %v", videoID, cause)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to mark video %s as failed: %w", videoID, err)
+	}
+	log.Printf("Marked videoID %s as failed: %v", videoID, cause)
+
+	video, err := p.DB.GetVideoById(ctx, videoID)
+	if err != nil {
+		log.Printf("Failed to load videoID %s to publish its failure: %v", videoID, err)
+		return nil
+	}
+	p.failVersion(ctx, video, cause)
+	p.notify(ctx, webhook.EventVideoFailed, video)
+	return nil
+}
+
+// localCopy downloads an S3 object into the job's scratch directory. Stages
+// running in parallel share a single download of the same key.
+func (p *Processor) localCopy(ctx context.Context, job *pipeline.Job, s3Key string) (string, error) {
+	localPath := filepath.Join(job.Dir, strings.ReplaceAll(s3Key, "/", "_"))
+
+	lock, _ := p.fetchLocks.LoadOrStore(localPath, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	if _, err := os.Stat(localPath); err == nil {
+		return localPath, nil
+	}
+
+	partial := localPath + ".part"
+	if err := downloadFromS3(ctx, p.S3Client, p.S3Bucket, s3Key, partial); err != nil {
+		os.Remove(partial)
+		return "", fmt.Errorf("failed to download file from S3: %w", err)
+	}
+	if err := os.Rename(partial, localPath); err != nil {
+		return "", fmt.Errorf("failed to move downloaded file: %w", err)
+	}
+	log.Printf("Downloaded %s from S3 to %s", s3Key, localPath)
 
-	os.Remove(localInputFile)
-	os.Remove(localOutputFile)
+	return localPath, nil
+}
+
+// readJSONArtifact decodes a JSON artifact written by an earlier stage.
+func (p *Processor) readJSONArtifact(ctx context.Context, job *pipeline.Job, name string, v interface{}) error {
+	key, err := job.Artifact(name)
+	if err != nil {
+		return err
+	}
+	local, err := p.localCopy(ctx, job, key)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(local)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", key, err)
+	}
 	return nil
 }
 
-func simulateAIInference(videoPath string) (string, error) {
-	time.Sleep(2 * time.Second)
-	return "This video appears to contain outdoor sports action.", nil
+func (p *Processor) cleanupJob(dir string) {
+	os.RemoveAll(dir)
+	p.fetchLocks.Range(func(key, _ any) bool {
+		if strings.HasPrefix(key.(string), dir) {
+			p.fetchLocks.Delete(key)
+		}
+		return true
+	})
 }
 
 func downloadFromS3(ctx context.Context, s3Client *s3.Client, bucket, s3Key, localPath string) error {
@@ -170,8 +485,12 @@ func downloadFromS3(ctx context.Context, s3Client *s3.Client, bucket, s3Key, loc
 	return nil
 }
 
-func transcodeVideo(inputFile, outputFile string) error {
-	cmd := exec.Command("ffmpeg", "-y", "-i", inputFile, "-vcodec", "libx264", "-acodec", "aac", outputFile)
+func transcodeVideo(inputFile, outputFile, audioFilter string, prof profile.Profile) error {
+	args := append([]string{"-y", "-i", inputFile}, prof.Args()...)
+	if audioFilter != "" {
+		args = append(args, "-af", audioFilter)
+	}
+	cmd := exec.Command("ffmpeg", append(args, outputFile)...)
 
 	output, err := cmd.CombinedOutput()
 
@@ -183,3 +502,81 @@ func transcodeVideo(inputFile, outputFile string) error {
 
 	return nil
 }
+
+func uploadFileToS3(ctx context.Context, s3Client *s3.Client, bucket, s3Key, localPath, contentType string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open local file: %w", err)
+	}
+	defer file.Close()
+
+	_, err = s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(s3Key),
+		Body:        file,
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload %s to S3: %w", s3Key, err)
+	}
+
+	return nil
+}
+
+func uploadBytesToS3(ctx context.Context, s3Client *s3.Client, bucket, s3Key string, body []byte, contentType string) error {
+	_, err := s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(s3Key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload %s to S3: %w", s3Key, err)
+	}
+
+	return nil
+}
+
+func uploadJSONToS3(ctx context.Context, s3Client *s3.Client, bucket, s3Key string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", s3Key, err)
+	}
+	return uploadBytesToS3(ctx, s3Client, bucket, s3Key, body, "application/json")
+}
+
+// checkObject verifies that a previously written artifact still exists and
+// is not empty.
+func checkObject(ctx context.Context, s3Client *s3.Client, bucket, s3Key string) error {
+	if s3Key == "" {
+		return fmt.Errorf("artifact key is empty")
+	}
+
+	head, err := s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(s3Key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", s3Key, err)
+	}
+	if aws.ToInt64(head.ContentLength) == 0 {
+		return fmt.Errorf("artifact %s is empty", s3Key)
+	}
+
+	return nil
+}
+
+func objectExists(ctx context.Context, s3Client *s3.Client, bucket, s3Key string) (bool, error) {
+	_, err := s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(s3Key),
+	})
+	if err != nil {
+		var notFound *s3types.NotFound
+		if errors.As(err, &notFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to stat %s: %w", s3Key, err)
+	}
+	return true, nil
+}
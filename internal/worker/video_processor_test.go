@@ -3,12 +3,27 @@ package worker
 
 import (
 	"testing"
+
+	"github.com/ryanschneiderman/video-api/internal/db"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestHandleMessage(t *testing.T) {
-	
+
 }
 
 func TestProcessMessages(t *testing.T) {
-	
+
+}
+
+func TestCheckSourceKey(t *testing.T) {
+	stored := &db.Video{VideoID: "v1", TenantID: "acme", URL: "https://bucket.s3.amazonaws.com/tenants/acme/v1-clip.mp4"}
+	assert.NoError(t, checkSourceKey(stored, "tenants/acme/v1-clip.mp4"))
+	assert.Error(t, checkSourceKey(stored, "tenants/acme/v2-clip.mp4"))
+
+	// An import names the key its source is fetched into.
+	importing := &db.Video{VideoID: "v1", TenantID: "acme"}
+	assert.NoError(t, checkSourceKey(importing, "tenants/acme/v1-clip.mp4"))
+	assert.Error(t, checkSourceKey(importing, "tenants/other/v1-clip.mp4"))
+	assert.Error(t, checkSourceKey(importing, "tenants/acme/v2-clip.mp4"))
 }